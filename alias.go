@@ -0,0 +1,245 @@
+package pdtp
+
+import (
+	"io"
+
+	"github.com/pdtp-workbench/pdtp-go/parse"
+)
+
+// PDF の解析は net/http に依存しない parse パッケージにある
+// 既存の利用者のため、parse パッケージの型・定数・関数をこのパッケージの名前でも参照できるようにする
+
+type (
+	Attachment            = parse.Attachment
+	ByteToken             = parse.ByteToken
+	CacheStats            = parse.CacheStats
+	Catalog               = parse.Catalog
+	ChunkOrder            = parse.ChunkOrder
+	ClipPath              = parse.ClipPath
+	ColorState            = parse.ColorState
+	CommandType           = parse.CommandType
+	ContentType           = parse.ContentType
+	ContentTypes          = parse.ContentTypes
+	DeliveredFonts        = parse.DeliveredFonts
+	Document              = parse.Document
+	DocumentCache         = parse.DocumentCache
+	DrawCommand           = parse.DrawCommand
+	ExtGState             = parse.ExtGState
+	ExtractedImage        = parse.ExtractedImage
+	Font                  = parse.Font
+	FontMetrics           = parse.FontMetrics
+	FontFixPolicy         = parse.FontFixPolicy
+	FontStyle             = parse.FontStyle
+	FormXObject           = parse.FormXObject
+	FsTypePolicy          = parse.FsTypePolicy
+	GraphicsState         = parse.GraphicsState
+	HyphenationJoiner     = parse.HyphenationJoiner
+	IDrawCommand          = parse.IDrawCommand
+	IPDFFile              = parse.IPDFFile
+	IPDFParser            = parse.IPDFParser
+	ITokenObject          = parse.ITokenObject
+	ImageCodec            = parse.ImageCodec
+	ImageCodecParams      = parse.ImageCodecParams
+	ImageCommand          = parse.ImageCommand
+	ImageMetadata         = parse.ImageMetadata
+	ImageRefCommand       = parse.ImageRefCommand
+	ImageCrop             = parse.ImageCrop
+	ImageTile             = parse.ImageTile
+	ImageTranscoder       = parse.ImageTranscoder
+	LigatureExpander      = parse.LigatureExpander
+	LimitError            = parse.LimitError
+	MarkedContent         = parse.MarkedContent
+	Matrix                = parse.Matrix
+	OffPagePolicy         = parse.OffPagePolicy
+	OffsetTable           = parse.OffsetTable
+	OperatorBudget        = parse.OperatorBudget
+	PDFFile               = parse.PDFFile
+	PDFObject             = parse.PDFObject
+	PDFParser             = parse.PDFParser
+	PDFRef                = parse.PDFRef
+	Page                  = parse.Page
+	PageMetadata          = parse.PageMetadata
+	PageRange             = parse.PageRange
+	PageRanges            = parse.PageRanges
+	PageRasterizer        = parse.PageRasterizer
+	PageTree              = parse.PageTree
+	PaintBatch            = parse.PaintBatch
+	ParsedAnnotation      = parse.ParsedAnnotation
+	ParsedAttachment      = parse.ParsedAttachment
+	ParsedBatch           = parse.ParsedBatch
+	ParsedData            = parse.ParsedData
+	ParsedDataType        = parse.ParsedDataType
+	ParsedDone            = parse.ParsedDone
+	ParsedError           = parse.ParsedError
+	ParsedFont            = parse.ParsedFont
+	ParsedICCProfile      = parse.ParsedICCProfile
+	ParsedImage           = parse.ParsedImage
+	ParsedImagePlacement  = parse.ParsedImagePlacement
+	ParsedLink            = parse.ParsedLink
+	ParsedMetadata        = parse.ParsedMetadata
+	ParsedPage            = parse.ParsedPage
+	ParsedPageDone        = parse.ParsedPageDone
+	ParsedPageSummary     = parse.ParsedPageSummary
+	ParsedPath            = parse.ParsedPath
+	ParsedProgress        = parse.ParsedProgress
+	ParsedSearchResult    = parse.ParsedSearchResult
+	ParsedSharedContent   = parse.ParsedSharedContent
+	ParsedText            = parse.ParsedText
+	ParsedThumbnail       = parse.ParsedThumbnail
+	ParsedWarning         = parse.ParsedWarning
+	ParserConfig          = parse.ParserConfig
+	PathCommand           = parse.PathCommand
+	PathState             = parse.PathState
+	ReadSeekCloser        = parse.ReadSeekCloser
+	Rect                  = parse.Rect
+	ResourceStack         = parse.ResourceStack
+	Resources             = parse.Resources
+	SeekerCloser          = parse.SeekerCloser
+	Signature             = parse.Signature
+	SimpleRasterizer      = parse.SimpleRasterizer
+	SoftMask              = parse.SoftMask
+	StreamLengthPolicy    = parse.StreamLengthPolicy
+	StreamLimits          = parse.StreamLimits
+	StreamObject          = parse.StreamObject
+	StructRole            = parse.StructRole
+	StructText            = parse.StructText
+	TableRecord           = parse.TableRecord
+	TextCommand           = parse.TextCommand
+	TextNormalization     = parse.TextNormalization
+	TextOptions           = parse.TextOptions
+	TextPostProcessor     = parse.TextPostProcessor
+	TextPostProcessorFunc = parse.TextPostProcessorFunc
+	TextRun               = parse.TextRun
+	TextState             = parse.TextState
+	TextToken             = parse.TextToken
+	TextWord              = parse.TextWord
+	ThumbnailPage         = parse.ThumbnailPage
+	Token                 = parse.Token
+	TokenObject           = parse.TokenObject
+	TokenType             = parse.TokenType
+	XRefTableElement      = parse.XRefTableElement
+)
+
+const (
+	ChunkOrderFontsFirst    = parse.ChunkOrderFontsFirst
+	ChunkOrderImagesLast    = parse.ChunkOrderImagesLast
+	ChunkOrderInterleave    = parse.ChunkOrderInterleave
+	CommandTypeImage        = parse.CommandTypeImage
+	CommandTypeText         = parse.CommandTypeText
+	ContentAnnotations      = parse.ContentAnnotations
+	ContentFonts            = parse.ContentFonts
+	ContentImages           = parse.ContentImages
+	ContentPaths            = parse.ContentPaths
+	ContentText             = parse.ContentText
+	DefaultTranscodeQuality = parse.DefaultTranscodeQuality
+	FontFixAlways           = parse.FontFixAlways
+	FontFixAuto             = parse.FontFixAuto
+	FontFixNever            = parse.FontFixNever
+	FsTypeHonor             = parse.FsTypeHonor
+	FsTypeStrip             = parse.FsTypeStrip
+	FsTypeWarn              = parse.FsTypeWarn
+	LimitImageBytes         = parse.LimitImageBytes
+	LimitPages              = parse.LimitPages
+	LimitTotalBytes         = parse.LimitTotalBytes
+	OffPageDrop             = parse.OffPageDrop
+	OffPageFlag             = parse.OffPageFlag
+	OffPageKeep             = parse.OffPageKeep
+	PaintBatchBackground    = parse.PaintBatchBackground
+	PaintBatchForeground    = parse.PaintBatchForeground
+	PaintBatchImage         = parse.PaintBatchImage
+	PaintBatchText          = parse.PaintBatchText
+	StreamLengthAuto        = parse.StreamLengthAuto
+	StreamLengthStrict      = parse.StreamLengthStrict
+	StreamLengthTolerant    = parse.StreamLengthTolerant
+	TextNormalizationNFC    = parse.TextNormalizationNFC
+	TextNormalizationNone   = parse.TextNormalizationNone
+	TokenTypeOperand        = parse.TokenTypeOperand
+	TokenTypeOperator       = parse.TokenTypeOperator
+)
+
+var (
+	ErrAttachmentNotFound            = parse.ErrAttachmentNotFound
+	ErrFontInvalid                   = parse.ErrFontInvalid
+	ErrInvalidPDF                    = parse.ErrInvalidPDF
+	ErrLimitExceeded                 = parse.ErrLimitExceeded
+	ErrNoPagesSelected               = parse.ErrNoPagesSelected
+	ErrOperatorBudgetExceeded        = parse.ErrOperatorBudgetExceeded
+	ErrPageTimeout                   = parse.ErrPageTimeout
+	ErrParserDeCompressionError      = parse.ErrParserDeCompressionError
+	ErrParserParseObjectError        = parse.ErrParserParseObjectError
+	ErrParserReadStreamError         = parse.ErrParserReadStreamError
+	ErrParserStreamLengthError       = parse.ErrParserStreamLengthError
+	ErrSignatureAlgorithmUnsupported = parse.ErrSignatureAlgorithmUnsupported
+	ErrSignatureInvalid              = parse.ErrSignatureInvalid
+	ErrSignatureUnsupported          = parse.ErrSignatureUnsupported
+	ErrSignatureUntrusted            = parse.ErrSignatureUntrusted
+	ErrStreamEncrypted               = parse.ErrStreamEncrypted
+)
+
+func NewPDFParser(open func() (IPDFFile, error)) (*PDFParser, error) {
+	return parse.NewPDFParser(open)
+}
+
+func NewPDFParserWithConfig(open func() (IPDFFile, error), config ParserConfig) (*PDFParser, error) {
+	return parse.NewPDFParserWithConfig(open, config)
+}
+
+func OpenDocument(open func() (IPDFFile, error), config ParserConfig) (*Document, error) {
+	return parse.OpenDocument(open, config)
+}
+
+func NewDeliveredFonts() *DeliveredFonts {
+	return parse.NewDeliveredFonts()
+}
+
+func NewDocumentCache(capacity int) *DocumentCache {
+	return parse.NewDocumentCache(capacity)
+}
+
+func NewPDFFile(rc io.ReadCloser) (IPDFFile, error) {
+	return parse.NewPDFFile(rc)
+}
+
+func NewTokenObject(contents string, resources *Resources, loadForm func(ref PDFRef) (*FormXObject, error)) *TokenObject {
+	return parse.NewTokenObject(contents, resources, loadForm)
+}
+
+func NewResourceStack(page *Resources) *ResourceStack {
+	return parse.NewResourceStack(page)
+}
+
+func NewGraphicsState() *GraphicsState {
+	return parse.NewGraphicsState()
+}
+
+func NewTextState() *TextState {
+	return parse.NewTextState()
+}
+
+func NewPathState() *PathState {
+	return parse.NewPathState()
+}
+
+func NewColorState() *ColorState {
+	return parse.NewColorState()
+}
+
+func IdentityMatrix() Matrix {
+	return parse.IdentityMatrix()
+}
+
+func ParseContentTypes(s string) (ContentTypes, error) {
+	return parse.ParseContentTypes(s)
+}
+
+func ParseFloat(str string) float64 {
+	return parse.ParseFloat(str)
+}
+
+func ParsePageRanges(s string) (PageRanges, error) {
+	return parse.ParsePageRanges(s)
+}
+
+func RegisterImageCodec(codec ImageCodec) {
+	parse.RegisterImageCodec(codec)
+}
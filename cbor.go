@@ -0,0 +1,284 @@
+package pdtp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// ChunkEncoding は チャンクのメタデータ (フレームの JSON の部分) の形式を示す
+type ChunkEncoding string
+
+const (
+	// ChunkEncodingJSON は メタデータを JSON で送る (既定)
+	ChunkEncodingJSON ChunkEncoding = "json"
+	// ChunkEncodingCBOR は メタデータを CBOR (RFC 8949) で送る
+	// フィールド名・数値が短くなり、テキストや画像の多い文書で JSON の部分のバイト数と解析時間を減らせる
+	ChunkEncodingCBOR ChunkEncoding = "cbor"
+)
+
+// CBOR の major type
+const (
+	cborUnsigned = 0
+	cborNegative = 1
+	cborText     = 3
+	cborArray    = 4
+	cborMap      = 5
+	cborSimple   = 7
+)
+
+// cborWriter は バージョン 1 のフレームの JSON の部分を CBOR に変換して書き込む
+// フレームの長さは CBOR の長さに置き換え、JSON に続くバイナリはそのまま書き込む
+type cborWriter struct {
+	bufferedFrameWriter
+}
+
+func newCBORWriter(w FlusherWriter) *cborWriter {
+	c := &cborWriter{bufferedFrameWriter{w: w, name: "cbor"}}
+	c.writeFrame = c.writeCBOR
+	return c
+}
+
+func (c *cborWriter) writeCBOR(dataType byte, meta, payload []byte) error {
+	cbor, err := jsonToCBOR(meta)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, 0, 5)
+	header = append(header, dataType)
+	header = binary.BigEndian.AppendUint32(header, uint32(len(cbor)))
+	return c.writeParts(header, cbor, payload)
+}
+
+// jsonToCBOR は JSON を同じ構造の CBOR に変換する
+// オブジェクトのキーの順番は JSON のまま保ち、整数は整数、それ以外の数値は浮動小数点数にする
+func jsonToCBOR(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var out []byte
+	out, err := appendCBORValue(out, dec)
+	if err != nil {
+		return nil, fmt.Errorf("cbor: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("cbor: trailing data after json value")
+	}
+	return out, nil
+}
+
+func appendCBORValue(out []byte, dec *json.Decoder) ([]byte, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := token.(type) {
+	case json.Delim:
+		// 要素の数を先頭に書くため、要素を別に書き出してから数と合わせる
+		var body []byte
+		count := 0
+		for dec.More() {
+			if t == '{' {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				body = appendCBORString(body, key.(string))
+			}
+			if body, err = appendCBORValue(body, dec); err != nil {
+				return nil, err
+			}
+			count++
+		}
+		// 閉じ括弧を読み捨てる
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		major := byte(cborArray)
+		if t == '{' {
+			major = cborMap
+		}
+		out = appendCBORHead(out, major, uint64(count))
+		return append(out, body...), nil
+	case string:
+		return appendCBORString(out, t), nil
+	case json.Number:
+		if i, err := strconv.ParseInt(string(t), 10, 64); err == nil {
+			if i < 0 {
+				return appendCBORHead(out, cborNegative, uint64(-1-i)), nil
+			}
+			return appendCBORHead(out, cborUnsigned, uint64(i)), nil
+		}
+		f, err := t.Float64()
+		if err != nil {
+			return nil, err
+		}
+		if float64(float32(f)) == f {
+			out = append(out, cborSimple<<5|26)
+			return binary.BigEndian.AppendUint32(out, math.Float32bits(float32(f))), nil
+		}
+		out = append(out, cborSimple<<5|27)
+		return binary.BigEndian.AppendUint64(out, math.Float64bits(f)), nil
+	case bool:
+		if t {
+			return append(out, cborSimple<<5|21), nil
+		}
+		return append(out, cborSimple<<5|20), nil
+	case nil:
+		return append(out, cborSimple<<5|22), nil
+	}
+	return nil, fmt.Errorf("unexpected json token %v", token)
+}
+
+func appendCBORString(out []byte, s string) []byte {
+	out = appendCBORHead(out, cborText, uint64(len(s)))
+	return append(out, s...)
+}
+
+// appendCBORHead は major type と値 (長さ・整数) を最短の形で書き込む
+func appendCBORHead(out []byte, major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return append(out, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(out, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(out, major|25), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(out, major|26), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(out, major|27), n)
+	}
+}
+
+// cborToJSON は jsonToCBOR が書き出す範囲の CBOR を JSON に戻す
+// 長さが不定の配列・マップ、バイト列、タグには対応しない
+func cborToJSON(data []byte) ([]byte, error) {
+	out, rest, err := appendJSONValue(nil, data, 0)
+	if err != nil {
+		return nil, fmt.Errorf("cbor: %w", err)
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("cbor: %d bytes of trailing data", len(rest))
+	}
+	return out, nil
+}
+
+// cborMaxDepth は 入れ子の深さの上限 (壊れたデータで再帰が深くなりすぎないようにする)
+const cborMaxDepth = 64
+
+func appendJSONValue(out, data []byte, depth int) ([]byte, []byte, error) {
+	if depth > cborMaxDepth {
+		return nil, nil, fmt.Errorf("nesting deeper than %d", cborMaxDepth)
+	}
+	if len(data) == 0 {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	major, info := data[0]>>5, data[0]&0x1f
+	if major == cborSimple {
+		switch info {
+		case 20:
+			return append(out, "false"...), data[1:], nil
+		case 21:
+			return append(out, "true"...), data[1:], nil
+		case 22:
+			return append(out, "null"...), data[1:], nil
+		case 26:
+			if len(data) < 5 {
+				return nil, nil, io.ErrUnexpectedEOF
+			}
+			f := math.Float32frombits(binary.BigEndian.Uint32(data[1:5]))
+			return strconv.AppendFloat(out, float64(f), 'g', -1, 64), data[5:], nil
+		case 27:
+			if len(data) < 9 {
+				return nil, nil, io.ErrUnexpectedEOF
+			}
+			f := math.Float64frombits(binary.BigEndian.Uint64(data[1:9]))
+			if math.IsInf(f, 0) || math.IsNaN(f) {
+				return nil, nil, fmt.Errorf("float %v is not valid json", f)
+			}
+			return strconv.AppendFloat(out, f, 'g', -1, 64), data[9:], nil
+		}
+		return nil, nil, fmt.Errorf("unsupported simple value %d", info)
+	}
+	n, data, err := readCBORHead(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch major {
+	case cborUnsigned:
+		return strconv.AppendUint(out, n, 10), data, nil
+	case cborNegative:
+		if n > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("negative integer out of range")
+		}
+		return strconv.AppendInt(out, -1-int64(n), 10), data, nil
+	case cborText:
+		if uint64(len(data)) < n {
+			return nil, nil, io.ErrUnexpectedEOF
+		}
+		quoted, err := json.Marshal(string(data[:n]))
+		if err != nil {
+			return nil, nil, err
+		}
+		return append(out, quoted...), data[n:], nil
+	case cborArray, cborMap:
+		open, close := byte('['), byte(']')
+		if major == cborMap {
+			open, close = '{', '}'
+		}
+		out = append(out, open)
+		for i := uint64(0); i < n; i++ {
+			if i > 0 {
+				out = append(out, ',')
+			}
+			if major == cborMap {
+				if len(data) == 0 || data[0]>>5 != cborText {
+					return nil, nil, fmt.Errorf("map key is not a text string")
+				}
+				if out, data, err = appendJSONValue(out, data, depth+1); err != nil {
+					return nil, nil, err
+				}
+				out = append(out, ':')
+			}
+			if out, data, err = appendJSONValue(out, data, depth+1); err != nil {
+				return nil, nil, err
+			}
+		}
+		return append(out, close), data, nil
+	}
+	return nil, nil, fmt.Errorf("unsupported major type %d", major)
+}
+
+// readCBORHead は 先頭の値 (長さ・整数) を読み、残りのデータを返す
+func readCBORHead(data []byte) (uint64, []byte, error) {
+	info := data[0] & 0x1f
+	data = data[1:]
+	var size int
+	switch {
+	case info < 24:
+		return uint64(info), data, nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		return 0, nil, fmt.Errorf("unsupported additional information %d", info)
+	}
+	if len(data) < size {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	var n uint64
+	for _, b := range data[:size] {
+		n = n<<8 | uint64(b)
+	}
+	return n, data[size:], nil
+}
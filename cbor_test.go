@@ -0,0 +1,68 @@
+package pdtp_test
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/pdtp-workbench/pdtp-go"
+	"github.com/pdtp-workbench/pdtp-go/pdtpclient"
+)
+
+// TestCBOREncoding は Pdtp ヘッダーの encoding=cbor でメタデータを CBOR で送り、
+// pdtpclient で読み込んだイベントが JSON で送った場合と同じになることを確かめる
+func TestCBOREncoding(t *testing.T) {
+	server := httptest.NewServer(pdtp.NewPDFProtocolHandler(pdtp.Config{
+		OpenPDF: pdtp.OpenUnder("cmd/pdtp/testdata/conform"),
+	}))
+	defer server.Close()
+	for _, test := range []struct {
+		name  string
+		file  string
+		field string
+	}{
+		{"content", "image.pdf", ""},
+		{"text", "text.pdf", ""},
+		{"metadata", "multipage.pdf", "mode=meta"},
+		{"version 2", "multipage.pdf", "version=2"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			url := server.URL + "?file=" + test.file
+			want := openEvents(t, &pdtpclient.Client{}, url, test.field)
+			got := openEvents(t, &pdtpclient.Client{Encoding: pdtp.ChunkEncodingCBOR}, url, test.field)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("CBOR events differ from JSON events")
+			}
+		})
+	}
+
+	// 送られたメタデータが JSON ではなく CBOR の map であることを確かめる
+	req, err := http.NewRequest(http.MethodGet, server.URL+"?file=multipage.pdf", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Pdtp", "mode=meta;encoding=cbor")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Pdtp-Encoding"); got != "cbor" {
+		t.Errorf("Pdtp-Encoding = %q, want cbor", got)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(body) < 6 || body[0] != pdtp.DataTypeMetadata {
+		t.Fatalf("stream does not start with a metadata chunk: % x", body[:min(len(body), 16)])
+	}
+	meta := body[5 : 5+binary.BigEndian.Uint32(body[1:5])]
+	if json.Valid(meta) || meta[0]>>5 != 5 {
+		t.Errorf("metadata starts with %#02x, want a CBOR map", meta[0])
+	}
+}
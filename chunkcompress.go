@@ -0,0 +1,118 @@
+package pdtp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ChunkFlagCompressed は チャンクの内容を zstd で圧縮したことを示す、種別の最上位ビット
+// ErrorChunk (0xFF) は圧縮しない
+// 圧縮したフレームでは、バージョン 1 のフレームの JSON の長さ以降 (JSON の長さ (4) | JSON | バイナリ) を圧縮したデータを JSON の部分として送る
+//
+//	種別 | 0x80 (1) | 圧縮したデータの長さ (4) | zstd(JSON の長さ (4) | JSON | バイナリ)
+const ChunkFlagCompressed byte = 0x80
+
+// chunkCompressMinSize は 圧縮するフレームの内容の最小のバイト数 (小さなチャンクは zstd のヘッダーの分だけ大きくなる)
+const chunkCompressMinSize = 128
+
+// ChunkCompression は Content-Encoding でストリーム全体を圧縮する代わりに、チャンクごとに zstd で圧縮する CompressionMethod
+// JPEG や Deflate で圧縮済みの画像を圧縮し直しても小さくならないため、StoredTypes の種別は圧縮せずに送り CPU を使わない
+// 圧縮したチャンクは種別に ChunkFlagCompressed を立てて送り、圧縮しても小さくならないチャンクはそのまま送る
+type ChunkCompression struct {
+	// StoredTypes は 圧縮せずに送るチャンクの種別 (nil の場合は DataTypeImage・DataTypeThumbnail と、大きな画像を分けた DataTypeContinuation)
+	StoredTypes []byte
+}
+
+func (c ChunkCompression) Name() string {
+	return "zstd-chunk"
+}
+
+// Writer は Content-Encoding を付けずに書き込む。チャンクの圧縮は NewPDFProtocolHandler がフレームごとに行う
+func (c ChunkCompression) Writer(w http.ResponseWriter) (FlusherWriter, error) {
+	w.Header().Set("Pdtp-Chunk-Compression", "zstd")
+	hf, ok := w.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("response writer cannot flush")
+	}
+	return &responseFlusherWriter{w: w, hf: hf}, nil
+}
+
+func (c ChunkCompression) stored(dataType byte) bool {
+	if c.StoredTypes == nil {
+		return dataType == DataTypeImage || dataType == DataTypeThumbnail || dataType == DataTypeContinuation
+	}
+	return slices.Contains(c.StoredTypes, dataType)
+}
+
+// chunkCompressWriter は バージョン 1 のフレームの内容を zstd で圧縮し、種別に ChunkFlagCompressed を立てて書き込む
+type chunkCompressWriter struct {
+	bufferedFrameWriter
+	method  ChunkCompression
+	encoder *zstd.Encoder
+	inner   []byte
+}
+
+func newChunkCompressWriter(w FlusherWriter, method ChunkCompression) (*chunkCompressWriter, error) {
+	encoder, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	c := &chunkCompressWriter{bufferedFrameWriter: bufferedFrameWriter{w: w, name: "chunk compression"}, method: method, encoder: encoder}
+	c.writeFrame = c.compress
+	return c, nil
+}
+
+func (c *chunkCompressWriter) compress(dataType byte, meta, payload []byte) error {
+	length := binary.BigEndian.AppendUint32(make([]byte, 0, 4), uint32(len(meta)))
+	if dataType == DataTypeError || c.method.stored(dataType) || 4+len(meta)+len(payload) < chunkCompressMinSize {
+		return c.writeParts([]byte{dataType}, length, meta, payload)
+	}
+	c.inner = append(append(append(c.inner[:0], length...), meta...), payload...)
+	compressed := c.encoder.EncodeAll(c.inner, nil)
+	if len(compressed)+4 >= len(c.inner) {
+		// 小さくならない場合はそのまま送る
+		return c.writeParts([]byte{dataType}, c.inner)
+	}
+	header := make([]byte, 0, 5)
+	header = append(header, dataType|ChunkFlagCompressed)
+	header = binary.BigEndian.AppendUint32(header, uint32(len(compressed)))
+	return c.writeParts(header, compressed)
+}
+
+func (c *chunkCompressWriter) Close() error {
+	if err := c.Flush(); err != nil {
+		return err
+	}
+	c.encoder.Close()
+	return c.w.Close()
+}
+
+// maxDecompressedChunkSize は 圧縮したチャンクを展開した大きさの上限
+// 小さなフレームが際限なく大きなデータに展開されないよう、既定の ChunkLimits で受け付けるチャンクの大きさに合わせる
+const maxDecompressedChunkSize = 4 + DefaultMaxMetadataSize + DefaultMaxPayloadSize
+
+// chunkDecoder は 圧縮したチャンクを展開する (全ての ChunkReader で共有する)
+var chunkDecoder = sync.OnceValues(func() (*zstd.Decoder, error) {
+	return zstd.NewReader(nil, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxMemory(maxDecompressedChunkSize))
+})
+
+// decompressChunk は 圧縮したチャンクの内容を展開し、JSON の長さ (4) | JSON | バイナリ を返す
+func decompressChunk(body []byte) ([]byte, error) {
+	decoder, err := chunkDecoder()
+	if err != nil {
+		return nil, err
+	}
+	inner, err := decoder.DecodeAll(body, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(inner) < 4 || uint64(binary.BigEndian.Uint32(inner[:4])) > uint64(len(inner)-4) {
+		return nil, fmt.Errorf("invalid json length in compressed chunk")
+	}
+	return inner, nil
+}
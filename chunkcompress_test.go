@@ -0,0 +1,148 @@
+package pdtp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// TestChunkCompressWriter は 圧縮したチャンクが元の内容に展開でき、画像・小さなチャンク・小さくならないチャンクはそのまま送ることを確かめる
+func TestChunkCompressWriter(t *testing.T) {
+	random := make([]byte, 4096)
+	r := rand.New(rand.NewPCG(1, 2))
+	for i := range random {
+		random[i] = byte(r.UintN(256))
+	}
+	frame := func(dataType byte, meta string, payload []byte) []byte {
+		f := binary.BigEndian.AppendUint32([]byte{dataType}, uint32(len(meta)))
+		return append(append(f, meta...), payload...)
+	}
+	for _, test := range []struct {
+		name       string
+		frame      []byte
+		compressed bool
+	}{
+		{"font", frame(DataTypeFont, `{"id":"F1","length":4096}`, bytes.Repeat([]byte("glyf"), 1024)), true},
+		{"text", frame(DataTypeText, `{"text":"`+string(bytes.Repeat([]byte("abc "), 100))+`"}`, nil), true},
+		{"image", frame(DataTypeImage, `{"id":"Im1","length":4096}`, bytes.Repeat([]byte{0}, 4096)), false},
+		{"incompressible font", frame(DataTypeFont, `{"id":"F1","length":4096}`, random), false},
+		{"small", frame(DataTypeText, `{"text":"a"}`, nil), false},
+		{"error", frame(DataTypeError, `{"code":500,"message":"`+string(bytes.Repeat([]byte("x"), 200))+`"}`, nil), false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			out := &bufferWriter{}
+			writer, err := newChunkCompressWriter(out, ChunkCompression{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := writer.Write(test.frame); err != nil {
+				t.Fatal(err)
+			}
+			if err := writer.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			got := out.Bytes()
+			if !test.compressed {
+				if !bytes.Equal(got, test.frame) {
+					t.Errorf("frame of %d bytes was written as %d bytes, want it unchanged", len(test.frame), len(got))
+				}
+				return
+			}
+			if got[0] != test.frame[0]|ChunkFlagCompressed {
+				t.Fatalf("type %#02x, want %#02x", got[0], test.frame[0]|ChunkFlagCompressed)
+			}
+			if length := binary.BigEndian.Uint32(got[1:5]); int(length) != len(got)-5 || len(got) >= len(test.frame) {
+				t.Errorf("compressed length %d in a %d-byte frame, want a frame smaller than %d bytes", length, len(got), len(test.frame))
+			}
+			inner, err := decompressChunk(got[5:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(inner, test.frame[1:]) {
+				t.Error("decompressed chunk differs from the original frame")
+			}
+		})
+	}
+}
+
+// TestChunkCompression は ChunkCompression を設定したハンドラーが、画像以外のチャンクを圧縮し、
+// 読み込んだチャンクが圧縮せずに送った場合と同じになることを確かめる
+func TestChunkCompression(t *testing.T) {
+	stream := func(config Config) *httptest.ResponseRecorder {
+		config.OpenPDF = OpenUnder("cmd/pdtp/testdata/conform")
+		rec := httptest.NewRecorder()
+		NewPDFProtocolHandler(config)(rec, httptest.NewRequest(http.MethodGet, "/?file=image.pdf", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+		}
+		return rec
+	}
+	want := readAllChunks(t, stream(Config{}).Body.Bytes(), ProtocolVersion1, ChunkEncodingJSON)
+	rec := stream(Config{CompressionMethod: ChunkCompression{}})
+	if got := rec.Header().Get("Pdtp-Chunk-Compression"); got != "zstd" {
+		t.Errorf("Pdtp-Chunk-Compression = %q, want zstd", got)
+	}
+	if got := rec.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("Content-Encoding = %q, want none", got)
+	}
+
+	body := rec.Body.Bytes()
+	r := bytes.NewReader(body)
+	compressed, images := 0, 0
+	for {
+		frame, err := readChunk(r, ProtocolVersion1, ChunkEncodingJSON, ChunkLimits{})
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frame.Compressed {
+			compressed++
+		}
+		if frame.Type == DataTypeImage {
+			images++
+			if frame.Compressed {
+				t.Error("image chunk was compressed")
+			}
+		}
+	}
+	if compressed == 0 || images == 0 {
+		t.Errorf("%d compressed chunks and %d image chunks, want both", compressed, images)
+	}
+
+	got := readAllChunks(t, body, ProtocolVersion1, ChunkEncodingJSON)
+	if len(got) != len(want) {
+		t.Fatalf("%d chunks, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i].Type != want[i].Type || !bytes.Equal(got[i].JSON, want[i].JSON) || !bytes.Equal(got[i].Payload, want[i].Payload) {
+			t.Errorf("chunk %d (%s) differs from the uncompressed stream", i, got[i].TypeName())
+		}
+	}
+}
+
+// TestDecompressChunkLimit は 展開すると maxDecompressedChunkSize を超えるチャンクを、展開する前に ErrInvalidChunk にすることを確かめる
+func TestDecompressChunkLimit(t *testing.T) {
+	// 展開後の大きさを上限より 1 バイト大きく宣言し、0 を繰り返す RLE ブロックを1つ持つ zstd のフレーム
+	compressed := []byte{0x28, 0xB5, 0x2F, 0xFD, 0xE0}
+	compressed = binary.LittleEndian.AppendUint64(compressed, maxDecompressedChunkSize+1)
+	compressed = append(compressed, 0x03|0x01<<1, 0x00, 0x02, 0x00) // 最後の RLE ブロック (1<<14 バイト) | 繰り返すバイト
+	if _, err := decompressChunk(compressed); !errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+		t.Fatalf("err = %v, want %v", err, zstd.ErrDecoderSizeExceeded)
+	}
+
+	frame := []byte{DataTypeText | ChunkFlagCompressed}
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(compressed)))
+	frame = append(frame, compressed...)
+	if _, err := readChunk(bytes.NewReader(frame), ProtocolVersion1, ChunkEncodingJSON, ChunkLimits{}); !errors.Is(err, ErrInvalidChunk) {
+		t.Fatalf("err = %v, want %v", err, ErrInvalidChunk)
+	}
+}
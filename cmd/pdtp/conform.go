@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pdtp-workbench/pdtp-go"
+)
+
+// chunkFixture は クライアントが期待するチャンクの JSON の形
+// TypeScript クライアントのリポジトリから書き出した <type>.json を1種別につき1ファイル置く
+//
+//	{"type": "text", "payload": false, "fields": {"x": "number", "text": "string"}, "optional": ["actualText"]}
+type chunkFixture struct {
+	Type     string            `json:"type"`
+	Payload  bool              `json:"payload"`  // JSON の後にバイナリが続く
+	Fields   map[string]string `json:"fields"`   // フィールド名と JSON の型 (string / number / boolean / object / array / null。| で複数指定)
+	Optional []string          `json:"optional"` // 省略されることがあるフィールド
+}
+
+// conform は ハンドラーを起動して PDF のストリームを受け取り、各チャンクがフィクスチャと一致するかを検証する
+func conform(args []string) error {
+	flags := flag.NewFlagSet("conform", flag.ExitOnError)
+	dir := flags.String("fixtures", "", "directory of chunk fixtures exported from the client")
+	field := flags.String("pdtp", "", "value of the Pdtp request header (e.g. start=1;end=3)")
+	flags.Parse(args)
+	if *dir == "" || flags.NArg() == 0 {
+		usage()
+	}
+	fixtures, err := loadFixtures(*dir)
+	if err != nil {
+		return err
+	}
+
+	server := httptest.NewServer(conformHandler())
+	defer server.Close()
+
+	failed := 0
+	for _, fileName := range flags.Args() {
+		problems, err := conformFile(server.URL, fileName, *field, fixtures)
+		if err != nil {
+			return fmt.Errorf("%s: %w", fileName, err)
+		}
+		for _, problem := range problems {
+			fmt.Printf("%s: %s\n", fileName, problem)
+		}
+		if len(problems) > 0 {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files do not conform", failed, flags.NArg())
+	}
+	log.Printf("%d files conform", flags.NArg())
+	return nil
+}
+
+// conformHandler は ローカルの PDF をパスで開くハンドラーを返す
+func conformHandler() http.Handler {
+	return pdtp.NewPDFProtocolHandler(pdtp.Config{
+		OpenPDF: pdtp.OpenFileFunc(func(fileName string) (pdtp.IPDFFile, error) {
+			return os.Open(fileName)
+		}),
+		CompressionMethod: pdtp.GzipCompression{},
+	})
+}
+
+func loadFixtures(dir string) (map[string]*chunkFixture, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no fixtures in %s", dir)
+	}
+	fixtures := make(map[string]*chunkFixture)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var fixture chunkFixture
+		if err := json.Unmarshal(data, &fixture); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if fixture.Type == "" {
+			fixture.Type = strings.TrimSuffix(filepath.Base(path), ".json")
+		}
+		fixtures[fixture.Type] = &fixture
+	}
+	return fixtures, nil
+}
+
+// conformFile は 1つの PDF のストリームを検証し、見つかった食い違いを返す
+func conformFile(serverURL, fileName, field string, fixtures map[string]*chunkFixture) ([]string, error) {
+	abs, err := filepath.Abs(fileName)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, serverURL+"?file="+url.QueryEscape(abs), nil)
+	if err != nil {
+		return nil, err
+	}
+	if field != "" {
+		req.Header.Set("Pdtp", field)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var problems []string
+	chunks := pdtp.NewChunkReader(resp.Body, pdtp.ProtocolVersion1)
+	for i := 0; ; i++ {
+		chunk, err := chunks.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return append(problems, fmt.Sprintf("chunk %d: %v", i, err)), nil
+		}
+		for _, problem := range checkChunk(chunk, fixtures) {
+			problems = append(problems, fmt.Sprintf("chunk %d (%s): %s", i, chunk.TypeName(), problem))
+		}
+	}
+	return problems, nil
+}
+
+// checkChunk は チャンクの JSON のフィールドと型、バイナリの有無をフィクスチャと比べる
+func checkChunk(chunk *pdtp.Chunk, fixtures map[string]*chunkFixture) []string {
+	fixture, ok := fixtures[chunk.TypeName()]
+	if !ok {
+		return []string{"type is unknown to the client"}
+	}
+	var problems []string
+	if !fixture.Payload && len(chunk.Payload) > 0 {
+		problems = append(problems, "unexpected payload")
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(chunk.JSON, &fields); err != nil {
+		return append(problems, fmt.Sprintf("json is not an object: %v", err))
+	}
+	names := make([]string, 0, len(fixture.Fields))
+	for name := range fixture.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, found := fields[name]
+		if !found {
+			if !slices.Contains(fixture.Optional, name) {
+				problems = append(problems, fmt.Sprintf("missing field %q", name))
+			}
+			continue
+		}
+		want := fixture.Fields[name]
+		if got := jsonType(value); !slices.Contains(strings.Split(want, "|"), got) {
+			problems = append(problems, fmt.Sprintf("field %q is %s, want %s", name, got, want))
+		}
+	}
+	extra := make([]string, 0)
+	for name := range fields {
+		if _, ok := fixture.Fields[name]; !ok {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		problems = append(problems, fmt.Sprintf("field %q is unknown to the client", name))
+	}
+	return problems
+}
+
+func jsonType(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/pdtp-workbench/pdtp-go"
+)
+
+// conformDir は クライアントのフィクスチャ (chunks) と、検証する PDF を置くディレクトリ
+var conformDir = filepath.Join("testdata", "conform")
+
+// TestConform は testdata/conform の PDF のストリームが、クライアントのフィクスチャと一致するかを検証する
+// 存在しないファイルの要求で ErrorChunk の形も確かめる
+func TestConform(t *testing.T) {
+	fixtures, err := loadFixtures(filepath.Join(conformDir, "chunks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(conformHandler())
+	t.Cleanup(server.Close)
+
+	files, err := filepath.Glob(filepath.Join(conformDir, "*.pdf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no PDFs in %s", conformDir)
+	}
+	files = append(files, filepath.Join("..", "..", "example", "example.pdf"), filepath.Join(conformDir, "missing.pdf"))
+	for _, fileName := range files {
+		t.Run(filepath.Base(fileName), func(t *testing.T) {
+			problems, err := conformFile(server.URL, fileName, "", fixtures)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, problem := range problems {
+				t.Error(problem)
+			}
+		})
+	}
+}
+
+// TestCheckChunk は フィクスチャと食い違うチャンクをそれぞれ見つけられるかを確かめる
+func TestCheckChunk(t *testing.T) {
+	fixtures := map[string]*chunkFixture{
+		"pageDone": {
+			Type:     "pageDone",
+			Fields:   map[string]string{"page": "number", "cursor": "string"},
+			Optional: []string{"cursor"},
+		},
+	}
+	tests := []struct {
+		name  string
+		chunk *pdtp.Chunk
+		want  []string
+	}{
+		{
+			name:  "conforming",
+			chunk: &pdtp.Chunk{Type: pdtp.DataTypePageDone, JSON: []byte(`{"page":1}`)},
+		},
+		{
+			name:  "missing field",
+			chunk: &pdtp.Chunk{Type: pdtp.DataTypePageDone, JSON: []byte(`{"cursor":"a"}`)},
+			want:  []string{`missing field "page"`},
+		},
+		{
+			name:  "wrong type",
+			chunk: &pdtp.Chunk{Type: pdtp.DataTypePageDone, JSON: []byte(`{"page":"1"}`)},
+			want:  []string{`field "page" is string, want number`},
+		},
+		{
+			name:  "unknown field and payload",
+			chunk: &pdtp.Chunk{Type: pdtp.DataTypePageDone, JSON: []byte(`{"page":1,"extra":true}`), Payload: []byte{0}},
+			want:  []string{"unexpected payload", `field "extra" is unknown to the client`},
+		},
+		{
+			name:  "unknown type",
+			chunk: &pdtp.Chunk{Type: pdtp.DataTypeDone, JSON: []byte(`{}`)},
+			want:  []string{"type is unknown to the client"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkChunk(tt.chunk, fixtures); !slices.Equal(got, tt.want) {
+				t.Errorf("checkChunk() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
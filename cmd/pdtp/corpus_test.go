@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// syntheticPDF は ゴールデンファイルと比べるために生成する小さな PDF
+type syntheticPDF struct {
+	name  string
+	pages []syntheticPage
+}
+
+// syntheticPage は 1ページの内容ストリームと、ページから参照する画像 XObject
+type syntheticPage struct {
+	content string
+	image   *syntheticImage
+}
+
+// syntheticImage は FlateDecode で圧縮する DeviceRGB の画像 (/Im1 として参照する)
+type syntheticImage struct {
+	width, height int
+	samples       []byte
+}
+
+// goldenCorpus は 抽出と送信の形式の回帰を見つけるための PDF の一覧
+// 1つの PDF で確かめる機能を絞り、差分が出たときに原因を追いやすくする
+var goldenCorpus = []syntheticPDF{
+	{
+		name: "text",
+		pages: []syntheticPage{
+			{content: "BT /F1 24 Tf 72 720 Td (Hello, PDTP) Tj ET"},
+		},
+	},
+	{
+		name: "paths",
+		pages: []syntheticPage{
+			{content: "/DeviceRGB cs 1 0 0 sc 72 600 200 100 re f\n/DeviceRGB CS 0 0 1 SC 4 w 100 400 m 300 500 l S\n/DeviceGray cs 0.5 sc 72 72 m 200 72 l 136 200 l h f"},
+		},
+	},
+	{
+		name: "multipage",
+		pages: []syntheticPage{
+			{content: "BT /F1 18 Tf 72 720 Td (Page one) Tj ET"},
+			{content: "BT /F1 18 Tf 72 720 Td (Page two) Tj 0 -24 Td (Second line) Tj ET"},
+			{content: "BT /F1 18 Tf 72 720 Td (Page three) Tj ET\n/DeviceRGB cs 0 1 0 sc 72 500 100 100 re f"},
+		},
+	},
+	{
+		name: "image",
+		pages: []syntheticPage{
+			{
+				content: "q 200 0 0 100 72 500 cm /Im1 Do Q\nBT /F1 12 Tf 72 480 Td (Caption) Tj ET",
+				image: &syntheticImage{width: 2, height: 2, samples: []byte{
+					255, 0, 0, 0, 255, 0,
+					0, 0, 255, 255, 255, 255,
+				}},
+			},
+		},
+	},
+}
+
+// firstPageObject は 最初のページのオブジェクト番号
+// 1: カタログ  2: ページツリー  3: フォント  4: フォントディスクリプター  5: フォントファイル  6: ToUnicode
+const firstPageObject = 7
+
+// toUnicodeCMap は 0x20〜0x7E の文字コードを同じ Unicode の文字に対応付ける
+const toUnicodeCMap = `/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+1 begincodespacerange
+<00><FF>
+endcodespacerange
+1 beginbfrange
+<20><7E><0020>
+endbfrange
+endcmap
+end
+end`
+
+// build は PDF を組み立てる。相互参照表のオフセットは書き込んだ位置から求める
+// フォントは埋め込みの TrueType (syntheticFont) で、ToUnicode でテキストを取り出せるようにする
+func (s syntheticPDF) build() []byte {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+	stream := func(dict string, data []byte) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n<< %s /Length %d >>\nstream\n", len(offsets), dict, len(data))
+		buf.Write(data)
+		buf.WriteString("\nendstream\nendobj\n")
+	}
+	deflate := func(data []byte) []byte {
+		var out bytes.Buffer
+		zw := zlib.NewWriter(&out)
+		zw.Write(data)
+		zw.Close()
+		return out.Bytes()
+	}
+
+	buf.WriteString("%PDF-1.7\n")
+	// 1ページにつきページ・内容ストリーム・リソース・画像の4つの番号を使う
+	// パーサーはリソースを間接参照として読むため、ページに直接書かない
+	kids := make([]string, len(s.pages))
+	for i := range s.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPageObject+i*4)
+	}
+	widths := strings.TrimSpace(strings.Repeat(fmt.Sprintf("%d ", syntheticGlyphWidth), 0x7E-0x20+1))
+	font := syntheticFont()
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(s.pages)))
+	object(fmt.Sprintf("<< /Type /Font /Subtype /TrueType /BaseFont /PDTPTest /FirstChar 32 /LastChar 126 /Widths [%s] /FontDescriptor 4 0 R /ToUnicode 6 0 R >>", widths))
+	object("<< /Type /FontDescriptor /FontName /PDTPTest /Flags 32 /FontBBox [0 0 600 700] /ItalicAngle 0 /Ascent 800 /Descent -200 /CapHeight 700 /StemV 80 /FontFile2 5 0 R >>")
+	stream(fmt.Sprintf("/Length1 %d /Filter /FlateDecode", len(font)), deflate(font))
+	stream("", []byte(toUnicodeCMap))
+	for i, page := range s.pages {
+		first := firstPageObject + i*4
+		resources := "/Font << /F1 3 0 R >>"
+		if page.image != nil {
+			resources += fmt.Sprintf(" /XObject << /Im1 %d 0 R >>", first+3)
+		}
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources %d 0 R /Contents %d 0 R >>", first+2, first+1))
+		stream("", []byte(page.content))
+		object("<< " + resources + " >>")
+		if page.image != nil {
+			stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode", page.image.width, page.image.height), deflate(page.image.samples))
+		} else {
+			// 番号をそろえるため、使わない番号にも空のオブジェクトを置く
+			object("null")
+		}
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	// 圧縮したデータは Go のバージョンで変わることがあるため、文書の識別に使う /ID を名前から決める
+	id := hex.EncodeToString([]byte(s.name))
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /ID [<%s> <%s>] >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, id, id, xref)
+	return buf.Bytes()
+}
+
+// syntheticGlyphWidth は 合成したフォントの全てのグリフの送り幅 (1000 単位)
+const syntheticGlyphWidth = 600
+
+// syntheticFont は 0x20〜0x7E の文字に四角形のグリフを割り当てた TrueType フォントを組み立てる
+// グリフ 0 は .notdef、空白 (0x20) は輪郭のないグリフにする
+func syntheticFont() []byte {
+	const first, last = 0x20, 0x7E
+	numGlyphs := last - first + 2
+	u16 := func(b *bytes.Buffer, values ...int) {
+		for _, v := range values {
+			binary.Write(b, binary.BigEndian, uint16(v))
+		}
+	}
+	u32 := func(b *bytes.Buffer, values ...uint32) {
+		for _, v := range values {
+			binary.Write(b, binary.BigEndian, v)
+		}
+	}
+
+	// 四角形の輪郭 (50,0) (550,0) (550,700) (50,700)。座標は直前の点からの差分
+	var box bytes.Buffer
+	u16(&box, 1, 50, 0, 550, 700, 3, 0)
+	box.Write([]byte{0x01, 0x01, 0x01, 0x01})
+	u16(&box, 50, 500, 0, -500)
+	u16(&box, 0, 0, 700, 0)
+	box.Write([]byte{0, 0}) // loca の短い形式のため偶数の長さにする
+
+	var glyf, loca, hmtx bytes.Buffer
+	for glyph := 0; glyph < numGlyphs; glyph++ {
+		u16(&loca, glyf.Len()/2)
+		u16(&hmtx, syntheticGlyphWidth, 50)
+		if glyph != 1 {
+			glyf.Write(box.Bytes())
+		}
+	}
+	u16(&loca, glyf.Len()/2)
+
+	var head bytes.Buffer
+	u32(&head, 0x00010000, 0x00010000, 0, 0x5F0F3CF5)
+	u16(&head, 0x000B, 1000)
+	u32(&head, 0, 0, 0, 0)
+	u16(&head, 0, 0, 550, 700, 0, 8, 2, 0, 0)
+
+	var hhea bytes.Buffer
+	u32(&hhea, 0x00010000)
+	u16(&hhea, 800, -200, 0, syntheticGlyphWidth, 50, 50, 550, 1, 0, 0, 0, 0, 0, 0, 0, numGlyphs)
+
+	var maxp bytes.Buffer
+	u32(&maxp, 0x00010000)
+	u16(&maxp, numGlyphs, 4, 1, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0)
+
+	// Windows の Unicode (3, 1) の形式 4。0x20〜0x7E をグリフ 1〜95 に対応付ける
+	var cmap bytes.Buffer
+	u16(&cmap, 0, 1, 3, 1)
+	u32(&cmap, 12)
+	u16(&cmap, 4, 32, 0, 4, 4, 1, 0)
+	u16(&cmap, last, 0xFFFF, 0, first, 0xFFFF, 1-first, 1, 0, 0)
+
+	family := []rune("PDTP Test")
+	var name bytes.Buffer
+	u16(&name, 0, 1, 18, 3, 1, 0x409, 1, len(family)*2, 0)
+	for _, r := range family {
+		u16(&name, int(r))
+	}
+
+	var post bytes.Buffer
+	u32(&post, 0x00030000, 0)
+	u16(&post, -100, 50)
+	u32(&post, 0, 0, 0, 0, 0)
+
+	tables := []struct {
+		tag  string
+		data []byte
+	}{
+		{"cmap", cmap.Bytes()},
+		{"glyf", glyf.Bytes()},
+		{"head", head.Bytes()},
+		{"hhea", hhea.Bytes()},
+		{"hmtx", hmtx.Bytes()},
+		{"loca", loca.Bytes()},
+		{"maxp", maxp.Bytes()},
+		{"name", name.Bytes()},
+		{"post", post.Bytes()},
+	}
+	var font bytes.Buffer
+	u32(&font, 0x00010000)
+	u16(&font, len(tables), 128, 3, len(tables)*16-128)
+	offset := 12 + len(tables)*16
+	headOffset := 0
+	for _, table := range tables {
+		if table.tag == "head" {
+			headOffset = offset
+		}
+		font.WriteString(table.tag)
+		u32(&font, tableChecksum(table.data), uint32(offset), uint32(len(table.data)))
+		offset += (len(table.data) + 3) &^ 3
+	}
+	for _, table := range tables {
+		font.Write(table.data)
+		font.Write(make([]byte, (4-len(table.data)%4)%4))
+	}
+	data := font.Bytes()
+	binary.BigEndian.PutUint32(data[headOffset+8:], 0xB1B0AFBA-tableChecksum(data))
+	return data
+}
+
+// tableChecksum は sfnt のテーブルのチェックサム (4 バイトごとの和) を求める
+func tableChecksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pdtp-workbench/pdtp-go"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the current streams")
+
+// TestGolden は 合成した PDF のストリームを正規化し、testdata/golden のゴールデンファイルと比べる
+// 抽出や送信の形式を意図して変えた場合は go test ./cmd/pdtp -run TestGolden -update で書き換える
+func TestGolden(t *testing.T) {
+	server := newCorpusServer(t)
+	for _, pdf := range goldenCorpus {
+		t.Run(pdf.name, func(t *testing.T) {
+			// 全ページを要求する
+			got, err := canonicalStream(server.URL, pdf.name, fmt.Sprintf("start=1;end=%d", len(pdf.pages)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join("testdata", "golden", pdf.name+".golden")
+			if *update {
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if line, ok := firstDifference(want, got); !ok {
+				t.Errorf("stream differs from %s at line %d\nwant: %s\ngot:  %s", path, line, lineAt(want, line), lineAt(got, line))
+			}
+		})
+	}
+}
+
+// newCorpusServer は goldenCorpus の PDF を名前で開くハンドラーを起動する
+func newCorpusServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	corpus := make(map[string][]byte, len(goldenCorpus))
+	for _, pdf := range goldenCorpus {
+		corpus[pdf.name] = pdf.build()
+	}
+	server := httptest.NewServer(pdtp.NewPDFProtocolHandler(pdtp.Config{
+		OpenPDF: pdtp.OpenFileFunc(func(fileName string) (pdtp.IPDFFile, error) {
+			data, ok := corpus[fileName]
+			if !ok {
+				return nil, os.ErrNotExist
+			}
+			return nopCloser{bytes.NewReader(data)}, nil
+		}),
+		CompressionMethod: pdtp.GzipCompression{},
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// canonicalStream は ストリームを1チャンク1行の比較しやすい形にする
+// JSON はキーを並べ替えて書き直し、バイナリは長さと SHA-256 で表す
+// 送る時刻で内容が変わる ProgressChunk は含めない
+func canonicalStream(serverURL, name, field string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, serverURL+"?file="+url.QueryEscape(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Pdtp", field)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out bytes.Buffer
+	chunks := pdtp.NewChunkReader(resp.Body, pdtp.ProtocolVersion1)
+	for {
+		chunk, err := chunks.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if chunk.Type == pdtp.DataTypeProgress {
+			continue
+		}
+		var value any
+		if err := json.Unmarshal(chunk.JSON, &value); err != nil {
+			return nil, err
+		}
+		normalized, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&out, "%s %s", chunk.TypeName(), normalized)
+		if len(chunk.Payload) > 0 {
+			sum := sha256.Sum256(chunk.Payload)
+			fmt.Fprintf(&out, " +%d sha256:%s", len(chunk.Payload), hex.EncodeToString(sum[:8]))
+		}
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), nil
+}
+
+// firstDifference は 最初に異なる行の番号 (1 から) を返す。一致する場合は ok が true
+func firstDifference(want, got []byte) (int, bool) {
+	wantLines := strings.Split(string(want), "\n")
+	gotLines := strings.Split(string(got), "\n")
+	for i := 0; i < max(len(wantLines), len(gotLines)); i++ {
+		if i >= len(wantLines) || i >= len(gotLines) || wantLines[i] != gotLines[i] {
+			return i + 1, false
+		}
+	}
+	return 0, true
+}
+
+// lineAt は line 行目 (1 から) を返す。行がない場合は空
+func lineAt(data []byte, line int) string {
+	lines := strings.Split(string(data), "\n")
+	if line > len(lines) {
+		return ""
+	}
+	return lines[line-1]
+}
+
+// nopCloser は メモリ上の PDF を IPDFFile として渡す
+type nopCloser struct {
+	*bytes.Reader
+}
+
+func (nopCloser) Close() error { return nil }
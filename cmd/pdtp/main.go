@@ -0,0 +1,239 @@
+// pdtp は PDTP のストリームを確認するためのデバッグ用のコマンド
+//
+//	pdtp dump [-start n] [-end n] file.pdf
+//	    ローカルの PDF を解析し、送られるチャンクの並びを表示する
+//	pdtp fetch [-pdtp field] [-timeout d] [-version n] [-encoding e] url
+//	    PDTP のエンドポイントに接続し、ストリームのフレームの長さと JSON を検証する
+//	pdtp conform -fixtures dir [-pdtp field] file.pdf...
+//	    ハンドラーのストリームがクライアントのフィクスチャのチャンクの形と一致するかを検証する
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pdtp-workbench/pdtp-go"
+)
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "dump":
+		err = dump(os.Args[2:])
+	case "fetch":
+		err = fetch(os.Args[2:])
+	case "conform":
+		err = conform(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage:")
+	fmt.Fprintln(os.Stderr, "  pdtp dump [-start n] [-end n] file.pdf")
+	fmt.Fprintln(os.Stderr, "  pdtp fetch [-pdtp field] [-timeout d] [-version n] [-encoding e] url")
+	fmt.Fprintln(os.Stderr, "  pdtp conform -fixtures dir [-pdtp field] file.pdf...")
+	os.Exit(2)
+}
+
+// dump は ローカルの PDF をハンドラーと同じ形式で書き出し、読み戻したチャンクを表示する
+func dump(args []string) error {
+	flags := flag.NewFlagSet("dump", flag.ExitOnError)
+	start := flags.Int64("start", 1, "first page")
+	end := flags.Int64("end", 0, "last page (0 for all)")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usage()
+	}
+	if *end == 0 {
+		// 最終ページより後ろは最終ページに丸められる
+		*end = math.MaxInt32
+	}
+	fileName := flags.Arg(0)
+
+	pp, err := pdtp.NewPDFParser(func() (pdtp.IPDFFile, error) {
+		return os.Open(fileName)
+	})
+	if err != nil {
+		return err
+	}
+	defer pp.Close()
+
+	var buf bufferWriter
+	var sendErr error
+	err = pp.StreamPageContents(context.Background(), *start, *end, 1, func(data pdtp.ParsedData) {
+		if sendErr != nil {
+			return
+		}
+		sendErr = pdtp.WriteChunk(data, &buf, noopFlusher{})
+	})
+	if err != nil {
+		return err
+	}
+	if sendErr != nil {
+		return sendErr
+	}
+	return report(&buf.Buffer, pdtp.ProtocolVersion1, pdtp.ChunkEncodingJSON)
+}
+
+// fetch は エンドポイントからストリームを受け取り、検証しながら表示する
+func fetch(args []string) error {
+	flags := flag.NewFlagSet("fetch", flag.ExitOnError)
+	field := flags.String("pdtp", "", "value of the Pdtp request header (e.g. start=1;end=3)")
+	timeout := flags.Duration("timeout", 0, "stop reading after this duration (0 for no limit)")
+	version := flags.Int("version", pdtp.ProtocolVersion1, "frame format version (2 adds sequence numbers and CRC32, 3 uses varint lengths)")
+	encoding := flags.String("encoding", string(pdtp.ChunkEncodingJSON), "chunk metadata encoding (json or cbor)")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usage()
+	}
+
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, flags.Arg(0), nil)
+	if err != nil {
+		return err
+	}
+	if *version != pdtp.ProtocolVersion1 {
+		*field = strings.TrimSuffix(*field, ";") + fmt.Sprintf(";version=%d", *version)
+	}
+	if pdtp.ChunkEncoding(*encoding) != pdtp.ChunkEncodingJSON {
+		*field = strings.TrimSuffix(*field, ";") + ";encoding=" + *encoding
+	}
+	if *field != "" {
+		req.Header.Set("Pdtp", strings.TrimPrefix(*field, ";"))
+	}
+	// Accept-Encoding を指定すると net/http は gzip を自動で展開しないため、decodeBody で展開する
+	req.Header.Set("Accept-Encoding", "zstd, gzip")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		if resp.Header.Get("Content-Type") != "application/octet-stream" {
+			return fmt.Errorf("unexpected status: %s", resp.Status)
+		}
+		// 要求を処理できなかった場合も、本文の ErrorChunk を表示する
+		log.Printf("status: %s", resp.Status)
+	}
+
+	body, err := decodeBody(resp)
+	if err != nil {
+		return err
+	}
+	// サーバーが CBOR に対応していない場合は JSON のまま送られる
+	chunkEncoding := pdtp.ChunkEncodingJSON
+	if resp.Header.Get("Pdtp-Encoding") == string(pdtp.ChunkEncodingCBOR) {
+		chunkEncoding = pdtp.ChunkEncodingCBOR
+	}
+	err = report(body, *version, chunkEncoding)
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Printf("stopped after %s", *timeout)
+		return nil
+	}
+	return err
+}
+
+// decodeBody は Content-Encoding に合わせてレスポンスを展開する
+func decodeBody(resp *http.Response) (io.Reader, error) {
+	switch encoding := resp.Header.Get("Content-Encoding"); encoding {
+	case "", "identity":
+		return resp.Body, nil
+	case "gzip":
+		return gzip.NewReader(resp.Body)
+	case "zstd":
+		decoder, err := zstd.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+}
+
+// report は チャンクを1行ずつ表示し、最後に種別ごとの件数を表示する
+// 不正なチャンクがあった場合は、それまでの件数を表示してからエラーを返す
+func report(r io.Reader, version int, encoding pdtp.ChunkEncoding) error {
+	counts := map[string]int{}
+	var order []string
+	total := 0
+	offset := int64(0)
+	reader := &countingReader{r: r}
+	chunks := pdtp.NewChunkReaderWithEncoding(reader, version, encoding)
+	var readErr error
+	for {
+		chunk, err := chunks.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			readErr = fmt.Errorf("chunk %d at offset %d: %w", total, offset, err)
+			break
+		}
+		total++
+		name := chunk.TypeName()
+		if counts[name] == 0 {
+			order = append(order, name)
+		}
+		counts[name]++
+		if len(chunk.Payload) > 0 {
+			fmt.Printf("%-10s %s +%d bytes\n", name, chunk.JSON, len(chunk.Payload))
+		} else {
+			fmt.Printf("%-10s %s\n", name, chunk.JSON)
+		}
+		offset = reader.n
+	}
+	fmt.Printf("-- %d bytes\n", offset)
+	for _, name := range order {
+		fmt.Printf("%-10s %d\n", name, counts[name])
+	}
+	return readErr
+}
+
+// bufferWriter は チャンクをメモリに書き出す FlusherWriter
+type bufferWriter struct {
+	bytes.Buffer
+}
+
+func (b *bufferWriter) Flush() error { return nil }
+func (b *bufferWriter) Close() error { return nil }
+
+type noopFlusher struct{}
+
+func (noopFlusher) Flush() {}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
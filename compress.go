@@ -4,6 +4,9 @@ import (
 	"errors"
 	"io"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 )
 
 type CompressionMethod interface {
@@ -18,24 +21,105 @@ type FlusherWriter interface {
 	Close() error
 }
 
-// TODO: 圧縮しない場合の処理を追加
+// CompressionMiddleware は レスポンスの共通ヘッダーを設定し、comp で圧縮して書き込む FlusherWriter を返す
+// comp が nil の場合は圧縮しない (IdentityCompression)
 func CompressionMiddleware(w http.ResponseWriter, r *http.Request, comp CompressionMethod) (FlusherWriter, http.Flusher, error) {
+	if comp == nil {
+		comp = IdentityCompression{}
+	}
 	// 共通ヘッダ
 	w.Header().Set("Content-Type", "application/octet-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 
+	// 圧縮の Writer は Flusher を前提にするため、先に確認する
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
+		return nil, nil, errors.New("streaming unsupported")
+	}
+
 	fw, err := comp.Writer(w)
 	if err != nil {
 		http.Error(w, "Failed to initialize compression", http.StatusInternalServerError)
 		return nil, nil, err
 	}
 
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
-		return nil, nil, errors.New("streaming unsupported")
+	return fw, flusher, nil
+}
+
+// compressionPreference は Accept-Encoding で複数の方法を受け付ける場合に選ぶ順番
+var compressionPreference = []string{"zstd", "br", "gzip", "identity"}
+
+// NegotiateCompression は Accept-Encoding で受け付けられる方法のうち、最も優先する方法を methods から選ぶ
+// 優先する順番は zstd > br > gzip > identity で、それ以外の名前の方法は登録した順に後に続く
+// q=0 の方法は使わず、受け付けられる方法がない場合は IdentityCompression を返す
+func NegotiateCompression(r *http.Request, methods []CompressionMethod) CompressionMethod {
+	accepted := acceptedEncodings(r.Header.Values("Accept-Encoding"))
+	rank := func(m CompressionMethod) int {
+		if i := slices.Index(compressionPreference, m.Name()); i >= 0 {
+			return i
+		}
+		return len(compressionPreference)
+	}
+	var best CompressionMethod
+	for _, m := range methods {
+		if m == nil || !accepted(m.Name()) {
+			continue
+		}
+		if best == nil || rank(m) < rank(best) {
+			best = m
+		}
+	}
+	if best == nil {
+		return IdentityCompression{}
+	}
+	return best
+}
+
+// preferredCompression は names (クライアントが優先する順) のうち、methods にある最初の方法を返す (ない場合は nil)
+func preferredCompression(names []string, methods []CompressionMethod) CompressionMethod {
+	for _, name := range names {
+		for _, m := range methods {
+			if m != nil && strings.EqualFold(m.Name(), strings.TrimSpace(name)) {
+				return m
+			}
+		}
 	}
+	return nil
+}
 
-	return fw, flusher, nil
+// acceptedEncodings は Accept-Encoding の値から、名前の方法を受け付けるかを返す関数を作る
+// ヘッダーがない場合は identity のみを受け付ける
+func acceptedEncodings(values []string) func(name string) bool {
+	qualities := make(map[string]float64)
+	for _, value := range values {
+		for _, entry := range strings.Split(value, ",") {
+			name, params, _ := strings.Cut(entry, ";")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			q := 1.0
+			for _, param := range strings.Split(params, ";") {
+				key, v, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if ok && strings.EqualFold(key, "q") {
+					if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+						q = parsed
+					}
+				}
+			}
+			qualities[name] = q
+		}
+	}
+	return func(name string) bool {
+		if q, ok := qualities[name]; ok {
+			return q > 0
+		}
+		if q, ok := qualities["*"]; ok {
+			return q > 0
+		}
+		// identity は明示的に拒否されない限り受け付ける
+		return name == "identity"
+	}
 }
@@ -0,0 +1,96 @@
+package pdtp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// TestNegotiateCompression は Accept-Encoding の q 値と zstd > br > gzip > identity の順で圧縮方法を選び、
+// 選んだ方法で展開したストリームが圧縮しない場合と同じになることを確かめる
+func TestNegotiateCompression(t *testing.T) {
+	methods := []CompressionMethod{GzipCompression{}, brotliStub{}, ZstdCompression{}, IdentityCompression{}}
+	serve := func(methods []CompressionMethod, acceptEncoding ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/?file=multipage.pdf", nil)
+		for _, value := range acceptEncoding {
+			req.Header.Add("Accept-Encoding", value)
+		}
+		rec := httptest.NewRecorder()
+		NewPDFProtocolHandler(Config{OpenPDF: OpenUnder("cmd/pdtp/testdata/conform"), CompressionMethods: methods})(rec, req)
+		return rec
+	}
+	want := serve(nil).Body.Bytes()
+	for _, test := range []struct {
+		name           string
+		methods        []CompressionMethod
+		acceptEncoding []string
+		encoding       string // Content-Encoding (空の場合は圧縮しない)
+	}{
+		{"no Accept-Encoding", methods, nil, ""},
+		{"gzip", methods, []string{"gzip"}, "gzip"},
+		{"zstd over gzip", methods, []string{"gzip, zstd"}, "zstd"},
+		{"br over gzip", methods, []string{"gzip, br"}, "br"},
+		{"zstd over br", methods, []string{"br, gzip, zstd"}, "zstd"},
+		{"q=0", methods, []string{"zstd;q=0, gzip;q=0.5"}, "gzip"},
+		{"wildcard", methods, []string{"*"}, "zstd"},
+		{"wildcard without zstd", methods, []string{"*, zstd;q=0"}, "br"},
+		{"unsupported", methods, []string{"compress, deflate"}, ""},
+		{"unregistered", []CompressionMethod{GzipCompression{}}, []string{"zstd, br"}, ""},
+		{"multiple headers", methods, []string{"identity", "gzip"}, "gzip"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rec := serve(test.methods, test.acceptEncoding...)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d", rec.Code)
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != test.encoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, test.encoding)
+			}
+			if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want Accept-Encoding", got)
+			}
+			body, err := decodeContent(rec.Header().Get("Content-Encoding"), rec.Body.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(body, want) {
+				t.Errorf("decoded %d bytes that differ from the %d bytes of the uncompressed stream", len(body), len(want))
+			}
+		})
+	}
+}
+
+// decodeContent は Content-Encoding に合わせて body を展開する
+func decodeContent(encoding string, body []byte) ([]byte, error) {
+	switch encoding {
+	case "gzip":
+		r, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(r)
+	case "zstd":
+		r, err := zstd.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	}
+	return body, nil
+}
+
+// brotliStub は 順番を確かめるため br の名前で登録する CompressionMethod (圧縮はしない)
+type brotliStub struct{}
+
+func (brotliStub) Name() string { return "br" }
+
+func (brotliStub) Writer(w http.ResponseWriter) (FlusherWriter, error) {
+	w.Header().Set("Content-Encoding", "br")
+	return IdentityCompression{}.Writer(w)
+}
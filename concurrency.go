@@ -0,0 +1,56 @@
+package pdtp
+
+import (
+	"context"
+	"time"
+)
+
+// streamLimiter は 同時に解析するストリームの数を制限するセマフォ
+// 要求が集中しても、解析の goroutine とメモリが際限なく増えないようにする
+type streamLimiter struct {
+	slots chan struct{}
+	wait  time.Duration
+}
+
+// newStreamLimiter は 同時に size 個までのストリームを許す streamLimiter を作成する
+// 空きがない場合は wait だけ待つ (0 の場合は待たない)。size が 0 以下の場合は nil (制限なし) を返す
+func newStreamLimiter(size int, wait time.Duration) *streamLimiter {
+	if size <= 0 {
+		return nil
+	}
+	return &streamLimiter{slots: make(chan struct{}, size), wait: wait}
+}
+
+// acquire は 空きができるまで待ち、1つ使う
+// 待つ時間を過ぎた場合・ctx が取り消された場合は false を返す
+func (l *streamLimiter) acquire(ctx context.Context) bool {
+	if l == nil {
+		return true
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if l.wait <= 0 {
+		return false
+	}
+	timer := time.NewTimer(l.wait)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release は acquire で使った空きを返す
+func (l *streamLimiter) release() {
+	if l == nil {
+		return
+	}
+	<-l.slots
+}
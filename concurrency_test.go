@@ -0,0 +1,77 @@
+package pdtp
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestMaxConcurrentStreams は 上限に達している間の要求を 429 で断るか、StreamQueueTimeout の間は空きを待って処理することを確かめる
+func TestMaxConcurrentStreams(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		wait   time.Duration
+		status int // 1つ目の解析中に送った要求のステータスコード
+	}{
+		{"reject", 0, http.StatusTooManyRequests},
+		{"queue", time.Minute, http.StatusOK},
+		{"queue timeout", 10 * time.Millisecond, http.StatusTooManyRequests},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			open := OpenUnder("cmd/pdtp/testdata/conform")
+			opened := make(chan struct{}, 2)
+			unblock := make(chan struct{})
+			handler := NewPDFProtocolHandler(Config{
+				// blocking.pdf を開く要求は unblock が閉じられるまで解析を始めない
+				OpenPDF: func(ctx context.Context, r *http.Request, fileName string) (IPDFFile, error) {
+					opened <- struct{}{}
+					if fileName == "blocking.pdf" {
+						<-unblock
+						fileName = "multipage.pdf"
+					}
+					return open(ctx, r, fileName)
+				},
+				MaxConcurrentStreams: 1,
+				StreamQueueTimeout:   test.wait,
+				Logger:               slog.New(slog.NewTextHandler(io.Discard, nil)),
+			})
+			serve := func(file string) int {
+				rec := httptest.NewRecorder()
+				handler(rec, httptest.NewRequest(http.MethodGet, "/?file="+file, nil))
+				return rec.Code
+			}
+
+			first := make(chan int)
+			go func() { first <- serve("blocking.pdf") }()
+			<-opened
+			second := make(chan int)
+			go func() { second <- serve("multipage.pdf") }()
+			if test.status == http.StatusOK {
+				// 待っている要求は、1つ目が終わるまで PDF を開かない
+				select {
+				case <-opened:
+					t.Fatal("second stream started while the first was being parsed")
+				case <-time.After(50 * time.Millisecond):
+				}
+				close(unblock)
+			}
+			if status := <-second; status != test.status {
+				t.Errorf("second request: status = %d, want %d", status, test.status)
+			}
+			if test.status != http.StatusOK {
+				close(unblock)
+			}
+			if status := <-first; status != http.StatusOK {
+				t.Errorf("first request: status = %d, want 200", status)
+			}
+			// 終わったストリームの空きは返されている
+			if status := serve("multipage.pdf"); status != http.StatusOK {
+				t.Errorf("request after the streams finished: status = %d, want 200", status)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package pdtp
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+)
+
+// Chunk は ストリームから読み込んだ1つのチャンク
+type Chunk struct {
+	Type       byte
+	Sequence   uint32 // 連番 (バージョン 2 のみ)
+	JSON       json.RawMessage
+	Payload    []byte // JSON に続くバイナリ (画像・フォントなど)
+	Compressed bool   // チャンクごとに圧縮されていた (ChunkFlagCompressed)
+
+	// total は 続く ContinuationChunk と合わせたバイナリの長さ (分割されていない場合は Payload の長さ)
+	total int64
+}
+
+// TypeName は チャンク種別の名前を返す (未知の種別は空文字)
+func (c *Chunk) TypeName() string {
+	return dataTypeNames[c.Type]
+}
+
+// ReadChunk は r からバージョン 1 のチャンクを1つ読み込み、フレームの長さと JSON の形式を検証する
+// JSON に続くバイナリの長さは、各チャンクの JSON の length などの値から求める
+// ストリームの終わりでは io.EOF を返す
+// 分割して送られたバイナリは、続く ContinuationChunk を読み込んでつなげる
+func ReadChunk(r io.Reader) (*Chunk, error) {
+	return NewChunkReader(r, ProtocolVersion1).Next()
+}
+
+// ChunkReader は ストリームからチャンクを順に読み込む
+// バージョン 2 では連番が続いているか、CRC32 が内容と一致するかも検証する
+type ChunkReader struct {
+	r        io.Reader
+	version  int
+	encoding ChunkEncoding
+	limits   ChunkLimits
+	sequence uint32
+}
+
+const (
+	// DefaultMaxMetadataSize は ChunkLimits を指定しない場合の、1つのフレームのメタデータのバイト数の上限
+	DefaultMaxMetadataSize = 16 << 20
+	// DefaultMaxPayloadSize は ChunkLimits を指定しない場合の、1つのチャンクのバイナリ (分割された続きを含む) のバイト数の上限
+	DefaultMaxPayloadSize = 256 << 20
+)
+
+// ChunkLimits は ChunkReader が受け付けるチャンクの大きさの上限
+// フレームのヘッダー・JSON の長さはサーバーから送られた値のため、壊れた・悪意のあるサーバーに大きなメモリを確保させないよう、読み込む前に確かめる
+type ChunkLimits struct {
+	MaxMetadataSize int64 // メタデータのバイト数の上限 (0 は DefaultMaxMetadataSize)
+	MaxPayloadSize  int64 // バイナリのバイト数の上限 (0 は DefaultMaxPayloadSize)
+}
+
+func (l ChunkLimits) maxMetadataSize() int64 {
+	if l.MaxMetadataSize > 0 {
+		return l.MaxMetadataSize
+	}
+	return DefaultMaxMetadataSize
+}
+
+func (l ChunkLimits) maxPayloadSize() int64 {
+	if l.MaxPayloadSize > 0 {
+		return l.MaxPayloadSize
+	}
+	return DefaultMaxPayloadSize
+}
+
+func NewChunkReader(r io.Reader, version int) *ChunkReader {
+	return NewChunkReaderWithEncoding(r, version, ChunkEncodingJSON)
+}
+
+// NewChunkReaderWithEncoding は メタデータの形式を指定して ChunkReader を作る
+// CBOR で送られたメタデータは JSON に変換して Chunk.JSON に入れる
+func NewChunkReaderWithEncoding(r io.Reader, version int, encoding ChunkEncoding) *ChunkReader {
+	return &ChunkReader{r: r, version: version, encoding: encoding}
+}
+
+// SetLimits は 受け付けるチャンクの大きさの上限を変える。上限を超えるフレームは ErrInvalidChunk になる
+func (c *ChunkReader) SetLimits(limits ChunkLimits) {
+	c.limits = limits
+}
+
+// Next は 次のチャンクを読み込む。ストリームの終わりでは io.EOF を返す
+// 分割して送られたバイナリは、続く ContinuationChunk を読み込んでつなげる
+func (c *ChunkReader) Next() (*Chunk, error) {
+	chunk, err := c.nextFrame()
+	if err != nil {
+		return nil, err
+	}
+	for int64(len(chunk.Payload)) < chunk.total {
+		next, err := c.nextFrame()
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: continuation of %s: %w", ErrInvalidChunk, chunk.TypeName(), err)
+		}
+		if next.Type != DataTypeContinuation {
+			return nil, fmt.Errorf("%w: %s payload is cut at %d of %d bytes by %s", ErrInvalidChunk, chunk.TypeName(), len(chunk.Payload), chunk.total, next.TypeName())
+		}
+		if int64(len(chunk.Payload)+len(next.Payload)) > chunk.total {
+			return nil, fmt.Errorf("%w: continuation exceeds %s payload of %d bytes", ErrInvalidChunk, chunk.TypeName(), chunk.total)
+		}
+		chunk.Payload = append(chunk.Payload, next.Payload...)
+	}
+	return chunk, nil
+}
+
+// nextFrame は フレームを1つ読み込み、連番を検証する
+func (c *ChunkReader) nextFrame() (*Chunk, error) {
+	chunk, err := readChunk(c.r, c.version, c.encoding, c.limits)
+	if err != nil {
+		return nil, err
+	}
+	if c.version == ProtocolVersion2 {
+		if chunk.Sequence != c.sequence {
+			return nil, fmt.Errorf("%w: sequence %d, expected %d", ErrInvalidChunk, chunk.Sequence, c.sequence)
+		}
+		c.sequence++
+	}
+	return chunk, nil
+}
+
+func readChunk(r io.Reader, version int, encoding ChunkEncoding, limits ChunkLimits) (*Chunk, error) {
+	header := make([]byte, frameHeaderSize(version))
+	if _, err := io.ReadFull(r, header[:1]); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(r, header[1:]); err != nil {
+		return nil, fmt.Errorf("%w: truncated header: %w", ErrInvalidChunk, err)
+	}
+	chunk := &Chunk{Type: header[0]}
+	if chunk.Type != DataTypeError && chunk.Type&ChunkFlagCompressed != 0 {
+		// ErrorChunk (0xFF) は圧縮しないため、最上位ビットを圧縮の印として扱わない
+		chunk.Type &^= ChunkFlagCompressed
+		chunk.Compressed = true
+	}
+	if _, known := dataTypeNames[chunk.Type]; !known {
+		return nil, fmt.Errorf("%w: unknown type %#02x", ErrInvalidChunk, chunk.Type)
+	}
+	var checksum uint32
+	var length uint32
+	switch version {
+	case ProtocolVersion2:
+		chunk.Sequence = binary.BigEndian.Uint32(header[1:5])
+		checksum = binary.BigEndian.Uint32(header[5:9])
+		length = binary.BigEndian.Uint32(header[9:13])
+	case ProtocolVersion3:
+		n, err := binary.ReadUvarint(byteReader{r})
+		if err == nil && n > math.MaxUint32 {
+			err = errors.New("length overflows uint32")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid header length: %w", ErrInvalidChunk, err)
+		}
+		length = uint32(n)
+	default:
+		length = binary.BigEndian.Uint32(header[1:5])
+	}
+	maxBody := limits.maxMetadataSize()
+	if chunk.Compressed {
+		// 圧縮したフレームはバイナリも含む
+		maxBody += limits.maxPayloadSize()
+	}
+	if int64(length) > maxBody {
+		return nil, fmt.Errorf("%w: %s json of %d bytes exceeds the limit of %d", ErrInvalidChunk, chunk.TypeName(), length, maxBody)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("%w: truncated %s json (%d bytes): %w", ErrInvalidChunk, chunk.TypeName(), length, err)
+	}
+	chunk.JSON = body
+	var compressedPayload []byte
+	if chunk.Compressed {
+		inner, err := decompressChunk(body)
+		if err != nil {
+			return nil, fmt.Errorf("%w: compressed %s: %w", ErrInvalidChunk, chunk.TypeName(), err)
+		}
+		jsonLength := binary.BigEndian.Uint32(inner[:4])
+		if int64(jsonLength) > limits.maxMetadataSize() {
+			return nil, fmt.Errorf("%w: compressed %s json of %d bytes exceeds the limit of %d", ErrInvalidChunk, chunk.TypeName(), jsonLength, limits.maxMetadataSize())
+		}
+		chunk.JSON, compressedPayload = inner[4:4+jsonLength], inner[4+jsonLength:]
+	}
+	if encoding == ChunkEncodingCBOR {
+		converted, err := cborToJSON(chunk.JSON)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s metadata: %w", ErrInvalidChunk, chunk.TypeName(), err)
+		}
+		chunk.JSON = converted
+	}
+	if !json.Valid(chunk.JSON) {
+		return nil, fmt.Errorf("%w: %s json is not valid", ErrInvalidChunk, chunk.TypeName())
+	}
+	payloadLength, total, err := chunkPayloadLength(chunk.Type, chunk.JSON)
+	if err != nil {
+		return nil, err
+	}
+	if total > limits.maxPayloadSize() {
+		return nil, fmt.Errorf("%w: %s payload of %d bytes exceeds the limit of %d", ErrInvalidChunk, chunk.TypeName(), total, limits.maxPayloadSize())
+	}
+	chunk.total = total
+	if chunk.Compressed {
+		// バイナリも圧縮したデータに含まれている
+		if int64(len(compressedPayload)) != payloadLength {
+			return nil, fmt.Errorf("%w: compressed %s payload is %d bytes, expected %d", ErrInvalidChunk, chunk.TypeName(), len(compressedPayload), payloadLength)
+		}
+		if payloadLength > 0 {
+			chunk.Payload = compressedPayload
+		}
+	} else if payloadLength > 0 {
+		chunk.Payload = make([]byte, payloadLength)
+		if _, err := io.ReadFull(r, chunk.Payload); err != nil {
+			return nil, fmt.Errorf("%w: truncated %s payload (%d bytes): %w", ErrInvalidChunk, chunk.TypeName(), payloadLength, err)
+		}
+	}
+	if version == ProtocolVersion2 {
+		// CRC32 は送られた形式のままの内容から求める (圧縮したチャンクではバイナリも body に含まれる)
+		crc := crc32.ChecksumIEEE(body)
+		if !chunk.Compressed {
+			crc = crc32.Update(crc, crc32.IEEETable, chunk.Payload)
+		}
+		if crc != checksum {
+			return nil, fmt.Errorf("%w: %s checksum mismatch (sequence %d)", ErrInvalidChunk, chunk.TypeName(), chunk.Sequence)
+		}
+	}
+	return chunk, nil
+}
+
+// byteReader は 可変長整数を読むため、r から1バイトずつ読み込む
+// 先読みしないため、続くデータを r からそのまま読める
+type byteReader struct {
+	r io.Reader
+}
+
+func (b byteReader) ReadByte() (byte, error) {
+	var buf [1]byte
+	if _, err := io.ReadFull(b.r, buf[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	return buf[0], nil
+}
+
+// chunkPayloadLength は フレームの JSON に続くバイナリの長さと、分割された場合も含めたバイナリ全体の長さを返す
+func chunkPayloadLength(dataType byte, body []byte) (int64, int64, error) {
+	var lengths struct {
+		Length      *int64 `json:"length"`
+		MaskLength  int64  `json:"maskLength"`
+		ChunkLength *int64 `json:"chunkLength"`
+	}
+	switch dataType {
+	case DataTypeImage, DataTypeFont, DataTypeAttachment, DataTypeThumbnail, DataTypeICCProfile, DataTypeContinuation:
+	case DataTypePath:
+		// パスはソフトマスクの画像のみを続けて送る (length はない)
+		zero := int64(0)
+		lengths.Length = &zero
+	default:
+		return 0, 0, nil
+	}
+	// フォントチャンクの JSON はフィールド名のまま (Length) だが、encoding/json は大文字・小文字を区別せずに読む
+	if err := json.Unmarshal(body, &lengths); err != nil {
+		return 0, 0, fmt.Errorf("%w: %v", ErrInvalidChunk, err)
+	}
+	if lengths.Length == nil {
+		return 0, 0, fmt.Errorf("%w: %s json has no length", ErrInvalidChunk, dataTypeNames[dataType])
+	}
+	total := *lengths.Length + lengths.MaskLength
+	if *lengths.Length < 0 || lengths.MaskLength < 0 {
+		return 0, 0, fmt.Errorf("%w: negative payload length", ErrInvalidChunk)
+	}
+	if lengths.ChunkLength != nil && dataType != DataTypeContinuation {
+		// バイナリの残りは ContinuationChunk で続けて送られる
+		if *lengths.ChunkLength < 0 || *lengths.ChunkLength > total {
+			return 0, 0, fmt.Errorf("%w: chunk length %d is outside payload of %d bytes", ErrInvalidChunk, *lengths.ChunkLength, total)
+		}
+		return *lengths.ChunkLength, total, nil
+	}
+	return total, total, nil
+}
+
+// WriteChunk は 解析したデータをプロトコルのチャンクとして書き込む
+func WriteChunk(data ParsedData, fw FlusherWriter, flusher http.Flusher) error {
+	return sendChunk(slog.Default(), data, fw, flusher)
+}
@@ -0,0 +1,99 @@
+package pdtp_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pdtp-workbench/pdtp-go"
+	"github.com/pdtp-workbench/pdtp-go/pdtpclient"
+)
+
+// frameV1 は バージョン 1 のフレーム (種別 | JSON の長さ | JSON | バイナリ) を組み立てる
+func frameV1(dataType byte, metadata string, payload []byte) []byte {
+	frame := []byte{dataType}
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(metadata)))
+	frame = append(frame, metadata...)
+	return append(frame, payload...)
+}
+
+// TestChunkLimits は ヘッダー・JSON の長さが上限を超えるフレームを、メモリを確保する前に ErrInvalidChunk にすることを確かめる
+func TestChunkLimits(t *testing.T) {
+	text := `{"x":0,"y":0,"text":"hello"}`
+	for _, test := range []struct {
+		name    string
+		limits  pdtp.ChunkLimits
+		stream  []byte
+		wantErr bool
+	}{
+		{
+			name:   "within the default limits",
+			stream: frameV1(pdtp.DataTypeImage, `{"length":3}`, []byte{1, 2, 3}),
+		},
+		{
+			// 上限がなければ 4GiB を確保してから、続きがないため失敗する
+			name:    "header length of 4GiB",
+			stream:  []byte{pdtp.DataTypeText, 0xFF, 0xFF, 0xFF, 0xFF},
+			wantErr: true,
+		},
+		{
+			name:    "payload length beyond the default limit",
+			stream:  frameV1(pdtp.DataTypeImage, `{"length":1099511627776}`, nil),
+			wantErr: true,
+		},
+		{
+			name:    "split payload whose total is beyond the limit",
+			limits:  pdtp.ChunkLimits{MaxPayloadSize: 4},
+			stream:  frameV1(pdtp.DataTypeImage, `{"length":8,"chunkLength":2}`, []byte{1, 2}),
+			wantErr: true,
+		},
+		{
+			name:    "metadata beyond a custom limit",
+			limits:  pdtp.ChunkLimits{MaxMetadataSize: int64(len(text) - 1)},
+			stream:  frameV1(pdtp.DataTypeText, text, nil),
+			wantErr: true,
+		},
+		{
+			name:   "metadata at a custom limit",
+			limits: pdtp.ChunkLimits{MaxMetadataSize: int64(len(text))},
+			stream: frameV1(pdtp.DataTypeText, text, nil),
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			chunks := pdtp.NewChunkReader(bytes.NewReader(test.stream), pdtp.ProtocolVersion1)
+			chunks.SetLimits(test.limits)
+			_, err := chunks.Next()
+			if test.wantErr {
+				if !errors.Is(err, pdtp.ErrInvalidChunk) {
+					t.Fatalf("err = %v, want %v", err, pdtp.ErrInvalidChunk)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// TestClientLimits は pdtpclient.Client の Limits を超える画像を含むストリームの読み込みが ErrInvalidChunk で終わることを確かめる
+func TestClientLimits(t *testing.T) {
+	server := httptest.NewServer(pdtp.NewPDFProtocolHandler(pdtp.Config{
+		OpenPDF: pdtp.OpenUnder("cmd/pdtp/testdata/conform"),
+	}))
+	defer server.Close()
+	client := &pdtpclient.Client{Limits: pdtp.ChunkLimits{MaxPayloadSize: 16}}
+	stream, err := client.Open(context.Background(), server.URL+"?file=image.pdf", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	for _, err = range stream.Events() {
+	}
+	if !errors.Is(err, pdtp.ErrInvalidChunk) {
+		t.Fatalf("err = %v, want %v", err, pdtp.ErrInvalidChunk)
+	}
+}
@@ -2,10 +2,30 @@ package pdtp
 
 import (
 	"errors"
+	"fmt"
 )
 
 var (
-	ErrParserDeCompressionError = errors.New("decompression error")
-	ErrParserParseObjectError   = errors.New("parse object error")
-	ErrParserReadStreamError    = errors.New("read stream error")
+	ErrInvalidChunk      = errors.New("invalid chunk")
+	ErrStreamTimeout     = errors.New("stream timed out")
+	ErrInvalidFileName   = errors.New("invalid file name")
+	ErrInvalidPDTPHeader = errors.New("invalid pdtp header")
 )
+
+// PDTPHeaderError は Pdtp ヘッダー (POST の場合は本文) の読み込めない項目
+type PDTPHeaderError struct {
+	Field  string // 項目の名前 (key=value の形でない場合は項目全体)
+	Value  string // 指定された値
+	Reason string // 読み込めない理由
+}
+
+func (e *PDTPHeaderError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("%s: %q: %s", ErrInvalidPDTPHeader, e.Field, e.Reason)
+	}
+	return fmt.Sprintf("%s: %s=%q: %s", ErrInvalidPDTPHeader, e.Field, e.Value, e.Reason)
+}
+
+func (e *PDTPHeaderError) Unwrap() error {
+	return ErrInvalidPDTPHeader
+}
@@ -0,0 +1,32 @@
+package pdtp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// responseETag は 文書の識別子と、送る内容を変える要求のオプションから ETag を作る
+// 同じ文書でもページの範囲・形式・圧縮方法が異なればチャンクの列も異なるため、それらを含める
+// variant は Pdtp ヘッダー・形式・検索する文字列 (POST の場合は本文)
+func responseETag(documentID, variant, compression string) string {
+	h := sha256.New()
+	for _, part := range []string{documentID, variant, compression} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// etagMatch は If-None-Match のいずれかの値が etag と一致するかを返す (弱い比較)
+func etagMatch(ifNoneMatch []string, etag string) bool {
+	for _, value := range ifNoneMatch {
+		for _, candidate := range strings.Split(value, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package pdtp
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"sync/atomic"
+	"testing"
+)
+
+// TestNotModified は If-None-Match が一致する要求に、本文と Content-Encoding のない 304 を返すことを確かめる
+// /ID のない文書のハッシュは DocumentCache に保持され、次の要求ではファイルを読まない
+func TestNotModified(t *testing.T) {
+	pdf, err := os.ReadFile("cmd/pdtp/testdata/conform/text.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	withoutID := regexp.MustCompile(` /ID \[<[0-9a-f]*> <[0-9a-f]*>\]`).ReplaceAll(pdf, nil)
+	if bytes.Equal(withoutID, pdf) {
+		t.Fatal("the test PDF has no trailer /ID to remove")
+	}
+
+	for name, data := range map[string][]byte{"with ID": pdf, "without ID": withoutID} {
+		t.Run(name, func(t *testing.T) {
+			var read atomic.Int64
+			server := httptest.NewServer(NewPDFProtocolHandler(Config{
+				OpenPDF: OpenFileFunc(func(string) (IPDFFile, error) {
+					return &countingFile{Reader: bytes.NewReader(data), read: &read}, nil
+				}),
+				CompressionMethod: GzipCompression{},
+				DocumentCache:     NewDocumentCache(4),
+				ETag:              true,
+			}))
+			defer server.Close()
+
+			resp := get(t, server.URL, "")
+			etag := resp.Header.Get("ETag")
+			if resp.StatusCode != http.StatusOK || etag == "" {
+				t.Fatalf("status %s, ETag %q", resp.Status, etag)
+			}
+
+			read.Store(0)
+			resp = get(t, server.URL, etag)
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != http.StatusNotModified || len(body) > 0 {
+				t.Fatalf("status %s with %d bytes, want 304 without a body", resp.Status, len(body))
+			}
+			if encoding := resp.Header.Get("Content-Encoding"); encoding != "" {
+				t.Errorf("304 has Content-Encoding %q", encoding)
+			}
+			if n := read.Load(); n > 0 {
+				t.Errorf("304 read %d bytes of the file, want the document ID from the cache", n)
+			}
+		})
+	}
+}
+
+func get(t *testing.T, url, ifNoneMatch string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url+"?file=text.pdf", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Pdtp", "start=1;end=1")
+	req.Header.Set("Accept-Encoding", "gzip")
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	// 最初の要求のストリームは読み終えてから次の要求を送る
+	if ifNoneMatch == "" {
+		io.Copy(io.Discard, resp.Body)
+	}
+	return resp
+}
+
+// countingFile は 読み込んだバイト数を数える、ETag で版を示すファイル
+type countingFile struct {
+	*bytes.Reader
+	read *atomic.Int64
+}
+
+func (f *countingFile) Read(p []byte) (int, error) {
+	n, err := f.Reader.Read(p)
+	f.read.Add(int64(n))
+	return n, err
+}
+
+func (f *countingFile) ETag() string { return `"v1"` }
+
+func (f *countingFile) Close() error { return nil }
@@ -0,0 +1,77 @@
+// auth は トークンを持つクライアントにのみ PDF を配信する例
+//
+//	PDTP_TOKEN=secret go run ./example/auth -root ./example
+//	curl -H "Authorization: Bearer secret" "http://localhost:8080/pdtp?file=example.pdf"
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/pdtp-workbench/pdtp-go"
+)
+
+// requireToken は Authorization ヘッダーのトークンを確認する
+func requireToken(token string, next http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, expected) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="pdtp"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// logChunks は 送ったチャンクの数をリクエストごとに記録する
+func logChunks(next pdtp.PDTPHandler) pdtp.PDTPHandler {
+	return func(ctx context.Context, data pdtp.ParsedData) error {
+		if page, ok := data.(*pdtp.ParsedPage); ok {
+			if r, ok := pdtp.RequestFromContext(ctx); ok {
+				log.Printf("%s: page %d", r.RemoteAddr, page.Page)
+			}
+		}
+		return next(ctx, data)
+	}
+}
+
+// newServer は root 以下の PDF を token を持つクライアントにだけ配信するハンドラーを返す
+func newServer(root, token string) (http.Handler, error) {
+	handler, err := pdtp.NewHandler(
+		pdtp.WithOpenPDF(pdtp.OpenUnder(root)),
+		pdtp.WithCompression(pdtp.GzipCompression{}),
+		pdtp.WithMiddlewares(logChunks),
+	)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/pdtp", requireToken(token, handler))
+	return mux, nil
+}
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	root := flag.String("root", ".", "directory containing the PDF files")
+	flag.Parse()
+
+	token := os.Getenv("PDTP_TOKEN")
+	if token == "" {
+		log.Fatal("PDTP_TOKEN is not set")
+	}
+
+	server, err := newServer(*root, token)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("PDF Protocol Server listening on %s\n", *addr)
+	log.Fatal(http.ListenAndServe(*addr, server))
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/pdtp-workbench/pdtp-go/pdtpclient"
+)
+
+// TestServer は トークンのない要求を拒否し、トークンのある要求にストリームを返すことを確かめる
+func TestServer(t *testing.T) {
+	handler, err := newServer("..", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	url := server.URL + "/pdtp?file=example.pdf"
+
+	for _, authorization := range []string{"", "Bearer wrong", "secret"} {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") == "" {
+			t.Errorf("Authorization %q: status %s, want 401 with WWW-Authenticate", authorization, resp.Status)
+		}
+	}
+
+	client := &pdtpclient.Client{HTTPClient: &http.Client{Transport: bearer{"secret"}}}
+	stream, err := client.Open(context.Background(), url, "start=1;end=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	var pages []int64
+	done := false
+	for event, err := range stream.Events() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch e := event.(type) {
+		case *pdtpclient.PageDoneEvent:
+			pages = append(pages, e.Page)
+		case *pdtpclient.DoneEvent:
+			done = true
+		}
+	}
+	if !done || !slices.Equal(pages, []int64{1}) {
+		t.Errorf("completed pages = %v (done %v), want [1] and a DoneChunk", pages, done)
+	}
+}
+
+// bearer は 要求に Authorization ヘッダーを付ける
+type bearer struct {
+	token string
+}
+
+func (b bearer) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+b.token)
+	return http.DefaultTransport.RoundTrip(req)
+}
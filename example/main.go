@@ -1,10 +1,14 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
 	"log"
 	"net/http"
-	"os"
+	"path"
+	"time"
 
 	"github.com/pdtp-workbench/pdtp-go"
 )
@@ -23,24 +27,54 @@ func CORSMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
-func main() {
 
-	http.HandleFunc("/pdtp", pdtp.NewPDFProtocolHandler(
-		pdtp.Config{
-			HandleOpenPDF: func(fileName string) (pdtp.IPDFFile, error) {
-				file, err := os.Open(fileName)
-				return file, err
-			},
-			CompressionMethod: pdtp.ZstdCompression{},
-		},
-	))
-	http.HandleFunc("/default", func(w http.ResponseWriter, r *http.Request) {
-		file := r.URL.Query().Get("file")
-		http.ServeFile(w, r, file)
+// newServer は root 以下の PDF を配信する PDTP のエンドポイントと、PDF をそのまま返す /default を CORS 付きで返す
+func newServer(root string) (http.Handler, error) {
+	// root 以下の PDF だけを開く
+	openPDF := pdtp.OpenUnder(root)
+	handler, err := pdtp.NewHandler(
+		pdtp.WithOpenPDF(openPDF),
+		pdtp.WithCompression(pdtp.ZstdCompression{}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pdtp", handler)
+	mux.HandleFunc("/default", func(w http.ResponseWriter, r *http.Request) {
+		// /pdtp と同じく root の外のファイルと PDF 以外のファイルは返さない
+		fileName := r.URL.Query().Get("file")
+		file, err := openPDF(r.Context(), r, fileName)
+		if errors.Is(err, pdtp.ErrInvalidFileName) {
+			http.Error(w, "invalid file name", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "file not found", http.StatusNotFound)
+			return
+		}
+		defer file.Close()
+		var modTime time.Time
+		if f, ok := file.(interface{ Stat() (fs.FileInfo, error) }); ok {
+			if info, err := f.Stat(); err == nil {
+				modTime = info.ModTime()
+			}
+		}
+		http.ServeContent(w, r, path.Base(fileName), modTime, file)
 	})
+	return CORSMiddleware(mux), nil
+}
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	root := flag.String("root", ".", "directory containing the PDF files")
+	flag.Parse()
 
-	corsHandler := CORSMiddleware(http.DefaultServeMux)
+	server, err := newServer(*root)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	fmt.Println("PDF Protocol Server listening on http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", corsHandler))
+	fmt.Printf("PDF Protocol Server listening on %s\n", *addr)
+	log.Fatal(http.ListenAndServe(*addr, server))
 }
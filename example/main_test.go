@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/pdtp-workbench/pdtp-go/pdtpclient"
+)
+
+// TestServer は /pdtp のストリームと /default の PDF、CORS のプリフライトを確かめる
+// /default は root の外のファイルと PDF 以外のファイルを返さない
+func TestServer(t *testing.T) {
+	handler, err := newServer(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	t.Run("pdtp", func(t *testing.T) {
+		pages := readStream(t, server.URL+"/pdtp?file=example.pdf", "start=1;end=1")
+		if !slices.Equal(pages, []int64{1}) {
+			t.Errorf("completed pages = %v, want [1]", pages)
+		}
+	})
+
+	t.Run("default", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/default?file=example.pdf")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		got, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := os.ReadFile("example.pdf")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK || !bytes.Equal(got, want) {
+			t.Errorf("status %s, %d bytes; want the %d bytes of example.pdf", resp.Status, len(got), len(want))
+		}
+	})
+
+	t.Run("default outside root", func(t *testing.T) {
+		for _, file := range []string{"../go.mod", "/etc/passwd", "main.go", "missing.pdf"} {
+			resp, err := http.Get(server.URL + "/default?file=" + url.QueryEscape(file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest && resp.StatusCode != http.StatusNotFound {
+				t.Errorf("file=%s: status %s, want 400 or 404", file, resp.Status)
+			}
+		}
+	})
+
+	t.Run("preflight", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodOptions, server.URL+"/pdtp", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("Access-Control-Allow-Headers") != "Content-Type,Pdtp" {
+			t.Errorf("status %s, allowed headers %q", resp.Status, resp.Header.Get("Access-Control-Allow-Headers"))
+		}
+	})
+}
+
+// readStream は ストリームを最後まで読み、PageDoneChunk を受け取ったページを昇順で返す
+func readStream(t *testing.T, url, field string) []int64 {
+	t.Helper()
+	stream, err := (&pdtpclient.Client{}).Open(context.Background(), url, field)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	var pages []int64
+	done := false
+	for event, err := range stream.Events() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch e := event.(type) {
+		case *pdtpclient.PageDoneEvent:
+			pages = append(pages, e.Page)
+		case *pdtpclient.DoneEvent:
+			done = true
+		}
+	}
+	if !done {
+		t.Fatal("stream ended without a DoneChunk")
+	}
+	slices.Sort(pages)
+	return pages
+}
@@ -0,0 +1,233 @@
+// proxy は PDTP サーバーの前段に置くリバースプロキシの例
+// 要求されたページ範囲を中継したあと、続きのページ範囲を先読みしてキャッシュする
+//
+//	go run ./example -addr :8081 -root ./example &
+//	go run ./example/proxy -upstream http://localhost:8081
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxCachedResponse は キャッシュするレスポンスのサイズの上限
+const maxCachedResponse = 32 << 20
+
+// maxCacheEntries は キャッシュする件数の上限
+const maxCacheEntries = 64
+
+type cachedResponse struct {
+	header http.Header
+	body   []byte
+}
+
+// responseCache は ファイル名・ページ範囲・圧縮方式ごとのレスポンスを保持する
+type responseCache struct {
+	mu      sync.Mutex
+	entries map[string]*cachedResponse
+	keys    []string
+}
+
+func (c *responseCache) get(key string) (*cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res, ok := c.entries[key]
+	return res, ok
+}
+
+func (c *responseCache) put(key string, res *cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+	if len(c.keys) >= maxCacheEntries {
+		delete(c.entries, c.keys[0])
+		c.keys = c.keys[1:]
+	}
+	c.entries[key] = res
+	c.keys = append(c.keys, key)
+}
+
+// pageRange は Pdtp ヘッダーの start と end を読む
+func pageRange(field string) (start, end int64) {
+	start, end = 1, -1
+	for _, f := range strings.Split(strings.Trim(field, ";"), ";") {
+		kv := strings.Split(f, "=")
+		if len(kv) != 2 {
+			continue
+		}
+		v, err := strconv.ParseInt(kv[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch kv[0] {
+		case "start":
+			start = v
+		case "end":
+			end = v
+		}
+	}
+	return start, end
+}
+
+// withPageRange は Pdtp ヘッダーの start と end を置き換え、それ以外の指定は残す
+func withPageRange(field string, start, end int64) string {
+	fields := []string{fmt.Sprintf("start=%d", start), fmt.Sprintf("end=%d", end)}
+	for _, f := range strings.Split(strings.Trim(field, ";"), ";") {
+		if f == "" || strings.HasPrefix(f, "start=") || strings.HasPrefix(f, "end=") {
+			continue
+		}
+		fields = append(fields, f)
+	}
+	return strings.Join(fields, ";")
+}
+
+func cacheKey(r *http.Request) string {
+	return r.URL.RawQuery + "|" + r.Header.Get("Pdtp") + "|" + r.Header.Get("Accept-Encoding")
+}
+
+// cacheBody は 中継し終えたレスポンスをキャッシュに入れる
+type cacheBody struct {
+	io.ReadCloser
+	buf     bytes.Buffer
+	onClose func(body []byte)
+	done    bool
+}
+
+func (c *cacheBody) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	if c.buf.Len()+n <= maxCachedResponse {
+		c.buf.Write(p[:n])
+	} else {
+		c.onClose = nil
+	}
+	if err == io.EOF {
+		c.done = true
+	}
+	return n, err
+}
+
+func (c *cacheBody) Close() error {
+	if c.done && c.onClose != nil {
+		c.onClose(c.buf.Bytes())
+	}
+	return c.ReadCloser.Close()
+}
+
+type prefetchProxy struct {
+	upstream *url.URL
+	proxy    *httputil.ReverseProxy
+	cache    *responseCache
+	client   *http.Client
+}
+
+func (p *prefetchProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key := cacheKey(r)
+	if res, ok := p.cache.get(key); ok {
+		for name, values := range res.header {
+			w.Header()[name] = values
+		}
+		w.Header().Set("X-Pdtp-Cache", "hit")
+		w.Write(res.body)
+	} else {
+		p.proxy.ServeHTTP(w, r)
+	}
+
+	// 続きのページ範囲を先読みする (範囲の長さは要求と同じ)
+	start, end := pageRange(r.Header.Get("Pdtp"))
+	if end < start {
+		return
+	}
+	next := r.Clone(r.Context())
+	next.Header.Set("Pdtp", withPageRange(r.Header.Get("Pdtp"), end+1, end+1+(end-start)))
+	go p.prefetch(next)
+}
+
+func (p *prefetchProxy) prefetch(r *http.Request) {
+	key := cacheKey(r)
+	if _, ok := p.cache.get(key); ok {
+		return
+	}
+	u := *p.upstream
+	u.Path = r.URL.Path
+	u.RawQuery = r.URL.RawQuery
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return
+	}
+	for _, name := range []string{"Pdtp", "Accept-Encoding"} {
+		req.Header.Set(name, r.Header.Get(name))
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		log.Println("Prefetch error:", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCachedResponse+1))
+	if err != nil || len(body) > maxCachedResponse {
+		return
+	}
+	p.cache.put(key, &cachedResponse{header: resp.Header.Clone(), body: body})
+}
+
+func newPrefetchProxy(upstream *url.URL) *prefetchProxy {
+	// 圧縮されたまま中継・保存するため、自動で展開させない
+	transport := &http.Transport{DisableCompression: true}
+	p := &prefetchProxy{
+		upstream: upstream,
+		cache:    &responseCache{entries: map[string]*cachedResponse{}},
+		client:   &http.Client{Transport: transport},
+	}
+	p.proxy = &httputil.ReverseProxy{
+		Transport: transport,
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(upstream)
+		},
+		// チャンクが届くたびにクライアントへ送る
+		FlushInterval: -1,
+		ModifyResponse: func(resp *http.Response) error {
+			if resp.StatusCode != http.StatusOK {
+				return nil
+			}
+			key := cacheKey(resp.Request)
+			header := resp.Header.Clone()
+			resp.Body = &cacheBody{
+				ReadCloser: resp.Body,
+				onClose: func(body []byte) {
+					p.cache.put(key, &cachedResponse{header: header, body: bytes.Clone(body)})
+				},
+			}
+			return nil
+		},
+	}
+	return p
+}
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	upstream := flag.String("upstream", "http://localhost:8081", "URL of the PDTP server")
+	flag.Parse()
+
+	upstreamURL, err := url.Parse(*upstream)
+	if err != nil {
+		log.Fatal(err)
+	}
+	http.Handle("/pdtp", newPrefetchProxy(upstreamURL))
+
+	fmt.Printf("PDTP proxy listening on %s (upstream %s)\n", *addr, upstreamURL)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
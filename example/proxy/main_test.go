@@ -0,0 +1,101 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/pdtp-workbench/pdtp-go"
+	"github.com/pdtp-workbench/pdtp-go/pdtpclient"
+)
+
+// TestPrefetchProxy は 要求したページ範囲を中継し、続きの範囲を先読みしてキャッシュから返すことを確かめる
+func TestPrefetchProxy(t *testing.T) {
+	upstream := httptest.NewServer(pdtp.NewPDFProtocolHandler(pdtp.Config{
+		OpenPDF:           pdtp.OpenUnder("../../cmd/pdtp/testdata/conform"),
+		CompressionMethod: pdtp.GzipCompression{},
+	}))
+	defer upstream.Close()
+	upstreamURL, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := newPrefetchProxy(upstreamURL)
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+	fileURL := server.URL + "/pdtp?file=multipage.pdf"
+
+	// 中継したレスポンス
+	stream, err := (&pdtpclient.Client{}).Open(context.Background(), fileURL, "start=1;end=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pages := completedPages(t, stream); !slices.Equal(pages, []int64{1}) {
+		t.Errorf("relayed pages = %v, want [1]", pages)
+	}
+
+	// 続きの範囲 (2ページ目) が先読みされるのを待つ
+	next, err := http.NewRequest(http.MethodGet, fileURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next.Header.Set("Pdtp", "start=2;end=2")
+	// 先読みの要求は元の要求 (pdtpclient) の Accept-Encoding を引き継ぐ
+	next.Header.Set("Accept-Encoding", "zstd, gzip")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := proxy.cache.get(cacheKey(next)); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the next page range was not prefetched")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// 先読みしたレスポンスは圧縮したまま返す
+	resp, err := http.DefaultClient.Do(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("X-Pdtp-Cache") != "hit" || resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("X-Pdtp-Cache %q, Content-Encoding %q; want a gzip response from the cache",
+			resp.Header.Get("X-Pdtp-Cache"), resp.Header.Get("Content-Encoding"))
+	}
+	body, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pages := completedPages(t, pdtpclient.NewStream(body, pdtp.ProtocolVersion1, pdtp.ChunkEncodingJSON)); !slices.Equal(pages, []int64{2}) {
+		t.Errorf("cached pages = %v, want [2]", pages)
+	}
+}
+
+// completedPages は ストリームを最後まで読み、PageDoneChunk を受け取ったページを返す
+func completedPages(t *testing.T, stream *pdtpclient.Stream) []int64 {
+	t.Helper()
+	defer stream.Close()
+	var pages []int64
+	done := false
+	for event, err := range stream.Events() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch e := event.(type) {
+		case *pdtpclient.PageDoneEvent:
+			pages = append(pages, e.Page)
+		case *pdtpclient.DoneEvent:
+			done = true
+		}
+	}
+	if !done {
+		t.Fatal("stream ended without a DoneChunk")
+	}
+	return pages
+}
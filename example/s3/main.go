@@ -0,0 +1,64 @@
+// s3 は S3 互換のストレージに置いた PDF を配信する例
+//
+//	AWS_ACCESS_KEY_ID=... AWS_SECRET_ACCESS_KEY=... go run ./example/s3 -bucket documents -region ap-northeast-1
+//
+// PDF を丸ごとダウンロードせず、範囲を指定した GET で解析に必要な部分だけを読み込む
+// MinIO などは -endpoint と -path-style を指定する。認証情報がない場合は署名せずに要求する (公開バケット)
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pdtp-workbench/pdtp-go"
+	"github.com/pdtp-workbench/pdtp-go/source"
+)
+
+// newServer は config のバケットの PDF を配信するハンドラーを返す
+func newServer(config source.S3Config) (http.Handler, error) {
+	storage, err := source.NewS3(config)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pdtp", pdtp.NewPDFProtocolHandler(
+		pdtp.Config{
+			OpenPDF:           storage.OpenPDF,
+			CompressionMethod: pdtp.ZstdCompression{},
+			MaxWarnings:       20,
+		},
+	))
+	return mux, nil
+}
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	endpoint := flag.String("endpoint", "", "storage URL (default: AWS S3 in the region)")
+	region := flag.String("region", "us-east-1", "region used for signing")
+	bucket := flag.String("bucket", "", "bucket containing the PDF files")
+	prefix := flag.String("prefix", "", "key prefix the files are served from")
+	pathStyle := flag.Bool("path-style", false, "put the bucket in the path instead of the host name")
+	flag.Parse()
+
+	server, err := newServer(source.S3Config{
+		Endpoint:        *endpoint,
+		Region:          *region,
+		Bucket:          *bucket,
+		Prefix:          *prefix,
+		PathStyle:       *pathStyle,
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		HTTPClient:      &http.Client{Timeout: 30 * time.Second},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("PDF Protocol Server listening on %s (bucket %s)\n", *addr, *bucket)
+	log.Fatal(http.ListenAndServe(*addr, server))
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pdtp-workbench/pdtp-go/pdtpclient"
+	"github.com/pdtp-workbench/pdtp-go/source"
+)
+
+// TestServer は 範囲を指定した GET に答えるバケットの PDF を、署名した要求で読み込んで配信できることを確かめる
+func TestServer(t *testing.T) {
+	pdf, err := os.ReadFile("../example.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now()
+	var mu sync.Mutex
+	var unsigned, ranges int
+	bucket := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+			unsigned++
+		}
+		if r.Header.Get("Range") != "" {
+			ranges++
+		}
+		mu.Unlock()
+		if r.URL.Path != "/documents/pdf/example.pdf" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", `"example"`)
+		http.ServeContent(w, r, "example.pdf", modTime, strings.NewReader(string(pdf)))
+	}))
+	defer bucket.Close()
+
+	handler, err := newServer(source.S3Config{
+		Endpoint:        bucket.URL,
+		Bucket:          "documents",
+		Prefix:          "pdf/",
+		PathStyle:       true,
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	stream, err := (&pdtpclient.Client{}).Open(context.Background(), server.URL+"/pdtp?file=example.pdf", "start=1;end=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	var pages []int64
+	done := false
+	for event, err := range stream.Events() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch e := event.(type) {
+		case *pdtpclient.PageDoneEvent:
+			pages = append(pages, e.Page)
+		case *pdtpclient.DoneEvent:
+			done = true
+		}
+	}
+	if !done || !slices.Equal(pages, []int64{1}) {
+		t.Errorf("completed pages = %v (done %v), want [1] and a DoneChunk", pages, done)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if ranges == 0 || unsigned > 0 {
+		t.Errorf("bucket received %d range requests and %d unsigned requests", ranges, unsigned)
+	}
+}
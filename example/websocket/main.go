@@ -0,0 +1,319 @@
+// websocket は HTTP のストリームの代わりに WebSocket でチャンクを送る例
+// 1つのチャンクを1つのバイナリメッセージとして送る
+//
+//	go run ./example/websocket -root ./example
+//	new WebSocket("ws://localhost:8080/pdtp?file=example.pdf&start=1&end=3")
+//
+// ブラウザの WebSocket はリクエストヘッダーを付けられないため、ページの範囲はクエリで受け取る
+//
+// クライアントはスクロールしたときに表示しているページをテキストメッセージで知らせる
+// サーバーはまだ送っていない画像をそのページに近い順に送り直す
+//
+//	socket.send(JSON.stringify({base: 5}))
+//
+// window を指定すると、クライアントが受け取りを確認するまで画像をその数までしか送らない
+// クライアントは処理した画像の数を知らせる
+//
+//	new WebSocket("ws://localhost:8080/pdtp?file=example.pdf&window=4")
+//	socket.send(JSON.stringify({ack: 1}))
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pdtp-workbench/pdtp-go"
+)
+
+// websocketGUID は RFC 6455 で定められたハンドシェイク用の値
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opText   = 0x1
+	opBinary = 0x2
+	opClose  = 0x8
+)
+
+// maxControlMessageSize は クライアントから受け取るメッセージの大きさの上限
+const maxControlMessageSize = 4096
+
+// closeTimeout は Close フレームを送ったあと、クライアントの Close フレームを待つ時間
+const closeTimeout = 5 * time.Second
+
+// messageWriter は Flush ごとに書かれたデータを1つのバイナリメッセージとして送る
+// 各チャンクは最後に1度だけ Flush するため、1チャンクが1メッセージになる
+type messageWriter struct {
+	conn *bufio.ReadWriter
+	buf  bytes.Buffer
+}
+
+func (m *messageWriter) Write(p []byte) (int, error) {
+	return m.buf.Write(p)
+}
+
+func (m *messageWriter) Flush() error {
+	if m.buf.Len() == 0 {
+		return nil
+	}
+	defer m.buf.Reset()
+	return m.writeFrame(opBinary, m.buf.Bytes())
+}
+
+// Close は 正常終了 (1000) の Close フレームを送る
+func (m *messageWriter) Close() error {
+	if err := m.Flush(); err != nil {
+		return err
+	}
+	return m.writeFrame(opClose, []byte{0x03, 0xE8})
+}
+
+func (m *messageWriter) writeFrame(opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode}
+	switch length := len(payload); {
+	case length < 126:
+		header = append(header, byte(length))
+	case length <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+	if _, err := m.conn.Write(header); err != nil {
+		return err
+	}
+	if _, err := m.conn.Write(payload); err != nil {
+		return err
+	}
+	return m.conn.Flush()
+}
+
+// readFrame は クライアントのフレームを1つ読み込み、マスクを外したペイロードを返す
+func readFrame(r *bufio.Reader) (byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	opcode := header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		buf := make([]byte, 2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(buf))
+	case 127:
+		buf := make([]byte, 8)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(buf)
+	}
+	if length > maxControlMessageSize {
+		return 0, nil, fmt.Errorf("message too large: %d bytes", length)
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return opcode, payload, nil
+}
+
+// controlMessage は クライアントが表示しているページ・受け取った画像の数を知らせるメッセージ
+type controlMessage struct {
+	Base int64 `json:"base,omitempty"`
+	Ack  int   `json:"ack,omitempty"`
+}
+
+// readControl は クライアントのメッセージを読み、表示しているページをパーサーに、受け取った画像の数を window に知らせる
+// Close フレームを受け取るか切断されたら戻る
+func readControl(r *bufio.Reader, pp *pdtp.PDFParser, window *pdtp.AckWindow) {
+	for {
+		opcode, payload, err := readFrame(r)
+		if err != nil || opcode == opClose {
+			return
+		}
+		if opcode != opText {
+			continue
+		}
+		var msg controlMessage
+		if err := json.Unmarshal(payload, &msg); err != nil || (msg.Base < 1 && msg.Ack < 1) {
+			log.Printf("Invalid control message: %q", payload)
+			continue
+		}
+		if msg.Base >= 1 {
+			pp.SetViewBase(msg.Base)
+		}
+		if msg.Ack >= 1 && window != nil {
+			window.Ack(msg.Ack)
+		}
+	}
+}
+
+type noopFlusher struct{}
+
+func (noopFlusher) Flush() {}
+
+// upgrade は WebSocket のハンドシェイクを行い、接続を取り出す
+func upgrade(w http.ResponseWriter, r *http.Request) (io.Closer, *bufio.ReadWriter, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || key == "" {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return nil, nil, fmt.Errorf("not a websocket request")
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, nil, fmt.Errorf("response writer cannot hijack")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: %s\r\n\r\n", base64.StdEncoding.EncodeToString(sum[:]))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, rw, nil
+}
+
+func queryInt(r *http.Request, name string, defaultValue int64) int64 {
+	v, err := strconv.ParseInt(r.URL.Query().Get(name), 10, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return v
+}
+
+// newHandler は root 以下の PDF のチャンクを WebSocket で送るハンドラーを返す
+func newHandler(root string) http.HandlerFunc {
+	openPDF := pdtp.OpenUnder(root)
+	return func(w http.ResponseWriter, r *http.Request) {
+		handle(w, r, openPDF)
+	}
+}
+
+func handle(w http.ResponseWriter, r *http.Request, openPDF pdtp.OpenPDFFunc) {
+	fileName := r.URL.Query().Get("file")
+	if fileName == "" {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+	start, end := queryInt(r, "start", 1), queryInt(r, "end", -1)
+	var window *pdtp.AckWindow
+	if size := queryInt(r, "window", 0); size > 0 {
+		window = pdtp.NewAckWindow(int(size))
+	}
+
+	// 開けないファイルは、接続を切り替える前に HTTP のエラーで返す
+	file, err := openPDF(r.Context(), r, fileName)
+	if errors.Is(err, pdtp.ErrInvalidFileName) {
+		http.Error(w, "invalid file name", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return
+	}
+
+	conn, rw, err := upgrade(w, r)
+	if err != nil {
+		log.Println("Upgrade error:", err)
+		file.Close()
+		return
+	}
+	defer conn.Close()
+
+	pp, err := pdtp.NewPDFParserWithConfig(func() (pdtp.IPDFFile, error) {
+		return file, nil
+	}, pdtp.ParserConfig{PrioritizeImages: true})
+	if err != nil {
+		log.Println("Parser error:", err)
+		return
+	}
+	defer pp.Close()
+
+	// Close フレームを受け取るか切断されたら解析を止める
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	controlDone := make(chan struct{})
+	go func() {
+		readControl(rw.Reader, pp, window)
+		cancel()
+		close(controlDone)
+	}()
+
+	mw := &messageWriter{conn: rw}
+	send := func(ctx context.Context, data pdtp.ParsedData) error {
+		return pdtp.WriteChunk(data, mw, noopFlusher{})
+	}
+	if window != nil {
+		send = pdtp.FlowControl(window)(send)
+	}
+	var sendErr error
+	err = pp.StreamPageContents(ctx, start, end, 1, func(data pdtp.ParsedData) {
+		if sendErr != nil {
+			return
+		}
+		if sendErr = send(ctx, data); sendErr != nil {
+			cancel()
+		}
+	})
+	if err != nil {
+		log.Println("Parser error:", err)
+	}
+	if sendErr != nil {
+		log.Println("Send chunk error:", sendErr)
+		return
+	}
+	mw.Close()
+	// クライアントの Close フレームを待ってから切断する
+	// 読んでいないメッセージを残したまま切断すると、送ったメッセージより先にリセットが届くことがある
+	select {
+	case <-controlDone:
+	case <-time.After(closeTimeout):
+	}
+}
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen address")
+	root := flag.String("root", ".", "directory containing the PDF files")
+	flag.Parse()
+
+	http.HandleFunc("/pdtp", newHandler(*root))
+
+	fmt.Printf("PDF Protocol WebSocket server listening on %s\n", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/pdtp-workbench/pdtp-go"
+	"github.com/pdtp-workbench/pdtp-go/pdtpclient"
+)
+
+// TestHandle は ハンドシェイクのあと1チャンクを1メッセージとして送り、Close フレームで終えることを確かめる
+func TestHandle(t *testing.T) {
+	server := httptest.NewServer(newHandler(".."))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/pdtp?file=example.pdf&start=1&end=1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	// RFC 6455 の例のキー
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("status %s, Sec-WebSocket-Accept %q", resp.Status, resp.Header.Get("Sec-WebSocket-Accept"))
+	}
+
+	// 表示しているページを知らせる (クライアントのフレームはマスクする)
+	if err := writeMaskedFrame(conn, opText, []byte(`{"base":1}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	var pages []int64
+	done := false
+	for {
+		opcode, payload, err := readServerFrame(r)
+		if err != nil {
+			t.Fatalf("reading frame: %v", err)
+		}
+		if opcode == opClose {
+			if !bytes.Equal(payload, []byte{0x03, 0xE8}) {
+				t.Errorf("close payload = %x, want 03e8", payload)
+			}
+			// サーバーはクライアントの Close フレームを待ってから切断する
+			if err := writeMaskedFrame(conn, opClose, payload); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := r.ReadByte(); err != io.EOF {
+				t.Errorf("connection still open after the closing handshake (%v)", err)
+			}
+			break
+		}
+		if opcode != opBinary {
+			t.Fatalf("unexpected opcode %#x", opcode)
+		}
+		stream := pdtpclient.NewStream(bytes.NewReader(payload), pdtp.ProtocolVersion1, pdtp.ChunkEncodingJSON)
+		event, err := stream.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := stream.Next(); err != io.EOF {
+			t.Fatalf("message holds more than one chunk (%v)", err)
+		}
+		switch e := event.(type) {
+		case *pdtpclient.PageDoneEvent:
+			pages = append(pages, e.Page)
+		case *pdtpclient.DoneEvent:
+			done = true
+		}
+	}
+	if !done || !slices.Equal(pages, []int64{1}) {
+		t.Errorf("completed pages = %v (done %v), want [1] and a DoneChunk", pages, done)
+	}
+}
+
+// TestHandleRejects は 開けないファイルと WebSocket でない要求を、接続を切り替えずに断ることを確かめる
+func TestHandleRejects(t *testing.T) {
+	handler := newHandler("..")
+	for _, test := range []struct {
+		query string
+		want  int
+	}{
+		{"file=example.pdf", http.StatusBadRequest}, // Upgrade ヘッダーがない
+		{"file=../go.mod", http.StatusBadRequest},
+		{"file=main.go", http.StatusBadRequest},
+		{"file=missing.pdf", http.StatusNotFound},
+		{"", http.StatusBadRequest},
+	} {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, "/pdtp?"+test.query, nil))
+		if rec.Code != test.want {
+			t.Errorf("%s: status = %d, want %d", test.query, rec.Code, test.want)
+		}
+	}
+}
+
+// readServerFrame は サーバーのフレーム (マスクなし) を1つ読み込む
+func readServerFrame(r *bufio.Reader) (byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		buf := make([]byte, 2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(buf))
+	case 127:
+		buf := make([]byte, 8)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(buf)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[0] & 0x0F, payload, nil
+}
+
+// writeMaskedFrame は クライアントのフレームとして payload をマスクして送る (125 バイトまで)
+func writeMaskedFrame(w io.Writer, opcode byte, payload []byte) error {
+	mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := w.Write(frame)
+	return err
+}
@@ -0,0 +1,75 @@
+package pdtp
+
+import (
+	"context"
+	"sync"
+)
+
+// AckWindow は クライアントが受け取りを確認していない画像チャンクの数を制限する
+// 遅いクライアントに画像を送り続けて、途中のバッファが膨らむのを防ぐ
+// クライアントは WebSocket のメッセージなど別の経路で確認を返し、サーバーが Ack を呼ぶ
+type AckWindow struct {
+	mu      sync.Mutex
+	size    int
+	pending int
+	changed chan struct{}
+}
+
+// NewAckWindow は 確認を待たずに送れる画像チャンクを size 個までにする AckWindow を作成する
+// size が 0 以下の場合は 1 にする
+func NewAckWindow(size int) *AckWindow {
+	return &AckWindow{size: max(size, 1), changed: make(chan struct{})}
+}
+
+// Ack は クライアントが n 個の画像チャンクを受け取ったことを記録し、待っている送信を再開する
+func (a *AckWindow) Ack(n int) {
+	if n <= 0 {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pending = max(a.pending-n, 0)
+	close(a.changed)
+	a.changed = make(chan struct{})
+}
+
+// Pending は 確認を待っている画像チャンクの数を返す
+func (a *AckWindow) Pending() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.pending
+}
+
+// acquire は 送れる数に空きができるまで待ち、1つ使う
+func (a *AckWindow) acquire(ctx context.Context) error {
+	for {
+		a.mu.Lock()
+		if a.pending < a.size {
+			a.pending++
+			a.mu.Unlock()
+			return nil
+		}
+		changed := a.changed
+		a.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// FlowControl は 画像チャンクを送る前に、window に空きができるまで待つミドルウェア
+// 待っている間は解析も止まる。コンテキストが終了した場合はそのエラーを返す
+func FlowControl(window *AckWindow) Middleware {
+	return func(next PDTPHandler) PDTPHandler {
+		return func(ctx context.Context, data ParsedData) error {
+			if _, ok := data.(*ParsedImage); ok {
+				if err := window.acquire(ctx); err != nil {
+					return err
+				}
+			}
+			return next(ctx, data)
+		}
+	}
+}
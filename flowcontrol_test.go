@@ -0,0 +1,83 @@
+package pdtp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestFlowControl は AckWindow に空きがない間は次の画像チャンクを送らず、Ack で送信を再開することを確かめる
+func TestFlowControl(t *testing.T) {
+	dir := t.TempDir()
+	writeImagePDF(t, filepath.Join(dir, "images.pdf"), 3, 8, 8)
+	window := NewAckWindow(1)
+	server := httptest.NewServer(NewPDFProtocolHandler(Config{
+		OpenPDF:     OpenUnder(dir),
+		Middlewares: []Middleware{FlowControl(window)},
+	}))
+	defer server.Close()
+	resp, err := http.Get(server.URL + "?file=images.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// 受け取った画像チャンクと、ストリームの終わり (done) を知らせる
+	received := make(chan byte)
+	errs := make(chan error, 1)
+	go func() {
+		chunks := NewChunkReader(resp.Body, ProtocolVersion1)
+		for {
+			chunk, err := chunks.Next()
+			if err != nil {
+				if err != io.EOF {
+					errs <- err
+				}
+				close(received)
+				return
+			}
+			if chunk.Type == DataTypeImage || chunk.Type == DataTypeDone {
+				received <- chunk.Type
+			}
+		}
+	}()
+	next := func() byte {
+		t.Helper()
+		select {
+		case dataType := <-received:
+			return dataType
+		case err := <-errs:
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no chunk within 5s")
+		}
+		return 0
+	}
+
+	for i := range 3 {
+		if got := next(); got != DataTypeImage {
+			t.Fatalf("chunk %d: type %#02x, want image", i, got)
+		}
+		if pending := window.Pending(); pending != 1 {
+			t.Errorf("image %d: %d pending, want 1", i, pending)
+		}
+		if i < 2 {
+			// 確認するまで次の画像は届かない
+			select {
+			case dataType := <-received:
+				t.Fatalf("image %d: received %#02x before the ack", i, dataType)
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+		window.Ack(1)
+	}
+	if got := next(); got != DataTypeDone {
+		t.Errorf("last chunk type %#02x, want done", got)
+	}
+	if pending := window.Pending(); pending != 0 {
+		t.Errorf("%d pending after the stream, want 0", pending)
+	}
+}
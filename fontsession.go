@@ -0,0 +1,61 @@
+package pdtp
+
+import (
+	"container/list"
+	"sync"
+)
+
+// FontSessions は クライアントごと・文書ごとに、送ったフォントを要求をまたいで記録する
+// クライアントは Pdtp ヘッダーの session で識別する。指定がなければ要求をまたいだ記録はしない
+// (リバースプロキシの1つの接続には複数のクライアントの要求が流れるため、接続では識別できない)
+// 記録は件数に上限があり、最近使われていないものから捨てる (捨てた後はフォントを送り直す)
+type FontSessions struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // 先頭ほど最近使われた
+	entries  map[string]*list.Element
+}
+
+type fontSessionEntry struct {
+	key   string
+	fonts *DeliveredFonts
+}
+
+// NewFontSessions は capacity 件の (クライアント, 文書) の組まで記録する FontSessions を作る (0 以下は上限なし)
+func NewFontSessions(capacity int) *FontSessions {
+	if capacity < 0 {
+		capacity = 0
+	}
+	return &FontSessions{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// deliveredFonts は クライアントと文書に対応する記録を返す (なければ作る)
+func (s *FontSessions) deliveredFonts(session, fileName string) *DeliveredFonts {
+	key := session + "\x00" + fileName
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok {
+		s.order.MoveToFront(e)
+		return e.Value.(*fontSessionEntry).fonts
+	}
+	fonts := NewDeliveredFonts()
+	s.entries[key] = s.order.PushFront(&fontSessionEntry{key: key, fonts: fonts})
+	for s.capacity > 0 && s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*fontSessionEntry).key)
+	}
+	return fonts
+}
+
+// Len は 記録している (クライアント, 文書) の組の数を返す
+func (s *FontSessions) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
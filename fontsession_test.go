@@ -0,0 +1,77 @@
+package pdtp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestFontSessions は 同じ session の要求で、書き込めたフォントだけを送り直さないことを確かめる
+// フォントを送る途中で止まった要求の後は、次の要求でフォントを送り直す
+func TestFontSessions(t *testing.T) {
+	failFont := true
+	handler := NewPDFProtocolHandler(Config{
+		OpenPDF:      OpenUnder("cmd/pdtp/testdata/conform"),
+		FontSessions: NewFontSessions(16),
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Middlewares: []Middleware{func(next PDTPHandler) PDTPHandler {
+			return func(ctx context.Context, data ParsedData) error {
+				if _, ok := data.(*ParsedFont); ok && failFont {
+					return errors.New("font not written")
+				}
+				return next(ctx, data)
+			}
+		}},
+	})
+
+	if got := fontChunks(t, handler, "session=a"); got != 0 {
+		t.Fatalf("failed request sent %d font chunks, want 0", got)
+	}
+	failFont = false
+	if got := fontChunks(t, handler, "session=a"); got == 0 {
+		t.Fatal("font that failed to send was not sent again")
+	}
+	if got := fontChunks(t, handler, "session=a"); got != 0 {
+		t.Errorf("sent %d font chunks again in the same session, want 0", got)
+	}
+	if got := fontChunks(t, handler, "session=b"); got == 0 {
+		t.Error("another session did not get the fonts")
+	}
+}
+
+// TestFontSessionsWithoutSession は session のない要求では、同じ接続からの要求でもフォントを毎回送ることを確かめる
+// リバースプロキシの1つの接続には複数のクライアントの要求が流れる
+func TestFontSessionsWithoutSession(t *testing.T) {
+	handler := NewPDFProtocolHandler(Config{
+		OpenPDF:      OpenUnder("cmd/pdtp/testdata/conform"),
+		FontSessions: NewFontSessions(16),
+	})
+	for i := range 2 {
+		if got := fontChunks(t, handler, ""); got == 0 {
+			t.Fatalf("request %d sent no font chunks", i+1)
+		}
+	}
+}
+
+// fontChunks は 同じ送信元から text.pdf を要求し、受け取ったフォントチャンクの数を返す
+func fontChunks(t *testing.T, handler http.HandlerFunc, header string) int {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/?file=text.pdf", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	if header != "" {
+		req.Header.Set("Pdtp", header)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	fonts := 0
+	for _, chunk := range readAllChunks(t, rec.Body.Bytes(), ProtocolVersion1, ChunkEncodingJSON) {
+		if chunk.Type == DataTypeFont {
+			fonts++
+		}
+	}
+	return fonts
+}
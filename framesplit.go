@@ -0,0 +1,55 @@
+package pdtp
+
+import (
+	"encoding/binary"
+	"strconv"
+)
+
+// frameSplitter は JSON に続くバイナリが maxPayload バイトを超えるフレームを、先頭のフレームと ContinuationChunk に分けて書き込む
+// 大きな画像が1つのフレームを占めて、後ろのチャンクが届かなくなるのを防ぐ
+// 先頭のフレームの JSON には、そのフレームに含めたバイナリの長さを chunkLength として加える
+//
+//	画像 {"chunkLength": 1048576, "length": 3000000, ...} | バイナリの先頭
+//	continuation {"length": 1048576} | バイナリの続き
+//	continuation {"length": 903424} | バイナリの最後
+type frameSplitter struct {
+	bufferedFrameWriter
+	maxPayload int
+}
+
+func newFrameSplitter(w FlusherWriter, maxPayload int) *frameSplitter {
+	f := &frameSplitter{bufferedFrameWriter: bufferedFrameWriter{w: w, name: "frame splitter"}, maxPayload: maxPayload}
+	f.writeFrame = f.split
+	return f
+}
+
+// split は バイナリが maxPayload バイトを超えるフレームを分けて書き込む
+func (f *frameSplitter) split(dataType byte, meta, payload []byte) error {
+	if len(payload) <= f.maxPayload || len(meta) < 2 || meta[0] != '{' {
+		return f.writeV1(dataType, meta, payload)
+	}
+	field := `"chunkLength":` + strconv.Itoa(f.maxPayload)
+	if meta[1] != '}' {
+		field += ","
+	}
+	first := append([]byte{'{'}, field...)
+	first = append(first, meta[1:]...)
+	if err := f.writeV1(dataType, first, payload[:f.maxPayload]); err != nil {
+		return err
+	}
+	for rest := payload[f.maxPayload:]; len(rest) > 0; {
+		part := rest[:min(len(rest), f.maxPayload)]
+		rest = rest[len(part):]
+		if err := f.writeV1(DataTypeContinuation, []byte(`{"length":`+strconv.Itoa(len(part))+`}`), part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *frameSplitter) writeV1(dataType byte, meta, payload []byte) error {
+	header := make([]byte, 0, 5)
+	header = append(header, dataType)
+	header = binary.BigEndian.AppendUint32(header, uint32(len(meta)))
+	return f.writeParts(header, meta, payload)
+}
@@ -0,0 +1,210 @@
+package pdtp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestFrameSplitter は 上限を少し超えるバイナリと上限のちょうど倍数のバイナリを分けたフレームの数・長さと、読み込んでつなげた結果を確かめる
+func TestFrameSplitter(t *testing.T) {
+	const maxPayload = 100
+	for _, test := range []struct {
+		name       string
+		meta       string
+		payload    int
+		wantFrames int
+	}{
+		{"empty", `{"id":"a","length":0}`, 0, 1},
+		{"below", `{"id":"a","length":99}`, 99, 1},
+		{"equal", `{"id":"a","length":100}`, 100, 1},
+		{"just above", `{"id":"a","length":101}`, 101, 2},
+		{"twice", `{"id":"a","length":200}`, 200, 2},
+		{"three times", `{"id":"a","length":300}`, 300, 3},
+		{"above three times", `{"id":"a","length":301}`, 301, 4},
+		{"with mask", `{"id":"a","length":150,"maskLength":50}`, 200, 2},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			payload := make([]byte, test.payload)
+			for i := range payload {
+				payload[i] = byte(i)
+			}
+			out := &bufferWriter{}
+			splitter := newFrameSplitter(out, maxPayload)
+			frame := binary.BigEndian.AppendUint32([]byte{DataTypeImage}, uint32(len(test.meta)))
+			frame = append(append(frame, test.meta...), payload...)
+			if _, err := splitter.Write(frame); err != nil {
+				t.Fatal(err)
+			}
+			if err := splitter.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			// 分けたフレームを1つずつ読む
+			r := bytes.NewReader(out.Bytes())
+			var frames []*Chunk
+			for {
+				frame, err := readChunk(r, ProtocolVersion1, ChunkEncodingJSON, ChunkLimits{})
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				frames = append(frames, frame)
+			}
+			if len(frames) != test.wantFrames || out.flushes != test.wantFrames {
+				t.Fatalf("%d frames and %d flushes, want %d", len(frames), out.flushes, test.wantFrames)
+			}
+			for i, frame := range frames {
+				if want := min(maxPayload, test.payload-i*maxPayload); len(frame.Payload) != want {
+					t.Errorf("frame %d: %d bytes, want %d", i, len(frame.Payload), want)
+				}
+				if i > 0 && frame.Type != DataTypeContinuation {
+					t.Errorf("frame %d: type %s, want continuation", i, frame.TypeName())
+				}
+			}
+			if split := strings.Contains(string(frames[0].JSON), `"chunkLength":100`); split != (test.wantFrames > 1) {
+				t.Errorf("first frame JSON %s: chunkLength present = %t", frames[0].JSON, split)
+			}
+
+			// ChunkReader は ContinuationChunk をつなげて元のバイナリに戻す
+			chunks := NewChunkReader(bytes.NewReader(out.Bytes()), ProtocolVersion1)
+			chunk, err := chunks.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if chunk.Type != DataTypeImage || !bytes.Equal(chunk.Payload, payload) {
+				t.Errorf("reassembled %s chunk with %d bytes, want image with %d bytes", chunk.TypeName(), len(chunk.Payload), len(payload))
+			}
+			if _, err := chunks.Next(); err != io.EOF {
+				t.Errorf("Next() after the chunk = %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+// TestMaxFrameSize は Config.MaxFrameSize を設定したハンドラーのストリームを読み込むと、
+// 分けずに送った場合と同じチャンクになり、どのフレームのバイナリも上限を超えないことを確かめる
+func TestMaxFrameSize(t *testing.T) {
+	stream := func(config Config, header string) []byte {
+		config.OpenPDF = OpenUnder("cmd/pdtp/testdata/conform")
+		req := httptest.NewRequest(http.MethodGet, "/?file=image.pdf", nil)
+		req.Header.Set("Pdtp", header)
+		rec := httptest.NewRecorder()
+		NewPDFProtocolHandler(config)(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+		}
+		return rec.Body.Bytes()
+	}
+	want := readAllChunks(t, stream(Config{}, ""), ProtocolVersion1, ChunkEncodingJSON)
+	largest := 0
+	for _, chunk := range want {
+		largest = max(largest, len(chunk.Payload))
+	}
+	if largest < 2 {
+		t.Fatalf("largest payload is %d bytes, want a payload to split", largest)
+	}
+	for _, test := range []struct {
+		maxFrameSize int
+		header       string
+	}{
+		{largest - 1, ""},
+		{largest / 2, ""},
+		{64, ""},
+		{64, "version=2"},
+		{64, "version=3;encoding=cbor"},
+	} {
+		t.Run(fmt.Sprintf("%d %s", test.maxFrameSize, test.header), func(t *testing.T) {
+			field, err := parsePDTPField(test.header)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body := stream(Config{MaxFrameSize: test.maxFrameSize}, test.header)
+
+			// 各フレームのバイナリは上限を超えない
+			r := bytes.NewReader(body)
+			continuations := 0
+			for {
+				frame, err := readChunk(r, field.Version, field.Encoding, ChunkLimits{})
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(frame.Payload) > test.maxFrameSize {
+					t.Errorf("%s frame carries %d bytes, want at most %d", frame.TypeName(), len(frame.Payload), test.maxFrameSize)
+				}
+				if frame.Type == DataTypeContinuation {
+					continuations++
+				}
+			}
+			if continuations == 0 {
+				t.Error("no continuation frames")
+			}
+
+			got := readAllChunks(t, body, field.Version, field.Encoding)
+			if len(got) != len(want) {
+				t.Fatalf("%d chunks, want %d", len(got), len(want))
+			}
+			for i := range got {
+				if got[i].Type != want[i].Type || !bytes.Equal(got[i].Payload, want[i].Payload) || !sameMetadata(t, got[i].JSON, want[i].JSON) {
+					t.Errorf("chunk %d (%s) differs from the unsplit stream", i, got[i].TypeName())
+				}
+			}
+		})
+	}
+}
+
+// readAllChunks は ストリームのチャンクを最後まで読み込む
+func readAllChunks(t *testing.T, body []byte, version int, encoding ChunkEncoding) []*Chunk {
+	t.Helper()
+	chunks := NewChunkReaderWithEncoding(bytes.NewReader(body), version, encoding)
+	var all []*Chunk
+	for {
+		chunk, err := chunks.Next()
+		if err == io.EOF {
+			return all
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, chunk)
+	}
+}
+
+// sameMetadata は 分けたフレームに加えた chunkLength を除いて、メタデータが同じかを返す
+func sameMetadata(t *testing.T, got, want json.RawMessage) bool {
+	t.Helper()
+	var g, w map[string]any
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(want, &w); err != nil {
+		t.Fatal(err)
+	}
+	delete(g, "chunkLength")
+	return maps.EqualFunc(g, w, func(a, b any) bool { return fmt.Sprint(a) == fmt.Sprint(b) })
+}
+
+// bufferWriter は 書き込まれたデータを保持し、Flush の回数を数える FlusherWriter
+type bufferWriter struct {
+	bytes.Buffer
+	flushes int
+}
+
+func (b *bufferWriter) Flush() error {
+	b.flushes++
+	return nil
+}
+
+func (b *bufferWriter) Close() error { return nil }
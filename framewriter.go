@@ -0,0 +1,57 @@
+package pdtp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// bufferedFrameWriter は 書き込まれたバージョン 1 のフレームを種別・JSON・バイナリに分けて writeFrame に渡す
+// フレームを変換して書き込む Writer (CBOR・チャンクの圧縮・フレームの分割・バージョン 2/3 のヘッダー・NDJSON) はこれを埋め込む
+//
+// 各チャンクの Send はフレーム全体を書き込んでから最後に1度だけ Flush するため、
+// 前の Flush から次の Flush までに書かれたデータをちょうど1つのフレームとして扱う
+// writeFrame は変換したフレームを w に書き込み、w を Flush する
+type bufferedFrameWriter struct {
+	w          FlusherWriter
+	name       string // エラーメッセージの接頭辞
+	writeFrame func(dataType byte, meta, payload []byte) error
+	buf        bytes.Buffer
+}
+
+func (b *bufferedFrameWriter) Write(p []byte) (int, error) {
+	return b.buf.Write(p)
+}
+
+func (b *bufferedFrameWriter) Flush() error {
+	if b.buf.Len() == 0 {
+		return b.w.Flush()
+	}
+	frame := b.buf.Bytes()
+	defer b.buf.Reset()
+	if len(frame) < 5 {
+		return fmt.Errorf("%s: short frame (%d bytes)", b.name, len(frame))
+	}
+	length := int(binary.BigEndian.Uint32(frame[1:5]))
+	if 5+length > len(frame) {
+		return fmt.Errorf("%s: frame length %d exceeds %d bytes", b.name, length, len(frame)-5)
+	}
+	return b.writeFrame(frame[0], frame[5:5+length], frame[5+length:])
+}
+
+func (b *bufferedFrameWriter) Close() error {
+	if err := b.Flush(); err != nil {
+		return err
+	}
+	return b.w.Close()
+}
+
+// writeParts は parts を続けて w に書き込み、w を Flush する
+func (b *bufferedFrameWriter) writeParts(parts ...[]byte) error {
+	for _, p := range parts {
+		if _, err := b.w.Write(p); err != nil {
+			return err
+		}
+	}
+	return b.w.Flush()
+}
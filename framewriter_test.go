@@ -0,0 +1,58 @@
+package pdtp
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// TestBufferedFrameWriter は Flush までに書かれたデータを1つのフレームとして種別・JSON・バイナリに分け、
+// 何も書かれていない Flush はそのまま伝え、壊れたフレームをエラーにすることを確かめる
+func TestBufferedFrameWriter(t *testing.T) {
+	type frame struct {
+		dataType      byte
+		meta, payload string
+	}
+	out := &bufferWriter{}
+	var frames []frame
+	b := &bufferedFrameWriter{w: out, name: "test", writeFrame: func(dataType byte, meta, payload []byte) error {
+		frames = append(frames, frame{dataType, string(meta), string(payload)})
+		return out.Flush()
+	}}
+
+	// 1つのフレームを分けて書き込む
+	header := binary.BigEndian.AppendUint32([]byte{DataTypeImage}, 2)
+	for _, p := range [][]byte{header, []byte("{}"), []byte("abc")} {
+		if _, err := b.Write(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []frame{{DataTypeImage, "{}", "abc"}}; len(frames) != 1 || frames[0] != want[0] {
+		t.Errorf("frames = %+v, want %+v", frames, want)
+	}
+	if out.flushes != 2 {
+		t.Errorf("flushes = %d, want 2", out.flushes)
+	}
+
+	for _, broken := range [][]byte{
+		{DataTypeText, 0, 0},
+		binary.BigEndian.AppendUint32([]byte{DataTypeText}, 10),
+	} {
+		b.Write(broken)
+		if err := b.Flush(); err == nil {
+			t.Errorf("Flush(%v) returned no error", broken)
+		}
+		// 壊れたフレームは捨て、次のフレームに残さない
+		if b.buf.Len() != 0 {
+			t.Errorf("%d bytes left after a broken frame", b.buf.Len())
+		}
+	}
+	if out.Len() != 0 {
+		t.Errorf("wrote %q, want nothing", out.Bytes())
+	}
+}
@@ -0,0 +1,208 @@
+package pdtp_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/pdtp-workbench/pdtp-go"
+	"github.com/pdtp-workbench/pdtp-go/pdtpclient"
+)
+
+// TestFrameVersions は ハンドラーが送るバージョン 2・3 のフレームを pdtpclient で読み込み、
+// バージョン 1 と同じイベントになること、各フレームのヘッダーの連番・CRC32・可変長の長さが内容と一致することを確かめる
+func TestFrameVersions(t *testing.T) {
+	server := httptest.NewServer(pdtp.NewPDFProtocolHandler(pdtp.Config{
+		OpenPDF: pdtp.OpenUnder("cmd/pdtp/testdata/conform"),
+	}))
+	defer server.Close()
+	url := server.URL + "?file=image.pdf"
+
+	v1 := readFrames(t, url, pdtp.ProtocolVersion1)
+	want := openEvents(t, &pdtpclient.Client{}, url, "")
+	for _, version := range []int{pdtp.ProtocolVersion2, pdtp.ProtocolVersion3} {
+		if got := openEvents(t, &pdtpclient.Client{Version: version}, url, ""); !reflect.DeepEqual(got, want) {
+			t.Errorf("version %d: events differ from version 1", version)
+		}
+		frames := readFrames(t, url, version)
+		if len(frames) != len(v1) {
+			t.Fatalf("version %d: %d frames, want %d", version, len(frames), len(v1))
+		}
+		for i, frame := range frames {
+			base := v1[i]
+			length := binary.BigEndian.Uint32(base[1:5])
+			if frame[0] != base[0] {
+				t.Fatalf("version %d frame %d: type %#02x, want %#02x", version, i, frame[0], base[0])
+			}
+			switch version {
+			case pdtp.ProtocolVersion2:
+				if sequence := binary.BigEndian.Uint32(frame[1:5]); sequence != uint32(i) {
+					t.Errorf("frame %d: sequence %d", i, sequence)
+				}
+				if crc, want := binary.BigEndian.Uint32(frame[5:9]), crc32.ChecksumIEEE(frame[13:]); crc != want {
+					t.Errorf("frame %d: CRC32 %08x, want %08x", i, crc, want)
+				}
+				if got := binary.BigEndian.Uint32(frame[9:13]); got != length {
+					t.Errorf("frame %d: JSON length %d, want %d", i, got, length)
+				}
+				frame = frame[13:]
+			case pdtp.ProtocolVersion3:
+				got, n := binary.Uvarint(frame[1:])
+				if n <= 0 || got != uint64(length) {
+					t.Errorf("frame %d: JSON length %d (%d bytes), want %d", i, got, n, length)
+				}
+				if n != len(binary.AppendUvarint(nil, uint64(length))) {
+					t.Errorf("frame %d: length takes %d bytes, want the shortest varint", i, n)
+				}
+				frame = frame[1+n:]
+			}
+			if !bytes.Equal(frame, base[5:]) {
+				t.Errorf("version %d frame %d: content differs from version 1", version, i)
+			}
+		}
+	}
+}
+
+// TestSequencedFrameErrors は バージョン 2 のフレームの並べ替え・欠落・破損を ChunkReader が検出することを確かめる
+func TestSequencedFrameErrors(t *testing.T) {
+	server := httptest.NewServer(pdtp.NewPDFProtocolHandler(pdtp.Config{
+		OpenPDF: pdtp.OpenUnder("cmd/pdtp/testdata/conform"),
+	}))
+	defer server.Close()
+	frames := readFrames(t, server.URL+"?file=image.pdf", pdtp.ProtocolVersion2)
+	if len(frames) < 3 {
+		t.Fatalf("%d frames, want at least 3", len(frames))
+	}
+	corrupted := bytes.Clone(frames[1])
+	corrupted[len(corrupted)-1] ^= 0xFF
+	for _, test := range []struct {
+		name   string
+		frames [][]byte
+	}{
+		{"reordered", [][]byte{frames[0], frames[2], frames[1]}},
+		{"dropped", [][]byte{frames[0], frames[2]}},
+		{"corrupted", [][]byte{frames[0], corrupted}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			stream := pdtpclient.NewStream(bytes.NewReader(bytes.Join(test.frames, nil)), pdtp.ProtocolVersion2, pdtp.ChunkEncodingJSON)
+			var err error
+			for _, err = range stream.Events() {
+			}
+			if err == nil {
+				t.Fatal("read the frames without an error")
+			}
+		})
+	}
+}
+
+// openEvents は client で url のストリームを読み込んだイベントを返す (field は Pdtp ヘッダーの値)
+func openEvents(t *testing.T, client *pdtpclient.Client, url, field string) []pdtpclient.Event {
+	t.Helper()
+	stream, err := client.Open(context.Background(), url, field)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	var events []pdtpclient.Event
+	for event, err := range stream.Events() {
+		if err != nil {
+			t.Fatalf("version %d, encoding %q: %v", client.Version, client.Encoding, err)
+		}
+		if raw, ok := event.(*pdtpclient.RawEvent); ok {
+			// 連番はバージョン 2 にのみあり、メタデータは CBOR から変換した JSON の場合がある
+			raw.Chunk.Sequence = 0
+			var v any
+			if err := json.Unmarshal(raw.Chunk.JSON, &v); err != nil {
+				t.Fatal(err)
+			}
+			raw.Chunk.JSON, _ = json.Marshal(v)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// readFrames は url のストリームを version の形式で受け取り、ChunkReader が読んだ位置でフレームに分ける
+func readFrames(t *testing.T, url string, version int) [][]byte {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Pdtp", "version="+strconv.Itoa(version))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bytes.NewReader(body)
+	chunks := pdtp.NewChunkReader(r, version)
+	var frames [][]byte
+	for start := 0; ; {
+		if _, err := chunks.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("version %d frame %d: %v", version, len(frames), err)
+		}
+		end := len(body) - r.Len()
+		frames = append(frames, body[start:end])
+		start = end
+	}
+	return frames
+}
+
+// TestCompactFrames は version=3 の要求に Pdtp-Version: 3 を返し、JSON の長さを 1 バイトと複数バイトの可変長整数で送って
+// バージョン 1 よりストリームが短くなることを確かめる
+func TestCompactFrames(t *testing.T) {
+	server := httptest.NewServer(pdtp.NewPDFProtocolHandler(pdtp.Config{
+		OpenPDF: pdtp.OpenUnder("cmd/pdtp/testdata/conform"),
+	}))
+	defer server.Close()
+	url := server.URL + "?file=text.pdf"
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Pdtp", "version=3")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("Pdtp-Version"); got != "3" {
+		t.Errorf("Pdtp-Version = %q, want 3", got)
+	}
+
+	v1 := readFrames(t, url, pdtp.ProtocolVersion1)
+	v3 := readFrames(t, url, pdtp.ProtocolVersion3)
+	var v1Size, v3Size int
+	lengthSizes := make(map[int]int) // 可変長整数のバイト数ごとのフレームの数
+	for i, frame := range v3 {
+		_, n := binary.Uvarint(frame[1:])
+		lengthSizes[n]++
+		if len(frame) != len(v1[i])-4+n {
+			t.Errorf("frame %d: %d bytes, want %d", i, len(frame), len(v1[i])-4+n)
+		}
+		v1Size += len(v1[i])
+		v3Size += len(frame)
+	}
+	if lengthSizes[1] == 0 || lengthSizes[2] == 0 {
+		t.Errorf("frames by length size = %v, want both 1-byte and 2-byte lengths", lengthSizes)
+	}
+	if v3Size >= v1Size {
+		t.Errorf("version 3 stream is %d bytes, want fewer than the %d bytes of version 1", v3Size, v1Size)
+	}
+}
@@ -2,4 +2,7 @@ module github.com/pdtp-workbench/pdtp-go
 
 go 1.23.3
 
-require github.com/klauspost/compress v1.17.11
+require (
+	github.com/klauspost/compress v1.17.11
+	golang.org/x/text v0.21.0
+)
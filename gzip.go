@@ -2,6 +2,7 @@ package pdtp
 
 import (
 	"compress/gzip"
+	"fmt"
 	"net/http"
 )
 
@@ -16,7 +17,7 @@ func (g GzipCompression) Writer(w http.ResponseWriter) (FlusherWriter, error) {
 	gz := gzip.NewWriter(w)
 	hf, ok := w.(http.Flusher)
 	if !ok {
-		return nil, nil
+		return nil, fmt.Errorf("response writer cannot flush")
 	}
 	// TODO: /n
 	return &GzipFlusherWriter{gz: gz, hf: hf}, nil
@@ -1,87 +1,521 @@
 package pdtp
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io/fs"
+	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 )
 
-// FIXME:configにLoggerを加える場合の設計
+// Config は PDFProtocolHandler の設定 (NewHandler には With 関数の Option でも渡せる)
 type Config struct {
+	// CompressionMethod は レスポンスの圧縮方法 (nil は圧縮しない)
 	CompressionMethod CompressionMethod
-	HandleOpenPDF     func(fileName string) (IPDFFile, error)
+	// CompressionMethods は Accept-Encoding から選ぶ圧縮方法 (設定した場合は CompressionMethod より優先する)
+	// zstd > br > gzip > identity の順に、クライアントが受け付ける方法を選ぶ
+	CompressionMethods []CompressionMethod
+	// OpenPDF は 要求されたファイル名の PDF を開く (要求のコンテキストと HTTP リクエストを受け取る)
+	OpenPDF OpenPDFFunc
+	// HandleOpenPDF は ファイル名だけを受け取って PDF を開く (OpenPDF が設定されている場合は使わない)
+	//
+	// Deprecated: OpenPDF を使う。既存の関数は OpenFileFunc で変換できる
+	HandleOpenPDF func(fileName string) (IPDFFile, error)
+	// StreamLengthPolicy は ストリームの /Length が実データと食い違う場合の扱い
+	StreamLengthPolicy StreamLengthPolicy
+	// OffPagePolicy は ページの表示領域外に描画されるコマンドを送るか・印を付けるか
+	OffPagePolicy OffPagePolicy
+	// DedupRunningContent は 繰り返し現れるヘッダー・フッターを共有コンテンツとして1度だけ送る
+	DedupRunningContent bool
+	// PaintBatches は ページのチャンクを描画段階ごとにまとめ、境界チャンクを付けて送る
+	PaintBatches bool
+	// CoalesceText は 同じスタイルで隣り合うテキストを1つのチャンクにまとめる
+	CoalesceText bool
+	// GroupLines は 同じ行のテキストを1つのチャンクにまとめ、単語の区切りを付けて送る
+	GroupLines bool
+	// ImageMetadata は 画像の著作権・説明などのメタデータを画像チャンクに含める
+	ImageMetadata bool
+	// FsTypePolicy は 埋め込みが制限されたフォントを送らない・制限を外す・警告のみのいずれにするか
+	FsTypePolicy FsTypePolicy
+	// Attachments は 添付ファイルを AttachmentChunk として送る
+	Attachments bool
+	// ShapingHints は テキストチャンクに元のグリフIDとクラスターの位置を含める
+	ShapingHints bool
+	// StructureTree は タグ付き PDF のテキストチャンクに構造種別と読み順を付ける
+	StructureTree bool
+	// MaxWarnings は 1回の送信で WarningChunk として送る警告の上限 (0 は送らない)
+	MaxWarnings int
+	// ThumbnailSize は 各ページの内容より先に送るサムネイルの長辺のピクセル数 (0 は送らない)
+	ThumbnailSize int
+	// Rasterizer は サムネイルの描画方法 (nil の場合は簡易的な描画)
+	Rasterizer PageRasterizer
+	// PageFingerprint は ページチャンクにページの内容のハッシュを含める
+	PageFingerprint bool
+	// Middlewares は 各チャンクを送る前後に挟む処理 (先頭が最も外側)
+	Middlewares []Middleware
+	// FontCacheSize は パーサーが保持するフォントの件数の上限 (0 は上限なし)
+	FontCacheSize int
+	// TextPostProcessors は テキストチャンクに検索・抽出用のテキストを付ける後処理 (先頭から順に適用する)
+	TextPostProcessors []TextPostProcessor
+	// OperatorBudget は 1ページで実行する演算子の数・処理時間の上限 (超えたページは打ち切って警告を送る)
+	OperatorBudget OperatorBudget
+	// PageTimeout は 1ページの解析にかける時間の上限 (超えた場合はストリームを中止してエラーチャンクを送る。0 は上限なし)
+	PageTimeout time.Duration
+	// DocumentCache は 文書の xref テーブルとページツリーを要求をまたいで保持する (nil の場合は要求ごとに読み込む)
+	// キーはファイル名とファイルの版 (ETag、または大きさと更新日時) のため、同じキャッシュを別の OpenPDF のハンドラーと共有しない
+	DocumentCache *DocumentCache
+	// ETag は 文書の識別子 (トレーラーの /ID、ない場合はファイルの内容のハッシュ) と要求のオプションから ETag を作って返す
+	// If-None-Match が一致する場合は、チャンクを送らずに 304 Not Modified を返す
+	ETag bool
+	// Logger は 要求ごとのログの出力先 (nil の場合は slog.Default())
+	// 各要求のログには要求 ID・ファイル名・ページの範囲が付く。ミドルウェアは LoggerFromContext で取り出せる
+	Logger *slog.Logger
+	// Limits は 1つの要求で解析するページ数・送るバイナリのバイト数の上限 (超えた場合は中止し、上限の種類を含むエラーチャンクを送る)
+	Limits StreamLimits
+	// MaxConcurrentStreams は 同時に解析するストリームの数の上限 (0 は上限なし)
+	// 上限に達している場合は StreamQueueTimeout まで空きを待ち、空かなければ 429 Too Many Requests を返す
+	MaxConcurrentStreams int
+	// StreamQueueTimeout は MaxConcurrentStreams に達しているときに空きを待つ時間 (0 は待たない)
+	StreamQueueTimeout time.Duration
+	// RateLimiter は 要求ごと・送った 1MiB ごとに、クライアントの要求を受け付けるか・送信を待つかを決める (nil は制限しない)
+	RateLimiter RateLimiter
+	// MaxStreamDuration は 1つの要求でストリームを送る時間の上限 (超えた場合は中止してエラーチャンクを送る。0 は上限なし)
+	MaxStreamDuration time.Duration
+	// ProgressInterval は ProgressChunk を送る間隔 (0 は送らない)
+	ProgressInterval time.Duration
+	// MaxImagePixels は 1つの画像チャンクのピクセル数の上限 (超える画像はタイルに分割する。0 は分割しない)
+	MaxImagePixels int
+	// ICCProfiles は ICC プロファイルを ICCProfileChunk として文書ごとに1回だけ送り、画像チャンクから参照する
+	ICCProfiles bool
+	// FontFix は フォントに OS/2 テーブルを補う修正の扱い (Pdtp ヘッダーの fontfix で要求ごとに変えられる)
+	FontFix FontFixPolicy
+	// PrioritizeImages は 画像を基準ページに近いページ・ページの上にある画像から送る
+	PrioritizeImages bool
+	// FontPriority は フォントを画像より先に送るか
+	FontPriority FontPriority
+	// ChunkOrder は ページの内容のチャンクを送る順番 (空は ChunkOrderImagesLast)
+	ChunkOrder ChunkOrder
+	// PageSummary は 各ページの内容より先に、テキスト・画像・パスの件数とおおよその範囲を PageSummaryChunk として送る
+	PageSummary bool
+	// FontSessions は 同じクライアントに送ったフォントを要求をまたいで記録し、送り直さない (nil は要求の中でのみ重複を除く)
+	// クライアントは Pdtp ヘッダーの session で識別し、session のない要求では要求の中でのみ重複を除く
+	FontSessions *FontSessions
+	// DedupImages は 複数回配置される画像を1度だけ送り、2回目以降は PlacementChunk で配置のみを送る
+	DedupImages bool
+	// ImagePreviewSize は 画像を元の解像度で送る前に送る、縮小したプレビューの長辺のピクセル数 (0 は送らない)
+	ImagePreviewSize int
+	// MaxImageDim は 画像の長辺のピクセル数の上限 (超える画像は縮小して送る。0 は縮小しない)
+	// Pdtp ヘッダーの maxImageDim でこれより小さい値を要求ごとに指定できる
+	MaxImageDim int
+	// ImageTranscoder は FlateDecode・非圧縮の画像を WebP・AVIF などに変換する (nil は変換しない)
+	ImageTranscoder ImageTranscoder
+	// TranscodeQuality は ImageTranscoder に渡す品質 (1〜100。0 は 80)
+	// Pdtp ヘッダーの quality でこれより低い値を要求ごとに指定できる
+	TranscodeQuality int
+	// CropImagesToClip は 矩形のクリッピングパスの下に描かれる画像を、見える範囲に切り出してから送る
+	CropImagesToClip bool
+	// MaxFrameSize は 1つのフレームで送るバイナリのバイト数の上限 (0 は分割しない)
+	// 超える画像・フォントなどのバイナリは、残りを ContinuationChunk として続けて送る
+	MaxFrameSize int
 }
 
-func NewPDFProtocolHandler(config Config) http.HandlerFunc {
+// FontPriority は フォントチャンクを送る順番を示す
+type FontPriority int
+
+const (
+	// FontPriorityDefault は フォントを各ページの完了 (PageDoneChunk) の直前に送る (画像のあるページは画像の後になる)
+	FontPriorityDefault FontPriority = iota
+	// FontPriorityHTTP2 は HTTP/2 の接続の場合だけ、フォントを各ページのテキストの直後に送る
+	// HTTP/2 では同じ接続で他のリソースも並行して読み込むため、画像を待たずにテキストを描画できるようにする
+	FontPriorityHTTP2
+	// FontPriorityAlways は 常にフォントを各ページのテキストの直後に送る
+	FontPriorityAlways
+)
+
+// fontsFirst は 要求に対してフォントを画像より先に送るかを返す
+func (p FontPriority) fontsFirst(r *http.Request) bool {
+	switch p {
+	case FontPriorityAlways:
+		return true
+	case FontPriorityHTTP2:
+		return r.ProtoMajor >= 2
+	default:
+		return false
+	}
+}
+
+// NewPDFProtocolHandler は opts を順に適用した設定でハンドラーを作成する
+// Config をそのまま渡す従来の呼び出しも使える。設定が正しくない場合は panic する (エラーを受け取る場合は NewHandler を使う)
+func NewPDFProtocolHandler(opts ...Option) http.HandlerFunc {
+	handler, err := NewHandler(opts...)
+	if err != nil {
+		panic(err)
+	}
+	return handler
+}
+
+func newPDFProtocolHandler(config Config) http.HandlerFunc {
+	streams := newStreamLimiter(config.MaxConcurrentStreams, config.StreamQueueTimeout)
+	openPDF := config.OpenPDF
+	if openPDF == nil && config.HandleOpenPDF != nil {
+		openPDF = OpenFileFunc(config.HandleOpenPDF)
+	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
-		fw, flusher, err := CompressionMiddleware(w, r, config.CompressionMethod)
+		logger, requestID := requestLogger(config.Logger, r)
+		w.Header().Set(RequestIDHeader, requestID)
+		if config.RateLimiter != nil && !config.RateLimiter.Allow(r) {
+			logger.Warn("Rate limited")
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		if !streams.acquire(r.Context()) {
+			// 同時に解析するストリームが多すぎる場合は、チャンクを送らずに断る
+			logger.Warn("Too many streams")
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		defer streams.release()
+
+		req, reqErr := readRequest(w, r)
+		compression := config.CompressionMethod
+		if len(config.CompressionMethods) > 0 {
+			compression = NegotiateCompression(r, config.CompressionMethods)
+			if preferred := preferredCompression(req.compression, config.CompressionMethods); preferred != nil {
+				// POST の本文で指定された方法を Accept-Encoding より優先する
+				compression = preferred
+			}
+			w.Header().Add("Vary", "Accept-Encoding")
+		}
+		fw, flusher, err := CompressionMiddleware(w, r, compression)
 		if err != nil {
-			log.Println("Compression error:", err)
+			logger.Error("Compression error", "err", err)
+			return
 		}
+		// 圧縮の終端を書き込んでストリームを終える
+		defer fw.Close()
 
-		fileName := r.URL.Query().Get("file")
-		if fileName == "" || err != nil {
-			log.Println("Invalid request")
+		if reqErr != nil {
+			logger.Warn("Invalid request", "err", reqErr)
+			args := &ErrorChunkArgs{Code: http.StatusBadRequest, Message: reqErr.Error()}
+			var headerErr *PDTPHeaderError
+			if errors.As(reqErr, &headerErr) {
+				args.Field = headerErr.Field
+			}
+			sendErrorChunk(logger, w, fw, flusher, args)
+			return
+		}
+		fileName := req.file
+		if fileName == "" {
+			logger.Warn("Invalid request: file is required")
+			sendError(logger, w, fw, flusher, http.StatusBadRequest, "file is required")
 			return
 		}
-		pdtpField := r.Header.Get("pdtp")
+		logger = logger.With("file", fileName)
+		format := req.format
+		switch format {
+		case "":
+		case "ndjson":
+			// デバッグ用に各チャンクを1行の JSON として送る
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			fw = newNDJSONWriter(fw)
+		default:
+			logger.Warn("Invalid request: unknown format", "format", format)
+			sendError(logger, w, fw, flusher, http.StatusBadRequest, "unknown format")
+			return
+		}
+		field := req.field
+		logger = logger.With("start", field.Start, "end", field.End)
+		if len(field.Pages) > 0 {
+			logger = logger.With("pages", field.Pages.String())
+		}
+		if field.Version >= ProtocolVersion2 && format == "" {
+			// NDJSON 出力は行単位のため、フレームの形式を変えない
+			w.Header().Set("Pdtp-Version", strconv.Itoa(field.Version))
+			switch field.Version {
+			case ProtocolVersion2:
+				// フレームに連番と CRC32 を付ける
+				fw = newSequencedWriter(fw)
+			case ProtocolVersion3:
+				// JSON の長さを可変長整数にしてヘッダーを短くする
+				fw = newCompactWriter(fw)
+			}
+		}
+		if method, ok := compression.(ChunkCompression); ok && format == "" {
+			// フレームの内容をチャンクごとに圧縮する (連番と CRC32 は圧縮後の内容に付ける)
+			cw, err := newChunkCompressWriter(fw, method)
+			if err != nil {
+				logger.Error("Compression error", "err", err)
+				sendError(logger, w, fw, flusher, http.StatusInternalServerError, "failed to initialize compression")
+				return
+			}
+			fw = cw
+		}
+		if field.Encoding == ChunkEncodingCBOR && format == "" {
+			// フレームの JSON の部分を CBOR に変換する (連番と CRC32 は変換後の内容に付ける)
+			w.Header().Set("Pdtp-Encoding", string(field.Encoding))
+			fw = newCBORWriter(fw)
+		}
+		if config.MaxFrameSize > 0 && format == "" {
+			// 大きなバイナリを ContinuationChunk に分ける (分けたフレームをそれぞれ CBOR に変換する)
+			fw = newFrameSplitter(fw, config.MaxFrameSize)
+		}
+
+		fontFix := config.FontFix
+		if field.FontFix != "" {
+			fontFix = field.FontFix
+		}
 
-		start, end, base, err := parsePDTPField(pdtpField)
+		maxImageDim := config.MaxImageDim
+		if field.MaxImageDim > 0 && (maxImageDim == 0 || field.MaxImageDim < maxImageDim) {
+			maxImageDim = field.MaxImageDim
+		}
+		transcodeQuality := config.TranscodeQuality
+		if field.Quality > 0 && field.Quality < cmp.Or(transcodeQuality, DefaultTranscodeQuality) {
+			transcodeQuality = field.Quality
+		}
+
+		var deliveredFonts *DeliveredFonts
+		if config.FontSessions != nil && field.Session != "" && field.Resume == nil {
+			// 再開する場合は、前の接続で完了したページのフォントだけを送らない (resumeTracker)
+			deliveredFonts = config.FontSessions.deliveredFonts(field.Session, fileName)
+		}
 
 		outCh := make(chan ParsedData, 20)
-		defer close(outCh)
 
 		ctx, cancel := context.WithCancel(r.Context())
 		defer cancel()
+		if config.MaxStreamDuration > 0 {
+			var cancelTimeout context.CancelFunc
+			ctx, cancelTimeout = context.WithTimeoutCause(ctx, config.MaxStreamDuration, fmt.Errorf("%w: exceeded %s", ErrStreamTimeout, config.MaxStreamDuration))
+			defer cancelTimeout()
+		}
 
-		pp, err := NewPDFParser(func() (IPDFFile, error) {
-			file, err := config.HandleOpenPDF(fileName)
-			if err != nil {
-				return nil, err
+		parserConfig := ParserConfig{
+			StreamLengthPolicy:  config.StreamLengthPolicy,
+			OffPagePolicy:       config.OffPagePolicy,
+			DedupRunningContent: config.DedupRunningContent,
+			PaintBatches:        config.PaintBatches,
+			CoalesceText:        config.CoalesceText,
+			GroupLines:          config.GroupLines,
+			ImageMetadata:       config.ImageMetadata,
+			FsTypePolicy:        config.FsTypePolicy,
+			Attachments:         config.Attachments,
+			ShapingHints:        config.ShapingHints,
+			StructureTree:       config.StructureTree,
+			MaxWarnings:         config.MaxWarnings,
+			ThumbnailSize:       config.ThumbnailSize,
+			Rasterizer:          config.Rasterizer,
+			PageFingerprint:     config.PageFingerprint,
+			FontCacheSize:       config.FontCacheSize,
+			MaxImagePixels:      config.MaxImagePixels,
+			TextPostProcessors:  config.TextPostProcessors,
+			OperatorBudget:      config.OperatorBudget,
+			PageTimeout:         config.PageTimeout,
+			Limits:              config.Limits,
+			ProgressInterval:    config.ProgressInterval,
+			ICCProfiles:         config.ICCProfiles,
+			FontFix:             fontFix,
+			PrioritizeImages:    config.PrioritizeImages,
+			FontsFirst:          config.FontPriority.fontsFirst(r),
+			ChunkOrder:          config.ChunkOrder,
+			PageSummary:         config.PageSummary,
+			DeliveredFonts:      deliveredFonts,
+			DedupImages:         config.DedupImages,
+			ImagePreviewSize:    config.ImagePreviewSize,
+			MaxImageDim:         maxImageDim,
+			ImageTranscoder:     config.ImageTranscoder,
+			TranscodeQuality:    transcodeQuality,
+			CropImagesToClip:    config.CropImagesToClip,
+			TextOptions:         field.Text,
+			Include:             field.Include,
+			Pages:               field.Pages,
+			Logger:              logger,
+			DocumentCache:       config.DocumentCache,
+			DocumentName:        fileName,
+		}
+		// 条件付きの要求に 304 で答える場合は xref テーブルを読まないよう、識別子だけを先に読み込む
+		document, err := OpenDocument(func() (IPDFFile, error) {
+			return openPDF(ctx, r, fileName)
+		}, parserConfig)
+		if err != nil {
+			logger.Error("Parser error", "err", err)
+			code := errorCode(err)
+			sendError(logger, w, fw, flusher, code, errorMessage(code, err))
+			return
+		}
+		documentID, err := document.ID()
+		if err != nil {
+			logger.Warn("Document ID error", "err", err)
+		}
+		if config.ETag && err == nil {
+			compressionName := IdentityCompression{}.Name()
+			if compression != nil {
+				compressionName = compression.Name()
 			}
-			return file, nil
-
-		})
+			etag := responseETag(documentID, req.variant, compressionName)
+			w.Header().Set("ETag", etag)
+			w.Header().Add("Vary", "Pdtp")
+			if r.Method != http.MethodPost && etagMatch(r.Header.Values("If-None-Match"), etag) {
+				// 304 の応答には本文を書き込めないため、圧縮の終端も送られない
+				// CompressionMiddleware が設定した Content-Encoding は、本文のない応答に付けない
+				logger.Info("Not modified")
+				document.Close()
+				w.Header().Del("Content-Encoding")
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		pp, err := document.Parser()
 		if err != nil {
-			log.Println("Parser error:", err)
+			logger.Error("Parser error", "err", err)
+			code := errorCode(err)
+			sendError(logger, w, fw, flusher, code, errorMessage(code, err))
 			return
 		}
+		defer pp.Close()
 
+		resumeKey := resumeKey(documentID, parserConfig, field, req.query)
+		if err := checkResume(field.Resume, resumeKey, config); err != nil {
+			logger.Warn("Invalid request", "err", err)
+			sendErrorChunk(logger, w, fw, flusher, &ErrorChunkArgs{Code: http.StatusBadRequest, Message: err.Error(), Field: "resume"})
+			return
+		}
+
+		// ?q= が指定された場合はページの内容の代わりに検索結果を送る
+		query := req.query
+		metadataOnly := field.Mode == RequestModeMeta || r.Method == http.MethodHead
+		if metadataOnly {
+			// HEAD の応答には本文を送れないため、ページ数はヘッダーでも返す
+			if pages, err := pp.PageCount(); err == nil {
+				w.Header().Set("Pdtp-Pages", strconv.Itoa(pages))
+			}
+		}
 		go func() {
-			err := pp.StreamPageContents(ctx, start, end, base, func(data ParsedData) {
-				outCh <- data
-			})
+			// 解析が終わったら送信ループを終える
+			defer close(outCh)
+			insertData := func(data ParsedData) {
+				// 切断された後は送信ループが読まないことがあるため、待たずに捨てる
+				select {
+				case outCh <- data:
+				case <-ctx.Done():
+				}
+			}
+			var err error
+			if metadataOnly {
+				err = pp.StreamMetadata(ctx, insertData)
+			} else if query != "" {
+				err = pp.StreamSearchResults(ctx, field.Start, field.End, field.Base, query, insertData)
+			} else {
+				err = pp.StreamPageContents(ctx, field.Start, field.End, field.Base, insertData)
+			}
 			if err != nil {
-				// TODO: slogでログレベルを使ってログ出力
-				// 解析エラーの場合はエラーチャンク送信 or ログ出力
-				log.Println("Parser error:", err)
+				if r.Context().Err() != nil || errors.Is(err, context.Canceled) {
+					// 切断された場合・送信に失敗して止めた場合は送らない
+					logger.Info("Parsing stopped", "err", err)
+					return
+				}
+				logger.Error("Parser error", "err", err)
+				code := errorCode(err)
+				parsedErr := &ParsedError{Code: code, Message: errorMessage(code, err)}
+				var limitErr *LimitError
+				if errors.As(err, &limitErr) {
+					parsedErr.Limit, parsedErr.Max = limitErr.Limit, limitErr.Max
+				}
+				outCh <- parsedErr
 				return
 			}
 			return
 		}()
-
-		if err != nil {
-			log.Println("SendChunkIter error:", err)
-			return
-		}
 		// チャンク送信
+		sent := &countingWriter{FlusherWriter: fw}
+		handle := Chain(config.Middlewares...)(func(ctx context.Context, data ParsedData) error {
+			if progress, ok := data.(*ParsedProgress); ok {
+				progress.Bytes = sent.n
+			}
+			return sendChunk(logger, data, sent, flusher)
+		})
+		chunkCtx := context.WithValue(ctx, requestContextKey{}, r)
+		chunkCtx = context.WithValue(chunkCtx, loggerContextKey{}, logger)
+		resume := newResumeTracker(resumeKey, field.Resume)
+		throttle := newStreamThrottle(config.RateLimiter)
+		stopped := false
 		for d := range outCh {
-			sendChunk(d, fw, flusher)
+			if _, isError := d.(*ParsedError); stopped || (ctx.Err() != nil && !isError) {
+				// 解析側が送り終えるまで読み捨てる (時間切れの場合もエラーチャンクは送る)
+				continue
+			}
+			if parsedErr, isError := d.(*ParsedError); isError && sent.n == 0 {
+				// まだ何も送っていなければ、ステータスコードでも失敗を知らせる
+				w.WriteHeader(parsedErr.Code)
+			}
+			if !resume.deliver(d) {
+				// 再開する場合は、前の接続で完了したページのチャンクを送らない
+				continue
+			}
+			if err := handle(chunkCtx, d); err != nil {
+				// 書き込みの失敗の詳細は sendChunk が出力済み
+				logger.Warn("Stopped sending chunks", "err", err)
+				stopped = true
+				cancel()
+				// ミドルウェアが止めた場合のために送ってみる (書き込みに失敗した場合は届かない)
+				sendChunk(logger, &ParsedError{Code: http.StatusInternalServerError, Message: err.Error()}, sent, flusher)
+				continue
+			}
+			if font, ok := d.(*ParsedFont); ok && deliveredFonts != nil {
+				// 書き込めたフォントだけを以降の要求で送らない
+				deliveredFonts.MarkSent(font)
+			}
+			if err := throttle.wait(chunkCtx, sent.n); err != nil {
+				stopped = true
+				if r.Context().Err() != nil {
+					// 切断された場合は送らない
+					logger.Info("Rate limit wait stopped", "err", err)
+					cancel()
+					continue
+				}
+				code := http.StatusTooManyRequests
+				if ctx.Err() != nil {
+					// 待っている間に MaxStreamDuration を過ぎた
+					err = context.Cause(ctx)
+					code = errorCode(err)
+				}
+				cancel()
+				logger.Warn("Rate limited", "err", err)
+				sendChunk(logger, &ParsedError{Code: code, Message: err.Error()}, sent, flusher)
+			}
 		}
 	}
 }
 
-func sendChunk(data ParsedData, fw FlusherWriter, flusher http.Flusher) error {
+// sendChunk は データをチャンクにして書き込み、失敗した場合はリクエストのロガーに出力する
+func sendChunk(logger *slog.Logger, data ParsedData, fw FlusherWriter, flusher http.Flusher) error {
+	if err := writeChunk(data, fw, flusher); err != nil {
+		logger.Error("Send chunk error", "chunk", fmt.Sprintf("%T", data), "err", err)
+		return err
+	}
+	return nil
+}
+
+func writeChunk(data ParsedData, fw FlusherWriter, flusher http.Flusher) error {
 	switch d := data.(type) {
 	case *ParsedPage:
 		chunk := NewPageChunk(&NewPageChunkArgs{
 			Width:  d.Width,
 			Height: d.Height,
 			Page:   d.Page,
+			Lang:   d.Lang,
+			Script: d.Script,
+			Label:  d.Label,
+
+			Fingerprint: d.Fingerprint,
 		},
 		)
 
@@ -94,14 +528,28 @@ func sendChunk(data ParsedData, fw FlusherWriter, flusher http.Flusher) error {
 				Y:        d.Y,
 				Z:        d.Z,
 				Text:     d.Text,
+				Width:    d.Width,
 				FontID:   d.FontID,
 				FontSize: d.FontSize,
 				Page:     d.Page,
 				Color:    d.Color,
+
+				StrokeAlpha:  d.StrokeAlpha,
+				FillAlpha:    d.FillAlpha,
+				BlendMode:    d.BlendMode,
+				ClipPaths:    d.ClipPaths,
+				OffPage:      d.OffPage,
+				SharedID:     d.SharedID,
+				Glyphs:       d.Glyphs,
+				Clusters:     d.Clusters,
+				Role:         d.Struct.Role,
+				StructID:     d.Struct.StructID,
+				ReadingOrder: d.Struct.ReadingOrder,
+				Words:        d.Words,
+				ActualText:   d.ActualText,
 			},
 		)
 		if err := chunk.Send(fw, flusher); err != nil {
-			log.Println("SendTextChunk error:", err)
 			return err
 		}
 
@@ -114,25 +562,68 @@ func sendChunk(data ParsedData, fw FlusherWriter, flusher http.Flusher) error {
 			Height:   d.Height,
 			DW:       d.DW,
 			DH:       d.DH,
+			Matrix:   d.Matrix,
 			Page:     d.Page,
 			Data:     d.Data,
 			MaskData: d.MaskData,
 			Ext:      d.Ext,
 			ClipPath: d.ClipPath,
+
+			StrokeAlpha: d.StrokeAlpha,
+			FillAlpha:   d.FillAlpha,
+			BlendMode:   d.BlendMode,
+			MaskType:    d.MaskType,
+
+			BitsPerComponent: d.BitsPerComponent,
+			ColorSpace:       d.ColorSpace,
+			ImageMask:        d.ImageMask,
+			FillColor:        d.FillColor,
+			ClipPaths:        d.ClipPaths,
+			OffPage:          d.OffPage,
+			Alt:              d.Alt,
+			ActualText:       d.ActualText,
+			Metadata:         d.Metadata,
+			Tile:             d.Tile,
+			ICCProfile:       d.ICCProfile,
+			ImageID:          d.ImageID,
+			Preview:          d.Preview,
+			Crop:             d.Crop,
 		})
 
 		if err := chunk.Send(fw, flusher); err != nil {
 			return err
 		}
 
-	case *ParsedFont:
-		newFont, err := fixOS2Table(d.Data)
-		if err != nil {
-			log.Println("fixOS2Table error:", err)
+	case *ParsedImagePlacement:
+		chunk := NewPlacementChunk(&PlacementChunkArgs{
+			ImageID:     d.ImageID,
+			X:           d.X,
+			Y:           d.Y,
+			Z:           d.Z,
+			DW:          d.DW,
+			DH:          d.DH,
+			Matrix:      d.Matrix,
+			Page:        d.Page,
+			ClipPath:    d.ClipPath,
+			StrokeAlpha: d.StrokeAlpha,
+			FillAlpha:   d.FillAlpha,
+			BlendMode:   d.BlendMode,
+			FillColor:   d.FillColor,
+			ClipPaths:   d.ClipPaths,
+			OffPage:     d.OffPage,
+			Alt:         d.Alt,
+			ActualText:  d.ActualText,
+		})
+		if err := chunk.Send(fw, flusher); err != nil {
+			return err
 		}
+	case *ParsedFont:
 		chunk := NewFontChunk(&FontChunkArgs{
-			FontID: d.FontID,
-			Font:   newFont,
+			FontID:     d.FontID,
+			Font:       d.Data,
+			Substitute: d.Substitute,
+			Style:      d.Style,
+			Metrics:    d.Metrics,
 		})
 		if err := chunk.Send(fw, flusher); err != nil {
 			return err
@@ -148,8 +639,198 @@ func sendChunk(data ParsedData, fw FlusherWriter, flusher http.Flusher) error {
 			FillColor:   d.FillColor,
 			StrokeColor: d.StrokeColor,
 			Path:        d.Path,
+			PaintMode:   d.PaintMode,
+			FillRule:    d.FillRule,
+			StrokeAlpha: d.StrokeAlpha,
+			FillAlpha:   d.FillAlpha,
+			BlendMode:   d.BlendMode,
+			MaskType:    d.MaskType,
+			MaskData:    d.MaskData,
+			ClipPaths:   d.ClipPaths,
+			OffPage:     d.OffPage,
+			SharedID:    d.SharedID,
 		})
 
+		if err := chunk.Send(fw, flusher); err != nil {
+			return err
+		}
+	case *ParsedBatch:
+		chunk := NewBatchChunk(&BatchChunkArgs{
+			Page:  d.Page,
+			Batch: d.Batch,
+			Count: d.Count,
+		})
+		if err := chunk.Send(fw, flusher); err != nil {
+			return err
+		}
+	case *ParsedAnnotation:
+		chunk := NewAnnotationChunk(&AnnotationChunkArgs{
+			Subtype:  d.Subtype,
+			X:        d.X,
+			Y:        d.Y,
+			Width:    d.Width,
+			Height:   d.Height,
+			Contents: d.Contents,
+			Color:    d.Color,
+			Page:     d.Page,
+		})
+		if err := chunk.Send(fw, flusher); err != nil {
+			return err
+		}
+	case *ParsedAttachment:
+		chunk := NewAttachmentChunk(&AttachmentChunkArgs{
+			Name:        d.Name,
+			Description: d.Description,
+			MimeType:    d.MimeType,
+			Page:        d.Page,
+			Data:        d.Data,
+		})
+		if err := chunk.Send(fw, flusher); err != nil {
+			return err
+		}
+	case *ParsedLink:
+		chunk := NewLinkChunk(&LinkChunkArgs{
+			X:          d.X,
+			Y:          d.Y,
+			Width:      d.Width,
+			Height:     d.Height,
+			URI:        d.URI,
+			TargetPage: d.TargetPage,
+			Page:       d.Page,
+		})
+		if err := chunk.Send(fw, flusher); err != nil {
+			return err
+		}
+	case *ParsedThumbnail:
+		chunk := NewThumbnailChunk(&ThumbnailChunkArgs{
+			Page:   d.Page,
+			Width:  d.Width,
+			Height: d.Height,
+			Data:   d.Data,
+		})
+		if err := chunk.Send(fw, flusher); err != nil {
+			return err
+		}
+	case *ParsedICCProfile:
+		chunk := NewICCProfileChunk(&ICCProfileChunkArgs{
+			ID:           d.ID,
+			Components:   d.Components,
+			OutputIntent: d.OutputIntent,
+			Data:         d.Data,
+		})
+		if err := chunk.Send(fw, flusher); err != nil {
+			return err
+		}
+	case *ParsedPageSummary:
+		chunk := NewPageSummaryChunk(&PageSummaryChunkArgs{
+			Page:        d.Page,
+			Texts:       d.Texts,
+			Images:      d.Images,
+			Paths:       d.Paths,
+			TextBounds:  d.TextBounds,
+			ImageBounds: d.ImageBounds,
+			PathBounds:  d.PathBounds,
+		})
+		if err := chunk.Send(fw, flusher); err != nil {
+			return err
+		}
+	case *ParsedMetadata:
+		args := &MetadataChunkArgs{
+			Pages:     len(d.Pages),
+			PageSizes: make([]MetadataPageSize, len(d.Pages)),
+			Lang:      d.Lang,
+			Info:      d.Info,
+		}
+		labels := make([]string, len(d.Pages))
+		for i, page := range d.Pages {
+			args.PageSizes[i] = MetadataPageSize{Width: page.Width, Height: page.Height}
+			labels[i] = page.Label
+			if page.Label != "" {
+				// /PageLabels がない文書ではラベルを送らない
+				args.Labels = labels
+			}
+		}
+		chunk := NewMetadataChunk(args)
+		if err := chunk.Send(fw, flusher); err != nil {
+			return err
+		}
+	case *ParsedPageDone:
+		chunk := NewPageDoneChunk(&PageDoneChunkArgs{
+			Page:   d.Page,
+			Cursor: d.Cursor,
+		})
+		if err := chunk.Send(fw, flusher); err != nil {
+			return err
+		}
+	case *ParsedDone:
+		chunk := NewDoneChunk(&DoneChunkArgs{
+			Pages:         d.Pages,
+			Texts:         d.Texts,
+			Images:        d.Images,
+			Paths:         d.Paths,
+			Fonts:         d.Fonts,
+			Shared:        d.Shared,
+			Annotations:   d.Annotations,
+			Links:         d.Links,
+			Attachments:   d.Attachments,
+			Warnings:      d.Warnings,
+			SearchResults: d.SearchResults,
+			Thumbnails:    d.Thumbnails,
+			ICCProfiles:   d.ICCProfiles,
+			PageSummaries: d.PageSummaries,
+			Placements:    d.Placements,
+			Metadata:      d.Metadata,
+		})
+		if err := chunk.Send(fw, flusher); err != nil {
+			return err
+		}
+	case *ParsedError:
+		chunk := NewErrorChunk(&ErrorChunkArgs{
+			Code:    d.Code,
+			Message: d.Message,
+			Limit:   d.Limit,
+			Max:     d.Max,
+		})
+		if err := chunk.Send(fw, flusher); err != nil {
+			return err
+		}
+	case *ParsedProgress:
+		chunk := NewProgressChunk(&ProgressChunkArgs{
+			Pages:      d.Pages,
+			TotalPages: d.TotalPages,
+			Bytes:      d.Bytes,
+			Cursor:     d.Cursor,
+		})
+		if err := chunk.Send(fw, flusher); err != nil {
+			return err
+		}
+	case *ParsedSearchResult:
+		chunk := NewSearchResultChunk(&SearchResultChunkArgs{
+			X:      d.X,
+			Y:      d.Y,
+			Width:  d.Width,
+			Height: d.Height,
+			Text:   d.Text,
+			Page:   d.Page,
+		})
+		if err := chunk.Send(fw, flusher); err != nil {
+			return err
+		}
+	case *ParsedWarning:
+		chunk := NewWarningChunk(&WarningChunkArgs{
+			Message:    d.Message,
+			Page:       d.Page,
+			Suppressed: d.Suppressed,
+		})
+		if err := chunk.Send(fw, flusher); err != nil {
+			return err
+		}
+	case *ParsedSharedContent:
+		chunk := NewSharedChunk(&SharedChunkArgs{
+			SharedID: d.SharedID,
+			Page:     d.Page,
+			Z:        d.Z,
+		})
 		if err := chunk.Send(fw, flusher); err != nil {
 			return err
 		}
@@ -165,32 +846,228 @@ func sendChunk(data ParsedData, fw FlusherWriter, flusher http.Flusher) error {
 // 		初期値: 1
 // end:   読み込み範囲最大ページ
 // 		初期値: PDFのページ数
+// normalize: テキストのUnicode正規化 (nfc)
+// 		初期値: なし
+// strip: テキストから取り除く文字 (control)
+// 		初期値: なし
+// encoding: チャンクのメタデータの形式 (json / cbor)
+// 		初期値: json
+// maxImageDim: 画像の長辺のピクセル数の上限 (Config.MaxImageDim より大きい値は使わない)
+// 		初期値: Config.MaxImageDim
+// quality: ImageTranscoder で変換する画像の品質 (1〜100。Config.TranscodeQuality より高い値は使わない)
+// 		初期値: Config.TranscodeQuality
+// resume: 中断したストリームを再開する位置 (PageDoneChunk・ProgressChunk の cursor)
+// 		初期値: なし (最初から送る)
+// session: 送ったフォントを要求をまたいで記録するためのクライアントの識別子 (Config.FontSessions を設定した場合)
+// 		初期値: なし (接続ごとに記録する)
+// mode: 送る内容 (content / meta)。HEAD の要求は meta として扱う
+// 		初期値: content
+// pages: 送るページの番号と範囲をカンマで区切る (1,5,9-12)。指定した場合は start・end を使わない
+// 		初期値: なし (start から end まで)
+// include: 送るページの内容の種類 (text / paths / images / fonts / annotations をカンマで区切る)
+// 		初期値: なし (全て送る)
+
+// RequestMode は 要求に対して送る内容
+type RequestMode string
+
+const (
+	// RequestModeContent は ページの内容を送る (既定)
+	RequestModeContent RequestMode = "content"
+	// RequestModeMeta は ページの内容を解析せずに、ページ数・ページの大きさ・ラベル・文書情報を MetadataChunk として送る
+	RequestModeMeta RequestMode = "meta"
+)
+
+// PDTPField は Pdtp ヘッダーで指定されるリクエストのオプション
+type PDTPField struct {
+	Start    int64
+	End      int64
+	Base     int64
+	Text     TextOptions
+	Version  int           // フレームの形式のバージョン (ProtocolVersion1 / ProtocolVersion2 / ProtocolVersion3)
+	FontFix  FontFixPolicy // フォントの修正の扱い (空の場合は Config の設定)
+	Encoding ChunkEncoding // チャンクのメタデータの形式
+	Session  string        // 送ったフォントの記録に使うクライアントの識別子
+	Mode     RequestMode   // 送る内容
+	Include  ContentTypes  // 送るページの内容の種類 (空の場合は全て送る)
+	Pages    PageRanges    // 送るページ (空の場合は Start から End まで)
+
+	MaxImageDim int           // 画像の長辺のピクセル数の上限 (0 の場合は Config の設定)
+	Quality     int           // ImageTranscoder で変換する画像の品質 (0 の場合は Config の設定)
+	Resume      *StreamCursor // ストリームを再開する位置 (nil の場合は最初から送る)
+}
 
-func parsePDTPField(pdtpField string) (int64, int64, int64, error) {
-	var start, end, base int64
-	start = 1
-	base = 1
-	end = -1
+func parsePDTPField(pdtpField string) (PDTPField, error) {
+	field := newPDTPField()
 	if pdtpField == "" {
-		return start, end, base, nil
+		return field, nil
 	}
 	pdtpField = strings.Trim(pdtpField, ";")
-	fields := strings.Split(pdtpField, ";")
-	for _, field := range fields {
-		kv := strings.Split(field, "=")
-		if len(kv) != 2 {
-			return start, end, base, fmt.Errorf("Invalid pdtp field")
+	for _, f := range strings.Split(pdtpField, ";") {
+		key, value, ok := strings.Cut(f, "=")
+		if !ok || strings.Contains(value, "=") {
+			return field, &PDTPHeaderError{Field: f, Reason: "expected key=value"}
 		}
-		switch kv[0] {
+		if err := field.set(key, value); err != nil {
+			return field, err
+		}
+	}
+	return field, nil
+}
+
+// newPDTPField は 何も指定されていない場合の PDTPField を返す
+func newPDTPField() PDTPField {
+	return PDTPField{
+		Start:    1,
+		End:      -1,
+		Base:     1,
+		Version:  ProtocolVersion1,
+		Encoding: ChunkEncodingJSON,
+		Mode:     RequestModeContent,
+	}
+}
+
+// set は Pdtp ヘッダーの1つの項目 (key=value) を読み込む
+// 読み込めない値は既定値にせず、項目の名前を含む *PDTPHeaderError を返す
+func (f *PDTPField) set(key, value string) error {
+	invalid := func(reason string) error {
+		return &PDTPHeaderError{Field: key, Value: value, Reason: reason}
+	}
+	switch key {
+	case "start", "end", "base":
+		page, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return invalid("not an integer")
+		}
+		// 範囲外のページ番号は文書のページ数に合わせる (normalizePageNum)
+		switch key {
 		case "start":
-			start, _ = strconv.ParseInt(kv[1], 10, 32)
+			f.Start = page
 		case "end":
-			end, _ = strconv.ParseInt(kv[1], 10, 32)
+			f.End = page
 		case "base":
-			base, _ = strconv.ParseInt(kv[1], 10, 32)
+			f.Base = page
+		}
+	case "normalize":
+		normalization := TextNormalization(strings.ToLower(value))
+		if normalization != TextNormalizationNFC {
+			return invalid("must be nfc")
+		}
+		f.Text.Normalization = normalization
+	case "version":
+		version, err := strconv.Atoi(value)
+		if err != nil || version < ProtocolVersion1 || version > ProtocolVersion3 {
+			return invalid(fmt.Sprintf("must be %d to %d", ProtocolVersion1, ProtocolVersion3))
+		}
+		f.Version = version
+	case "fontfix":
+		switch policy := FontFixPolicy(strings.ToLower(value)); policy {
+		case FontFixAuto, FontFixAlways, FontFixNever:
+			f.FontFix = policy
 		default:
-			return start, end, base, fmt.Errorf("Invalid pdtp field")
+			return invalid("must be auto, always or never")
 		}
+	case "encoding":
+		switch encoding := ChunkEncoding(strings.ToLower(value)); encoding {
+		case ChunkEncodingJSON, ChunkEncodingCBOR:
+			f.Encoding = encoding
+		default:
+			return invalid("must be json or cbor")
+		}
+	case "maxImageDim":
+		dim, err := strconv.Atoi(value)
+		if err != nil || dim <= 0 {
+			return invalid("must be a positive integer")
+		}
+		f.MaxImageDim = dim
+	case "quality":
+		quality, err := strconv.Atoi(value)
+		if err != nil || quality < 1 || quality > 100 {
+			return invalid("must be an integer from 1 to 100")
+		}
+		f.Quality = quality
+	case "resume":
+		cursor, err := ParseStreamCursor(value)
+		if err != nil {
+			return invalid(err.Error())
+		}
+		f.Resume = &cursor
+	case "session":
+		if value == "" {
+			return invalid("must not be empty")
+		}
+		f.Session = value
+	case "mode":
+		switch mode := RequestMode(strings.ToLower(value)); mode {
+		case RequestModeContent, RequestModeMeta:
+			f.Mode = mode
+		default:
+			return invalid("must be content or meta")
+		}
+	case "pages":
+		pages, err := ParsePageRanges(value)
+		if err != nil {
+			return invalid(err.Error())
+		}
+		f.Pages = pages
+	case "include":
+		include, err := ParseContentTypes(value)
+		if err != nil {
+			return invalid(err.Error())
+		}
+		f.Include = include
+	case "strip":
+		if value != "control" {
+			return invalid("must be control")
+		}
+		f.Text.StripControl = true
+	default:
+		return invalid("unknown field")
+	}
+	return nil
+}
+
+// sendError は 要求を処理できないことを、ステータスコードと ErrorChunk で知らせる
+// チャンクを送る前にだけ使う (ステータスコードは最初の書き込みより前にしか変えられない)
+func sendError(logger *slog.Logger, w http.ResponseWriter, fw FlusherWriter, flusher http.Flusher, code int, message string) {
+	sendErrorChunk(logger, w, fw, flusher, &ErrorChunkArgs{Code: code, Message: message})
+}
+
+// sendErrorChunk は sendError と同じく、args のコードをステータスコードにして ErrorChunk を送る
+func sendErrorChunk(logger *slog.Logger, w http.ResponseWriter, fw FlusherWriter, flusher http.Flusher, args *ErrorChunkArgs) {
+	w.WriteHeader(args.Code)
+	chunk := NewErrorChunk(args)
+	if err := chunk.Send(fw, flusher); err != nil {
+		logger.Error("Send error chunk error", "err", err)
+	}
+}
+
+// errorCode は エラーを ErrorChunk のコードに変換する (HTTP のステータスコードにそろえる)
+func errorCode(err error) int {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, fs.ErrPermission):
+		return http.StatusForbidden
+	case errors.Is(err, ErrInvalidFileName), errors.Is(err, ErrInvalidPDTPHeader):
+		return http.StatusBadRequest
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, ErrStreamTimeout), errors.Is(err, ErrPageTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, ErrLimitExceeded):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrInvalidPDF):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ErrNoPagesSelected):
+		return http.StatusRequestedRangeNotSatisfiable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// errorMessage は クライアントに送るエラーメッセージを返す
+// ファイルを開けなかった場合はサーバーのパスを含むため、ステータスの説明のみを送る
+func errorMessage(code int, err error) string {
+	if code == http.StatusNotFound || code == http.StatusForbidden {
+		return http.StatusText(code)
 	}
-	return start, end, base, nil
+	return err.Error()
 }
@@ -0,0 +1,321 @@
+package pdtp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestSendStopsOnFlushError は 内側の Writer の Flush が失敗した場合に、送信ループがチャンクを送り続けないことを確かめる
+// NDJSON・フレームの形式・CBOR・分割の各 Writer も、内側の Flush のエラーを返す
+func TestSendStopsOnFlushError(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		query  string
+		header string
+		config Config
+	}{
+		{"binary", "", "start=1;end=3", Config{}},
+		{"ndjson", "&format=ndjson", "start=1;end=3", Config{}},
+		{"version 2", "", "start=1;end=3;version=2", Config{}},
+		{"version 3", "", "start=1;end=3;version=3", Config{}},
+		{"cbor", "", "start=1;end=3;encoding=cbor", Config{}},
+		{"frame splitter", "", "start=1;end=3", Config{MaxFrameSize: 64}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			failing := &failingCompression{}
+			config := test.config
+			config.OpenPDF = OpenUnder("cmd/pdtp/testdata/conform")
+			config.CompressionMethod = failing
+			handler := NewPDFProtocolHandler(config)
+
+			req := httptest.NewRequest(http.MethodGet, "/?file=multipage.pdf"+test.query, nil)
+			req.Header.Set("Pdtp", test.header)
+			handler(httptest.NewRecorder(), req)
+
+			// 失敗したチャンクと、止めたことを知らせるエラーチャンクの2回まで
+			if failing.flushes > 2 {
+				t.Errorf("Flush called %d times, want the handler to stop after the failed chunk and the error chunk", failing.flushes)
+			}
+		})
+	}
+}
+
+var errFlushFailed = errors.New("flush failed")
+
+// failingCompression は Flush が常に失敗する Writer を返す CompressionMethod
+type failingCompression struct {
+	flushes int
+}
+
+func (f *failingCompression) Name() string { return "identity" }
+
+func (f *failingCompression) Writer(w http.ResponseWriter) (FlusherWriter, error) {
+	return &failingWriter{w: w, compression: f}, nil
+}
+
+type failingWriter struct {
+	w           http.ResponseWriter
+	compression *failingCompression
+}
+
+func (f *failingWriter) Write(p []byte) (int, error) { return f.w.Write(p) }
+
+func (f *failingWriter) Flush() error {
+	f.compression.flushes++
+	return errFlushFailed
+}
+
+func (f *failingWriter) Close() error { return nil }
+
+// TestRequestErrors は ストリームを始める前に失敗した要求に、失敗の種類に合わせたステータスコードと ErrorChunk だけを返すことを確かめる
+func TestRequestErrors(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "broken.pdf"), []byte("this is not a PDF"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	failing := func(context.Context, *http.Request, string) (IPDFFile, error) {
+		return nil, errors.New("storage unavailable")
+	}
+	for _, test := range []struct {
+		name    string
+		target  string
+		header  string
+		openPDF OpenPDFFunc
+		status  int
+	}{
+		{"missing file", "/", "", nil, http.StatusBadRequest},
+		{"unknown format", "/?file=multipage.pdf&format=xml", "", nil, http.StatusBadRequest},
+		{"invalid header", "/?file=multipage.pdf", "start=abc", nil, http.StatusBadRequest},
+		{"not found", "/?file=missing.pdf", "", nil, http.StatusNotFound},
+		{"outside the root", "/?file=../secret.pdf", "", nil, http.StatusBadRequest},
+		{"not a PDF", "/?file=broken.pdf", "", OpenUnder(dir), http.StatusUnprocessableEntity},
+		{"open failure", "/?file=multipage.pdf", "", failing, http.StatusInternalServerError},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			openPDF := test.openPDF
+			if openPDF == nil {
+				openPDF = OpenUnder("cmd/pdtp/testdata/conform")
+			}
+			req := httptest.NewRequest(http.MethodGet, test.target, nil)
+			if test.header != "" {
+				req.Header.Set("Pdtp", test.header)
+			}
+			rec := httptest.NewRecorder()
+			NewPDFProtocolHandler(Config{OpenPDF: openPDF, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})(rec, req)
+			if rec.Code != test.status {
+				t.Errorf("status = %d, want %d", rec.Code, test.status)
+			}
+			chunks := readAllChunks(t, rec.Body.Bytes(), ProtocolVersion1, ChunkEncodingJSON)
+			if len(chunks) != 1 || chunks[0].Type != DataTypeError {
+				t.Fatalf("got %d chunks, want a single error chunk", len(chunks))
+			}
+			var args ErrorChunkArgs
+			if err := json.Unmarshal(chunks[0].JSON, &args); err != nil {
+				t.Fatal(err)
+			}
+			if args.Code != test.status || args.Message == "" {
+				t.Errorf("error chunk = %+v, want code %d with a message", args, test.status)
+			}
+		})
+	}
+}
+
+// TestInvalidPDTPHeader は 読み込めない Pdtp ヘッダーの項目を既定値にせず、項目の名前を含む 400 の ErrorChunk を返すことを確かめる
+func TestInvalidPDTPHeader(t *testing.T) {
+	handler := NewPDFProtocolHandler(Config{
+		OpenPDF: OpenUnder("cmd/pdtp/testdata/conform"),
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	for _, test := range []struct {
+		header string
+		field  string
+	}{
+		{"start=abc", "start"},
+		{"start=1;end=", "end"},
+		{"base=1.5", "base"},
+		{"version=9", "version"},
+		{"encoding=xml", "encoding"},
+		{"maxImageDim=-1", "maxImageDim"},
+		{"quality=0", "quality"},
+		{"mode=full", "mode"},
+		{"pages=3-1", "pages"},
+		{"start=1;colour=red", "colour"},
+		{"start", "start"},
+		{"start=1=2", "start=1=2"},
+	} {
+		t.Run(test.header, func(t *testing.T) {
+			_, err := parsePDTPField(test.header)
+			var headerErr *PDTPHeaderError
+			if !errors.As(err, &headerErr) || !errors.Is(err, ErrInvalidPDTPHeader) || headerErr.Field != test.field {
+				t.Fatalf("parsePDTPField() = %v, want a *PDTPHeaderError for %q", err, test.field)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/?file=multipage.pdf", nil)
+			req.Header.Set("Pdtp", test.header)
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", rec.Code)
+			}
+			chunks := readAllChunks(t, rec.Body.Bytes(), ProtocolVersion1, ChunkEncodingJSON)
+			if len(chunks) != 1 || chunks[0].Type != DataTypeError {
+				t.Fatalf("got %d chunks, want a single error chunk", len(chunks))
+			}
+			var args ErrorChunkArgs
+			if err := json.Unmarshal(chunks[0].JSON, &args); err != nil {
+				t.Fatal(err)
+			}
+			if args.Field != test.field || args.Message != err.Error() {
+				t.Errorf("error chunk = %+v, want field %q and message %q", args, test.field, err)
+			}
+		})
+	}
+
+	field, err := parsePDTPField("start=2;end=3;base=2;version=2;encoding=cbor;quality=50;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if field.Start != 2 || field.End != 3 || field.Base != 2 || field.Version != 2 || field.Encoding != ChunkEncodingCBOR || field.Quality != 50 {
+		t.Errorf("parsePDTPField() = %+v", field)
+	}
+}
+
+// TestIncludeContentTypes は include で選ばなかった種類のチャンクを送らず、ページ・ページの完了・終了のチャンクは常に送ること、
+// 知らない種類を指定した要求に include を示す 400 の ErrorChunk を返すことを確かめる
+func TestIncludeContentTypes(t *testing.T) {
+	handler := NewPDFProtocolHandler(Config{
+		OpenPDF: OpenUnder("cmd/pdtp/testdata/conform"),
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	countChunks := func(file, header string) map[byte]int {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/?file="+file, nil)
+		if header != "" {
+			req.Header.Set("Pdtp", header)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s %q: status = %d, want 200", file, header, rec.Code)
+		}
+		counts := map[byte]int{}
+		for _, chunk := range readAllChunks(t, rec.Body.Bytes(), ProtocolVersion1, ChunkEncodingJSON) {
+			counts[chunk.Type]++
+		}
+		return counts
+	}
+
+	// image.pdf は画像・テキスト・フォント、multipage.pdf はパス・テキスト・フォントを含む
+	for _, file := range []string{"image.pdf", "multipage.pdf"} {
+		all := countChunks(file, "")
+		for _, test := range []struct {
+			include string
+			want    []byte
+		}{
+			{"text", []byte{DataTypeText}},
+			{"text,fonts", []byte{DataTypeText, DataTypeFont}},
+			{"paths,images", []byte{DataTypePath, DataTypeImage}},
+		} {
+			got := countChunks(file, "include="+test.include)
+			want := map[byte]int{}
+			for _, dataType := range append([]byte{DataTypePage, DataTypePageDone, DataTypeDone}, test.want...) {
+				if all[dataType] > 0 {
+					want[dataType] = all[dataType]
+				}
+			}
+			if !maps.Equal(got, want) {
+				t.Errorf("%s include=%s: chunk counts by type = %v, want %v", file, test.include, got, want)
+			}
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/?file=image.pdf", nil)
+	req.Header.Set("Pdtp", "include=text,video")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown type: status = %d, want 400", rec.Code)
+	}
+	chunks := readAllChunks(t, rec.Body.Bytes(), ProtocolVersion1, ChunkEncodingJSON)
+	if len(chunks) != 1 || chunks[0].Type != DataTypeError {
+		t.Fatalf("unknown type: got %d chunks, want a single error chunk", len(chunks))
+	}
+	var args ErrorChunkArgs
+	if err := json.Unmarshal(chunks[0].JSON, &args); err != nil {
+		t.Fatal(err)
+	}
+	if args.Field != "include" {
+		t.Errorf("error chunk = %+v, want field %q", args, "include")
+	}
+}
+
+// TestMetadataMode は mode=meta の要求にページの内容を解析せず、ページ数と各ページの大きさの MetadataChunk と DoneChunk だけを送り、
+// Pdtp-Pages ヘッダーにもページ数を返すことを確かめる
+func TestMetadataMode(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/?file=multipage.pdf", nil)
+	req.Header.Set("Pdtp", "mode=meta")
+	rec := httptest.NewRecorder()
+	NewPDFProtocolHandler(Config{
+		OpenPDF: OpenUnder("cmd/pdtp/testdata/conform"),
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if got := rec.Header().Get("Pdtp-Pages"); got != "3" {
+		t.Errorf("Pdtp-Pages = %q, want %q", got, "3")
+	}
+	chunks := readAllChunks(t, rec.Body.Bytes(), ProtocolVersion1, ChunkEncodingJSON)
+	if len(chunks) != 2 || chunks[0].Type != DataTypeMetadata || chunks[1].Type != DataTypeDone {
+		t.Fatalf("got %d chunks, want a metadata chunk and a done chunk", len(chunks))
+	}
+	var args MetadataChunkArgs
+	if err := json.Unmarshal(chunks[0].JSON, &args); err != nil {
+		t.Fatal(err)
+	}
+	want := MetadataPageSize{Width: 612, Height: 792}
+	if args.Pages != 3 || len(args.PageSizes) != 3 || args.PageSizes[0] != want {
+		t.Errorf("metadata = %+v, want 3 pages of %+v", args, want)
+	}
+}
+
+// TestHeadPageCount は HEAD の要求に本文を送らず、Pdtp-Pages ヘッダーでページ数を返すことを確かめる
+func TestHeadPageCount(t *testing.T) {
+	server := httptest.NewServer(NewPDFProtocolHandler(Config{
+		OpenPDF: OpenUnder("cmd/pdtp/testdata/conform"),
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}))
+	defer server.Close()
+	for _, test := range []struct {
+		file  string
+		pages string
+	}{
+		{"multipage.pdf", "3"},
+		{"text.pdf", "1"},
+	} {
+		resp, err := http.Head(server.URL + "?file=" + test.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK || len(body) != 0 {
+			t.Errorf("%s: status %d with %d bytes of body, want 200 without a body", test.file, resp.StatusCode, len(body))
+		}
+		if got := resp.Header.Get("Pdtp-Pages"); got != test.pages {
+			t.Errorf("%s: Pdtp-Pages = %q, want %q", test.file, got, test.pages)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package pdtp
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// IdentityCompression は 圧縮せずに送る CompressionMethod
+// Config.CompressionMethod を設定しない場合にも使う
+type IdentityCompression struct{}
+
+func (i IdentityCompression) Name() string {
+	return "identity"
+}
+
+func (i IdentityCompression) Writer(w http.ResponseWriter) (FlusherWriter, error) {
+	hf, ok := w.(http.Flusher)
+	if !ok {
+		return nil, fmt.Errorf("response writer cannot flush")
+	}
+	return &responseFlusherWriter{w: w, hf: hf}, nil
+}
+
+// responseFlusherWriter は 圧縮せずにレスポンスへ書き込む FlusherWriter
+type responseFlusherWriter struct {
+	w  http.ResponseWriter
+	hf http.Flusher
+}
+
+func (r *responseFlusherWriter) Write(p []byte) (int, error) {
+	return r.w.Write(p)
+}
+
+func (r *responseFlusherWriter) Flush() error {
+	r.hf.Flush()
+	return nil
+}
+
+func (r *responseFlusherWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,41 @@
+package pdtp
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+)
+
+// RequestIDHeader は 要求 ID を受け取り、応答で返すヘッダー
+// 前段のプロキシが付けた ID があればそれを使い、ログとクライアントの報告を対応付けられるようにする
+const RequestIDHeader = "X-Request-Id"
+
+// requestLogger は 要求 ID を付けたロガーと要求 ID を返す (ヘッダーに ID がない場合は作成する)
+func requestLogger(base *slog.Logger, r *http.Request) (*slog.Logger, string) {
+	id := r.Header.Get(RequestIDHeader)
+	if id == "" {
+		id = newRequestID()
+	}
+	return cmp.Or(base, slog.Default()).With("request", id), id
+}
+
+// newRequestID は ランダムな 16 文字の要求 ID を作成する
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+type loggerContextKey struct{}
+
+// LoggerFromContext は ミドルウェアに渡されるコンテキストから要求ごとのロガーを取り出す
+// 要求 ID・ファイル名・ページの範囲が付いている。ない場合は slog.Default() を返す
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerContextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
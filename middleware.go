@@ -0,0 +1,46 @@
+package pdtp
+
+import (
+	"context"
+	"net/http"
+)
+
+// PDTPHandler は 解析したデータを1件ずつ受け取り、チャンクとして処理する
+type PDTPHandler func(ctx context.Context, data ParsedData) error
+
+// Middleware は チャンク単位の処理を前後に挟む
+// 監査ログ・送信量の集計・墨消しなどを、本体を変更せずに重ねられる
+// next を呼ばなければそのデータは送られず、エラーを返すとそれ以降のチャンクは送られない
+type Middleware func(next PDTPHandler) PDTPHandler
+
+// Chain は 複数のミドルウェアを1つにまとめる。先頭のミドルウェアが最も外側になる
+func Chain(middlewares ...Middleware) Middleware {
+	return func(next PDTPHandler) PDTPHandler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			if middlewares[i] != nil {
+				next = middlewares[i](next)
+			}
+		}
+		return next
+	}
+}
+
+type requestContextKey struct{}
+
+// RequestFromContext は ミドルウェアに渡されるコンテキストから元の HTTP リクエストを取り出す
+func RequestFromContext(ctx context.Context) (*http.Request, bool) {
+	r, ok := ctx.Value(requestContextKey{}).(*http.Request)
+	return r, ok
+}
+
+// countingWriter は 書き込んだバイト数を数える FlusherWriter
+type countingWriter struct {
+	FlusherWriter
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.FlusherWriter.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,68 @@
+package pdtp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// dataTypeNames は NDJSON 出力やデコーダーで使うチャンク種別の名前
+var dataTypeNames = map[byte]string{
+	DataTypePage:         "page",
+	DataTypeText:         "text",
+	DataTypeImage:        "image",
+	DataTypeFont:         "font",
+	DataTypePath:         "path",
+	DataTypeShared:       "shared",
+	DataTypeBatch:        "batch",
+	DataTypeAnnotation:   "annotation",
+	DataTypeLink:         "link",
+	DataTypeAttachment:   "attachment",
+	DataTypeWarning:      "warning",
+	DataTypeSearch:       "search",
+	DataTypeThumbnail:    "thumbnail",
+	DataTypePageDone:     "pageDone",
+	DataTypeDone:         "done",
+	DataTypeProgress:     "progress",
+	DataTypeICCProfile:   "iccProfile",
+	DataTypePageSummary:  "pageSummary",
+	DataTypePlacement:    "placement",
+	DataTypeContinuation: "continuation",
+	DataTypeMetadata:     "metadata",
+	DataTypeError:        "error",
+}
+
+// ndjsonChunk は NDJSON 出力の1行
+type ndjsonChunk struct {
+	Type     string          `json:"type"`
+	TypeCode byte            `json:"typeCode"`
+	JSON     json.RawMessage `json:"json"`
+	Payload  string          `json:"payload,omitempty"` // JSON に続くバイナリ (base64)
+}
+
+// ndjsonWriter は バイナリ形式のチャンクを、1行1チャンクの JSON に変換して書き込む
+// curl や jq でストリームを確認するためのデバッグ用の出力
+type ndjsonWriter struct {
+	bufferedFrameWriter
+}
+
+func newNDJSONWriter(w FlusherWriter) *ndjsonWriter {
+	n := &ndjsonWriter{bufferedFrameWriter{w: w, name: "ndjson"}}
+	n.writeFrame = n.writeLine
+	return n
+}
+
+func (n *ndjsonWriter) writeLine(dataType byte, meta, payload []byte) error {
+	chunk := ndjsonChunk{
+		Type:     dataTypeNames[dataType],
+		TypeCode: dataType,
+		JSON:     json.RawMessage(meta),
+	}
+	if len(payload) > 0 {
+		chunk.Payload = base64.StdEncoding.EncodeToString(payload)
+	}
+	line, err := json.Marshal(chunk)
+	if err != nil {
+		return err
+	}
+	return n.writeParts(append(line, '\n'))
+}
@@ -0,0 +1,113 @@
+package pdtp
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// OpenPDFFunc は 要求されたファイル名の PDF を開く
+// ctx は要求のコンテキストで、切断された場合や MaxStreamDuration を過ぎた場合に取り消される
+// r から認証情報などを参照し、ファイルごとのアクセス制御やストレージからの取得に使える
+type OpenPDFFunc func(ctx context.Context, r *http.Request, fileName string) (IPDFFile, error)
+
+// OpenFileFunc は ファイル名だけを受け取る関数を OpenPDFFunc に変換する (HandleOpenPDF からの移行用)
+func OpenFileFunc(open func(fileName string) (IPDFFile, error)) OpenPDFFunc {
+	return func(_ context.Context, _ *http.Request, fileName string) (IPDFFile, error) {
+		return open(fileName)
+	}
+}
+
+// OpenUnder は root ディレクトリ以下のファイルだけを開く Config.OpenPDF を返す
+// fileName は root からの相対パスとして扱い、絶対パス・root の外を指す .. ・root の外へのシンボリックリンクは ErrInvalidFileName にする
+// extensions は開いてよい拡張子 (大文字・小文字は区別しない)。指定しない場合は ".pdf" のみを開く
+func OpenUnder(root string, extensions ...string) OpenPDFFunc {
+	allowed := allowedExtensions(extensions)
+	return func(ctx context.Context, _ *http.Request, fileName string) (IPDFFile, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		name, err := resolveUnder(root, fileName, allowed)
+		if err != nil {
+			return nil, err
+		}
+		return os.Open(name)
+	}
+}
+
+// OpenFS は fsys のファイルを開く Config.OpenPDF を返す (embed.FS・os.DirFS・fstest.MapFS など)
+// fileName は fsys のパス (/ 区切り、先頭の / は無視する) として扱い、.. を含む名前やディレクトリは ErrInvalidFileName にする
+// extensions は OpenUnder と同じく開いてよい拡張子。シークできないファイルはメモリに読み込む
+func OpenFS(fsys fs.FS, extensions ...string) OpenPDFFunc {
+	allowed := allowedExtensions(extensions)
+	return func(ctx context.Context, _ *http.Request, fileName string) (IPDFFile, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		name := strings.TrimPrefix(fileName, "/")
+		if !fs.ValidPath(name) || name == "." {
+			return nil, fmt.Errorf("%w: %q is not a valid path", ErrInvalidFileName, fileName)
+		}
+		if !slices.Contains(allowed, strings.ToLower(path.Ext(name))) {
+			return nil, fmt.Errorf("%w: %q has an extension that is not allowed", ErrInvalidFileName, fileName)
+		}
+		file, err := fsys.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		if info, err := file.Stat(); err != nil || info.IsDir() {
+			file.Close()
+			return nil, fmt.Errorf("%w: %q is not a file", ErrInvalidFileName, fileName)
+		}
+		if seeker, ok := file.(IPDFFile); ok {
+			// Stat を残し、DocumentCache がファイルの版を確かめられるようにする
+			return seeker, nil
+		}
+		return NewPDFFile(file)
+	}
+}
+
+// allowedExtensions は 小文字にした拡張子の一覧を返す (指定しない場合は ".pdf" のみ)
+func allowedExtensions(extensions []string) []string {
+	if len(extensions) == 0 {
+		return []string{".pdf"}
+	}
+	allowed := make([]string, len(extensions))
+	for i, ext := range extensions {
+		allowed[i] = strings.ToLower(ext)
+	}
+	return allowed
+}
+
+// resolveUnder は fileName を root 以下のパスに変換する
+// シンボリックリンクをたどった先も root 以下であることを確かめる
+func resolveUnder(root, fileName string, extensions []string) (string, error) {
+	// URL のパスのように / で区切った名前も受け付ける
+	rel := filepath.FromSlash(fileName)
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%w: %q is outside the root", ErrInvalidFileName, fileName)
+	}
+	if !slices.Contains(extensions, strings.ToLower(filepath.Ext(rel))) {
+		return "", fmt.Errorf("%w: %q has an extension that is not allowed", ErrInvalidFileName, fileName)
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	realName, err := filepath.EvalSymlinks(filepath.Join(realRoot, rel))
+	if err != nil {
+		// 存在しないファイルは os.Open と同じ fs.ErrNotExist を返す
+		return "", err
+	}
+	inside, err := filepath.Rel(realRoot, realName)
+	if err != nil || !filepath.IsLocal(inside) {
+		return "", fmt.Errorf("%w: %q links outside the root", ErrInvalidFileName, fileName)
+	}
+	return realName, nil
+}
@@ -0,0 +1,183 @@
+package pdtp
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Option は NewPDFProtocolHandler・NewHandler に渡すハンドラーの設定
+// Config もそのまま Option として渡せる (それより前の Option の設定はすべて置き換える)
+type Option interface {
+	apply(config *Config)
+}
+
+// OptionFunc は Config を直接変更する Option (With 関数のない項目の設定に使う)
+type OptionFunc func(config *Config)
+
+func (f OptionFunc) apply(config *Config) {
+	f(config)
+}
+
+func (c Config) apply(config *Config) {
+	*config = c
+}
+
+// WithOpenPDF は 要求されたファイル名の PDF を開く関数を設定する (必須)
+func WithOpenPDF(open OpenPDFFunc) Option {
+	return OptionFunc(func(config *Config) {
+		config.OpenPDF = open
+	})
+}
+
+// WithCompression は レスポンスの圧縮方法を設定する
+// 複数を指定した場合は Accept-Encoding から選ぶ (Config.CompressionMethods)
+func WithCompression(methods ...CompressionMethod) Option {
+	return OptionFunc(func(config *Config) {
+		if len(methods) == 1 {
+			config.CompressionMethod = methods[0]
+			config.CompressionMethods = nil
+			return
+		}
+		config.CompressionMethods = methods
+	})
+}
+
+// WithLogger は 要求ごとのログの出力先を設定する
+func WithLogger(logger *slog.Logger) Option {
+	return OptionFunc(func(config *Config) {
+		config.Logger = logger
+	})
+}
+
+// WithMiddlewares は 各チャンクを送る前後に挟む処理を追加する (先に追加したものが外側)
+func WithMiddlewares(middlewares ...Middleware) Option {
+	return OptionFunc(func(config *Config) {
+		config.Middlewares = append(config.Middlewares, middlewares...)
+	})
+}
+
+// WithLimits は 1つの要求で解析するページ数・送るバイト数の上限を設定する
+func WithLimits(limits StreamLimits) Option {
+	return OptionFunc(func(config *Config) {
+		config.Limits = limits
+	})
+}
+
+// WithTimeouts は 1ページの解析と、1つの要求のストリームにかける時間の上限を設定する (0 は上限なし)
+func WithTimeouts(page, stream time.Duration) Option {
+	return OptionFunc(func(config *Config) {
+		config.PageTimeout = page
+		config.MaxStreamDuration = stream
+	})
+}
+
+// WithConcurrency は 同時に解析するストリームの数の上限と、空きを待つ時間を設定する
+func WithConcurrency(maxStreams int, queueTimeout time.Duration) Option {
+	return OptionFunc(func(config *Config) {
+		config.MaxConcurrentStreams = maxStreams
+		config.StreamQueueTimeout = queueTimeout
+	})
+}
+
+// WithRateLimiter は クライアントごとの要求と送信量の制限を設定する
+func WithRateLimiter(limiter RateLimiter) Option {
+	return OptionFunc(func(config *Config) {
+		config.RateLimiter = limiter
+	})
+}
+
+// WithDocumentCache は 文書の構造を要求をまたいで保持するキャッシュを設定する
+func WithDocumentCache(cache *DocumentCache) Option {
+	return OptionFunc(func(config *Config) {
+		config.DocumentCache = cache
+	})
+}
+
+// NewHandler は opts を順に適用した設定を検証し、ハンドラーを作成する
+// 設定が正しくない場合は、要求を受ける前にエラーを返す
+func NewHandler(opts ...Option) (http.HandlerFunc, error) {
+	config := newConfig(opts)
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return newPDFProtocolHandler(config), nil
+}
+
+func newConfig(opts []Option) Config {
+	var config Config
+	for _, opt := range opts {
+		if opt != nil {
+			opt.apply(&config)
+		}
+	}
+	return config
+}
+
+// Validate は 必須の項目と、値の範囲を確かめる
+func (c Config) Validate() error {
+	var errs []error
+	if c.OpenPDF == nil && c.HandleOpenPDF == nil {
+		errs = append(errs, errors.New("OpenPDF is required"))
+	}
+	for _, field := range []struct {
+		name  string
+		value int64
+	}{
+		{"MaxWarnings", int64(c.MaxWarnings)},
+		{"ThumbnailSize", int64(c.ThumbnailSize)},
+		{"FontCacheSize", int64(c.FontCacheSize)},
+		{"MaxConcurrentStreams", int64(c.MaxConcurrentStreams)},
+		{"StreamQueueTimeout", int64(c.StreamQueueTimeout)},
+		{"PageTimeout", int64(c.PageTimeout)},
+		{"MaxStreamDuration", int64(c.MaxStreamDuration)},
+		{"ProgressInterval", int64(c.ProgressInterval)},
+		{"MaxImagePixels", int64(c.MaxImagePixels)},
+		{"ImagePreviewSize", int64(c.ImagePreviewSize)},
+		{"MaxImageDim", int64(c.MaxImageDim)},
+		{"MaxFrameSize", int64(c.MaxFrameSize)},
+		{"Limits.MaxPages", int64(c.Limits.MaxPages)},
+		{"Limits.MaxTotalBytes", c.Limits.MaxTotalBytes},
+		{"Limits.MaxImageBytes", c.Limits.MaxImageBytes},
+		{"OperatorBudget.MaxOperators", int64(c.OperatorBudget.MaxOperators)},
+		{"OperatorBudget.MaxDuration", int64(c.OperatorBudget.MaxDuration)},
+	} {
+		if field.value < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative", field.name))
+		}
+	}
+	if c.TranscodeQuality < 0 || c.TranscodeQuality > 100 {
+		errs = append(errs, fmt.Errorf("TranscodeQuality must be between 0 and 100 (got %d)", c.TranscodeQuality))
+	}
+	switch c.FontFix {
+	case "", FontFixAuto, FontFixAlways, FontFixNever:
+	default:
+		errs = append(errs, fmt.Errorf("unknown FontFix %q", c.FontFix))
+	}
+	switch c.ChunkOrder {
+	case "", ChunkOrderImagesLast, ChunkOrderFontsFirst, ChunkOrderInterleave:
+	default:
+		errs = append(errs, fmt.Errorf("unknown ChunkOrder %q", c.ChunkOrder))
+	}
+	switch c.StreamLengthPolicy {
+	case StreamLengthTolerant, StreamLengthStrict, StreamLengthAuto:
+	default:
+		errs = append(errs, fmt.Errorf("unknown StreamLengthPolicy %d", c.StreamLengthPolicy))
+	}
+	switch c.OffPagePolicy {
+	case OffPageKeep, OffPageDrop, OffPageFlag:
+	default:
+		errs = append(errs, fmt.Errorf("unknown OffPagePolicy %d", c.OffPagePolicy))
+	}
+	switch c.FsTypePolicy {
+	case FsTypeWarn, FsTypeHonor, FsTypeStrip:
+	default:
+		errs = append(errs, fmt.Errorf("unknown FsTypePolicy %d", c.FsTypePolicy))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("pdtp: invalid config: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package pdtp
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestValidate は 範囲外の値と未知の方針を持つ設定を拒否し、既定値の設定を受け付けることを確かめる
+func TestValidate(t *testing.T) {
+	open := OpenUnder(".")
+	for _, test := range []struct {
+		name   string
+		config Config
+		want   string // エラーに含まれる文字列 (空の場合はエラーにならない)
+	}{
+		{"defaults", Config{OpenPDF: open}, ""},
+		{"known policies", Config{OpenPDF: open, StreamLengthPolicy: StreamLengthAuto, OffPagePolicy: OffPageFlag, FsTypePolicy: FsTypeStrip}, ""},
+		{"missing OpenPDF", Config{}, "OpenPDF is required"},
+		{"negative limit", Config{OpenPDF: open, MaxWarnings: -1}, "MaxWarnings must not be negative"},
+		{"unknown ChunkOrder", Config{OpenPDF: open, ChunkOrder: "random"}, `unknown ChunkOrder "random"`},
+		{"unknown StreamLengthPolicy", Config{OpenPDF: open, StreamLengthPolicy: StreamLengthAuto + 1}, "unknown StreamLengthPolicy 3"},
+		{"unknown OffPagePolicy", Config{OpenPDF: open, OffPagePolicy: -1}, "unknown OffPagePolicy -1"},
+		{"unknown FsTypePolicy", Config{OpenPDF: open, FsTypePolicy: 7}, "unknown FsTypePolicy 7"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.config.Validate()
+			switch {
+			case test.want == "" && err != nil:
+				t.Errorf("Validate() = %v, want nil", err)
+			case test.want != "" && (err == nil || !strings.Contains(err.Error(), test.want)):
+				t.Errorf("Validate() = %v, want an error containing %q", err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package parse
+
+import (
+	"encoding/hex"
+	"strings"
+	"unicode/utf16"
+)
+
+// MarkedContent は BDC / BMC で始まるマークコンテンツの区間を表す
+type MarkedContent struct {
+	Tag        string // 構造種別 (Figure / Span など)
+	MCID       int    // マークコンテンツID (-1 の場合はなし)
+	Alt        string // 代替テキスト (/Alt)
+	ActualText string // 置き換えテキスト (/ActualText)
+}
+
+// parseMarkedContent は BDC / BMC のオペランドからマークコンテンツを作る
+// プロパティはインラインの辞書のみ解釈し、/Properties リソースの名前は無視する
+func parseMarkedContent(operands []string) MarkedContent {
+	mc := MarkedContent{MCID: -1}
+	if len(operands) == 0 {
+		return mc
+	}
+	mc.Tag = strings.TrimLeft(operands[0], "/")
+	if len(operands) < 2 || !strings.HasPrefix(operands[1], "<<") {
+		return mc
+	}
+	obj, err := parseMetadata(strings.Join(operands[1:], " "))
+	if err != nil {
+		return mc
+	}
+	props, ok := obj.(map[string]PDFObject)
+	if !ok {
+		return mc
+	}
+	if mcid, ok := props["MCID"].(int); ok {
+		mc.MCID = mcid
+	}
+	mc.Alt = textStringFrom(props["Alt"])
+	mc.ActualText = textStringFrom(props["ActualText"])
+	return mc
+}
+
+// markedContentText は 入れ子のマークコンテンツから内側を優先して代替テキストと MCID を返す
+func markedContentText(stack []MarkedContent) (alt, actualText string, mcid int) {
+	mcid = -1
+	for i := len(stack) - 1; i >= 0; i-- {
+		if alt == "" {
+			alt = stack[i].Alt
+		}
+		if actualText == "" {
+			actualText = stack[i].ActualText
+		}
+		if mcid < 0 {
+			mcid = stack[i].MCID
+		}
+	}
+	return alt, actualText, mcid
+}
+
+// markedContentID は 入れ子のマークコンテンツのうち最も内側の MCID を返す
+func markedContentID(stack []MarkedContent) int {
+	_, _, mcid := markedContentText(stack)
+	return mcid
+}
+
+// textStringFrom は 文字列オブジェクトをテキスト文字列として読む
+func textStringFrom(obj PDFObject) string {
+	s, ok := obj.(string)
+	if !ok {
+		return ""
+	}
+	return decodeTextString(s)
+}
+
+// decodeTextString は UTF-16BE (BOM 付き16進文字列)のテキスト文字列を UTF-8 に変換する
+// 16進文字列はパース時に16進数字のまま残るため、BOM で始まるものだけを変換する
+func decodeTextString(s string) string {
+	if len(s) < 4 || len(s)%4 != 0 || !strings.EqualFold(s[:4], "feff") {
+		return s
+	}
+	b, err := hex.DecodeString(s[4:])
+	if err != nil {
+		return s
+	}
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+	}
+	return string(utf16.Decode(units))
+}
+
+// StructText は 構造要素の代替テキスト
+type StructText struct {
+	Alt        string
+	ActualText string
+}
+
+// structTexts は ページ(/StructParents)のマークコンテンツIDと構造要素の代替テキストの対応を返す
+// 構造ツリーや親ツリーがない場合は nil を返す
+func (p *PDFParser) structTexts(structTreeRef PDFRef, structParents int) (map[int]StructText, error) {
+	if structTreeRef == 0 || structParents < 0 {
+		return nil, nil
+	}
+	root, err := p.ParseObject(structTreeRef)
+	if err != nil {
+		return nil, err
+	}
+	dict, ok := root.(map[string]PDFObject)
+	if !ok {
+		return nil, nil
+	}
+	parentTree, err := p.resolveObject(dict["ParentTree"])
+	if err != nil {
+		return nil, err
+	}
+	obj, found, err := p.lookupNumberTree(parentTree, structParents, 0)
+	if err != nil || !found {
+		return nil, err
+	}
+	obj, err = p.resolveObject(obj)
+	if err != nil {
+		return nil, err
+	}
+	elems, ok := obj.([]PDFObject)
+	if !ok {
+		return nil, nil
+	}
+	texts := make(map[int]StructText)
+	for mcid, elem := range elems {
+		elem, err := p.resolveObject(elem)
+		if err != nil {
+			return nil, err
+		}
+		elemDict, ok := elem.(map[string]PDFObject)
+		if !ok {
+			continue
+		}
+		text := StructText{
+			Alt:        textStringFrom(elemDict["Alt"]),
+			ActualText: textStringFrom(elemDict["ActualText"]),
+		}
+		if text != (StructText{}) {
+			texts[mcid] = text
+		}
+	}
+	return texts, nil
+}
+
+// lookupNumberTree は 数値ツリーから key の値を探す
+func (p *PDFParser) lookupNumberTree(node PDFObject, key, depth int) (PDFObject, bool, error) {
+	if depth > maxTreeDepth {
+		return nil, false, nil
+	}
+	dict, ok := node.(map[string]PDFObject)
+	if !ok {
+		return nil, false, nil
+	}
+	if nums, ok := dict["Nums"].([]PDFObject); ok {
+		for i := 0; i+1 < len(nums); i += 2 {
+			if k, ok := nums[i].(int); ok && k == key {
+				return nums[i+1], true, nil
+			}
+		}
+	}
+	kids, ok := dict["Kids"].([]PDFObject)
+	if !ok {
+		return nil, false, nil
+	}
+	for _, kid := range kids {
+		kid, err := p.resolveObject(kid)
+		if err != nil {
+			return nil, false, err
+		}
+		kidDict, ok := kid.(map[string]PDFObject)
+		if !ok {
+			continue
+		}
+		if limits, ok := kidDict["Limits"].([]PDFObject); ok && len(limits) == 2 {
+			lo, _ := limits[0].(int)
+			hi, _ := limits[1].(int)
+			if key < lo || key > hi {
+				continue
+			}
+		}
+		if value, found, err := p.lookupNumberTree(kidDict, key, depth+1); err != nil || found {
+			return value, found, err
+		}
+	}
+	return nil, false, nil
+}
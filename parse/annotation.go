@@ -0,0 +1,234 @@
+package parse
+
+import (
+	"errors"
+)
+
+// annotationFlagHidden は 表示も印刷もしない注釈のフラグ (/F のビット2)
+const annotationFlagHidden = 1 << 1
+
+// extractAnnotations は ページの /Annots から注釈とリンクを読み込む
+// 座標は他のチャンクと同じく上端を原点とするページ座標に変換する
+func (p *PDFParser) extractAnnotations(catalog *Catalog, annots PDFObject, page int64, pageHeight float64) ([]ParsedData, error) {
+	if annots == nil {
+		return nil, nil
+	}
+	obj, err := p.resolveObject(annots)
+	if err != nil {
+		return nil, err
+	}
+	arr, ok := obj.([]PDFObject)
+	if !ok {
+		return nil, errors.New("Annots is not array")
+	}
+	var annotations []ParsedData
+	for _, item := range arr {
+		item, err := p.resolveObject(item)
+		if err != nil {
+			return nil, err
+		}
+		dict, ok := item.(map[string]PDFObject)
+		if !ok {
+			continue
+		}
+		if flags, ok := dict["F"].(int); ok && flags&annotationFlagHidden != 0 {
+			continue
+		}
+		rect, ok := annotationRect(dict["Rect"], pageHeight)
+		if !ok {
+			continue
+		}
+		subtype, _ := dict["Subtype"].(string)
+		if subtype == "Link" {
+			link, err := p.extractLink(catalog, dict, rect, page)
+			if err != nil {
+				return nil, err
+			}
+			if link != nil {
+				annotations = append(annotations, link)
+			}
+			continue
+		}
+		annotations = append(annotations, &ParsedAnnotation{
+			Subtype:  subtype,
+			X:        rect.X0,
+			Y:        rect.Y0,
+			Width:    rect.X1 - rect.X0,
+			Height:   rect.Y1 - rect.Y0,
+			Contents: textStringFrom(dict["Contents"]),
+			Color:    annotationColor(dict["C"]),
+			Page:     page,
+		})
+	}
+	return annotations, nil
+}
+
+// annotationRect は 注釈の /Rect を上端を原点とする矩形に変換する
+func annotationRect(obj PDFObject, pageHeight float64) (Rect, bool) {
+	arr, ok := obj.([]PDFObject)
+	if !ok || len(arr) != 4 {
+		return Rect{}, false
+	}
+	var v [4]float64
+	for i, o := range arr {
+		f, ok := toFloat(o)
+		if !ok {
+			return Rect{}, false
+		}
+		v[i] = f
+	}
+	return newRect(v[0], pageHeight-v[1], v[2], pageHeight-v[3]), true
+}
+
+// annotationColor は 注釈の /C (0, 1, 3, 4 成分)を色文字列に変換する
+func annotationColor(obj PDFObject) string {
+	arr, ok := obj.([]PDFObject)
+	if !ok {
+		return ""
+	}
+	components := make([]float64, 0, len(arr))
+	for _, o := range arr {
+		f, ok := toFloat(o)
+		if !ok {
+			return ""
+		}
+		components = append(components, f)
+	}
+	return parseColor(components)
+}
+
+// extractLink は リンク注釈の URI アクションまたは宛先を解決する
+// 解決できないリンク(他の文書への移動など)は nil を返す
+func (p *PDFParser) extractLink(catalog *Catalog, dict map[string]PDFObject, rect Rect, page int64) (*ParsedLink, error) {
+	link := &ParsedLink{
+		X:      rect.X0,
+		Y:      rect.Y0,
+		Width:  rect.X1 - rect.X0,
+		Height: rect.Y1 - rect.Y0,
+		Page:   page,
+	}
+	dest := dict["Dest"]
+	if action, err := p.resolveObject(dict["A"]); err != nil {
+		return nil, err
+	} else if actionDict, ok := action.(map[string]PDFObject); ok {
+		switch actionDict["S"] {
+		case "URI":
+			uri, _ := actionDict["URI"].(string)
+			link.URI = uri
+			return link, nil
+		case "GoTo":
+			dest = actionDict["D"]
+		}
+	}
+	if dest == nil {
+		return nil, nil
+	}
+	targetPage, err := p.resolveDestination(catalog, dest, 0)
+	if err != nil || targetPage == 0 {
+		return nil, err
+	}
+	link.TargetPage = targetPage
+	return link, nil
+}
+
+// resolveDestination は 宛先(配列・名前付き宛先)から移動先のページ番号を返す
+// ページが見つからない場合は 0 を返す
+func (p *PDFParser) resolveDestination(catalog *Catalog, dest PDFObject, depth int) (int64, error) {
+	if depth > maxTreeDepth {
+		return 0, nil
+	}
+	dest, err := p.resolveObject(dest)
+	if err != nil {
+		return 0, err
+	}
+	switch d := dest.(type) {
+	case []PDFObject:
+		// [ページ /XYZ left top zoom] などの明示的な宛先
+		if len(d) == 0 {
+			return 0, nil
+		}
+		if refString, ok := d[0].(string); ok {
+			if ref, ok := parseRef(refString); ok {
+				return p.pageNumber(ref), nil
+			}
+		}
+		return 0, nil
+	case map[string]PDFObject:
+		// 名前付き宛先の値は /D に宛先を持つ辞書の場合がある
+		return p.resolveDestination(catalog, d["D"], depth+1)
+	case string:
+		// 名前(PDF 1.1 の /Dests)と文字列(名前ツリー)はどちらも文字列として読まれるため両方を探す
+		names, err := p.resolveObject(catalog.DestNames)
+		if err != nil {
+			return 0, err
+		}
+		if named, found, err := p.lookupNameTree(names, d, 0); err != nil {
+			return 0, err
+		} else if found {
+			return p.resolveDestination(catalog, named, depth+1)
+		}
+		dests, err := p.resolveObject(catalog.Dests)
+		if err != nil {
+			return 0, err
+		}
+		if destsDict, ok := dests.(map[string]PDFObject); ok {
+			if named, found := destsDict[d]; found {
+				return p.resolveDestination(catalog, named, depth+1)
+			}
+		}
+	}
+	return 0, nil
+}
+
+// lookupNameTree は 名前ツリーから key の値を探す
+func (p *PDFParser) lookupNameTree(node PDFObject, key string, depth int) (PDFObject, bool, error) {
+	if depth > maxTreeDepth {
+		return nil, false, nil
+	}
+	dict, ok := node.(map[string]PDFObject)
+	if !ok {
+		return nil, false, nil
+	}
+	if names, ok := dict["Names"].([]PDFObject); ok {
+		for i := 0; i+1 < len(names); i += 2 {
+			if k, ok := names[i].(string); ok && k == key {
+				return names[i+1], true, nil
+			}
+		}
+	}
+	kids, ok := dict["Kids"].([]PDFObject)
+	if !ok {
+		return nil, false, nil
+	}
+	for _, kid := range kids {
+		kid, err := p.resolveObject(kid)
+		if err != nil {
+			return nil, false, err
+		}
+		kidDict, ok := kid.(map[string]PDFObject)
+		if !ok {
+			continue
+		}
+		if limits, ok := kidDict["Limits"].([]PDFObject); ok && len(limits) == 2 {
+			lo, _ := limits[0].(string)
+			hi, _ := limits[1].(string)
+			if key < lo || key > hi {
+				continue
+			}
+		}
+		if value, found, err := p.lookupNameTree(kidDict, key, depth+1); err != nil || found {
+			return value, found, err
+		}
+	}
+	return nil, false, nil
+}
+
+// pageNumber は ページオブジェクトの参照から1始まりのページ番号を返す
+func (p *PDFParser) pageNumber(ref PDFRef) int64 {
+	for i, page := range p.pageQueue {
+		if page.Ref == ref {
+			return int64(i + 1)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,201 @@
+package parse
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Attachment は 文書に埋め込まれたファイルを表す
+type Attachment struct {
+	Name        string // ファイル名 (/UF または /F)
+	Description string // 説明 (/Desc)
+	MimeType    string // MIME タイプ (埋め込みファイルストリームの /Subtype)
+	Size        int    // 展開後のサイズ (/Params の /Size、不明な場合は0)
+	Page        int64  // FileAttachment 注釈のページ (文書レベルの添付は0)
+
+	ref PDFRef // 埋め込みファイルストリーム
+}
+
+// ListAttachments は /EmbeddedFiles 名前ツリーと FileAttachment 注釈の添付ファイルを返す
+func (p *PDFParser) ListAttachments() ([]Attachment, error) {
+	c, err := p.GetCatalog()
+	if err != nil {
+		return nil, err
+	}
+	var attachments []Attachment
+	if c.EmbeddedFiles != nil {
+		files, err := p.resolveObject(c.EmbeddedFiles)
+		if err != nil {
+			return nil, err
+		}
+		err = p.walkNameTree(files, 0, func(name string, value PDFObject) error {
+			attachment, ok, err := p.readFileSpec(value)
+			if err != nil || !ok {
+				return err
+			}
+			if attachment.Name == "" {
+				attachment.Name = name
+			}
+			attachments = append(attachments, attachment)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(p.pageQueue) == 0 {
+		if err := p.loadPageObject(*c); err != nil {
+			return nil, err
+		}
+	}
+	for i, page := range p.pageQueue {
+		annots, err := p.resolveObject(page.Annots)
+		if err != nil {
+			return nil, err
+		}
+		arr, _ := annots.([]PDFObject)
+		for _, item := range arr {
+			item, err := p.resolveObject(item)
+			if err != nil {
+				return nil, err
+			}
+			dict, ok := item.(map[string]PDFObject)
+			if !ok || dict["Subtype"] != "FileAttachment" {
+				continue
+			}
+			attachment, ok, err := p.readFileSpec(dict["FS"])
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				attachment.Page = int64(i + 1)
+				attachments = append(attachments, attachment)
+			}
+		}
+	}
+	return attachments, nil
+}
+
+// ExtractAttachment は name の添付ファイルの内容を返す
+func (p *PDFParser) ExtractAttachment(name string) ([]byte, error) {
+	attachments, err := p.ListAttachments()
+	if err != nil {
+		return nil, err
+	}
+	for _, attachment := range attachments {
+		if attachment.Name == name {
+			return p.attachmentData(attachment)
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrAttachmentNotFound, name)
+}
+
+func (p *PDFParser) attachmentData(attachment Attachment) ([]byte, error) {
+	so, err := p.ParseStreamObject(attachment.ref)
+	if err != nil {
+		return nil, err
+	}
+	return so.Decoded()
+}
+
+// readFileSpec は ファイル指定辞書から埋め込みファイルを読み込む
+// 外部ファイルを指すだけのもの(/EF がない)は false を返す
+func (p *PDFParser) readFileSpec(obj PDFObject) (Attachment, bool, error) {
+	obj, err := p.resolveObject(obj)
+	if err != nil {
+		return Attachment{}, false, err
+	}
+	spec, ok := obj.(map[string]PDFObject)
+	if !ok {
+		return Attachment{}, false, nil
+	}
+	ef, err := p.resolveObject(spec["EF"])
+	if err != nil {
+		return Attachment{}, false, err
+	}
+	efDict, ok := ef.(map[string]PDFObject)
+	if !ok {
+		return Attachment{}, false, nil
+	}
+	ref, ok := findTargetRef(efDict, "UF")
+	if !ok {
+		if ref, ok = findTargetRef(efDict, "F"); !ok {
+			return Attachment{}, false, nil
+		}
+	}
+	attachment := Attachment{
+		Name:        textStringFrom(spec["UF"]),
+		Description: textStringFrom(spec["Desc"]),
+		ref:         ref,
+	}
+	if attachment.Name == "" {
+		attachment.Name = textStringFrom(spec["F"])
+	}
+	so, err := p.ParseStreamObject(ref)
+	if err != nil {
+		return Attachment{}, false, err
+	}
+	if subtype, ok := so.Dict["Subtype"].(string); ok {
+		attachment.MimeType = decodeNameEscapes(subtype)
+	}
+	if params, err := p.resolveObject(so.Dict["Params"]); err == nil {
+		if size, found := findTarget(params, "Size"); found {
+			attachment.Size, _ = size.(int)
+		}
+	}
+	return attachment, true, nil
+}
+
+// decodeNameEscapes は 名前オブジェクトの #xx エスケープ(application#2Fpdf など)を戻す
+func decodeNameEscapes(name string) string {
+	if !strings.Contains(name, "#") {
+		return name
+	}
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '#' && i+2 < len(name) {
+			if v, err := strconv.ParseUint(name[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
+
+// walkNameTree は 名前ツリーの全ての値を key の順に fn へ渡す
+func (p *PDFParser) walkNameTree(node PDFObject, depth int, fn func(key string, value PDFObject) error) error {
+	if depth > maxTreeDepth {
+		return errors.New("name tree is too deep")
+	}
+	dict, ok := node.(map[string]PDFObject)
+	if !ok {
+		return nil
+	}
+	if names, ok := dict["Names"].([]PDFObject); ok {
+		for i := 0; i+1 < len(names); i += 2 {
+			key, ok := names[i].(string)
+			if !ok {
+				continue
+			}
+			if err := fn(key, names[i+1]); err != nil {
+				return err
+			}
+		}
+	}
+	kids, _ := dict["Kids"].([]PDFObject)
+	for _, kid := range kids {
+		kid, err := p.resolveObject(kid)
+		if err != nil {
+			return err
+		}
+		if err := p.walkNameTree(kid, depth+1, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package parse
+
+// PaintBatch は クライアントが段階的に描画するための描画段階を示す
+type PaintBatch string
+
+const (
+	PaintBatchBackground PaintBatch = "background" // 最初のテキストより前に描かれるパス
+	PaintBatchText       PaintBatch = "text"       // 本文テキスト
+	PaintBatchImage      PaintBatch = "image"      // 画像
+	PaintBatchForeground PaintBatch = "foreground" // テキストより後に描かれるパス
+)
+
+// paintBatchOrder は 描画段階を送る順序
+var paintBatchOrder = []PaintBatch{
+	PaintBatchBackground,
+	PaintBatchText,
+	PaintBatchImage,
+	PaintBatchForeground,
+}
+
+// paintBatcher は ページごとのコマンドを描画段階ごとにまとめ、
+// 各段階の前に境界マーカー(ParsedBatch)を付けて送る
+// 無効な場合はコマンドをそのまま送る
+type paintBatcher struct {
+	enabled    bool
+	insertData func(data ParsedData)
+
+	page  int64
+	items map[PaintBatch][]ParsedData
+}
+
+func newPaintBatcher(enabled bool, insertData func(data ParsedData)) *paintBatcher {
+	return &paintBatcher{
+		enabled:    enabled,
+		insertData: insertData,
+		items:      make(map[PaintBatch][]ParsedData),
+	}
+}
+
+// add は page の batch 段階にコマンドを追加する
+// 前と異なるページのコマンドが来た場合は、前のページの段階を送ってから追加する
+func (b *paintBatcher) add(page int64, batch PaintBatch, data ParsedData) {
+	if !b.enabled {
+		b.insertData(data)
+		return
+	}
+	if page != b.page {
+		b.flush()
+		b.page = page
+	}
+	b.items[batch] = append(b.items[batch], data)
+}
+
+// flush は 溜まっている段階を順番に送る
+func (b *paintBatcher) flush() {
+	for _, batch := range paintBatchOrder {
+		items := b.items[batch]
+		if len(items) == 0 {
+			continue
+		}
+		b.insertData(&ParsedBatch{Page: b.page, Batch: batch, Count: len(items)})
+		for _, data := range items {
+			b.insertData(data)
+		}
+		delete(b.items, batch)
+	}
+}
+
+// pathPaintBatch は パスが最初のテキストより前に描かれていれば背景、そうでなければ前景とする
+func pathPaintBatch(z, firstTextZ int64, hasText bool) PaintBatch {
+	if !hasText || z < firstTextZ {
+		return PaintBatchBackground
+	}
+	return PaintBatchForeground
+}
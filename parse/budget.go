@@ -0,0 +1,73 @@
+package parse
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// OperatorBudget は 1ページのコンテンツストリーム (展開した Form XObject を含む) を処理する上限
+// 退化した演算子が大量に並ぶ壊れた PDF で、ストリーム全体が止まらないようにする
+type OperatorBudget struct {
+	MaxOperators int           // 実行する演算子の数の上限 (0 は上限なし)
+	MaxDuration  time.Duration // 処理時間の上限 (0 は上限なし)
+}
+
+// operatorBudgetCheckInterval は 処理時間を確認する演算子の間隔
+const operatorBudgetCheckInterval = 256
+
+// operatorCounter は 1ページの処理で実行した演算子の数と経過時間を数える
+type operatorCounter struct {
+	budget   OperatorBudget
+	count    int
+	deadline time.Time
+	ctx      context.Context // 取り消された場合はページの処理を打ち切らずに中止する
+	exceeded error
+}
+
+// start は ページの処理を始める時点の operatorCounter を返す (上限がなく、ctx が取り消されない場合は nil)
+func (b OperatorBudget) start(ctx context.Context) *operatorCounter {
+	if b.MaxOperators <= 0 && b.MaxDuration <= 0 && ctx.Done() == nil {
+		return nil
+	}
+	c := &operatorCounter{budget: b}
+	if ctx.Done() != nil {
+		c.ctx = ctx
+	}
+	if b.MaxDuration > 0 {
+		c.deadline = time.Now().Add(b.MaxDuration)
+	}
+	return c
+}
+
+// step は 演算子を1つ数え、上限を超えた場合は false を返す
+func (c *operatorCounter) step() bool {
+	if c == nil {
+		return true
+	}
+	if c.exceeded != nil {
+		return false
+	}
+	c.count++
+	if c.budget.MaxOperators > 0 && c.count > c.budget.MaxOperators {
+		c.exceeded = fmt.Errorf("%w: more than %d operators", ErrOperatorBudgetExceeded, c.budget.MaxOperators)
+		return false
+	}
+	if c.ctx != nil && c.count%operatorBudgetCheckInterval == 0 && c.ctx.Err() != nil {
+		c.exceeded = context.Cause(c.ctx)
+		return false
+	}
+	if !c.deadline.IsZero() && c.count%operatorBudgetCheckInterval == 0 && time.Now().After(c.deadline) {
+		c.exceeded = fmt.Errorf("%w: exceeded %s after %d operators", ErrOperatorBudgetExceeded, c.budget.MaxDuration, c.count)
+		return false
+	}
+	return true
+}
+
+// err は 上限を超えた場合・取り消された場合にその理由を返す
+func (c *operatorCounter) err() error {
+	if c == nil {
+		return nil
+	}
+	return c.exceeded
+}
@@ -0,0 +1,104 @@
+package parse
+
+import "container/list"
+
+// CacheStats は キャッシュの利用状況
+type CacheStats struct {
+	Entries   int
+	Capacity  int // 0 は上限なし
+	Hits      int
+	Misses    int
+	Evictions int
+}
+
+// boundedCache は 最近使われていないものから追い出す、件数に上限のあるキャッシュ
+// パーサーを複数のリクエストで使い回しても、読み込んだフォントが増え続けないようにする
+// beginPin から endPin までの間に保存・参照したものは追い出さない (その間は上限を超えることがある)
+type boundedCache[V any] struct {
+	capacity int
+	order    *list.List // 先頭ほど最近使われた
+	entries  map[string]*list.Element
+	stats    CacheStats
+	pinning  bool
+	pinned   map[string]bool
+}
+
+type cacheEntry[V any] struct {
+	key   string
+	value V
+}
+
+// newBoundedCache は capacity 件まで保持するキャッシュを作る (0 以下は上限なし)
+func newBoundedCache[V any](capacity int) *boundedCache[V] {
+	if capacity < 0 {
+		capacity = 0
+	}
+	return &boundedCache[V]{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+		stats:    CacheStats{Capacity: capacity},
+		pinned:   make(map[string]bool),
+	}
+}
+
+// get は key の値を返し、最近使われたものとして記録する
+func (c *boundedCache[V]) get(key string) (V, bool) {
+	if e, ok := c.entries[key]; ok {
+		c.stats.Hits++
+		c.order.MoveToFront(e)
+		if c.pinning {
+			c.pinned[key] = true
+		}
+		return e.Value.(*cacheEntry[V]).value, true
+	}
+	c.stats.Misses++
+	var zero V
+	return zero, false
+}
+
+// put は key の値を保存し、上限を超えた分を古いものから追い出す
+func (c *boundedCache[V]) put(key string, value V) {
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*cacheEntry[V]).value = value
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry[V]{key: key, value: value})
+	if c.pinning {
+		c.pinned[key] = true
+	}
+	c.evict()
+}
+
+// beginPin は endPin を呼ぶまで、保存・参照したものを追い出さないようにする
+// 送信中のストリームで使うフォントが、同じストリームの途中で追い出されないようにするため
+func (c *boundedCache[V]) beginPin() {
+	c.pinning = true
+}
+
+// endPin は beginPin からの固定を外し、上限を超えた分を古いものから追い出す
+func (c *boundedCache[V]) endPin() {
+	c.pinning = false
+	clear(c.pinned)
+	c.evict()
+}
+
+// evict は 上限を超えた分を、固定していないものから古い順に追い出す
+func (c *boundedCache[V]) evict() {
+	for e := c.order.Back(); e != nil && c.capacity > 0 && c.order.Len() > c.capacity; {
+		prev := e.Prev()
+		if key := e.Value.(*cacheEntry[V]).key; !c.pinned[key] {
+			c.order.Remove(e)
+			delete(c.entries, key)
+			c.stats.Evictions++
+		}
+		e = prev
+	}
+}
+
+func (c *boundedCache[V]) snapshot() CacheStats {
+	stats := c.stats
+	stats.Entries = c.order.Len()
+	return stats
+}
@@ -0,0 +1,140 @@
+package parse
+
+import (
+	"image"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ImageCrop は クリッピングパスに合わせて切り出した画像の範囲
+// X, Y は元の画像の左上からのピクセル単位の位置
+type ImageCrop struct {
+	X           int `json:"x"`
+	Y           int `json:"y"`
+	ImageWidth  int `json:"imageWidth"`
+	ImageHeight int `json:"imageHeight"`
+}
+
+// clipRectEpsilon は 矩形のクリッピングパスの辺が軸に平行かを判定する許容誤差
+const clipRectEpsilon = 0.01
+
+// rectClipPath は M / L / Z のみで軸に平行な四角形を描くクリッピングパスの範囲を返す
+// re 演算子のパスは "M x y L x y L x y L x y Z" の形になる
+func rectClipPath(path string) (Rect, bool) {
+	var xs, ys []float64
+	fields := strings.Fields(path)
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "M", "L":
+			if i+2 >= len(fields) {
+				return Rect{}, false
+			}
+			x, errX := strconv.ParseFloat(fields[i+1], 64)
+			y, errY := strconv.ParseFloat(fields[i+2], 64)
+			if errX != nil || errY != nil {
+				return Rect{}, false
+			}
+			if fields[i] == "M" && len(xs) > 0 {
+				// 複数のサブパス
+				return Rect{}, false
+			}
+			xs, ys = append(xs, x), append(ys, y)
+			i += 2
+		case "Z":
+		default:
+			return Rect{}, false
+		}
+	}
+	if len(xs) == 5 && math.Abs(xs[4]-xs[0]) < clipRectEpsilon && math.Abs(ys[4]-ys[0]) < clipRectEpsilon {
+		// 始点に戻る線
+		xs, ys = xs[:4], ys[:4]
+	}
+	if len(xs) != 4 {
+		return Rect{}, false
+	}
+	r := newRect(xs[0], ys[0], xs[2], ys[2])
+	for i := 0; i < 4; i++ {
+		x, y := xs[i], ys[i]
+		onX := math.Abs(x-r.X0) < clipRectEpsilon || math.Abs(x-r.X1) < clipRectEpsilon
+		onY := math.Abs(y-r.Y0) < clipRectEpsilon || math.Abs(y-r.Y1) < clipRectEpsilon
+		if !onX || !onY {
+			return Rect{}, false
+		}
+	}
+	return r, true
+}
+
+// clipRect は 矩形のクリッピングパスの共通部分を返す
+// 矩形でないパスは無視する (共通部分はさらに小さくなるため、矩形の共通部分で切り出しても描画は変わらない)
+func clipRect(clips []ClipPath) (Rect, bool) {
+	var r Rect
+	found := false
+	for _, clip := range clips {
+		rect, ok := rectClipPath(clip.Path)
+		if !ok {
+			continue
+		}
+		if !found {
+			r, found = rect, true
+			continue
+		}
+		r.X0, r.Y0 = math.Max(r.X0, rect.X0), math.Max(r.Y0, rect.Y0)
+		r.X1, r.Y1 = math.Min(r.X1, rect.X1), math.Min(r.Y1, rect.Y1)
+	}
+	return r, found
+}
+
+// cropImageToClip は 回転していない画像を矩形のクリッピングパスの範囲に切り出す
+// 配置行列は切り出した範囲に合わせて変え、元の画像での範囲を Crop に記録する
+// 切り出す必要がない場合や切り出せない場合は img を返す
+func cropImageToClip(img *ParsedImage, pageHeight float64) (*ParsedImage, error) {
+	m := img.Matrix
+	if m[1] != 0 || m[2] != 0 || m[0] == 0 || m[3] == 0 || img.Tile != nil {
+		return img, nil
+	}
+	clip, ok := clipRect(img.ClipPaths)
+	if !ok {
+		return img, nil
+	}
+	width, height := int(img.Width), int(img.Height)
+	// ページ座標 (上端が原点) を画像空間の単位正方形の座標に戻す
+	u0, u1 := (clip.X0-m[4])/m[0], (clip.X1-m[4])/m[0]
+	v0, v1 := (pageHeight-clip.Y1-m[5])/m[3], (pageHeight-clip.Y0-m[5])/m[3]
+	u0, u1 = math.Min(u0, u1), math.Max(u0, u1)
+	v0, v1 = math.Min(v0, v1), math.Max(v0, v1)
+	// 画像の1行目は単位正方形の上端 (v = 1) に来る
+	r := image.Rect(
+		int(math.Floor(u0*float64(width))),
+		int(math.Floor((1-v1)*float64(height))),
+		int(math.Ceil(u1*float64(width))),
+		int(math.Ceil((1-v0)*float64(height))),
+	).Intersect(image.Rect(0, 0, width, height))
+	if r.Empty() || r.Eq(image.Rect(0, 0, width, height)) {
+		// 全て見えない画像はクライアントの描画に任せる
+		return img, nil
+	}
+	crop, err := newImageCropper(img)
+	if err != nil {
+		return img, err
+	}
+	data, mask, err := crop(r)
+	if err != nil {
+		return img, err
+	}
+	cropped := *img
+	cropped.Data = data
+	cropped.MaskData = mask
+	cropped.Width = float64(r.Dx())
+	cropped.Height = float64(r.Dy())
+	cropped.Matrix = tileMatrix(img.Matrix, r, width, height)
+	cropped.X, cropped.Y = cropped.Matrix[4], cropped.Matrix[5]
+	cropped.DW, cropped.DH = matrixScale(cropped.Matrix)
+	cropped.Crop = &ImageCrop{
+		X:           r.Min.X,
+		Y:           r.Min.Y,
+		ImageWidth:  width,
+		ImageHeight: height,
+	}
+	return &cropped, nil
+}
@@ -0,0 +1,242 @@
+package parse
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+)
+
+var (
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+
+	oidDigestSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidDigestSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidDigestSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidDigestSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+
+	oidRSAPrefix   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1}
+	oidECDSAPrefix = asn1.ObjectIdentifier{1, 2, 840, 10045}
+)
+
+// CMS (RFC 5652) の SignedData のうち、署名の検証に必要な部分
+type cmsContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type cmsSignedDataASN1 struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo asn1.RawValue
+	Certificates     asn1.RawValue   `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue   `asn1:"optional,tag:1"`
+	SignerInfos      []cmsSignerInfo `asn1:"set"`
+}
+
+// cmsSignedData は 読み込んだ SignedData の署名者と同梱の証明書
+type cmsSignedData struct {
+	signers []cmsSignerInfo
+	certs   []*x509.Certificate
+}
+
+type cmsSignerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type cmsIssuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type cmsAttribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+// parseCMSSignedData は 署名値 (/Contents) を SignedData として読み込む
+// /Contents は固定長のため、DER の後ろの0埋めは無視する
+func parseCMSSignedData(der []byte) (*cmsSignedData, error) {
+	var info cmsContentInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+	}
+	if !info.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("%w: content type %v", ErrSignatureUnsupported, info.ContentType)
+	}
+	var raw cmsSignedDataASN1
+	if _, err := asn1.Unmarshal(info.Content.Bytes, &raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+	}
+	if len(raw.SignerInfos) == 0 {
+		return nil, fmt.Errorf("%w: no signer", ErrSignatureInvalid)
+	}
+	signed := &cmsSignedData{signers: raw.SignerInfos}
+	if len(raw.Certificates.Bytes) > 0 {
+		certs, err := x509.ParseCertificates(raw.Certificates.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+		}
+		signed.certs = certs
+	}
+	return signed, nil
+}
+
+// signerCertificate は 最初の署名者の証明書を返す
+func (s *cmsSignedData) signerCertificate() *x509.Certificate {
+	return s.findCertificate(s.signers[0].SID)
+}
+
+// findCertificate は 署名者の識別子 (発行者とシリアル番号、またはサブジェクト鍵識別子) に一致する証明書を返す
+func (s *cmsSignedData) findCertificate(sid asn1.RawValue) *x509.Certificate {
+	if sid.Class == asn1.ClassContextSpecific && sid.Tag == 0 {
+		for _, cert := range s.certs {
+			if bytes.Equal(cert.SubjectKeyId, sid.Bytes) {
+				return cert
+			}
+		}
+		return nil
+	}
+	var ias cmsIssuerAndSerial
+	if _, err := asn1.Unmarshal(sid.FullBytes, &ias); err != nil {
+		return nil
+	}
+	for _, cert := range s.certs {
+		if bytes.Equal(cert.RawIssuer, ias.Issuer.FullBytes) && cert.SerialNumber.Cmp(ias.Serial) == 0 {
+			return cert
+		}
+	}
+	return nil
+}
+
+// verify は 全ての署名者の署名を content (分離された署名の対象) に対して検証し、
+// 署名者の証明書が roots に連なることを確かめる
+func (s *cmsSignedData) verify(content []byte, roots *x509.CertPool) error {
+	for _, signer := range s.signers {
+		cert := s.findCertificate(signer.SID)
+		if cert == nil {
+			return fmt.Errorf("%w: signer certificate not found", ErrSignatureInvalid)
+		}
+		if signer.DigestAlgorithm.Algorithm.Equal(oidDigestSHA1) {
+			return fmt.Errorf("%w: SHA-1 is insecure", ErrSignatureAlgorithmUnsupported)
+		}
+		hash, ok := cmsDigestHash(signer.DigestAlgorithm.Algorithm)
+		if !ok {
+			return fmt.Errorf("%w: digest %v", ErrSignatureAlgorithmUnsupported, signer.DigestAlgorithm.Algorithm)
+		}
+		algorithm, ok := cmsSignatureAlgorithm(hash, signer.SignatureAlgorithm.Algorithm)
+		if !ok {
+			return fmt.Errorf("%w: signature %v", ErrSignatureAlgorithmUnsupported, signer.SignatureAlgorithm.Algorithm)
+		}
+		signedBytes := content
+		if len(signer.SignedAttrs.FullBytes) > 0 {
+			digest, err := cmsMessageDigest(signer.SignedAttrs.Bytes)
+			if err != nil {
+				return err
+			}
+			h := hash.New()
+			h.Write(content)
+			if !bytes.Equal(h.Sum(nil), digest) {
+				return fmt.Errorf("%w: message digest mismatch", ErrSignatureInvalid)
+			}
+			// 署名は [0] IMPLICIT ではなく SET OF として符号化した属性に対して計算される
+			signedBytes = append([]byte{0x31}, signer.SignedAttrs.FullBytes[1:]...)
+		}
+		if err := cert.CheckSignature(algorithm, signedBytes, signer.Signature); err != nil {
+			return fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+		}
+		if err := s.verifyChain(cert, roots); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyChain は 同梱の証明書を中間証明書として、cert から roots (nil の場合はシステムのルート証明書) まで連なるかを確かめる
+func (s *cmsSignedData) verifyChain(cert *x509.Certificate, roots *x509.CertPool) error {
+	intermediates := x509.NewCertPool()
+	for _, c := range s.certs {
+		if c != cert {
+			intermediates.AddCert(c)
+		}
+	}
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		// 文書の署名に使う証明書の拡張鍵用途は発行元によって異なるため、用途は問わない
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSignatureUntrusted, err)
+	}
+	return nil
+}
+
+// cmsMessageDigest は 署名属性から messageDigest の値を取り出す
+func cmsMessageDigest(attrs []byte) ([]byte, error) {
+	for len(attrs) > 0 {
+		var attr cmsAttribute
+		rest, err := asn1.Unmarshal(attrs, &attr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+		}
+		attrs = rest
+		if !attr.Type.Equal(oidMessageDigest) {
+			continue
+		}
+		var digest []byte
+		if _, err := asn1.Unmarshal(attr.Values.Bytes, &digest); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+		}
+		return digest, nil
+	}
+	return nil, fmt.Errorf("%w: messageDigest not found", ErrSignatureInvalid)
+}
+
+func cmsDigestHash(oid asn1.ObjectIdentifier) (crypto.Hash, bool) {
+	switch {
+	case oid.Equal(oidDigestSHA256):
+		return crypto.SHA256, true
+	case oid.Equal(oidDigestSHA384):
+		return crypto.SHA384, true
+	case oid.Equal(oidDigestSHA512):
+		return crypto.SHA512, true
+	}
+	return 0, false
+}
+
+// cmsSignatureAlgorithm は ダイジェストと署名アルゴリズムの OID から x509 の署名アルゴリズムを決める
+// 署名アルゴリズムは rsaEncryption のように鍵の種類だけを示すことが多いため、ダイジェストと組み合わせる
+func cmsSignatureAlgorithm(hash crypto.Hash, oid asn1.ObjectIdentifier) (x509.SignatureAlgorithm, bool) {
+	rsa := hasOIDPrefix(oid, oidRSAPrefix)
+	ecdsa := hasOIDPrefix(oid, oidECDSAPrefix)
+	switch {
+	case rsa && hash == crypto.SHA256:
+		return x509.SHA256WithRSA, true
+	case rsa && hash == crypto.SHA384:
+		return x509.SHA384WithRSA, true
+	case rsa && hash == crypto.SHA512:
+		return x509.SHA512WithRSA, true
+	case ecdsa && hash == crypto.SHA256:
+		return x509.ECDSAWithSHA256, true
+	case ecdsa && hash == crypto.SHA384:
+		return x509.ECDSAWithSHA384, true
+	case ecdsa && hash == crypto.SHA512:
+		return x509.ECDSAWithSHA512, true
+	}
+	return 0, false
+}
+
+func hasOIDPrefix(oid, prefix asn1.ObjectIdentifier) bool {
+	return len(oid) > len(prefix) && oid[:len(prefix)].Equal(prefix)
+}
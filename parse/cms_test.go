@@ -0,0 +1,154 @@
+package parse
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+var oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+
+// TestCMSVerify は 署名者の証明書の連なりをルート証明書まで確かめ、SHA-1 の署名を対応していないアルゴリズムとして断ることを確かめる
+func TestCMSVerify(t *testing.T) {
+	root, rootKey := newTestCertificate(t, "Root CA", nil, nil, true)
+	intermediate, intermediateKey := newTestCertificate(t, "Intermediate CA", root, rootKey, true)
+	signer, signerKey := newTestCertificate(t, "Signer", intermediate, intermediateKey, false)
+	other, _ := newTestCertificate(t, "Other CA", nil, nil, true)
+	content := []byte("signed byte ranges")
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	otherRoots := x509.NewCertPool()
+	otherRoots.AddCert(other)
+
+	for _, test := range []struct {
+		name    string
+		digest  asn1.ObjectIdentifier
+		certs   []*x509.Certificate
+		roots   *x509.CertPool
+		content []byte
+		want    error
+	}{
+		{"trusted", oidDigestSHA256, []*x509.Certificate{signer, intermediate}, roots, content, nil},
+		{"untrusted root", oidDigestSHA256, []*x509.Certificate{signer, intermediate}, otherRoots, content, ErrSignatureUntrusted},
+		{"missing intermediate", oidDigestSHA256, []*x509.Certificate{signer}, roots, content, ErrSignatureUntrusted},
+		{"modified content", oidDigestSHA256, []*x509.Certificate{signer, intermediate}, roots, []byte("modified"), ErrSignatureInvalid},
+		{"SHA-1", oidDigestSHA1, []*x509.Certificate{signer, intermediate}, roots, content, ErrSignatureAlgorithmUnsupported},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			signed, err := parseCMSSignedData(newTestSignedData(t, content, test.digest, signer, signerKey, test.certs))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = signed.verify(test.content, test.roots)
+			if !errors.Is(err, test.want) || (test.want == nil && err != nil) {
+				t.Errorf("verify() = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+// newTestCertificate は parent (nil の場合は自己署名) が発行した証明書と秘密鍵を作る
+func newTestCertificate(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, ca bool) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  ca,
+	}
+	if ca {
+		template.KeyUsage |= x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// newTestSignedData は content に対する分離された署名を、署名属性付きの SignedData として作る
+// digest が SHA-256 でない場合も、ダイジェストの OID だけを差し替えて SHA-256 で署名する
+func newTestSignedData(t *testing.T, content []byte, digest asn1.ObjectIdentifier, signer *x509.Certificate, key *ecdsa.PrivateKey, certs []*x509.Certificate) []byte {
+	t.Helper()
+	sum := sha256.Sum256(content)
+	digestValue, err := asn1.Marshal(sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	attr, err := asn1.Marshal(cmsAttribute{Type: oidMessageDigest, Values: asn1.RawValue{FullBytes: append([]byte{0x31, byte(len(digestValue))}, digestValue...)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	attrSet, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: attr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	attrsSum := sha256.Sum256(attrSet)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, attrsSum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sid, err := asn1.Marshal(cmsIssuerAndSerial{Issuer: asn1.RawValue{FullBytes: signer.RawIssuer}, Serial: signer.SerialNumber})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rawCerts []byte
+	for _, cert := range certs {
+		rawCerts = append(rawCerts, cert.Raw...)
+	}
+	encap, err := asn1.Marshal(struct{ ContentType asn1.ObjectIdentifier }{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedData, err := asn1.Marshal(cmsSignedDataASN1{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: digest}},
+		EncapContentInfo: asn1.RawValue{FullBytes: encap},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: rawCerts},
+		SignerInfos: []cmsSignerInfo{{
+			Version:            1,
+			SID:                asn1.RawValue{FullBytes: sid},
+			DigestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: digest},
+			SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: attr},
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256},
+			Signature:          signature,
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// FullBytes はそのまま書き出されるため、[0] EXPLICIT のタグはここで付ける
+	content0, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: signedData})
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := asn1.Marshal(cmsContentInfo{ContentType: oidSignedData, Content: asn1.RawValue{FullBytes: content0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
@@ -0,0 +1,96 @@
+package parse
+
+import (
+	"math"
+	"strings"
+)
+
+const (
+	// coalesceBaselineTolerance は 同じベースラインとみなす Y 座標の差(フォントサイズに対する割合)
+	coalesceBaselineTolerance = 0.05
+	// coalesceMaxGap は 結合する前後のテキストの最大の隙間(フォントサイズに対する割合)
+	coalesceMaxGap = 1.0
+	// coalesceSpaceGap は 結合時に空白を補う隙間(フォントサイズに対する割合)
+	coalesceSpaceGap = 0.15
+)
+
+// coalesceTextCommands は 連続するテキストのうちフォント・サイズ・色・ベースラインが同じものを1つにまとめる
+// 幅が分からないテキストは隙間を判定できないため結合しない
+func coalesceTextCommands(commands []TextCommand) []TextCommand {
+	if len(commands) < 2 {
+		return commands
+	}
+	merged := make([]TextCommand, 0, len(commands))
+	merged = append(merged, commands[0])
+	for _, next := range commands[1:] {
+		prev := &merged[len(merged)-1]
+		gap, ok := coalescibleGap(prev, &next)
+		if !ok {
+			merged = append(merged, next)
+			continue
+		}
+		text := make([]string, 0, len(prev.Text)+len(next.Text)+1)
+		text = append(text, prev.Text...)
+		codes := make([]int, 0, len(prev.Codes)+len(next.Codes)+1)
+		codes = append(codes, prev.Codes...)
+		if gap > prev.FontSize*coalesceSpaceGap && !endsWithSpace(prev.Text) && !startsWithSpace(next.Text) {
+			// 補った空白に対応するグリフはない
+			text = append(text, " ")
+			codes = append(codes, -1)
+		}
+		prev.Text = append(text, next.Text...)
+		prev.Codes = append(codes, next.Codes...)
+		prev.Width = math.Max(prev.X+prev.Width, next.X+next.Width) - prev.X
+	}
+	return merged
+}
+
+// coalescibleGap は next を prev に結合できる場合に2つの間の隙間を返す
+func coalescibleGap(prev, next *TextCommand) (float64, bool) {
+	if !sameTextStyle(prev, next) {
+		return 0, false
+	}
+	size := prev.FontSize
+	if math.Abs(prev.Y-next.Y) > size*coalesceBaselineTolerance {
+		return 0, false
+	}
+	if prev.Width <= 0 || next.Width <= 0 {
+		return 0, false
+	}
+	gap := next.X - (prev.X + prev.Width)
+	if gap < -size*coalesceSpaceGap || gap > size*coalesceMaxGap {
+		return 0, false
+	}
+	return gap, true
+}
+
+// sameTextStyle は 2つのテキストのフォント・サイズ・色・合成方法・構造要素が同じかを返す
+func sameTextStyle(prev, next *TextCommand) bool {
+	if prev.FontID != next.FontID || prev.Color != next.Color || prev.MCID != next.MCID ||
+		prev.StrokeAlpha != next.StrokeAlpha || prev.FillAlpha != next.FillAlpha ||
+		prev.BlendMode != next.BlendMode || !sameClipPaths(prev.ClipPaths, next.ClipPaths) {
+		return false
+	}
+	size := prev.FontSize
+	return size > 0 && math.Abs(size-next.FontSize) <= size*coalesceBaselineTolerance
+}
+
+func sameClipPaths(a, b []ClipPath) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func endsWithSpace(text []string) bool {
+	return len(text) > 0 && strings.HasSuffix(text[len(text)-1], " ")
+}
+
+func startsWithSpace(text []string) bool {
+	return len(text) > 0 && strings.HasPrefix(text[0], " ")
+}
@@ -0,0 +1,99 @@
+package parse
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"sync"
+)
+
+// ImageCodecParams は 画像コーデックに渡す画像辞書の情報
+type ImageCodecParams struct {
+	Width            int
+	Height           int
+	BitsPerComponent int
+	ColorSpace       string
+	Components       int
+	DecodeParms      PDFObject // /DecodeParms (ない場合は nil)
+}
+
+// ImageCodec は 標準では扱えないフィルタ (JBIG2Decode / JPXDecode など) の画像を展開する
+// 独自のコーデックや CGo のライブラリを使うコーデックを RegisterImageCodec で追加できる
+type ImageCodec interface {
+	// Match は フィルタ名と色空間の画像を展開できるかを返す
+	Match(filter, colorSpace string) bool
+	// Decode は フィルタ適用前のストリームデータを画像に展開する
+	Decode(stream []byte, params ImageCodecParams) (image.Image, error)
+}
+
+var (
+	imageCodecsMu sync.RWMutex
+	imageCodecs   []ImageCodec
+)
+
+// RegisterImageCodec は 画像コーデックを登録する
+// 後から登録したコーデックを優先し、標準の処理より先に使う
+func RegisterImageCodec(codec ImageCodec) {
+	imageCodecsMu.Lock()
+	defer imageCodecsMu.Unlock()
+	imageCodecs = append([]ImageCodec{codec}, imageCodecs...)
+}
+
+// findImageCodec は フィルタと色空間に一致する登録済みのコーデックを返す
+func findImageCodec(filter, colorSpace string) (ImageCodec, bool) {
+	imageCodecsMu.RLock()
+	defer imageCodecsMu.RUnlock()
+	for _, codec := range imageCodecs {
+		if codec.Match(filter, colorSpace) {
+			return codec, true
+		}
+	}
+	return nil, false
+}
+
+// decodeWithCodec は コーデックで展開した画像を FlateDecode 画像と同じ zlib 圧縮した 8bit のサンプル列にする
+// グレースケールの画像は DeviceGray、それ以外は DeviceRGB として返す
+func decodeWithCodec(codec ImageCodec, stream []byte, format *imageFormat, decodeParms PDFObject) ([]byte, *imageFormat, error) {
+	img, err := codec.Decode(stream, ImageCodecParams{
+		Width:            format.Width,
+		Height:           format.Height,
+		BitsPerComponent: format.BitsPerComponent,
+		ColorSpace:       format.ColorSpace,
+		Components:       format.Components,
+		DecodeParms:      decodeParms,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("image codec for %s: %w", format.Filter, err)
+	}
+	bounds := img.Bounds()
+	decoded := &imageFormat{
+		Width:            bounds.Dx(),
+		Height:           bounds.Dy(),
+		BitsPerComponent: 8,
+		Filter:           "FlateDecode",
+	}
+	var samples []byte
+	if gray, ok := img.(*image.Gray); ok {
+		decoded.ColorSpace, decoded.Components = "DeviceGray", 1
+		samples = make([]byte, 0, decoded.Width*decoded.Height)
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			i := gray.PixOffset(bounds.Min.X, y)
+			samples = append(samples, gray.Pix[i:i+decoded.Width]...)
+		}
+	} else {
+		decoded.ColorSpace, decoded.Components = "DeviceRGB", 3
+		samples = make([]byte, 0, decoded.Width*decoded.Height*3)
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				r, g, b, _ := img.At(x, y).RGBA()
+				samples = append(samples, byte(r>>8), byte(g>>8), byte(b>>8))
+			}
+		}
+	}
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(samples)
+	zw.Close()
+	return buf.Bytes(), decoded, nil
+}
@@ -0,0 +1,97 @@
+package parse
+
+// CommandType は描画コマンドの種別を示す
+type CommandType int
+
+const (
+	CommandTypeText CommandType = iota
+	CommandTypeImage
+)
+
+// ClipPath は クリッピングパスとその内外判定規則を表す
+// 複数のクリッピングパスがある場合、有効な領域はそれらの共通部分となる
+type ClipPath struct {
+	Path     string `json:"path"`
+	FillRule string `json:"fillRule"` // nonzero / evenodd
+}
+
+type TextCommand struct {
+	X        float64    // X座標
+	Y        float64    // Y座標
+	Z        int64      // Z座標
+	Text     []string   // テキストの生バイト列
+	Codes    []int      // Text の各要素の文字コード (グリフを伴わない要素は -1)
+	MCID     int        // マークコンテンツID (-1 の場合はなし)
+	Words    []TextWord // 単語の区切り (GroupLines が有効な場合のみ)
+	Width    float64    // 表示幅 (グリフ幅が分かる場合)
+	FontID   string     // フォントID
+	FontSize float64    // フォントサイズ
+	Color    string     // テキストカラー
+
+	StrokeAlpha float64 // ストローク不透明度
+	FillAlpha   float64 // 塗り不透明度
+	BlendMode   string  // ブレンドモード
+
+	ClipPaths []ClipPath // 有効なクリッピングパス
+}
+
+type PathCommand struct {
+	X           float64
+	Y           float64
+	Z           int64
+	Width       float64
+	Height      float64
+	Path        string
+	PaintMode   string // 描き方 (fill / stroke / fillStroke)
+	FillRule    string // 塗りの規則 (nonzero / evenodd)、ストロークのみの場合は空文字
+	StrokeColor string
+	FillColor   string
+	StrokeAlpha float64
+	FillAlpha   float64
+	BlendMode   string
+	SoftMask    *SoftMask
+	ClipPaths   []ClipPath
+}
+
+type ImageCommand struct {
+	X        float64 // X座標
+	Y        float64 // Y座標
+	Z        int64   // Z座標
+	DW       float64 // 表示横幅
+	DH       float64 // 表示縦幅
+	Matrix   Matrix  // 単位正方形をユーザー空間へ配置する行列 (回転・反転を含む)
+	ImageID  string  // 画像ID
+	ImageRef PDFRef  // 画像XObjectの参照 (リソースから解決できた場合)
+	ClipPath string  // 画像クリップパス
+
+	StrokeAlpha float64   // ストローク不透明度
+	FillAlpha   float64   // 塗り不透明度
+	BlendMode   string    // ブレンドモード
+	SoftMask    *SoftMask // ソフトマスク
+	FillColor   string    // 塗り色 (ステンシルマスク用)
+
+	ClipPaths []ClipPath // 有効なクリッピングパス
+
+	Alt        string // マークコンテンツの代替テキスト (/Alt)
+	ActualText string // マークコンテンツの置き換えテキスト (/ActualText)
+	MCID       int    // マークコンテンツID (-1 の場合はなし)
+}
+
+type IDrawCommand interface {
+	GetTextCommand() *[]TextCommand
+	GetImageCommand() *[]ImageCommand
+}
+
+type DrawCommand struct {
+	contents      string
+	textCommands  []TextCommand
+	imageCommands []ImageCommand
+}
+
+func (dc *DrawCommand) GetTextCommand() *[]TextCommand {
+	return &dc.textCommands
+}
+
+func (dc *DrawCommand) GetImageCommand() *[]ImageCommand {
+	return &dc.imageCommands
+}
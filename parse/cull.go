@@ -0,0 +1,100 @@
+package parse
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// OffPagePolicy は ページの表示領域の外に描画されるコマンドの扱いを示す
+type OffPagePolicy int
+
+const (
+	// OffPageKeep は 表示領域外のコマンドもそのまま送る
+	OffPageKeep OffPagePolicy = iota
+	// OffPageDrop は 表示領域と全く重ならないコマンドを送らない
+	OffPageDrop
+	// OffPageFlag は 表示領域と全く重ならないコマンドに offPage を付けて送る
+	OffPageFlag
+)
+
+// Rect は 上端を原点とするページ座標系の矩形を表す
+type Rect struct {
+	X0, Y0, X1, Y1 float64
+}
+
+// newRect は 2点から正規化した矩形を作る
+func newRect(x0, y0, x1, y1 float64) Rect {
+	return Rect{
+		X0: math.Min(x0, x1),
+		Y0: math.Min(y0, y1),
+		X1: math.Max(x0, x1),
+		Y1: math.Max(y0, y1),
+	}
+}
+
+// Intersects は 2つの矩形が重なる(接する場合を含む)かを返す
+func (r Rect) Intersects(o Rect) bool {
+	return r.X0 <= o.X1 && o.X0 <= r.X1 && r.Y0 <= o.Y1 && o.Y0 <= r.Y1
+}
+
+// offPage は policy に従い、bounds がページの外にあるかを判定する
+// 戻り値は (送らないか, offPage フラグ)
+func (policy OffPagePolicy) offPage(page, bounds Rect) (bool, bool) {
+	if policy == OffPageKeep || page.Intersects(bounds) {
+		return false, false
+	}
+	return policy == OffPageDrop, policy == OffPageFlag
+}
+
+// textBounds は テキストのおおよその範囲を返す
+// グリフ幅が分からない場合は、1文字をフォントサイズ四方として見積もる
+func textBounds(cmd TextCommand) Rect {
+	size := math.Abs(cmd.FontSize)
+	width := cmd.Width
+	if width <= 0 {
+		width = float64(len(cmd.Text)) * size
+	}
+	return newRect(cmd.X, cmd.Y-size, cmd.X+width, cmd.Y)
+}
+
+// imageBounds は 画像(単位正方形を配置行列で変換したもの)の描画範囲を返す
+// 画像の座標は PDF のユーザー空間(下端が原点)なので上下を反転する
+func imageBounds(cmd ImageCommand, pageHeight float64) Rect {
+	var r Rect
+	for i, corner := range [][2]float64{{0, 0}, {1, 0}, {1, 1}, {0, 1}} {
+		x, y := cmd.Matrix.Transform(corner[0], corner[1])
+		y = pageHeight - y
+		if i == 0 {
+			r = newRect(x, y, x, y)
+			continue
+		}
+		r.X0, r.X1 = math.Min(r.X0, x), math.Max(r.X1, x)
+		r.Y0, r.Y1 = math.Min(r.Y0, y), math.Max(r.Y1, y)
+	}
+	return r
+}
+
+// pathBounds は SVG 形式のパスに含まれる全ての点を囲む矩形を返す
+func pathBounds(path string) (Rect, bool) {
+	var coords []float64
+	for _, field := range strings.Fields(path) {
+		v, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			// M / L / C / Z などのコマンド
+			continue
+		}
+		coords = append(coords, v)
+	}
+	if len(coords) < 2 {
+		return Rect{}, false
+	}
+	r := newRect(coords[0], coords[1], coords[0], coords[1])
+	for i := 2; i+1 < len(coords); i += 2 {
+		r.X0 = math.Min(r.X0, coords[i])
+		r.X1 = math.Max(r.X1, coords[i])
+		r.Y0 = math.Min(r.Y0, coords[i+1])
+		r.Y1 = math.Max(r.Y1, coords[i+1])
+	}
+	return r, true
+}
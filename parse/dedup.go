@@ -0,0 +1,47 @@
+package parse
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// runningContentBand は ヘッダー・フッターとみなすページ上端・下端からの範囲(ページ高さに対する割合)
+const runningContentBand = 0.12
+
+// sharedContentTracker は ページをまたいで同じ位置に現れるテキスト・パス(ヘッダー・フッター)を記録する
+type sharedContentTracker struct {
+	ids  map[string]string
+	next int
+}
+
+func newSharedContentTracker() *sharedContentTracker {
+	return &sharedContentTracker{ids: make(map[string]string)}
+}
+
+// share は key のコンテンツが既に送られていれば共有IDと true を返す
+// 初めての場合は新しい共有IDを割り当てて false を返す
+func (t *sharedContentTracker) share(key string) (string, bool) {
+	if id, ok := t.ids[key]; ok {
+		return id, true
+	}
+	t.next++
+	id := strconv.Itoa(t.next)
+	t.ids[key] = id
+	return id, false
+}
+
+// inRunningBand は 上端を原点とする y 座標がページ上端・下端の帯に含まれるかを返す
+func inRunningBand(y0, y1, pageHeight float64) bool {
+	band := pageHeight * runningContentBand
+	return (y0 <= band && y1 <= band) || (y0 >= pageHeight-band && y1 >= pageHeight-band)
+}
+
+func textContentKey(t *ParsedText) string {
+	return fmt.Sprintf("text|%.2f|%.2f|%s|%s|%.2f|%s|%g|%s|%v",
+		t.X, t.Y, t.Text, t.FontID, t.FontSize, t.Color, t.FillAlpha, t.BlendMode, t.ClipPaths)
+}
+
+func pathContentKey(p *ParsedPath) string {
+	return fmt.Sprintf("path|%s|%s|%s|%s|%s|%g|%g|%s|%v",
+		p.Path, p.PaintMode, p.FillRule, p.FillColor, p.StrokeColor, p.StrokeAlpha, p.FillAlpha, p.BlendMode, p.ClipPaths)
+}
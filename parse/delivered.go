@@ -0,0 +1,50 @@
+package parse
+
+import (
+	"strconv"
+	"sync"
+)
+
+// DeliveredFonts は クライアントに送ったフォントを記録する
+// 同じ文書の別のページ範囲の要求で、クライアントが既に持っているフォントを送り直さないようにする
+// 複数の要求から同時に使える
+type DeliveredFonts struct {
+	mu   sync.Mutex
+	sent map[string]bool
+}
+
+func NewDeliveredFonts() *DeliveredFonts {
+	return &DeliveredFonts{sent: make(map[string]bool)}
+}
+
+// delivered は フォントを以前の要求で送ったかを返す
+func (d *DeliveredFonts) delivered(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.sent[key]
+}
+
+// MarkSent は フォントチャンクを書き込めた後に、フォントを送ったものとして記録する
+// 送る前に記録すると、送信に失敗した・切断されたフォントを以降の要求で送らなくなるため、書き込んだ側が呼ぶ
+// DeliveredFonts で重複を除く対象でないフォントは記録しない
+func (d *DeliveredFonts) MarkSent(font *ParsedFont) {
+	if font.deliveredKey == "" {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sent[font.deliveredKey] = true
+}
+
+// Len は 記録しているフォントの数を返す
+func (d *DeliveredFonts) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.sent)
+}
+
+// deliveredFontKey は フォントIDとフォントファイルのオブジェクトからフォントを識別する
+// リソース名が同じでも別のフォントファイルを指す場合は別のフォントとして扱う
+func deliveredFontKey(fontID string, font Font) string {
+	return fontID + "@" + strconv.FormatInt(int64(font.FontDataRef), 10)
+}
@@ -0,0 +1,249 @@
+package parse
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+)
+
+// DocumentCache は 文書の xref テーブルとページツリーを要求をまたいで保持する
+// 同じ文書の別のページ範囲を続けて要求された場合に、xref テーブルとページツリーを読み直さない
+// 複数のパーサーから並行に使える。保持した内容は読み取りのみで、パーサーごとに変更しない
+type DocumentCache struct {
+	mu   sync.Mutex
+	docs *boundedCache[*preparedDocument]
+}
+
+// preparedDocument は パーサーの起動時に読み込む、文書の構造
+type preparedDocument struct {
+	xrefTable map[PDFRef]XRefTableElement
+	root      PDFRef
+	id        string // トレーラーの /ID (ない場合は空)
+	info      PDFRef // トレーラーの /Info (ない場合は 0)
+	pageQueue []Page
+}
+
+// NewDocumentCache は capacity 件の文書まで保持する DocumentCache を作成する (0 以下は上限なし)
+func NewDocumentCache(capacity int) *DocumentCache {
+	return &DocumentCache{docs: newBoundedCache[*preparedDocument](capacity)}
+}
+
+// Stats は キャッシュの利用状況を返す
+func (c *DocumentCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.docs.snapshot()
+}
+
+func (c *DocumentCache) get(key string) (*preparedDocument, bool) {
+	if c == nil || key == "" {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.docs.get(key)
+}
+
+func (c *DocumentCache) put(key string, doc *preparedDocument) {
+	if c == nil || key == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.docs.put(key, doc)
+}
+
+// documentKey は 文書の名前と、ファイルの版 (ETag または大きさと更新日時) からキャッシュのキーを作る
+// 版を確かめられないファイルは、内容が変わっても気付けないためキャッシュしない (空文字を返す)
+func documentKey(name string, file IPDFFile) string {
+	if name == "" {
+		return ""
+	}
+	if f, ok := file.(interface{ ETag() string }); ok {
+		if etag := f.ETag(); etag != "" {
+			return fmt.Sprintf("%s\x00etag:%s", name, etag)
+		}
+	}
+	if f, ok := file.(interface{ Stat() (fs.FileInfo, error) }); ok {
+		if info, err := f.Stat(); err == nil {
+			return fmt.Sprintf("%s\x00%d:%d", name, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return ""
+}
+
+// DocumentID は 文書の版を識別する文字列を返す
+// トレーラーの /ID (元の識別子と更新ごとに変わる識別子) を使い、/ID がない場合はファイルの内容のハッシュを求める
+// ハッシュを求める場合はファイルを全て読むため、最初に呼んだときだけ時間がかかる (DocumentCache にも保持する)
+func (p *PDFParser) DocumentID() (string, error) {
+	if p.documentID != "" {
+		return p.documentID, nil
+	}
+	id, err := hashFile(p.file)
+	if err != nil {
+		return "", err
+	}
+	p.documentID = id
+	p.cacheDocument()
+	return p.documentID, nil
+}
+
+// Document は 開いたファイルと、パーサーを作る前に読み込んだ文書の識別子
+// 条件付きの要求では ID で識別子だけを確かめ、応答を送る場合にだけ Parser で xref テーブルから読み込む
+type Document struct {
+	file   IPDFFile
+	config ParserConfig
+	key    string
+	doc    *preparedDocument // DocumentCache から取り出した、または読み込んだ文書の構造 (まだ読み込んでいない場合は nil)
+	id     string
+}
+
+// OpenDocument は ファイルを開き、DocumentCache に文書があれば取り出す
+// Parser を呼ばない場合は Close でファイルを閉じる
+func OpenDocument(open func() (IPDFFile, error), config ParserConfig) (*Document, error) {
+	file, err := open()
+	if err != nil {
+		return nil, err
+	}
+	d := &Document{file: file, config: config, key: documentKey(config.DocumentName, file)}
+	if doc, ok := config.DocumentCache.get(d.key); ok {
+		d.doc, d.id = doc, doc.id
+	}
+	return d, nil
+}
+
+// ID は PDFParser.DocumentID と同じ文書の識別子を、xref テーブルを読まずに返す
+// DocumentCache にある文書はファイルを読まず、ない場合は末尾のトレーラーの /ID だけを読む
+// /ID がない文書はハッシュを求め、xref テーブルと合わせて DocumentCache に保持する
+func (d *Document) ID() (string, error) {
+	if d.id != "" {
+		return d.id, nil
+	}
+	if d.doc == nil {
+		if id, ok := readTrailerID(d.file); ok && id != "" {
+			d.id = id
+			return id, nil
+		}
+		// 末尾にトレーラーがない・/ID がない場合は、xref テーブルから読み込む
+		if err := d.prepare(); err != nil {
+			return "", fmt.Errorf("%w: %w", ErrInvalidPDF, err)
+		}
+		if d.doc.id != "" {
+			d.id = d.doc.id
+			return d.id, nil
+		}
+	}
+	id, err := hashFile(d.file)
+	if err != nil {
+		return "", err
+	}
+	d.id = id
+	// DocumentCache の文書は共有するため、書き換えずに識別子を加えたものに置き換える
+	doc := *d.doc
+	doc.id = id
+	d.doc = &doc
+	d.config.DocumentCache.put(d.key, d.doc)
+	return id, nil
+}
+
+// Parser は 文書の構造を読み込んでパーサーを作る。ファイルはパーサーの Close で閉じる
+func (d *Document) Parser() (*PDFParser, error) {
+	if err := d.prepare(); err != nil {
+		d.file.Close()
+		// ファイルは開けたが、PDF として読めない
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPDF, err)
+	}
+	return newPDFParser(d.file, d.doc, d.key, d.config), nil
+}
+
+// Close は Parser を呼ばずにファイルを閉じる
+func (d *Document) Close() error {
+	return d.file.Close()
+}
+
+// prepare は 文書の構造をまだ読み込んでいなければ xref テーブルから読み込む
+func (d *Document) prepare() error {
+	if d.doc != nil {
+		return nil
+	}
+	doc, err := prepareDocument(d.file)
+	if err != nil {
+		return err
+	}
+	if d.id != "" {
+		// 末尾のトレーラーから読んだ識別子を使い、ID とパーサーで同じ値にする
+		doc.id = d.id
+	}
+	d.doc = doc
+	return nil
+}
+
+// trailerTailSize は 末尾のトレーラーを探すときに読むファイルの末尾の大きさ
+const trailerTailSize = 4096
+
+// readTrailerID は ファイルの末尾にある最後のトレーラーの /ID を読む (/ID がない場合は空)
+// 末尾にトレーラーが見つからない場合は ok が false
+func readTrailerID(file IPDFFile) (id string, ok bool) {
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return "", false
+	}
+	if _, err := file.Seek(max(size-trailerTailSize, 0), io.SeekStart); err != nil {
+		return "", false
+	}
+	tail, err := io.ReadAll(io.LimitReader(file, trailerTailSize))
+	if err != nil {
+		return "", false
+	}
+	start := bytes.LastIndex(tail, []byte("trailer"))
+	if start < 0 {
+		return "", false
+	}
+	trailer := tail[start+len("trailer"):]
+	if end := bytes.Index(trailer, []byte("startxref")); end >= 0 {
+		trailer = trailer[:end]
+	}
+	dict, err := parseMetadata(string(trailer))
+	if err != nil {
+		return "", false
+	}
+	return trailerID(dict), true
+}
+
+// hashFile は ファイルの内容のハッシュを文書の識別子として返す
+func hashFile(file IPDFFile) (string, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// trailerID は トレーラーの /ID の2つの文字列をつなげて返す (ない場合は空)
+func trailerID(trailer PDFObject) string {
+	obj, found := findTarget(trailer, "ID")
+	if !found {
+		return ""
+	}
+	ids, ok := obj.([]PDFObject)
+	if !ok || len(ids) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		s, ok := id.(string)
+		if !ok || s == "" {
+			return ""
+		}
+		parts = append(parts, s)
+	}
+	return "id:" + strings.Join(parts, ":")
+}
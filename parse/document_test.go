@@ -0,0 +1,172 @@
+package parse
+
+import (
+	"bytes"
+	"io/fs"
+	"sync"
+	"testing"
+	"time"
+)
+
+// etagFile は ETag で版を示すファイル
+type etagFile struct {
+	nopFile
+	etag string
+}
+
+func (f etagFile) ETag() string { return f.etag }
+
+// statFile は 大きさと更新日時で版を示すファイル
+type statFile struct {
+	nopFile
+	modTime time.Time
+}
+
+func (f statFile) Stat() (fs.FileInfo, error) {
+	return fileInfo{size: f.Size(), modTime: f.modTime}, nil
+}
+
+type fileInfo struct {
+	fs.FileInfo
+	size    int64
+	modTime time.Time
+}
+
+func (i fileInfo) Size() int64        { return i.size }
+func (i fileInfo) ModTime() time.Time { return i.modTime }
+
+// TestDocumentKey は ETag、または大きさと更新日時が変わるとキーが変わり、版を確かめられないファイルをキャッシュしないことを確かめる
+func TestDocumentKey(t *testing.T) {
+	file := func(data string) nopFile { return nopFile{bytes.NewReader([]byte(data))} }
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	base := documentKey("a.pdf", etagFile{file("x"), "v1"})
+	for _, test := range []struct {
+		name  string
+		key   string
+		equal bool
+	}{
+		{"same etag", documentKey("a.pdf", etagFile{file("y"), "v1"}), true},
+		{"another etag", documentKey("a.pdf", etagFile{file("x"), "v2"}), false},
+		{"another name", documentKey("b.pdf", etagFile{file("x"), "v1"}), false},
+		{"stat instead of etag", documentKey("a.pdf", statFile{file("x"), modTime}), false},
+	} {
+		if got := test.key == base; got != test.equal {
+			t.Errorf("%s: key %q == %q is %v, want %v", test.name, test.key, base, got, test.equal)
+		}
+	}
+
+	stat := documentKey("a.pdf", statFile{file("xx"), modTime})
+	if documentKey("a.pdf", statFile{file("xx"), modTime}) != stat {
+		t.Error("the same size and modification time gave another key")
+	}
+	if documentKey("a.pdf", statFile{file("xxx"), modTime}) == stat {
+		t.Error("another size gave the same key")
+	}
+	if documentKey("a.pdf", statFile{file("xx"), modTime.Add(time.Second)}) == stat {
+		t.Error("another modification time gave the same key")
+	}
+
+	for name, key := range map[string]string{
+		"no version":   documentKey("a.pdf", file("x")),
+		"empty etag":   documentKey("a.pdf", etagFile{file("x"), ""}),
+		"without name": documentKey("", etagFile{file("x"), "v1"}),
+	} {
+		if key != "" {
+			t.Errorf("%s: key = %q, want empty", name, key)
+		}
+	}
+}
+
+// cachedText は DocumentCache を使って file を開き、1ページ目のテキストと、キャッシュから文書を取り出したかどうかを返す
+func cachedText(cache *DocumentCache, file IPDFFile) (string, bool, error) {
+	d, err := OpenDocument(func() (IPDFFile, error) { return file, nil }, ParserConfig{
+		DocumentCache: cache,
+		DocumentName:  "a.pdf",
+		Logger:        quietLogger(),
+	})
+	if err != nil {
+		return "", false, err
+	}
+	cached := d.doc != nil
+	p, err := d.Parser()
+	if err != nil {
+		return "", cached, err
+	}
+	defer p.Close()
+	text, err := p.ExtractText(1)
+	return text, cached, err
+}
+
+// TestDocumentCache は 同じ版のファイルでは保持した xref テーブルとページツリーを使い、
+// ETag や大きさ・更新日時が変わったファイルでは読み直すことを確かめる
+func TestDocumentCache(t *testing.T) {
+	first := textPDF("BT /F1 12 Tf 1 0 0 1 100 700 Tm (First) Tj ET")
+	// オブジェクトの位置が変わるため、古い xref テーブルを使うと読めない
+	second := textPDF("BT /F1 12 Tf 1 0 0 1 100 700 Tm (Second version) Tj ET")
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, test := range []struct {
+		name                     string
+		before, reopen, modified IPDFFile
+	}{
+		{
+			name:     "etag",
+			before:   etagFile{nopFile{bytes.NewReader(first)}, "v1"},
+			reopen:   etagFile{nopFile{bytes.NewReader(first)}, "v1"},
+			modified: etagFile{nopFile{bytes.NewReader(second)}, "v2"},
+		},
+		{
+			name:     "size and modification time",
+			before:   statFile{nopFile{bytes.NewReader(first)}, modTime},
+			reopen:   statFile{nopFile{bytes.NewReader(first)}, modTime},
+			modified: statFile{nopFile{bytes.NewReader(second)}, modTime.Add(time.Second)},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cache := NewDocumentCache(4)
+			for _, step := range []struct {
+				name   string
+				file   IPDFFile
+				text   string
+				cached bool
+			}{
+				{"first open", test.before, "First", false},
+				{"same version", test.reopen, "First", true},
+				{"modified file", test.modified, "Second version", false},
+			} {
+				text, cached, err := cachedText(cache, step.file)
+				if err != nil {
+					t.Fatalf("%s: %v", step.name, err)
+				}
+				if text != step.text || cached != step.cached {
+					t.Errorf("%s: text %q, cached %v, want %q, %v", step.name, text, cached, step.text, step.cached)
+				}
+			}
+			if stats := cache.Stats(); stats.Entries != 2 || stats.Hits != 1 {
+				t.Errorf("stats = %+v, want 2 entries and 1 hit", stats)
+			}
+		})
+	}
+}
+
+// TestDocumentCacheConcurrent は 複数のパーサーが同じ文書を並行に開いて読んでも、保持した構造を壊さないことを確かめる (-race で実行する)
+func TestDocumentCacheConcurrent(t *testing.T) {
+	data := textPDF("BT /F1 12 Tf 1 0 0 1 100 700 Tm (Hello) Tj ET")
+	cache := NewDocumentCache(4)
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 4 {
+				text, _, err := cachedText(cache, etagFile{nopFile{bytes.NewReader(data)}, "v1"})
+				if err != nil || text != "Hello" {
+					t.Errorf("text = %q, %v, want %q", text, err, "Hello")
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if stats := cache.Stats(); stats.Entries != 1 || stats.Hits == 0 {
+		t.Errorf("stats = %+v, want 1 entry and some hits", stats)
+	}
+}
@@ -0,0 +1,61 @@
+package parse
+
+// streamSummary は 送ったデータを種別ごとに数え、ストリームの最後に DoneChunk として送る
+type streamSummary struct {
+	counts     ParsedDone
+	insertData func(data ParsedData)
+}
+
+func newStreamSummary(insertData func(data ParsedData)) *streamSummary {
+	return &streamSummary{insertData: insertData}
+}
+
+// insert は データを数えてから送る
+func (s *streamSummary) insert(data ParsedData) {
+	switch data.(type) {
+	case *ParsedPage:
+		s.counts.Pages++
+	case *ParsedText:
+		s.counts.Texts++
+	case *ParsedImage:
+		s.counts.Images++
+	case *ParsedPath:
+		s.counts.Paths++
+	case *ParsedFont:
+		s.counts.Fonts++
+	case *ParsedSharedContent:
+		s.counts.Shared++
+	case *ParsedAnnotation:
+		s.counts.Annotations++
+	case *ParsedLink:
+		s.counts.Links++
+	case *ParsedAttachment:
+		s.counts.Attachments++
+	case *ParsedWarning:
+		s.counts.Warnings++
+	case *ParsedSearchResult:
+		s.counts.SearchResults++
+	case *ParsedThumbnail:
+		s.counts.Thumbnails++
+	case *ParsedICCProfile:
+		s.counts.ICCProfiles++
+	case *ParsedPageSummary:
+		s.counts.PageSummaries++
+	case *ParsedImagePlacement:
+		s.counts.Placements++
+	case *ParsedMetadata:
+		s.counts.Metadata++
+	}
+	s.insertData(data)
+}
+
+// pageDone は ページのデータをすべて送り終えたことを送る
+func (s *streamSummary) pageDone(page int64) {
+	s.insertData(&ParsedPageDone{Page: page})
+}
+
+// done は ストリームの終わりと件数を送る
+func (s *streamSummary) done() {
+	counts := s.counts
+	s.insertData(&counts)
+}
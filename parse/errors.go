@@ -0,0 +1,23 @@
+package parse
+
+import (
+	"errors"
+)
+
+var (
+	ErrParserDeCompressionError      = errors.New("decompression error")
+	ErrParserParseObjectError        = errors.New("parse object error")
+	ErrParserReadStreamError         = errors.New("read stream error")
+	ErrParserStreamLengthError       = errors.New("stream length mismatch")
+	ErrFontInvalid                   = errors.New("invalid font")
+	ErrAttachmentNotFound            = errors.New("attachment not found")
+	ErrSignatureUnsupported          = errors.New("unsupported signature format")
+	ErrSignatureInvalid              = errors.New("invalid signature")
+	ErrSignatureAlgorithmUnsupported = errors.New("unsupported signature algorithm")
+	ErrSignatureUntrusted            = errors.New("signer certificate is not trusted")
+	ErrStreamEncrypted               = errors.New("stream is encrypted")
+	ErrOperatorBudgetExceeded        = errors.New("operator budget exceeded")
+	ErrPageTimeout                   = errors.New("page extraction timed out")
+	ErrInvalidPDF                    = errors.New("invalid PDF")
+	ErrNoPagesSelected               = errors.New("no pages in the document are selected")
+)
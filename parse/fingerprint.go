@@ -0,0 +1,110 @@
+package parse
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"sort"
+)
+
+// fingerprintSkipKeys は ページの内容に影響しない逆参照のキー
+// (親のページツリーや構造ツリーをたどると文書全体を読むことになる)
+var fingerprintSkipKeys = map[string]bool{
+	"Parent":         true,
+	"P":              true,
+	"Pg":             true,
+	"StructTreeRoot": true,
+}
+
+// pageFingerprint は ページが参照するオブジェクト(内容・リソース・注釈)の内容から計算したハッシュを返す
+// 文書の版が変わってもページの内容が同じであれば同じ値になる
+func (p *PDFParser) pageFingerprint(page *Page) (string, error) {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%g %g ", page.PageWidth, page.PageHeight)
+	for _, ref := range []PDFRef{page.Ref, page.ContentsRef, page.ResourcesRef} {
+		if ref == 0 {
+			continue
+		}
+		digest, err := p.objectDigest(ref, map[PDFRef]bool{})
+		if err != nil {
+			return "", err
+		}
+		binary.Write(h, binary.BigEndian, digest)
+	}
+	return fmt.Sprintf("%016x", h.Sum64()), nil
+}
+
+// objectDigest は オブジェクトとそこから参照されるオブジェクトの内容のハッシュを返す
+// フォントや画像はページ間で共有されるため、オブジェクトごとの値を保持して再計算を避ける
+func (p *PDFParser) objectDigest(ref PDFRef, visiting map[PDFRef]bool) (uint64, error) {
+	if digest, ok := p.objectDigests[ref]; ok {
+		return digest, nil
+	}
+	if visiting[ref] {
+		// 循環参照は参照先の内容を含めない
+		return 0, nil
+	}
+	visiting[ref] = true
+	defer delete(visiting, ref)
+
+	object, err := p.ParseObject(ref)
+	if err != nil {
+		return 0, err
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%v", object)
+	if dict, ok := object.(map[string]PDFObject); ok {
+		if _, isStream := dict["Length"]; isStream {
+			stream, err := p.ParseStreamObject(ref)
+			if err != nil {
+				return 0, err
+			}
+			raw, err := stream.Raw()
+			if err != nil {
+				return 0, err
+			}
+			h.Write(raw)
+		}
+	}
+	for _, child := range referencedObjects(object) {
+		digest, err := p.objectDigest(child, visiting)
+		if err != nil {
+			return 0, err
+		}
+		binary.Write(h, binary.BigEndian, digest)
+	}
+	digest := h.Sum64()
+	p.objectDigests[ref] = digest
+	return digest, nil
+}
+
+// referencedObjects は オブジェクト中の間接参照を重複なく昇順で返す
+func referencedObjects(object PDFObject) []PDFRef {
+	seen := map[PDFRef]bool{}
+	var walk func(obj PDFObject)
+	walk = func(obj PDFObject) {
+		switch v := obj.(type) {
+		case string:
+			if ref, ok := parseRef(v); ok {
+				seen[ref] = true
+			}
+		case []PDFObject:
+			for _, item := range v {
+				walk(item)
+			}
+		case map[string]PDFObject:
+			for key, item := range v {
+				if !fingerprintSkipKeys[key] {
+					walk(item)
+				}
+			}
+		}
+	}
+	walk(object)
+	refs := make([]PDFRef, 0, len(seen))
+	for ref := range seen {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i] < refs[j] })
+	return refs
+}
@@ -1,9 +1,10 @@
-package pdtp
+package parse
 
 import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"slices"
 )
 
 // OffsetTable は TTF/OTF の先頭にある Offset Table (sfnt header) を表す。
@@ -24,7 +25,10 @@ type TableRecord struct {
 }
 
 // fixOS2Table は TTF データを読み込み、OS/2 テーブルがなければ追加して返す。
+// 元のデータは変更しない
 func fixOS2Table(fontData []byte) ([]byte, error) {
+	// 末尾への追記で呼び出し元の配列を書き換えないようにする
+	fontData = slices.Clip(fontData)
 	// 1. Offset Table のパース
 	if len(fontData) < 12 {
 		return nil, fmt.Errorf("input too short for offset table")
@@ -110,6 +114,12 @@ func fixOS2Table(fontData []byte) ([]byte, error) {
 	//   4) checkSumAdjustment = 0xB1B0AFBA - fileChecksum
 	//   5) head テーブルに再度書き込み
 
+	// ディレクトリが増えた分だけテーブル本体が後ろにずれるため、各テーブルのオフセットをずらす
+	shift := uint32(int(ot.NumTables)*16 - dirSize)
+	for i := range directory {
+		directory[i].Offset += shift
+	}
+
 	// 新たなバッファに書き出して返す
 	outBuf := new(bytes.Buffer)
 
@@ -154,13 +164,7 @@ func fixOS2Table(fontData []byte) ([]byte, error) {
 	// offsetTable + directory のサイズぶんだけ読み飛ばし → 残りを付与、という簡易方針を取ります。
 
 	// もともとのファイル先頭(Offset Table + Directory)ぶんを読み飛ばす
-	oldDataPos := 12 + (int(ot.NumTables)-1)*16 // (追加前のNumTables-1)に注意
-	if oldDataPos < 0 {
-		oldDataPos = 12 // fallback
-	}
-	if oldDataPos > len(fontData) {
-		oldDataPos = len(fontData)
-	}
+	oldDataPos := 12 + dirSize
 	// もとのデータのテーブル本体部分をそのまま書き込む
 	outBuf.Write(fontData[oldDataPos:])
 
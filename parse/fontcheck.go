@@ -0,0 +1,254 @@
+package parse
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+)
+
+// sfnt のバージョン
+const (
+	sfntVersionTrueType = 0x00010000
+	sfntVersionApple    = 0x74727565 // 'true'
+	sfntVersionCFF      = 0x4F54544F // 'OTTO'
+)
+
+// requiredFontTables は クライアントのフォントスタックが描画に必要とするテーブル
+var requiredFontTables = []string{"head", "hhea", "maxp", "hmtx"}
+
+// validateFont は 送信前にフォントファイルの構造を検証する
+// sfnt ヘッダー・テーブルの範囲・必須テーブル・グリフ数の整合性を確認し、壊れていればエラーを返す
+// テーブルのチェックサムはサブセット化で更新されていないことが多いため、
+// 一致しないテーブルは送信を止めずに返り値で知らせる
+func validateFont(data []byte) (mismatched []string, err error) {
+	ot, err := parseOffsetTable(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFontInvalid, err)
+	}
+	switch ot.SfntVersion {
+	case sfntVersionTrueType, sfntVersionApple, sfntVersionCFF:
+	default:
+		return nil, fmt.Errorf("%w: unknown sfnt version %#08x", ErrFontInvalid, ot.SfntVersion)
+	}
+	if ot.NumTables == 0 {
+		return nil, fmt.Errorf("%w: no tables", ErrFontInvalid)
+	}
+	directory, err := parseTableDirectory(data[12:], int(ot.NumTables))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFontInvalid, err)
+	}
+	tables := make(map[string][]byte, len(directory))
+	for _, rec := range directory {
+		tag := tagUint32ToString(rec.Tag)
+		end := uint64(rec.Offset) + uint64(rec.Length)
+		if end > uint64(len(data)) {
+			return nil, fmt.Errorf("%w: table %q is out of range", ErrFontInvalid, tag)
+		}
+		table := data[rec.Offset:end]
+		sum := calcTableChecksum(data, int(rec.Offset), int(rec.Length))
+		if tag == "head" && len(table) >= 12 {
+			// head のチェックサムは checkSumAdjustment を0として計算する
+			sum -= binary.BigEndian.Uint32(table[8:12])
+		}
+		// サブセット化したフォントではチェックサムが0のまま埋め込まれていることがある
+		if rec.CheckSum != 0 && sum != rec.CheckSum {
+			mismatched = append(mismatched, tag)
+		}
+		tables[tag] = table
+	}
+	for _, tag := range requiredFontTables {
+		if _, ok := tables[tag]; !ok {
+			return nil, fmt.Errorf("%w: missing required table %q", ErrFontInvalid, tag)
+		}
+	}
+	if ot.SfntVersion != sfntVersionCFF {
+		for _, tag := range []string{"loca", "glyf"} {
+			if _, ok := tables[tag]; !ok {
+				return nil, fmt.Errorf("%w: missing required table %q", ErrFontInvalid, tag)
+			}
+		}
+	}
+	return mismatched, validateGlyphCounts(tables, ot.SfntVersion != sfntVersionCFF)
+}
+
+// validateGlyphCounts は maxp のグリフ数と hhea / hmtx / loca の大きさが整合しているかを確認する
+func validateGlyphCounts(tables map[string][]byte, hasGlyf bool) error {
+	head, hhea, maxp, hmtx := tables["head"], tables["hhea"], tables["maxp"], tables["hmtx"]
+	if len(head) < 54 || len(hhea) < 36 || len(maxp) < 6 {
+		return fmt.Errorf("%w: head, hhea or maxp is too short", ErrFontInvalid)
+	}
+	numGlyphs := int(binary.BigEndian.Uint16(maxp[4:6]))
+	if numGlyphs == 0 {
+		return fmt.Errorf("%w: no glyphs", ErrFontInvalid)
+	}
+	numHMetrics := int(binary.BigEndian.Uint16(hhea[34:36]))
+	if numHMetrics == 0 || numHMetrics > numGlyphs {
+		return fmt.Errorf("%w: numberOfHMetrics %d is inconsistent with %d glyphs", ErrFontInvalid, numHMetrics, numGlyphs)
+	}
+	if len(hmtx) < numHMetrics*4+(numGlyphs-numHMetrics)*2 {
+		return fmt.Errorf("%w: hmtx is too short for %d glyphs", ErrFontInvalid, numGlyphs)
+	}
+	if !hasGlyf {
+		return nil
+	}
+	// indexToLocFormat が0なら16ビット、1なら32ビットのオフセット
+	entrySize := 2
+	if binary.BigEndian.Uint16(head[50:52]) == 1 {
+		entrySize = 4
+	}
+	loca, glyf := tables["loca"], tables["glyf"]
+	if len(loca) < (numGlyphs+1)*entrySize {
+		return fmt.Errorf("%w: loca is too short for %d glyphs", ErrFontInvalid, numGlyphs)
+	}
+	last := locaOffset(loca, numGlyphs, entrySize)
+	if last > len(glyf) {
+		return fmt.Errorf("%w: loca points past the end of glyf", ErrFontInvalid)
+	}
+	return nil
+}
+
+func locaOffset(loca []byte, i, entrySize int) int {
+	if entrySize == 2 {
+		return int(binary.BigEndian.Uint16(loca[i*2:])) * 2
+	}
+	return int(binary.BigEndian.Uint32(loca[i*4:]))
+}
+
+// tagUint32ToString は テーブルタグを4文字の文字列に戻す
+func tagUint32ToString(tag uint32) string {
+	return string([]byte{byte(tag >> 24), byte(tag >> 16), byte(tag >> 8), byte(tag)})
+}
+
+// FontDescriptor の /Flags
+const (
+	fontFlagFixedPitch = 1 << 0
+	fontFlagSerif      = 1 << 1
+)
+
+// substituteFontFamily は 送信できないフォントの代わりにクライアントが使う総称フォントファミリーを返す
+func substituteFontFamily(flags int) string {
+	switch {
+	case flags&fontFlagFixedPitch != 0:
+		return "monospace"
+	case flags&fontFlagSerif != 0:
+		return "serif"
+	}
+	return "sans-serif"
+}
+
+// FsTypePolicy は 埋め込みフォントの OS/2 fsType (埋め込み制限)の扱いを示す
+type FsTypePolicy int
+
+const (
+	// FsTypeWarn は 制限のあるフォントもそのまま送り、ログに警告を出す
+	FsTypeWarn FsTypePolicy = iota
+	// FsTypeHonor は 表示への埋め込みが許可されていないフォントを送らず、代替フォントを指示する
+	FsTypeHonor
+	// FsTypeStrip は fsType を0(インストール可能)に書き換えて送る
+	FsTypeStrip
+)
+
+// OS/2 fsType のビット
+const (
+	fsTypeRestricted = 0x0002 // 制限付きライセンス (埋め込み不可)
+	fsTypeBitmapOnly = 0x0200 // ビットマップのみ埋め込み可
+)
+
+// fontFsType は フォントの OS/2 テーブルと fsType の位置を返す
+func fontFsType(data []byte) (TableRecord, uint16, bool) {
+	ot, err := parseOffsetTable(data)
+	if err != nil || len(data) < 12+int(ot.NumTables)*16 {
+		return TableRecord{}, 0, false
+	}
+	directory, err := parseTableDirectory(data[12:], int(ot.NumTables))
+	if err != nil {
+		return TableRecord{}, 0, false
+	}
+	for _, rec := range directory {
+		if rec.Tag != tagStringToUint32("OS/2") {
+			continue
+		}
+		if rec.Length < 10 || uint64(rec.Offset)+10 > uint64(len(data)) {
+			return TableRecord{}, 0, false
+		}
+		return rec, binary.BigEndian.Uint16(data[rec.Offset+8:]), true
+	}
+	return TableRecord{}, 0, false
+}
+
+// applyFsTypePolicy は policy に従って fsType を確認する
+// 送信できない場合は false を返し、FsTypeStrip の場合は書き換えたフォントを返す
+func applyFsTypePolicy(logger *slog.Logger, policy FsTypePolicy, fontID string, data []byte) ([]byte, bool) {
+	rec, fsType, ok := fontFsType(data)
+	if !ok || fsType == 0 {
+		return data, true
+	}
+	restricted := fsType&(fsTypeRestricted|fsTypeBitmapOnly) != 0
+	switch policy {
+	case FsTypeHonor:
+		if restricted {
+			logger.Warn("Font is not sent: embedding is restricted", "font", fontID, "fsType", fmt.Sprintf("%#04x", fsType))
+			return nil, false
+		}
+	case FsTypeStrip:
+		stripped := append([]byte(nil), data...)
+		binary.BigEndian.PutUint16(stripped[rec.Offset+8:], 0)
+		if rec.CheckSum != 0 {
+			if i, ok := tableRecordIndex(stripped, rec.Tag); ok {
+				binary.BigEndian.PutUint32(stripped[12+i*16+4:], calcTableChecksum(stripped, int(rec.Offset), int(rec.Length)))
+			}
+		}
+		return stripped, true
+	default:
+		if restricted {
+			logger.Warn("Font has embedding restrictions", "font", fontID, "fsType", fmt.Sprintf("%#04x", fsType))
+		}
+	}
+	return data, true
+}
+
+// FontFixPolicy は 送信前にフォントへ OS/2 テーブルを補う修正の扱いを示す
+type FontFixPolicy string
+
+const (
+	// FontFixAuto は OS/2 テーブルがない・壊れているフォントだけを修正する (空文字列も同じ)
+	FontFixAuto FontFixPolicy = "auto"
+	// FontFixAlways は すべてのフォントの OS/2 テーブルを最小限のテーブルに置き換える
+	FontFixAlways FontFixPolicy = "always"
+	// FontFixNever は フォントを修正せずに送る
+	FontFixNever FontFixPolicy = "never"
+)
+
+// applyFontFix は policy に従ってフォントに OS/2 テーブルを補う
+// 修正に失敗した場合や、修正したフォントが検証を通らない場合は元のフォントを返す
+func applyFontFix(logger *slog.Logger, policy FontFixPolicy, fontID string, data []byte) []byte {
+	switch policy {
+	case FontFixNever:
+		return data
+	case FontFixAlways:
+	default:
+		if _, _, ok := fontFsType(data); ok {
+			return data
+		}
+	}
+	fixed, err := fixOS2Table(data)
+	if err == nil {
+		_, err = validateFont(fixed)
+	}
+	if err != nil {
+		logger.Warn("Font is sent without fixing", "font", fontID, "err", err)
+		return data
+	}
+	return fixed
+}
+
+// tableRecordIndex は テーブルディレクトリ内の tag の位置を返す
+func tableRecordIndex(data []byte, tag uint32) (int, bool) {
+	numTables := int(binary.BigEndian.Uint16(data[4:6]))
+	for i := 0; i < numTables; i++ {
+		if binary.BigEndian.Uint32(data[12+i*16:]) == tag {
+			return i, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,206 @@
+package parse
+
+import (
+	"encoding/binary"
+	"strings"
+	"unicode/utf16"
+)
+
+// FontStyle は フォントの読み込みに失敗した場合にクライアントが代替フォントを選ぶための情報
+type FontStyle struct {
+	Family      string  // ファミリー名
+	Weight      int     // 太さ (100〜900)
+	ItalicAngle float64 // 斜体の角度 (度、反時計回り)
+	Italic      bool
+	Serif       bool
+	FixedPitch  bool
+}
+
+// FontDescriptor の /Flags (fontFlagFixedPitch / fontFlagSerif 以外)
+const (
+	fontFlagItalic    = 1 << 6
+	fontFlagForceBold = 1 << 18
+)
+
+// fontStyleFromDescriptor は FontDescriptor と /BaseFont から書体の情報を読み込む
+func fontStyleFromDescriptor(descriptor PDFObject, baseFont string, flags int) FontStyle {
+	name := baseFont
+	if fontName, found := findTarget(descriptor, "FontName"); found {
+		if s, ok := fontName.(string); ok {
+			name = s
+		}
+	}
+	// サブセットのフォント名は ABCDEF+ で始まる
+	if i := strings.IndexByte(name, '+'); i == 6 {
+		name = name[i+1:]
+	}
+	style := FontStyle{
+		Family:     fontFamilyFromName(name),
+		Weight:     400,
+		Italic:     flags&fontFlagItalic != 0,
+		Serif:      flags&fontFlagSerif != 0,
+		FixedPitch: flags&fontFlagFixedPitch != 0,
+	}
+	if family, found := findTarget(descriptor, "FontFamily"); found {
+		if s, ok := family.(string); ok && s != "" {
+			style.Family = textStringFrom(s)
+		}
+	}
+	if angle, found := findTarget(descriptor, "ItalicAngle"); found {
+		style.ItalicAngle, _ = toFloat(angle)
+	}
+	if style.ItalicAngle != 0 {
+		style.Italic = true
+	}
+	lower := strings.ToLower(name)
+	if weight, found := findTarget(descriptor, "FontWeight"); found {
+		if w, ok := toFloat(weight); ok && w > 0 {
+			style.Weight = int(w)
+		}
+	} else if flags&fontFlagForceBold != 0 || strings.Contains(lower, "bold") || strings.Contains(lower, "heavy") || strings.Contains(lower, "black") {
+		style.Weight = 700
+	}
+	if strings.Contains(lower, "italic") || strings.Contains(lower, "oblique") {
+		style.Italic = true
+	}
+	return style
+}
+
+// fontFamilyFromName は PostScript 名 (Arial-BoldMT, Times New Roman,Bold など)からファミリー名を推定する
+func fontFamilyFromName(name string) string {
+	if i := strings.IndexAny(name, "-,"); i > 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// applySfntStyle は フォントファイルの name / OS/2 テーブルの値で書体の情報を補う
+func applySfntStyle(style *FontStyle, data []byte) {
+	ot, err := parseOffsetTable(data)
+	if err != nil || len(data) < 12+int(ot.NumTables)*16 {
+		return
+	}
+	directory, err := parseTableDirectory(data[12:], int(ot.NumTables))
+	if err != nil {
+		return
+	}
+	for _, rec := range directory {
+		end := uint64(rec.Offset) + uint64(rec.Length)
+		if end > uint64(len(data)) {
+			continue
+		}
+		table := data[rec.Offset:end]
+		switch tagUint32ToString(rec.Tag) {
+		case "name":
+			if family := sfntFamilyName(table); family != "" {
+				style.Family = family
+			}
+		case "OS/2":
+			if len(table) >= 6 {
+				if weight := int(binary.BigEndian.Uint16(table[4:6])); weight >= 100 && weight <= 1000 {
+					style.Weight = weight
+				}
+			}
+		}
+	}
+}
+
+// sfntFamilyName は name テーブルから英語のファミリー名を返す
+// タイポグラフィックファミリー名 (ID 16) を優先し、なければファミリー名 (ID 1) を使う
+func sfntFamilyName(table []byte) string {
+	if len(table) < 6 {
+		return ""
+	}
+	count := int(binary.BigEndian.Uint16(table[2:4]))
+	storage := int(binary.BigEndian.Uint16(table[4:6]))
+	names := make(map[uint16]string)
+	for i := 0; i < count; i++ {
+		rec := 6 + i*12
+		if rec+12 > len(table) {
+			break
+		}
+		platform := binary.BigEndian.Uint16(table[rec:])
+		language := binary.BigEndian.Uint16(table[rec+4:])
+		nameID := binary.BigEndian.Uint16(table[rec+6:])
+		length := int(binary.BigEndian.Uint16(table[rec+8:]))
+		offset := storage + int(binary.BigEndian.Uint16(table[rec+10:]))
+		if (nameID != 1 && nameID != 16) || offset+length > len(table) {
+			continue
+		}
+		raw := table[offset : offset+length]
+		switch {
+		case platform == 3 && language == 0x0409:
+			// Windows (UTF-16BE、英語)
+			units := make([]uint16, len(raw)/2)
+			for j := range units {
+				units[j] = binary.BigEndian.Uint16(raw[j*2:])
+			}
+			names[nameID] = string(utf16.Decode(units))
+		case platform == 1 && language == 0:
+			// Macintosh (Roman、英語)
+			if _, ok := names[nameID]; !ok {
+				names[nameID] = string(raw)
+			}
+		}
+	}
+	if family := names[16]; family != "" {
+		return family
+	}
+	return names[1]
+}
+
+// FontMetrics は テキストの縦方向の配置に使うフォントの寸法
+// Ascent と Descent は UnitsPerEm を1emとする単位で、Descent はベースラインより下を負の値で表す
+type FontMetrics struct {
+	Ascent     float64
+	Descent    float64
+	UnitsPerEm int
+}
+
+// fontMetricsFromDescriptor は FontDescriptor の /Ascent と /Descent (1/1000 em)を読み込む
+func fontMetricsFromDescriptor(descriptor PDFObject) FontMetrics {
+	metrics := FontMetrics{UnitsPerEm: 1000}
+	if ascent, found := findTarget(descriptor, "Ascent"); found {
+		metrics.Ascent, _ = toFloat(ascent)
+	}
+	if descent, found := findTarget(descriptor, "Descent"); found {
+		metrics.Descent, _ = toFloat(descent)
+	}
+	return metrics
+}
+
+// applySfntMetrics は フォントファイルの head / hhea テーブルから寸法を読み込む
+// 埋め込まれたグリフと一致するため、読み込めた場合は FontDescriptor の値より優先する
+func applySfntMetrics(metrics *FontMetrics, data []byte) {
+	ot, err := parseOffsetTable(data)
+	if err != nil || len(data) < 12+int(ot.NumTables)*16 {
+		return
+	}
+	directory, err := parseTableDirectory(data[12:], int(ot.NumTables))
+	if err != nil {
+		return
+	}
+	var head, hhea []byte
+	for _, rec := range directory {
+		end := uint64(rec.Offset) + uint64(rec.Length)
+		if end > uint64(len(data)) {
+			continue
+		}
+		switch tagUint32ToString(rec.Tag) {
+		case "head":
+			head = data[rec.Offset:end]
+		case "hhea":
+			hhea = data[rec.Offset:end]
+		}
+	}
+	if len(head) < 20 || len(hhea) < 8 {
+		return
+	}
+	unitsPerEm := int(binary.BigEndian.Uint16(head[18:20]))
+	if unitsPerEm == 0 {
+		return
+	}
+	metrics.UnitsPerEm = unitsPerEm
+	metrics.Ascent = float64(int16(binary.BigEndian.Uint16(hhea[4:6])))
+	metrics.Descent = float64(int16(binary.BigEndian.Uint16(hhea[6:8])))
+}
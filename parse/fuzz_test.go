@@ -0,0 +1,131 @@
+package parse
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+// seedPDFs は シードに使うリポジトリ内の PDF
+var seedPDFs = []string{
+	filepath.Join("..", "example", "example.pdf"),
+	filepath.Join("..", "cmd", "pdtp", "testdata", "conform", "image.pdf"),
+	filepath.Join("..", "cmd", "pdtp", "testdata", "conform", "multipage.pdf"),
+	filepath.Join("..", "cmd", "pdtp", "testdata", "conform", "paths.pdf"),
+	filepath.Join("..", "cmd", "pdtp", "testdata", "conform", "text.pdf"),
+}
+
+// maxSeedsPerPDF は 1つの PDF から取り出すシードの数の上限
+const maxSeedsPerPDF = 32
+
+// maxXrefSeedSize は FuzzXref にファイル全体をシードとして渡す PDF の大きさの上限
+const maxXrefSeedSize = 16 << 10
+
+var (
+	objectPattern = regexp.MustCompile(`(?s)\d+ \d+ obj\s*(<<.*?>>)\s*(?:endobj|stream)`)
+	streamPattern = regexp.MustCompile(`(?s)stream\r?\n(.*?)\r?\nendstream`)
+	// contentOperators は 展開したストリームが内容ストリームかを見分ける演算子
+	contentOperators = regexp.MustCompile(`\b(BT|Tj|TJ|re|cm|Do)\b`)
+)
+
+func readSeedPDFs(f *testing.F) [][]byte {
+	f.Helper()
+	var pdfs [][]byte
+	for _, path := range seedPDFs {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		pdfs = append(pdfs, data)
+	}
+	return pdfs
+}
+
+// quietLogger は ファジング中の警告を捨てる
+func quietLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// FuzzParseMetadata は オブジェクトの辞書の解析が、壊れた入力でパニックしないことを確かめる
+func FuzzParseMetadata(f *testing.F) {
+	for _, pdf := range readSeedPDFs(f) {
+		for _, match := range objectPattern.FindAllSubmatch(pdf, maxSeedsPerPDF) {
+			f.Add(string(match[1]))
+		}
+	}
+	f.Add("<< /A [1 2 (x) <41> /N 3 0 R] /B << /C true >> >>")
+	f.Fuzz(func(t *testing.T, object string) {
+		parseMetadata(object)
+	})
+}
+
+// FuzzTokenize は 内容ストリームの分割と実行が、壊れた入力でパニックしないことを確かめる
+func FuzzTokenize(f *testing.F) {
+	for _, pdf := range readSeedPDFs(f) {
+		seeds := 0
+		for _, match := range streamPattern.FindAllSubmatch(pdf, -1) {
+			content := match[1]
+			if r, err := zlib.NewReader(bytes.NewReader(content)); err == nil {
+				if inflated, err := io.ReadAll(r); err == nil {
+					content = inflated
+				}
+			}
+			if !contentOperators.Match(content) || seeds >= maxSeedsPerPDF {
+				continue
+			}
+			f.Add(string(content))
+			seeds++
+		}
+	}
+	f.Add("BT /F1 12 Tf 0 -14 TD [(A) 120 (B)] TJ T* (C) ' 1 2 (D) \" ET")
+	f.Add("BT /F1 12 Tf [<00410042> -250 <00430044> ] TJ ET")
+	f.Add("q 1 0 0 1 0 0 cm BI /W 1 /H 1 /BPC 8 /CS /G ID \x00 EI Q")
+	f.Fuzz(func(t *testing.T, content string) {
+		if _, err := tokenize(content); err != nil {
+			return
+		}
+		to := NewTokenObject(content, nil, nil)
+		to.logger = quietLogger()
+		to.ExtractCommands(792)
+	})
+}
+
+// FuzzXref は 相互参照表とトレーラーの読み込みが、壊れた入力でパニック・停止しないことを確かめる
+func FuzzXref(f *testing.F) {
+	for _, pdf := range readSeedPDFs(f) {
+		// 大きな PDF は変異が遅くなるため、小さいものだけをそのまま使う
+		if len(pdf) <= maxXrefSeedSize {
+			f.Add(pdf)
+		}
+		// 相互参照表から後ろだけを使い、変異を表の部分に集める (startxref の xref には一致させない)
+		if i := bytes.LastIndex(pdf, []byte("\nxref")); i >= 0 {
+			f.Add(pdf[i+1:])
+		}
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		xrefTable, trailer, err := parseXrefTable(nopFile{bytes.NewReader(data)})
+		if err != nil {
+			return
+		}
+		for ref, element := range xrefTable {
+			if ref != element.ObjNum {
+				t.Fatalf("entry %d has object number %d", ref, element.ObjNum)
+			}
+		}
+		if trailer != nil {
+			parseMetadata(*trailer)
+		}
+	})
+}
+
+// nopFile は メモリ上のデータを IPDFFile として渡す
+type nopFile struct {
+	*bytes.Reader
+}
+
+func (nopFile) Close() error { return nil }
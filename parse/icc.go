@@ -0,0 +1,122 @@
+package parse
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+)
+
+// iccProfileTracker は 送った ICC プロファイルを内容のハッシュで記録し、文書ごとに1回だけ送る
+// 別のオブジェクトに同じプロファイルが埋め込まれていても、ハッシュが同じであれば送り直さない
+type iccProfileTracker struct {
+	parser     *PDFParser
+	ids        map[PDFRef]string
+	sent       map[string]bool
+	insertData func(data ParsedData)
+}
+
+func newICCProfileTracker(p *PDFParser, insertData func(data ParsedData)) *iccProfileTracker {
+	return &iccProfileTracker{
+		parser:     p,
+		ids:        make(map[PDFRef]string),
+		sent:       make(map[string]bool),
+		insertData: insertData,
+	}
+}
+
+// send は ref のプロファイルをまだ送っていなければ送り、プロファイルの ID を返す
+// outputIntent は 出力インテントのプロファイルの場合にその種別 (/S) を指定する
+func (t *iccProfileTracker) send(ref PDFRef, outputIntent string) (string, error) {
+	if id, ok := t.ids[ref]; ok {
+		return id, nil
+	}
+	stream, err := t.parser.ParseStreamObject(ref)
+	if err != nil {
+		return "", err
+	}
+	data, err := stream.Decoded()
+	if err != nil {
+		return "", err
+	}
+	n, ok := stream.Dict["N"].(int)
+	if !ok {
+		return "", errors.New("ICC profile N is not int")
+	}
+	sum := sha256.Sum256(data)
+	id := hex.EncodeToString(sum[:])
+	t.ids[ref] = id
+	if !t.sent[id] {
+		t.sent[id] = true
+		t.insertData(&ParsedICCProfile{
+			ID:           id,
+			Components:   n,
+			OutputIntent: outputIntent,
+			Data:         data,
+		})
+	}
+	return id, nil
+}
+
+// iccProfileRef は /ColorSpace が ICCBased (Indexed の基底色空間を含む) の場合にプロファイルストリームの参照を返す
+func (p *PDFParser) iccProfileRef(obj PDFObject) (PDFRef, bool) {
+	obj, err := p.resolveObject(obj)
+	if err != nil {
+		return 0, false
+	}
+	cs, ok := obj.([]PDFObject)
+	if !ok || len(cs) < 2 {
+		return 0, false
+	}
+	name, _ := cs[0].(string)
+	switch name {
+	case "ICCBased":
+		ref, ok := cs[1].(string)
+		if !ok {
+			return 0, false
+		}
+		return parseRef(ref)
+	case "Indexed":
+		return p.iccProfileRef(cs[1])
+	}
+	return 0, false
+}
+
+// outputIntentProfile は 文書の出力インテント (/OutputIntents) の出力先プロファイル
+type outputIntentProfile struct {
+	Subtype string // 出力インテントの種別 (/S。GTS_PDFX、GTS_PDFA1 など)
+	Ref     PDFRef // /DestOutputProfile
+}
+
+// outputIntents は カタログの /OutputIntents からプロファイルを持つ出力インテントを読み込む
+func (p *PDFParser) outputIntents(c *Catalog) ([]outputIntentProfile, error) {
+	if c.OutputIntents == nil {
+		return nil, nil
+	}
+	obj, err := p.resolveObject(c.OutputIntents)
+	if err != nil {
+		return nil, err
+	}
+	intents, ok := obj.([]PDFObject)
+	if !ok {
+		return nil, errors.New("OutputIntents is not array")
+	}
+	var profiles []outputIntentProfile
+	for _, intent := range intents {
+		intent, err := p.resolveObject(intent)
+		if err != nil {
+			return nil, err
+		}
+		dict, ok := intent.(map[string]PDFObject)
+		if !ok {
+			return nil, errors.New("OutputIntent is not dictionary")
+		}
+		ref, ok := findTargetRef(dict, "DestOutputProfile")
+		if !ok {
+			// 出力条件の名前だけを指定したもの
+			continue
+		}
+		subtype, _ := dict["S"].(string)
+		profiles = append(profiles, outputIntentProfile{Subtype: subtype, Ref: ref})
+	}
+	return profiles, nil
+}
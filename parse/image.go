@@ -0,0 +1,260 @@
+package parse
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"math"
+)
+
+// imageFormat は 画像XObjectのサンプル形式を表す
+type imageFormat struct {
+	Width            int
+	Height           int
+	BitsPerComponent int
+	ColorSpace       string
+	Components       int
+	Decode           []float64
+	Filter           string
+	ImageMask        bool   // ステンシルマスク(/ImageMask true)
+	ICCProfile       PDFRef // ICCBased のプロファイルストリーム (0 の場合はなし)
+}
+
+// readImageFormat は 画像辞書から幅・高さ・色空間などのサンプル形式を読み込む
+func (p *PDFParser) readImageFormat(image PDFObject) (*imageFormat, error) {
+	dict, ok := image.(map[string]PDFObject)
+	if !ok {
+		return nil, errors.New("image is not dictionary")
+	}
+	width, ok := dict["Width"].(int)
+	if !ok {
+		return nil, errors.New("Width or Height is not int")
+	}
+	height, ok := dict["Height"].(int)
+	if !ok {
+		return nil, errors.New("Width or Height is not int")
+	}
+	format := &imageFormat{
+		Width:            width,
+		Height:           height,
+		BitsPerComponent: 8,
+		Components:       1,
+	}
+	filter, err := streamFilter(dict)
+	if err != nil {
+		return nil, err
+	}
+	format.Filter = filter
+	if imageMask, ok := dict["ImageMask"].(bool); ok && imageMask {
+		// ステンシルマスクは常に1bit・色空間なし
+		format.ImageMask = true
+		format.BitsPerComponent = 1
+		return format, p.readImageDecode(dict, format)
+	}
+	if bpc, err := p.resolveObject(dict["BitsPerComponent"]); err == nil {
+		if bpcInt, ok := bpc.(int); ok {
+			format.BitsPerComponent = bpcInt
+		}
+	}
+	if cs, found := dict["ColorSpace"]; found {
+		name, components, err := p.imageColorSpace(cs)
+		if err != nil {
+			return nil, err
+		}
+		format.ColorSpace = name
+		format.Components = components
+		format.ICCProfile, _ = p.iccProfileRef(cs)
+	}
+	return format, p.readImageDecode(dict, format)
+}
+
+func (p *PDFParser) readImageDecode(dict map[string]PDFObject, format *imageFormat) error {
+	decode, ok := dict["Decode"].([]PDFObject)
+	if !ok {
+		return nil
+	}
+	for _, v := range decode {
+		f, ok := toFloat(v)
+		if !ok {
+			return errors.New("Decode is not number array")
+		}
+		format.Decode = append(format.Decode, f)
+	}
+	return nil
+}
+
+// imageColorSpace は /ColorSpace から色空間名と1ピクセルあたりの成分数を求める
+func (p *PDFParser) imageColorSpace(obj PDFObject) (string, int, error) {
+	obj, err := p.resolveObject(obj)
+	if err != nil {
+		return "", 0, err
+	}
+	switch cs := obj.(type) {
+	case string:
+		return cs, colorSpaceComponents(cs), nil
+	case []PDFObject:
+		if len(cs) == 0 {
+			return "", 0, errors.New("ColorSpace is empty")
+		}
+		name, _ := cs[0].(string)
+		switch name {
+		case "ICCBased":
+			if len(cs) < 2 {
+				return "", 0, errors.New("ICCBased format error")
+			}
+			profile, err := p.resolveObject(cs[1])
+			if err != nil {
+				return "", 0, err
+			}
+			n, found := findTarget(profile, "N")
+			if !found {
+				return "", 0, errors.New("ICCBased N not found")
+			}
+			nInt, ok := n.(int)
+			if !ok {
+				return "", 0, errors.New("ICCBased N is not int")
+			}
+			return name, nInt, nil
+		case "DeviceN":
+			if len(cs) < 2 {
+				return "", 0, errors.New("DeviceN format error")
+			}
+			names, ok := cs[1].([]PDFObject)
+			if !ok {
+				return "", 0, errors.New("DeviceN format error")
+			}
+			return name, len(names), nil
+		default:
+			return name, colorSpaceComponents(name), nil
+		}
+	}
+	return "", 0, errors.New("ColorSpace format error")
+}
+
+func colorSpaceComponents(name string) int {
+	switch name {
+	case "DeviceRGB", "CalRGB", "Lab", "RGB":
+		return 3
+	case "DeviceCMYK", "CMYK":
+		return 4
+	default:
+		// DeviceGray, CalGray, Indexed, Separation など
+		return 1
+	}
+}
+
+// needsNormalize は 8bit未満/以外のサンプルや /Decode 配列の変換が必要かを返す
+func (f *imageFormat) needsNormalize() bool {
+	if f.ImageMask || f.BitsPerComponent != 8 {
+		return true
+	}
+	return f.Decode != nil && !f.isDefaultDecode()
+}
+
+func (f *imageFormat) maxSample() float64 {
+	return math.Pow(2, float64(f.BitsPerComponent)) - 1
+}
+
+func (f *imageFormat) isDefaultDecode() bool {
+	dmax := 1.0
+	if f.ColorSpace == "Indexed" {
+		dmax = f.maxSample()
+	}
+	for i := 0; i+1 < len(f.Decode); i += 2 {
+		if f.Decode[i] != 0 || f.Decode[i+1] != dmax {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeImageStream は 圧縮されていない/FlateDecode の画像を 8bit/成分に展開し /Decode を適用する
+// DCTDecode など他のフィルタはそのまま返す
+// 途中で壊れている FlateDecode のデータは、展開できた部分を正規化してエラーと合わせて返す
+func normalizeImageStream(stream []byte, format *imageFormat) ([]byte, error) {
+	if !format.needsNormalize() {
+		return stream, nil
+	}
+	var samples []byte
+	var err error
+	switch format.Filter {
+	case "FlateDecode":
+		var inflated []byte
+		inflated, err = deCompressStream(stream)
+		if len(inflated) == 0 && err != nil {
+			return nil, err
+		}
+		samples = unpackSamples(inflated, format)
+	case "":
+		samples = unpackSamples(stream, format)
+	default:
+		return stream, nil
+	}
+	// 出力は FlateDecode 画像と同じく zlib 圧縮したサンプル列にそろえる
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(samples)
+	zw.Close()
+	format.BitsPerComponent = 8
+	format.Decode = nil
+	format.Filter = "FlateDecode"
+	return buf.Bytes(), err
+}
+
+// unpackSamples は 行ごとにバイト境界へ揃えられたサンプル列を 8bit/成分 に展開する
+func unpackSamples(data []byte, format *imageFormat) []byte {
+	bpc := format.BitsPerComponent
+	perRow := format.Width * format.Components
+	rowBytes := (perRow*bpc + 7) / 8
+	maxv := format.maxSample()
+	indexed := format.ColorSpace == "Indexed"
+
+	out := make([]byte, 0, perRow*format.Height)
+	for y := 0; y < format.Height; y++ {
+		row := data[min(y*rowBytes, len(data)):min((y+1)*rowBytes, len(data))]
+		for i := 0; i < perRow; i++ {
+			v := float64(readSample(row, i, bpc))
+			dmin, dmax := 0.0, 1.0
+			if indexed {
+				dmax = maxv
+			}
+			if c := (i % format.Components) * 2; c+1 < len(format.Decode) {
+				dmin, dmax = format.Decode[c], format.Decode[c+1]
+			}
+			d := dmin + v*(dmax-dmin)/maxv
+			if !indexed {
+				// 0..1 の値を 0..255 にスケーリング
+				d *= 255
+			}
+			if format.ImageMask {
+				// ステンシルマスクは 0 が塗り、1 が透過なので不透明度に反転する
+				d = 255 - d
+			}
+			out = append(out, byte(math.Max(0, math.Min(255, math.Round(d)))))
+		}
+	}
+	return out
+}
+
+// readSample は 行データから i 番目のサンプル値を取り出す
+func readSample(row []byte, i, bpc int) uint32 {
+	switch bpc {
+	case 16:
+		if 2*i+1 >= len(row) {
+			return 0
+		}
+		return uint32(row[2*i])<<8 | uint32(row[2*i+1])
+	case 8:
+		if i >= len(row) {
+			return 0
+		}
+		return uint32(row[i])
+	default:
+		bit := i * bpc
+		if bit/8 >= len(row) {
+			return 0
+		}
+		shift := 8 - bpc - bit%8
+		return uint32(row[bit/8]>>shift) & (1<<bpc - 1)
+	}
+}
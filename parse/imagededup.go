@@ -0,0 +1,85 @@
+package parse
+
+import "strconv"
+
+// imageDedupKey は 同じ画像とみなす組み合わせ
+// ExtGState のソフトマスクが違えば送るマスクも違うため、別の画像として扱う
+type imageDedupKey struct {
+	image PDFRef
+	mask  PDFRef
+}
+
+// imageDedupTracker は 送った画像を参照ごとに記録し、2回目以降の配置を ParsedImagePlacement にする
+// ロゴや背景のように全ページに置かれる画像を、1度だけ展開して送る
+type imageDedupTracker struct {
+	ids      map[imageDedupKey]string
+	excluded map[imageDedupKey]bool
+	imageIDs *imageIDAllocator
+}
+
+func newImageDedupTracker(imageIDs *imageIDAllocator) *imageDedupTracker {
+	return &imageDedupTracker{
+		ids:      make(map[imageDedupKey]string),
+		excluded: make(map[imageDedupKey]bool),
+		imageIDs: imageIDs,
+	}
+}
+
+// imageIDAllocator は ストリームの中で一意な画像の ID を割り当てる
+// 重複を除いた画像とプレビューを送った画像で同じ番号を使わないように共有する
+type imageIDAllocator struct {
+	next int
+}
+
+func (a *imageIDAllocator) allocate() string {
+	a.next++
+	return strconv.Itoa(a.next)
+}
+
+func imageKey(cmd ImageRefCommand) imageDedupKey {
+	key := imageDedupKey{image: cmd.ImageRef}
+	if cmd.SoftMask != nil {
+		key.mask = cmd.SoftMask.GroupRef
+	}
+	return key
+}
+
+// placed は cmd の画像を既に送っていれば、その配置と true を返す
+func (t *imageDedupTracker) placed(cmd ImageRefCommand) (*ParsedImagePlacement, bool) {
+	id, ok := t.ids[imageKey(cmd)]
+	if !ok {
+		return nil, false
+	}
+	return &ParsedImagePlacement{
+		ImageID:     id,
+		X:           cmd.X,
+		Y:           cmd.Y,
+		Z:           cmd.Z,
+		DW:          cmd.DW,
+		DH:          cmd.DH,
+		Matrix:      cmd.Matrix.Values(),
+		Page:        cmd.Page,
+		ClipPath:    cmd.ClipPath,
+		StrokeAlpha: cmd.StrokeAlpha,
+		FillAlpha:   cmd.FillAlpha,
+		BlendMode:   cmd.BlendMode,
+		FillColor:   cmd.FillColor,
+		ClipPaths:   cmd.ClipPaths,
+		OffPage:     cmd.OffPage,
+		Alt:         cmd.Alt,
+		ActualText:  cmd.ActualText,
+	}, true
+}
+
+// register は 初めて送る画像に ID を割り当てる
+// 配置ごとに内容が変わる画像 (タイルに分割した画像など) は exclude を指定し、記録せずに毎回送る
+func (t *imageDedupTracker) register(cmd ImageRefCommand, exclude bool) string {
+	key := imageKey(cmd)
+	if exclude || t.excluded[key] {
+		t.excluded[key] = true
+		return ""
+	}
+	id := t.imageIDs.allocate()
+	t.ids[key] = id
+	return id
+}
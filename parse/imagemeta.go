@@ -0,0 +1,212 @@
+package parse
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"strings"
+)
+
+// ImageMetadata は 画像に埋め込まれた権利・説明などのメタデータ
+type ImageMetadata struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Creator     string `json:"creator,omitempty"`
+	Copyright   string `json:"copyright,omitempty"`
+}
+
+func (m ImageMetadata) isEmpty() bool {
+	return m == ImageMetadata{}
+}
+
+// merge は 空の項目を other で補う
+func (m *ImageMetadata) merge(other ImageMetadata) {
+	if m.Title == "" {
+		m.Title = other.Title
+	}
+	if m.Description == "" {
+		m.Description = other.Description
+	}
+	if m.Creator == "" {
+		m.Creator = other.Creator
+	}
+	if m.Copyright == "" {
+		m.Copyright = other.Copyright
+	}
+}
+
+const (
+	xmpDublinCoreNS = "http://purl.org/dc/elements/1.1/"
+	xmpRDFNS        = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+)
+
+// readImageMetadata は 画像 XObject の /Metadata (XMP) と JPEG に埋め込まれた XMP・EXIF を読み込む
+// /Metadata の値を優先し、足りない項目を JPEG のメタデータで補う
+func (p *PDFParser) readImageMetadata(dict map[string]PDFObject, data []byte, ext string) *ImageMetadata {
+	var meta ImageMetadata
+	if ref, found := findTargetRef(dict, "Metadata"); found {
+		if so, err := p.ParseStreamObject(ref); err == nil {
+			if xmp, err := so.Decoded(); err == nil {
+				meta = parseXMP(xmp)
+			}
+		}
+	}
+	if ext == "jpg" {
+		meta.merge(parseJPEGMetadata(data))
+	}
+	if meta.isEmpty() {
+		return nil
+	}
+	return &meta
+}
+
+// parseXMP は XMP パケットから Dublin Core の title / description / creator / rights を取り出す
+// 言語別・順序付きの値(rdf:Alt / rdf:Seq)は最初の rdf:li を使う
+func parseXMP(data []byte) ImageMetadata {
+	var meta ImageMetadata
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var field *string
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Space == xmpDublinCoreNS {
+				field = xmpField(&meta, t.Name.Local)
+				text.Reset()
+			}
+		case xml.CharData:
+			if field != nil {
+				text.Write(t)
+			}
+		case xml.EndElement:
+			if field == nil {
+				continue
+			}
+			if (t.Name.Space == xmpRDFNS && t.Name.Local == "li") || t.Name.Space == xmpDublinCoreNS {
+				if *field == "" {
+					*field = strings.TrimSpace(text.String())
+				}
+				text.Reset()
+				if t.Name.Space == xmpDublinCoreNS {
+					field = nil
+				}
+			}
+		}
+	}
+	return meta
+}
+
+func xmpField(meta *ImageMetadata, name string) *string {
+	switch name {
+	case "title":
+		return &meta.Title
+	case "description":
+		return &meta.Description
+	case "creator":
+		return &meta.Creator
+	case "rights":
+		return &meta.Copyright
+	}
+	return nil
+}
+
+// EXIF (TIFF) のタグ
+const (
+	exifTagImageDescription = 0x010E
+	exifTagArtist           = 0x013B
+	exifTagCopyright        = 0x8298
+	exifTypeASCII           = 2
+)
+
+var (
+	jpegExifHeader = []byte("Exif\x00\x00")
+	jpegXMPHeader  = []byte("http://ns.adobe.com/xap/1.0/\x00")
+)
+
+// parseJPEGMetadata は JPEG の APP1 セグメントから EXIF と XMP を読み込む
+func parseJPEGMetadata(data []byte) ImageMetadata {
+	var meta ImageMetadata
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return meta
+	}
+	for pos := 2; pos+4 <= len(data); {
+		if data[pos] != 0xFF {
+			break
+		}
+		marker := data[pos+1]
+		if marker == 0xDA || marker == 0xD9 {
+			// スキャンの開始以降にメタデータはない
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			break
+		}
+		segment := data[pos+4 : end]
+		if marker == 0xE1 {
+			if bytes.HasPrefix(segment, jpegExifHeader) {
+				meta.merge(parseExif(segment[len(jpegExifHeader):]))
+			} else if bytes.HasPrefix(segment, jpegXMPHeader) {
+				meta.merge(parseXMP(segment[len(jpegXMPHeader):]))
+			}
+		}
+		pos = end
+	}
+	return meta
+}
+
+// parseExif は TIFF 形式の EXIF の IFD0 から説明・作者・著作権を読み込む
+func parseExif(tiff []byte) ImageMetadata {
+	var meta ImageMetadata
+	if len(tiff) < 8 {
+		return meta
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return meta
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 0 || ifd+2 > len(tiff) {
+		return meta
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			break
+		}
+		tag := order.Uint16(tiff[entry:])
+		if order.Uint16(tiff[entry+2:]) != exifTypeASCII {
+			continue
+		}
+		n := int(order.Uint32(tiff[entry+4:]))
+		// 4バイト以下の値はオフセットの位置に直接格納される
+		value := entry + 8
+		if n > 4 {
+			value = int(order.Uint32(tiff[entry+8:]))
+		}
+		if n <= 0 || value < 0 || value+n > len(tiff) {
+			continue
+		}
+		s := strings.TrimSpace(strings.TrimRight(string(tiff[value:value+n]), "\x00"))
+		switch tag {
+		case exifTagImageDescription:
+			meta.Description = s
+		case exifTagArtist:
+			meta.Creator = s
+		case exifTagCopyright:
+			meta.Copyright = s
+		}
+	}
+	return meta
+}
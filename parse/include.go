@@ -0,0 +1,58 @@
+package parse
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ContentType は 送るかどうかを選べるページの内容の種類
+type ContentType string
+
+const (
+	// ContentText は テキスト (繰り返し現れるヘッダー・フッターの参照を含む)
+	ContentText ContentType = "text"
+	// ContentPaths は パス
+	ContentPaths ContentType = "paths"
+	// ContentImages は 画像。含めない場合は画像を展開しない
+	ContentImages ContentType = "images"
+	// ContentFonts は テキストの描画に使うフォント
+	ContentFonts ContentType = "fonts"
+	// ContentAnnotations は 注釈とリンク
+	ContentAnnotations ContentType = "annotations"
+)
+
+// ContentTypes は 送るページの内容の種類 (空の場合は全て送る)
+// ページ・ページの完了・進捗・終了のチャンクは常に送る。テキストだけを索引するクライアントは text、
+// テキストを描画するクライアントは text,fonts のように、必要な種類だけを指定して解析と送信を省く
+type ContentTypes []ContentType
+
+// ParseContentTypes は text,paths のようにカンマで区切った種類を読み込む
+func ParseContentTypes(s string) (ContentTypes, error) {
+	var types ContentTypes
+	for _, name := range strings.Split(s, ",") {
+		switch t := ContentType(strings.ToLower(strings.TrimSpace(name))); t {
+		case ContentText, ContentPaths, ContentImages, ContentFonts, ContentAnnotations:
+			if !slices.Contains(types, t) {
+				types = append(types, t)
+			}
+		default:
+			return nil, fmt.Errorf("unknown content type %q (text, paths, images, fonts or annotations)", name)
+		}
+	}
+	return types, nil
+}
+
+// Includes は t の種類を送るかを返す
+func (types ContentTypes) Includes(t ContentType) bool {
+	return len(types) == 0 || slices.Contains(types, t)
+}
+
+// String は ParseContentTypes で読み込める形にする
+func (types ContentTypes) String() string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = string(t)
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,90 @@
+package parse
+
+import (
+	"unicode"
+)
+
+// scriptRanges は 判定対象の文字体系と ISO 15924 コードの対応
+var scriptRanges = []struct {
+	code  string
+	table *unicode.RangeTable
+}{
+	{"Latn", unicode.Latin},
+	{"Hani", unicode.Han},
+	{"Hira", unicode.Hiragana},
+	{"Kana", unicode.Katakana},
+	{"Hang", unicode.Hangul},
+	{"Cyrl", unicode.Cyrillic},
+	{"Grek", unicode.Greek},
+	{"Arab", unicode.Arabic},
+	{"Hebr", unicode.Hebrew},
+	{"Thai", unicode.Thai},
+	{"Deva", unicode.Devanagari},
+}
+
+// scriptLanguages は 文字体系から一意に推定できる言語 (BCP 47)
+// ラテン文字やキリル文字のように複数の言語で使われるものは含めない
+var scriptLanguages = map[string]string{
+	"Jpan": "ja",
+	"Kore": "ko",
+	"Hani": "zh",
+	"Grek": "el",
+	"Hebr": "he",
+	"Arab": "ar",
+	"Thai": "th",
+	"Deva": "hi",
+}
+
+// detectScript は テキストで最も多く使われている文字体系を ISO 15924 コードで返す
+// 漢字と仮名が混在する場合は Jpan、漢字とハングルの場合は Kore とする
+func detectScript(texts []string) string {
+	counts := make(map[string]int)
+	for _, text := range texts {
+		for _, r := range text {
+			if !unicode.IsLetter(r) {
+				continue
+			}
+			for _, s := range scriptRanges {
+				if unicode.Is(s.table, r) {
+					counts[s.code]++
+					break
+				}
+			}
+		}
+	}
+	if kana := counts["Hira"] + counts["Kana"]; kana > 0 {
+		counts["Jpan"] = counts["Hani"] + kana
+	} else if counts["Hang"] > 0 {
+		counts["Kore"] = counts["Hani"] + counts["Hang"]
+	}
+	delete(counts, "Hira")
+	delete(counts, "Kana")
+	delete(counts, "Hang")
+	if counts["Jpan"] > 0 || counts["Kore"] > 0 {
+		delete(counts, "Hani")
+	}
+
+	script, best := "", 0
+	for _, s := range append([]string{"Jpan", "Kore"}, scriptCodes()...) {
+		if counts[s] > best {
+			script, best = s, counts[s]
+		}
+	}
+	return script
+}
+
+func scriptCodes() []string {
+	codes := make([]string, 0, len(scriptRanges))
+	for _, s := range scriptRanges {
+		codes = append(codes, s.code)
+	}
+	return codes
+}
+
+// pageLanguage は 文書の /Lang を優先し、無ければ文字体系から言語を推定する
+func pageLanguage(documentLang, script string) string {
+	if documentLang != "" {
+		return documentLang
+	}
+	return scriptLanguages[script]
+}
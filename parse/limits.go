@@ -0,0 +1,101 @@
+package parse
+
+import (
+	"errors"
+	"fmt"
+)
+
+// StreamLimits は 1つの要求で解析・送信する量の上限 (公開するサーバーで巨大な PDF による負荷を抑える)
+// 超えた場合はストリームを中止し、*LimitError を返す
+type StreamLimits struct {
+	MaxPages      int   // 1つの要求で解析するページ数の上限 (0 は上限なし)
+	MaxTotalBytes int64 // 送るバイナリ (画像・フォント・添付ファイルなど) の合計バイト数の上限 (0 は上限なし)
+	MaxImageBytes int64 // 1つの画像のバイナリ (マスクを含む) のバイト数の上限 (0 は上限なし)
+}
+
+// 上限の種類 (LimitError.Limit)
+const (
+	LimitPages      = "pages"
+	LimitTotalBytes = "totalBytes"
+	LimitImageBytes = "imageBytes"
+)
+
+// ErrLimitExceeded は StreamLimits の上限を超えたことを示す
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+// LimitError は 超えた上限の種類と値
+type LimitError struct {
+	Limit string // 上限の種類 (LimitPages など)
+	Value int64  // 要求された・送ろうとした値
+	Max   int64  // 上限
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s %d exceeds %d", ErrLimitExceeded, e.Limit, e.Value, e.Max)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// checkPages は 要求されたページ数が上限を超えていないか確かめる
+func (l StreamLimits) checkPages(pages int) error {
+	if l.MaxPages > 0 && pages > l.MaxPages {
+		return &LimitError{Limit: LimitPages, Value: int64(pages), Max: int64(l.MaxPages)}
+	}
+	return nil
+}
+
+// limitTracker は 送るバイナリのバイト数を数え、上限を超えたデータを送らずに止める
+type limitTracker struct {
+	limits     StreamLimits
+	total      int64
+	exceeded   error
+	insertData func(data ParsedData)
+}
+
+func newLimitTracker(limits StreamLimits, insertData func(data ParsedData)) *limitTracker {
+	return &limitTracker{limits: limits, insertData: insertData}
+}
+
+// insert は 上限を超えていなければデータを送る。一度超えた後はデータを送らない
+func (t *limitTracker) insert(data ParsedData) {
+	if t.exceeded != nil {
+		return
+	}
+	size := payloadSize(data)
+	if _, ok := data.(*ParsedImage); ok && t.limits.MaxImageBytes > 0 && size > t.limits.MaxImageBytes {
+		t.exceeded = &LimitError{Limit: LimitImageBytes, Value: size, Max: t.limits.MaxImageBytes}
+		return
+	}
+	t.total += size
+	if t.limits.MaxTotalBytes > 0 && t.total > t.limits.MaxTotalBytes {
+		t.exceeded = &LimitError{Limit: LimitTotalBytes, Value: t.total, Max: t.limits.MaxTotalBytes}
+		return
+	}
+	t.insertData(data)
+}
+
+// err は 上限を超えた場合にその理由を返す
+func (t *limitTracker) err() error {
+	return t.exceeded
+}
+
+// payloadSize は チャンクの JSON に続けて送るバイナリのバイト数
+func payloadSize(data ParsedData) int64 {
+	switch d := data.(type) {
+	case *ParsedImage:
+		return int64(len(d.Data) + len(d.MaskData))
+	case *ParsedPath:
+		return int64(len(d.MaskData))
+	case *ParsedFont:
+		return int64(len(d.Data))
+	case *ParsedAttachment:
+		return int64(len(d.Data))
+	case *ParsedThumbnail:
+		return int64(len(d.Data))
+	case *ParsedICCProfile:
+		return int64(len(d.Data))
+	}
+	return 0
+}
@@ -0,0 +1,108 @@
+package parse
+
+import (
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TextWord は 行のテキスト中の単語の位置
+type TextWord struct {
+	Start int     `json:"start"` // テキスト中の開始位置 (文字数)
+	End   int     `json:"end"`   // テキスト中の終了位置 (文字数、この位置を含まない)
+	X     float64 `json:"x"`     // 単語の左端 (グリフ幅が分からない場合は見積もり)
+	Width float64 `json:"width"`
+}
+
+// groupTextLines は 同じベースラインに並ぶ同じスタイルのテキストを行単位の1つのテキストにまとめ、単語の区切りを付ける
+// 離れたテキストの間には空白を補い、スタイルが変わる箇所では別のテキストに分ける
+func groupTextLines(commands []TextCommand) []TextCommand {
+	var grouped []TextCommand
+	for _, line := range textLines(commands) {
+		var pieces []TextCommand
+		flush := func() {
+			if len(pieces) > 0 {
+				grouped = append(grouped, mergeLinePieces(pieces))
+				pieces = nil
+			}
+		}
+		for _, cmd := range line {
+			if len(pieces) > 0 && !sameTextStyle(&pieces[len(pieces)-1], &cmd) {
+				flush()
+			}
+			pieces = append(pieces, cmd)
+		}
+		flush()
+	}
+	return grouped
+}
+
+// mergeLinePieces は 左から並んだテキストを1つにまとめ、単語の区切りを計算する
+func mergeLinePieces(pieces []TextCommand) TextCommand {
+	merged := pieces[0]
+	merged.Text = nil
+	merged.Codes = nil
+	var words []TextWord
+	offset := 0
+	right := merged.X
+	for i, piece := range pieces {
+		text := strings.Join(piece.Text, "")
+		if i > 0 {
+			prev := pieces[i-1]
+			gap := piece.X - (prev.X + approxTextWidth(prev))
+			if gap > math.Abs(prev.FontSize)*coalesceSpaceGap && !endsWithSpace(prev.Text) && !startsWithSpace(piece.Text) {
+				// 補った空白に対応するグリフはない
+				merged.Text = append(merged.Text, " ")
+				merged.Codes = append(merged.Codes, -1)
+				offset++
+			}
+		}
+		words = appendWords(words, text, offset, piece.X, approxTextWidth(piece))
+		merged.Text = append(merged.Text, piece.Text...)
+		merged.Codes = append(merged.Codes, piece.Codes...)
+		merged.Z = min(merged.Z, piece.Z)
+		offset += utf8.RuneCountInString(text)
+		right = math.Max(right, piece.X+approxTextWidth(piece))
+	}
+	merged.Width = right - merged.X
+	merged.Words = words
+	return merged
+}
+
+// appendWords は text を空白で区切った単語を追加する
+// 前のテキストの単語と空白を挟まずに続く単語 (1つの単語が複数の Tj に分かれた場合) はつなげる
+// 単語の位置はテキストの幅を文字数で按分して見積もる
+func appendWords(words []TextWord, text string, offset int, x, width float64) []TextWord {
+	runes := []rune(text)
+	if len(runes) == 0 {
+		return words
+	}
+	perRune := width / float64(len(runes))
+	start := -1
+	for i := 0; i <= len(runes); i++ {
+		if i < len(runes) && !unicode.IsSpace(runes[i]) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start < 0 {
+			continue
+		}
+		word := TextWord{
+			Start: offset + start,
+			End:   offset + i,
+			X:     x + perRune*float64(start),
+			Width: perRune * float64(i-start),
+		}
+		if n := len(words); n > 0 && words[n-1].End == word.Start {
+			words[n-1].End = word.End
+			words[n-1].Width = word.X + word.Width - words[n-1].X
+		} else {
+			words = append(words, word)
+		}
+		start = -1
+	}
+	return words
+}
@@ -0,0 +1,119 @@
+package parse
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestGroupTextLines は ベースラインが少しずれたテキストを1行にまとめ、離れたテキストの間に空白を補い、
+// スタイルが変わる箇所で分けて単語の区切りを付けることを確かめる
+func TestGroupTextLines(t *testing.T) {
+	text := func(x, y, size, width float64, s string) TextCommand {
+		return TextCommand{X: x, Y: y, Text: strings.Split(s, ""), Width: width, FontID: "F1", FontSize: size, MCID: -1}
+	}
+	commands := []TextCommand{
+		// 1行目 (左右の順番が入れ替わり、ベースラインが少しずれている)
+		text(80, 100.3, 10, 25, "world"),
+		text(105, 99.8, 10, 3, "!"),
+		text(10, 100, 10, 55, "Hello there"),
+		// 2行目 (大きさの違うフォントが同じ行に並ぶ)
+		text(40, 121, 10, 25, "small"),
+		text(10, 120, 14, 20, "Big"),
+	}
+
+	grouped := groupTextLines(commands)
+
+	want := []struct {
+		text  string
+		x     float64
+		width float64
+		words []TextWord
+	}{
+		{
+			// "Hello there" の右端 65 と "world" の間は 15 空いているため空白を補い、隙間のない "!" はつなげる
+			text:  "Hello there world!",
+			x:     10,
+			width: 98,
+			words: []TextWord{
+				{Start: 0, End: 5, X: 10, Width: 25},
+				{Start: 6, End: 11, X: 40, Width: 25},
+				{Start: 12, End: 18, X: 80, Width: 28},
+			},
+		},
+		{
+			text:  "Big",
+			x:     10,
+			width: 20,
+			words: []TextWord{{Start: 0, End: 3, X: 10, Width: 20}},
+		},
+		{
+			text:  "small",
+			x:     40,
+			width: 25,
+			words: []TextWord{{Start: 0, End: 5, X: 40, Width: 25}},
+		},
+	}
+	if len(grouped) != len(want) {
+		t.Fatalf("got %d lines, want %d: %+v", len(grouped), len(want), grouped)
+	}
+	for i, line := range grouped {
+		if got := strings.Join(line.Text, ""); got != want[i].text {
+			t.Errorf("line %d text = %q, want %q", i, got, want[i].text)
+		}
+		if line.X != want[i].x || line.Width != want[i].width {
+			t.Errorf("line %d X, Width = %g, %g, want %g, %g", i, line.X, line.Width, want[i].x, want[i].width)
+		}
+		if !reflect.DeepEqual(line.Words, want[i].words) {
+			t.Errorf("line %d words = %+v, want %+v", i, line.Words, want[i].words)
+		}
+	}
+}
+
+// TestTextLines は ベースラインの差が大きい方のフォントサイズの半分以内のテキストを同じ行にまとめることを確かめる
+func TestTextLines(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		a, b TextCommand
+		want int
+	}{
+		{"jitter", TextCommand{Y: 100, FontSize: 10}, TextCommand{Y: 104, FontSize: 10}, 1},
+		{"next line", TextCommand{Y: 100, FontSize: 10}, TextCommand{Y: 112, FontSize: 10}, 2},
+		{"larger font widens tolerance", TextCommand{Y: 100, FontSize: 10}, TextCommand{Y: 106, FontSize: 14}, 1},
+		{"small font narrows tolerance", TextCommand{Y: 100, FontSize: 6}, TextCommand{Y: 104, FontSize: 6}, 2},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			test.a.Text, test.b.Text = []string{"a"}, []string{"b"}
+			if got := len(textLines([]TextCommand{test.a, test.b})); got != test.want {
+				t.Errorf("got %d lines, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+// TestGroupLinesOption は GroupLines を有効にしたパーサーが、同じ行のテキストを1つの ParsedText にまとめて単語の区切りを付けることを確かめる
+func TestGroupLinesOption(t *testing.T) {
+	content := "BT /F1 12 Tf 1 0 0 1 200 700.5 Tm (world) Tj 1 0 0 1 100 700 Tm (Hello) Tj 1 0 0 1 100 680 Tm (Next) Tj ET"
+	p := newTextParser(t, content, ParserConfig{GroupLines: true})
+	var texts []*ParsedText
+	err := p.StreamPageContents(context.Background(), 1, 1, 1, func(data ParsedData) {
+		if text, ok := data.(*ParsedText); ok {
+			texts = append(texts, text)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(texts) != 2 {
+		t.Fatalf("got %d texts, want 2", len(texts))
+	}
+	if texts[0].Text != "Hello world" || texts[1].Text != "Next" {
+		t.Errorf("texts = %q, %q, want %q, %q", texts[0].Text, texts[1].Text, "Hello world", "Next")
+	}
+	// 12pt で 1 文字の幅は 6pt
+	want := []TextWord{{Start: 0, End: 5, X: 100, Width: 30}, {Start: 6, End: 11, X: 200, Width: 30}}
+	if !reflect.DeepEqual(texts[0].Words, want) {
+		t.Errorf("words = %+v, want %+v", texts[0].Words, want)
+	}
+}
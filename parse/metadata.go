@@ -0,0 +1,94 @@
+package parse
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+)
+
+// Metadata は ページの内容を解析せずに、ページ数・ページの大きさ・ラベル・文書情報を読み込む
+// ページツリーと /PageLabels・/Info だけを読むため、内容を送るよりはるかに短い時間で返せる
+func (p *PDFParser) Metadata() (*ParsedMetadata, error) {
+	c, err := p.GetCatalog()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.loadPageObject(*c); err != nil {
+		return nil, err
+	}
+	labels, err := p.pageLabels(c)
+	if err != nil {
+		p.logger.Warn("Failed to read page labels", "err", err)
+	}
+	meta := &ParsedMetadata{
+		Pages: make([]PageMetadata, len(p.pageQueue)),
+		Lang:  c.Lang,
+	}
+	for i, page := range p.pageQueue {
+		meta.Pages[i] = PageMetadata{
+			Width:  page.PageWidth,
+			Height: page.PageHeight,
+			Label:  pageLabel(labels, int64(i+1)),
+		}
+	}
+	meta.Info, err = p.documentInfo()
+	if err != nil {
+		p.logger.Warn("Failed to read document info", "err", err)
+	}
+	return meta, nil
+}
+
+// PageCount は 文書のページ数を返す
+func (p *PDFParser) PageCount() (int, error) {
+	c, err := p.GetCatalog()
+	if err != nil {
+		return 0, err
+	}
+	if err := p.loadPageObject(*c); err != nil {
+		return 0, err
+	}
+	return len(p.pageQueue), nil
+}
+
+// StreamMetadata は Metadata の結果を1つのデータとして送り、ストリームを終える
+func (p *PDFParser) StreamMetadata(ctx context.Context, insertData func(data ParsedData)) error {
+	meta, err := p.Metadata()
+	if err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	summary := newStreamSummary(insertData)
+	summary.insert(meta)
+	summary.done()
+	return nil
+}
+
+// documentInfo は トレーラーの /Info から文字列の項目を読み込む (/Info がない場合は nil)
+func (p *PDFParser) documentInfo() (map[string]string, error) {
+	if p.info == 0 {
+		return nil, nil
+	}
+	obj, err := p.ParseObject(p.info)
+	if err != nil {
+		return nil, err
+	}
+	dict, ok := obj.(map[string]PDFObject)
+	if !ok {
+		return nil, nil
+	}
+	info := make(map[string]string, len(dict))
+	for key, value := range dict {
+		// 間接参照の文字列もある
+		value, err := p.resolveObject(value)
+		if err != nil {
+			continue
+		}
+		// エスケープした UTF-16BE のリテラル文字列は読み込み時に壊れるため送らない
+		if s := textStringFrom(value); s != "" && !strings.ContainsRune(s, utf8.RuneError) {
+			info[key] = s
+		}
+	}
+	return info, nil
+}
@@ -1,12 +1,10 @@
-package pdtp
+package parse
 
 import (
 	"bytes"
 	"errors"
 	"fmt"
 	"io"
-	"log"
-	"runtime/debug"
 	"strconv"
 	"strings"
 	"unicode"
@@ -14,6 +12,10 @@ import (
 
 type PDFObject interface{}
 
+// maxTreeDepth は 名前ツリー・数値ツリー・フィールドの階層・名前付き宛先をたどる深さの上限
+// /Kids や /Parent が循環した壊れたファイルで、再帰が止まらなくなるのを防ぐ
+const maxTreeDepth = 16
+
 func findTarget(obj PDFObject, target string) (PDFObject, bool) {
 	switch expression := obj.(type) {
 	case map[string]PDFObject:
@@ -69,10 +71,20 @@ func parseRef(refString string) (PDFRef, bool) {
 	return PDFRef(num), true
 }
 
+// toFloat は 数値オブジェクト(int/float64)を float64 に変換する
+func toFloat(obj PDFObject) (float64, bool) {
+	switch v := obj.(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
 func parseMetadata(objectString string) (PDFObject, error) {
 	m := strings.TrimSpace(objectString)
 	if !strings.HasPrefix(m, "<<") || !strings.HasSuffix(m, ">>") {
-		log.Println(string(debug.Stack()))
 		return nil, errors.New("object format is not correct")
 	}
 	reader := strings.NewReader(m)
@@ -323,6 +335,13 @@ func parseKeyword(r io.RuneScanner) (PDFObject, error) {
 	}
 	token := buf.String()
 	switch token {
+	case "":
+		// 区切り文字から始まる場合は読み進められないため、同じ位置で繰り返し解析しないようにする
+		ch, _, err := r.ReadRune()
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("unexpected character %q", ch)
 	case "null":
 		return nil, nil
 	case "true":
@@ -0,0 +1,132 @@
+package parse
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// pageLabelRange は /PageLabels の1つの範囲(開始ページから次の範囲の手前まで)の付け方
+type pageLabelRange struct {
+	start  int    // 範囲の最初のページ (0始まり)
+	style  string // 番号の形式 (D / R / r / A / a、空の場合は接頭辞のみ)
+	prefix string
+	first  int // 範囲の最初のページの番号 (/St)
+}
+
+// pageLabels は カタログの /PageLabels 数値ツリーを開始ページ順に読み込む
+func (p *PDFParser) pageLabels(catalog *Catalog) ([]pageLabelRange, error) {
+	if catalog.PageLabels == nil {
+		return nil, nil
+	}
+	tree, err := p.resolveObject(catalog.PageLabels)
+	if err != nil {
+		return nil, err
+	}
+	var ranges []pageLabelRange
+	err = p.walkNumberTree(tree, 0, func(key int, value PDFObject) error {
+		value, err := p.resolveObject(value)
+		if err != nil {
+			return err
+		}
+		dict, _ := value.(map[string]PDFObject)
+		r := pageLabelRange{start: key, first: 1}
+		r.style, _ = dict["S"].(string)
+		r.prefix = textStringFrom(dict["P"])
+		if st, ok := dict["St"].(int); ok && st > 0 {
+			r.first = st
+		}
+		ranges = append(ranges, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start < ranges[j].start })
+	return ranges, nil
+}
+
+// pageLabel は 1始まりのページ番号の表示ラベルを返す (/PageLabels がなければ空文字)
+func pageLabel(ranges []pageLabelRange, page int64) string {
+	index := int(page) - 1
+	var r *pageLabelRange
+	for i := range ranges {
+		if ranges[i].start > index {
+			break
+		}
+		r = &ranges[i]
+	}
+	if r == nil {
+		return ""
+	}
+	n := r.first + index - r.start
+	switch r.style {
+	case "D":
+		return r.prefix + strconv.Itoa(n)
+	case "R":
+		return r.prefix + romanNumeral(n)
+	case "r":
+		return r.prefix + strings.ToLower(romanNumeral(n))
+	case "A":
+		return r.prefix + alphabeticLabel(n)
+	case "a":
+		return r.prefix + strings.ToLower(alphabeticLabel(n))
+	}
+	return r.prefix
+}
+
+func romanNumeral(n int) string {
+	values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	symbols := []string{"M", "CM", "D", "CD", "C", "XC", "L", "XL", "X", "IX", "V", "IV", "I"}
+	var b strings.Builder
+	for i, v := range values {
+		for n >= v {
+			b.WriteString(symbols[i])
+			n -= v
+		}
+	}
+	return b.String()
+}
+
+// alphabeticLabel は A〜Z、AA〜ZZ、AAA〜 の形式の番号を返す
+func alphabeticLabel(n int) string {
+	if n < 1 {
+		return ""
+	}
+	letter := string(rune('A' + (n-1)%26))
+	return strings.Repeat(letter, (n-1)/26+1)
+}
+
+// walkNumberTree は 数値ツリーの全ての値を fn へ渡す
+func (p *PDFParser) walkNumberTree(node PDFObject, depth int, fn func(key int, value PDFObject) error) error {
+	if depth > maxTreeDepth {
+		return errors.New("number tree is too deep")
+	}
+	dict, ok := node.(map[string]PDFObject)
+	if !ok {
+		return nil
+	}
+	if nums, ok := dict["Nums"].([]PDFObject); ok {
+		for i := 0; i+1 < len(nums); i += 2 {
+			key, ok := nums[i].(int)
+			if !ok {
+				continue
+			}
+			if err := fn(key, nums[i+1]); err != nil {
+				return err
+			}
+		}
+	}
+	kids, _ := dict["Kids"].([]PDFObject)
+	for _, kid := range kids {
+		kid, err := p.resolveObject(kid)
+		if err != nil {
+			return err
+		}
+		if err := p.walkNumberTree(kid, depth+1, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
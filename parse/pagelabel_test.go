@@ -0,0 +1,95 @@
+package parse
+
+import "testing"
+
+// TestPageLabel は 範囲ごとの形式・接頭辞・開始番号 (/St) からページの表示ラベルを求めることを確かめる
+func TestPageLabel(t *testing.T) {
+	ranges := []pageLabelRange{
+		{start: 0, style: "r", first: 1},
+		{start: 4, style: "D", first: 1},
+		{start: 9, style: "A", prefix: "A-", first: 1},
+		{start: 12, style: "D", first: 100},
+		{start: 14, prefix: "Cover", first: 1},
+		{start: 15, style: "R", first: 1994},
+		{start: 16, style: "a", first: 26},
+	}
+	for _, test := range []struct {
+		page int64
+		want string
+	}{
+		{1, "i"},
+		{4, "iv"},
+		{5, "1"},
+		{9, "5"},
+		{10, "A-A"},
+		{12, "A-C"},
+		{13, "100"},
+		{14, "101"},
+		{15, "Cover"},
+		{16, "MCMXCIV"},
+		{17, "z"},
+		{18, "aa"},
+		{19, "bb"},
+	} {
+		if got := pageLabel(ranges, test.page); got != test.want {
+			t.Errorf("pageLabel(%d) = %q, want %q", test.page, got, test.want)
+		}
+	}
+}
+
+// TestPageLabelBeforeFirstRange は 最初の範囲より前のページと /PageLabels のない文書でラベルが空になることを確かめる
+func TestPageLabelBeforeFirstRange(t *testing.T) {
+	if got := pageLabel([]pageLabelRange{{start: 2, style: "D", first: 1}}, 1); got != "" {
+		t.Errorf("pageLabel before the first range = %q, want empty", got)
+	}
+	if got := pageLabel(nil, 1); got != "" {
+		t.Errorf("pageLabel without /PageLabels = %q, want empty", got)
+	}
+}
+
+// TestRomanNumeral は 減算表記を含むローマ数字を確かめる
+func TestRomanNumeral(t *testing.T) {
+	for n, want := range map[int]string{1: "I", 4: "IV", 9: "IX", 14: "XIV", 40: "XL", 90: "XC", 400: "CD", 2024: "MMXXIV", 3999: "MMMCMXCIX"} {
+		if got := romanNumeral(n); got != want {
+			t.Errorf("romanNumeral(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+// TestAlphabeticLabel は Z の次が AA、ZZ の次が AAA になる (同じ文字を繰り返す) ことを確かめる
+func TestAlphabeticLabel(t *testing.T) {
+	for n, want := range map[int]string{0: "", 1: "A", 26: "Z", 27: "AA", 28: "BB", 52: "ZZ", 53: "AAA"} {
+		if got := alphabeticLabel(n); got != want {
+			t.Errorf("alphabeticLabel(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+// TestPageLabelsNumberTree は 子を持つ数値ツリーの /PageLabels を開始ページ順に読み込むことを確かめる
+func TestPageLabelsNumberTree(t *testing.T) {
+	p := newObjectsParser(StreamLengthTolerant,
+		"1 0 obj\n<< /Kids [2 0 R 3 0 R] >>\nendobj\n",
+		"2 0 obj\n<< /Nums [0 << /S /r >>] >>\nendobj\n",
+		"3 0 obj\n<< /Nums [6 4 0 R 2 << /S /D /St 3 >>] >>\nendobj\n",
+		"4 0 obj\n<< /S /A /P (App-) >>\nendobj\n",
+	)
+	ranges, err := p.pageLabels(&Catalog{PageLabels: "1 0 R"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		page int64
+		want string
+	}{
+		{1, "i"},
+		{2, "ii"},
+		{3, "3"},
+		{6, "6"},
+		{7, "App-A"},
+		{8, "App-B"},
+	} {
+		if got := pageLabel(ranges, test.page); got != test.want {
+			t.Errorf("page %d label = %q, want %q", test.page, got, test.want)
+		}
+	}
+}
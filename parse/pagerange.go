@@ -0,0 +1,69 @@
+package parse
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// PageRange は 連続したページの範囲 (1つのページの場合は Start と End が同じ)
+type PageRange struct {
+	Start int64
+	End   int64
+}
+
+// PageRanges は 1,5,9-12 のように指定した、連続しないページの集まり
+// ジャンプした先で表示されるページだけを要求するために使う
+type PageRanges []PageRange
+
+// ParsePageRanges は カンマで区切ったページ番号と範囲 (9-12) を読み込む
+func ParsePageRanges(s string) (PageRanges, error) {
+	var ranges PageRanges
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.ParseInt(first, 10, 32)
+		if err != nil || start < 1 {
+			return nil, fmt.Errorf("invalid page %q", part)
+		}
+		end := start
+		if isRange {
+			end, err = strconv.ParseInt(last, 10, 32)
+			if err != nil || end < start {
+				return nil, fmt.Errorf("invalid page range %q", part)
+			}
+		}
+		ranges = append(ranges, PageRange{Start: start, End: end})
+	}
+	return ranges, nil
+}
+
+// String は ParsePageRanges で読み込める形にする
+func (ranges PageRanges) String() string {
+	parts := make([]string, len(ranges))
+	for i, r := range ranges {
+		parts[i] = strconv.FormatInt(r.Start, 10)
+		if r.End != r.Start {
+			parts[i] += "-" + strconv.FormatInt(r.End, 10)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// sequence は 文書にあるページを重複なく base に近い順に並べる (文書のページ数を超える番号は除く)
+func (ranges PageRanges) sequence(base, pageLen int64) ([]int64, error) {
+	var pages []int64
+	for _, r := range ranges {
+		for page := r.Start; page <= min(r.End, pageLen); page++ {
+			pages = append(pages, page)
+		}
+	}
+	slices.Sort(pages)
+	pages = slices.Compact(pages)
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("%w: pages=%s (the document has %d pages)", ErrNoPagesSelected, ranges, pageLen)
+	}
+	sortByDistance(pages, base)
+	return pages, nil
+}
@@ -0,0 +1,78 @@
+package parse
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// TestParsePageRanges は カンマで区切ったページ番号と範囲を読み込み、String で同じ形に戻せることを確かめる
+func TestParsePageRanges(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		want PageRanges
+	}{
+		{"1", PageRanges{{1, 1}}},
+		{"1,5,9-12", PageRanges{{1, 1}, {5, 5}, {9, 12}}},
+		{"3-3", PageRanges{{3, 3}}},
+		{" 2 , 4-6 ", PageRanges{{2, 2}, {4, 6}}},
+	} {
+		got, err := ParsePageRanges(test.s)
+		if err != nil {
+			t.Errorf("ParsePageRanges(%q): %v", test.s, err)
+			continue
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("ParsePageRanges(%q) = %v, want %v", test.s, got, test.want)
+		}
+		again, err := ParsePageRanges(got.String())
+		if err != nil || !slices.Equal(again, got) {
+			t.Errorf("ParsePageRanges(%q) = %v, %v, want %v", got.String(), again, err, got)
+		}
+	}
+}
+
+// TestParsePageRangesInvalid は 1 より小さいページ、逆順の範囲、数でないページをエラーにすることを確かめる
+func TestParsePageRangesInvalid(t *testing.T) {
+	for _, s := range []string{"", "0", "-1", "a", "1,,2", "5-3", "1-", "1-b", "2147483648"} {
+		if got, err := ParsePageRanges(s); err == nil {
+			t.Errorf("ParsePageRanges(%q) = %v, want an error", s, got)
+		}
+	}
+}
+
+// TestPageRangesSequence は 重なる範囲のページを1度だけ、基準のページに近い順 (同じ距離なら前のページから) に並べ、
+// 文書にないページを除くことを確かめる
+func TestPageRangesSequence(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		ranges  PageRanges
+		base    int64
+		pageLen int64
+		want    []int64
+	}{
+		{"pages from the first page", PageRanges{{1, 1}, {5, 5}, {9, 10}}, 1, 20, []int64{1, 5, 9, 10}},
+		{"overlapping ranges", PageRanges{{2, 5}, {4, 7}, {5, 5}}, 2, 20, []int64{2, 3, 4, 5, 6, 7}},
+		{"closest to base first", PageRanges{{1, 1}, {5, 5}, {9, 12}}, 9, 20, []int64{9, 10, 11, 12, 5, 1}},
+		{"earlier page on a tie", PageRanges{{3, 3}, {7, 7}, {5, 5}}, 5, 20, []int64{5, 3, 7}},
+		{"pages beyond the document", PageRanges{{2, 2}, {4, 30}, {40, 50}}, 1, 5, []int64{2, 4, 5}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.ranges.sequence(test.base, test.pageLen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("sequence = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// TestPageRangesSequenceNoPages は 文書にあるページを1つも含まない場合に ErrNoPagesSelected を返すことを確かめる
+func TestPageRangesSequenceNoPages(t *testing.T) {
+	_, err := PageRanges{{6, 8}, {10, 10}}.sequence(1, 5)
+	if !errors.Is(err, ErrNoPagesSelected) {
+		t.Errorf("err = %v, want %v", err, ErrNoPagesSelected)
+	}
+}
@@ -0,0 +1,82 @@
+package parse
+
+import "math"
+
+// pageSummaryBuilder は ページの内容を送る前に、送るコマンドの件数とおおよその範囲をまとめる
+// 仮想スクロールするクライアントが、内容が届く前にページの配置やプレースホルダーを用意できるようにする
+type pageSummaryBuilder struct {
+	summary ParsedPageSummary
+}
+
+func newPageSummaryBuilder(page int64) *pageSummaryBuilder {
+	return &pageSummaryBuilder{summary: ParsedPageSummary{Page: page}}
+}
+
+// addText は テキストの件数と範囲を加える
+func (b *pageSummaryBuilder) addText(bounds Rect) {
+	b.summary.Texts++
+	b.summary.TextBounds = unionBounds(b.summary.TextBounds, bounds)
+}
+
+// addImage は 画像の件数と範囲を加える
+func (b *pageSummaryBuilder) addImage(bounds Rect) {
+	b.summary.Images++
+	b.summary.ImageBounds = unionBounds(b.summary.ImageBounds, bounds)
+}
+
+// addPath は パスの件数と範囲を加える (範囲が分からないパスは件数のみ)
+func (b *pageSummaryBuilder) addPath(bounds Rect, hasBounds bool) {
+	b.summary.Paths++
+	if hasBounds {
+		b.summary.PathBounds = unionBounds(b.summary.PathBounds, bounds)
+	}
+}
+
+// build は まとめた内容を返す。範囲は外側の整数に丸める
+func (b *pageSummaryBuilder) build() *ParsedPageSummary {
+	summary := b.summary
+	for _, bounds := range []*Rect{summary.TextBounds, summary.ImageBounds, summary.PathBounds} {
+		if bounds == nil {
+			continue
+		}
+		bounds.X0, bounds.Y0 = math.Floor(bounds.X0), math.Floor(bounds.Y0)
+		bounds.X1, bounds.Y1 = math.Ceil(bounds.X1), math.Ceil(bounds.Y1)
+	}
+	return &summary
+}
+
+func unionBounds(r *Rect, o Rect) *Rect {
+	if r == nil {
+		return &o
+	}
+	r.X0, r.Y0 = math.Min(r.X0, o.X0), math.Min(r.Y0, o.Y0)
+	r.X1, r.Y1 = math.Max(r.X1, o.X1), math.Max(r.Y1, o.Y1)
+	return r
+}
+
+// summarizePage は 表示領域外で送らないコマンドを除いて、ページの概要を作る
+func (p *PDFParser) summarizePage(page int64, pageBox Rect, tc []TextCommand, pc []PathCommand, ic []ImageCommand) *ParsedPageSummary {
+	b := newPageSummaryBuilder(page)
+	for _, cmd := range tc {
+		bounds := textBounds(cmd)
+		if drop, _ := p.offPagePolicy.offPage(pageBox, bounds); !drop {
+			b.addText(bounds)
+		}
+	}
+	for _, cmd := range pc {
+		bounds, hasBounds := pathBounds(cmd.Path)
+		if hasBounds {
+			if drop, _ := p.offPagePolicy.offPage(pageBox, bounds); drop {
+				continue
+			}
+		}
+		b.addPath(bounds, hasBounds)
+	}
+	for _, cmd := range ic {
+		bounds := imageBounds(cmd, pageBox.Y1)
+		if drop, _ := p.offPagePolicy.offPage(pageBox, bounds); !drop {
+			b.addImage(bounds)
+		}
+	}
+	return b.build()
+}
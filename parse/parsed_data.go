@@ -0,0 +1,332 @@
+package parse
+
+type ParsedDataType int
+
+// ParsedData インターフェース: 解析結果(テキスト/画像/フォント)を表す
+type ParsedData interface {
+}
+
+// --------------------------
+// ページデータ
+// --------------------------
+type ParsedPage struct {
+	Width  float64
+	Height float64
+	Page   int64
+	Lang   string // 言語 (BCP 47、/Lang またはテキストから推定)
+	Script string // 主な文字体系 (ISO 15924)
+	Label  string // 表示用のページラベル ("iv"、"A-3" など、/PageLabels がなければ空文字)
+
+	Fingerprint string // ページが参照するオブジェクトの内容のハッシュ (PageFingerprint が有効な場合のみ)
+}
+
+// --------------------------
+// テキストデータ
+// --------------------------
+type ParsedText struct {
+	X        float64
+	Y        float64 // ベースラインの位置 (ページ上端からの距離)
+	Z        int64
+	Text     string
+	Width    float64 // 表示幅 (グリフ幅が分からない場合は0)
+	FontID   string
+	FontSize float64
+	Page     int64
+	Color    string
+
+	StrokeAlpha float64
+	FillAlpha   float64
+	BlendMode   string
+	ClipPaths   []ClipPath
+	OffPage     bool       // ページの表示領域外
+	SharedID    string     // 他のページから参照される共有コンテンツのID
+	Glyphs      []int      // 元のグリフIDの並び (ShapingHints が有効な場合のみ)
+	Clusters    []int      // 各グリフに対応するテキストの開始位置 (文字数)
+	Struct      StructRole // 構造ツリー上の種別と読み順 (StructureTree が有効で、対応する要素がある場合のみ)
+	Words       []TextWord // 単語の区切り (GroupLines が有効な場合のみ)
+	ActualText  *string    // 後処理で置き換えた検索・抽出用のテキスト (置き換えがない場合は nil)
+}
+
+type ParsedPath struct {
+	X           float64
+	Y           float64
+	Z           int64
+	Width       float64
+	Height      float64
+	Page        int64
+	Path        string
+	PaintMode   string // fill / stroke / fillStroke
+	FillRule    string // nonzero / evenodd (ストロークのみの場合は空文字)
+	FillColor   string
+	StrokeColor string
+	StrokeAlpha float64
+	FillAlpha   float64
+	BlendMode   string
+	MaskData    []byte // 解凍済みソフトマスクバイト列
+	MaskType    string // ソフトマスク種別 (Luminosity / Alpha)
+	ClipPaths   []ClipPath
+	OffPage     bool   // ページの表示領域外
+	SharedID    string // 他のページから参照される共有コンテンツのID
+}
+
+// --------------------------
+// 共有コンテンツ参照
+// --------------------------
+// ParsedSharedContent は 前のページで送った SharedID のテキスト・パスをこのページにも描画することを表す
+type ParsedSharedContent struct {
+	SharedID string
+	Page     int64
+	Z        int64
+}
+
+// --------------------------
+// 画像データ
+// --------------------------
+type ParsedImage struct {
+	X        float64
+	Y        float64
+	Z        int64
+	Width    float64
+	Height   float64
+	DW       float64
+	DH       float64
+	Matrix   [6]float64 // 配置行列 [a b c d e f] (PDF のユーザー空間)
+	Data     []byte     // 解凍済み画像バイト列
+	MaskData []byte     // 解凍済みマスクバイト列
+	Page     int64
+	Ext      string
+	ClipPath string
+
+	StrokeAlpha float64
+	FillAlpha   float64
+	BlendMode   string
+	MaskType    string // ソフトマスク種別 (Luminosity / Alpha)
+
+	BitsPerComponent int    // Data の1成分あたりのビット数
+	ColorSpace       string // 色空間名
+	ImageMask        bool   // ステンシルマスク(Data は不透明度、FillColor で塗る)
+	FillColor        string
+	ClipPaths        []ClipPath
+	OffPage          bool           // ページの表示領域外
+	Alt              string         // 代替テキスト (マークコンテンツまたは構造要素の /Alt)
+	ActualText       string         // 置き換えテキスト (/ActualText)
+	Metadata         *ImageMetadata // 埋め込みメタデータ (XMP / EXIF)
+	Tile             *ImageTile     // 分割した画像のタイルの位置 (分割していない場合は nil)
+	ICCProfile       string         // 色空間の ICC プロファイルの ID (ParsedICCProfile で先に送る)
+	ImageID          string         // 同じ画像の2回目以降の配置 (ParsedImagePlacement) や元の解像度の画像から参照する ID
+	Preview          bool           // 縮小したプレビュー (後から同じ ImageID で元の解像度の画像を送る)
+	Crop             *ImageCrop     // クリッピングパスに合わせて切り出した範囲 (切り出していない場合は nil)
+}
+
+// ParsedImagePlacement は 既に送った画像を別の位置・ページに配置する
+// 画像の内容・マスク・大きさは ImageID の ParsedImage のものを使う
+type ParsedImagePlacement struct {
+	ImageID     string
+	X           float64
+	Y           float64
+	Z           int64
+	DW          float64
+	DH          float64
+	Matrix      [6]float64
+	Page        int64
+	ClipPath    string
+	StrokeAlpha float64
+	FillAlpha   float64
+	BlendMode   string
+	FillColor   string // ステンシルマスクを塗る色 (配置ごとに異なる)
+	ClipPaths   []ClipPath
+	OffPage     bool
+	Alt         string
+	ActualText  string
+}
+
+// --------------------------
+// 描画段階の境界
+// --------------------------
+// ParsedBatch は 続く Count 個のコマンドが Page の Batch 段階に属することを表す
+type ParsedBatch struct {
+	Page  int64
+	Batch PaintBatch
+	Count int
+}
+
+// --------------------------
+// 注釈
+// --------------------------
+type ParsedAnnotation struct {
+	Subtype  string // 注釈の種別 (Highlight / Text / Stamp など)
+	X        float64
+	Y        float64
+	Width    float64
+	Height   float64
+	Contents string // 注釈のテキスト (/Contents)
+	Color    string // 注釈の色 (/C)
+	Page     int64
+}
+
+// --------------------------
+// リンク
+// --------------------------
+type ParsedLink struct {
+	X          float64
+	Y          float64
+	Width      float64
+	Height     float64
+	URI        string // 外部リンクの URI
+	TargetPage int64  // 文書内リンクの移動先ページ (URI の場合は 0)
+	Page       int64
+}
+
+// --------------------------
+// 添付ファイル
+// --------------------------
+type ParsedAttachment struct {
+	Name        string
+	Description string
+	MimeType    string
+	Page        int64  // FileAttachment 注釈のページ (文書レベルの添付は0)
+	Data        []byte // 添付ファイル本体
+}
+
+// --------------------------
+// フォントファイルデータ
+// --------------------------
+type ParsedFont struct {
+	FontID     string
+	Data       []byte      // フォントファイル本体
+	Substitute string      // フォントが壊れている場合に代わりに使う総称フォントファミリー
+	Style      FontStyle   // ファミリー名・太さ・斜体などの書体の情報
+	Metrics    FontMetrics // アセント・ディセントなどの縦方向の寸法
+
+	deliveredKey string // DeliveredFonts に記録するキー (DeliveredFonts を使わない場合は空文字)
+}
+
+// --------------------------
+// 警告
+// --------------------------
+// ParsedWarning は 解析を続けられた問題の警告
+type ParsedWarning struct {
+	Message    string
+	Page       int64 // 0 の場合は文書全体
+	Suppressed int   // 上限を超えて送らなかった警告の件数 (最後のまとめの警告のみ)
+}
+
+// --------------------------
+// 検索結果
+// --------------------------
+// ParsedSearchResult は 検索語が見つかった箇所 (上端を原点とするページ座標の矩形)
+type ParsedSearchResult struct {
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+	Text   string // 見つかったテキスト (大文字・小文字は文書のまま)
+	Page   int64
+}
+
+// --------------------------
+// サムネイル
+// --------------------------
+// ParsedThumbnail は ページの低解像度の PNG 画像
+type ParsedThumbnail struct {
+	Page   int64
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// --------------------------
+// ページの概要
+// --------------------------
+// ParsedPageSummary は ページの内容より先に送る、送るコマンドの件数とおおよその範囲
+// 範囲は上端を原点とするページ座標系で、その種別のコマンドがなければ nil
+type ParsedPageSummary struct {
+	Page        int64
+	Texts       int
+	Images      int
+	Paths       int
+	TextBounds  *Rect
+	ImageBounds *Rect
+	PathBounds  *Rect
+}
+
+// --------------------------
+// 文書のメタデータ
+// --------------------------
+// ParsedMetadata は ページの内容を解析せずに読み込める、文書全体のページ数・ページの大きさ・ラベル・文書情報
+// ビューアーがスクロールバーとページの枠を、内容を要求する前に作れるようにする
+type ParsedMetadata struct {
+	Pages []PageMetadata    // 各ページの大きさとラベル (1ページ目から順に)
+	Lang  string            // 文書の既定の言語 (/Lang)
+	Info  map[string]string // 文書情報辞書 (/Info) の文字列の項目 (Title・Author など)
+}
+
+// PageMetadata は 1ページの大きさとラベル
+type PageMetadata struct {
+	Width  float64
+	Height float64
+	Label  string // 表示用のページラベル (/PageLabels がなければ空文字)
+}
+
+// --------------------------
+// 送信の完了
+// --------------------------
+// ParsedPageDone は ページのテキスト・パス・注釈・画像をすべて送り終えたことを示す
+// フォントは全ページの後に送るため含まない
+type ParsedPageDone struct {
+	Page   int64
+	Cursor string // ストリームを再開する位置 (HTTP ハンドラーが送信時に設定する)
+}
+
+// ParsedDone は ストリームの終わりと、送ったデータの種別ごとの件数
+type ParsedDone struct {
+	Pages         int
+	Texts         int
+	Images        int
+	Paths         int
+	Fonts         int
+	Shared        int
+	Annotations   int
+	Links         int
+	Attachments   int
+	Warnings      int
+	SearchResults int
+	Thumbnails    int
+	ICCProfiles   int
+	PageSummaries int
+	Placements    int
+	Metadata      int
+}
+
+// --------------------------
+// 進捗
+// --------------------------
+// ParsedProgress は 長いストリームの途中経過
+type ParsedProgress struct {
+	Pages      int    // 処理し終えたページ数 (画像・フォントは全ページの後に送る)
+	TotalPages int    // 要求されたページ数
+	Bytes      int64  // これまでに送ったバイト数 (圧縮前。HTTP ハンドラーが送信時に設定する)
+	Cursor     string // ストリームを再開する位置 (HTTP ハンドラーが送信時に設定する)
+}
+
+// --------------------------
+// エラー
+// --------------------------
+// ParsedError は 途中で処理を続けられなくなったことを示す (ストリームの最後のデータ)
+type ParsedError struct {
+	Code    int // HTTP のステータスコードにそろえたエラーの種類
+	Message string
+	Limit   string // 超えた上限の種類 (StreamLimits の上限を超えた場合のみ)
+	Max     int64  // 超えた上限の値
+}
+
+// --------------------------
+// ICC プロファイル
+// --------------------------
+// ParsedICCProfile は 色空間・出力インテントの ICC プロファイル (文書ごとに1回だけ送る)
+type ParsedICCProfile struct {
+	ID           string // プロファイルの内容の SHA-256 (16進数)
+	Components   int    // 色の成分数 (/N)
+	OutputIntent string // 出力インテントの種別 (/S。出力インテントでない場合は空)
+	Data         []byte // 展開済みのプロファイル
+}
@@ -0,0 +1,2002 @@
+package parse
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"compress/zlib"
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type Font struct {
+	FontID      string
+	FontDataRef PDFRef
+	fontMap     map[byte]string
+	widths      map[byte]float64 // 文字コードごとのグリフ幅 (1/1000 em)
+	flags       int              // FontDescriptor の /Flags
+	style       FontStyle
+	metrics     FontMetrics
+}
+
+func (f *Font) ToUnicode(b byte) string {
+	return f.fontMap[b]
+}
+
+type XRefTableElement struct {
+	ObjNum     PDFRef
+	GenNum     PDFRef
+	offsetByte int64
+}
+
+type PDFRef int64
+
+type Catalog struct {
+	PagesRef       PDFRef
+	Lang           string    // 文書の既定の言語 (/Lang)
+	StructTreeRoot PDFRef    // 論理構造ツリー (タグ付き PDF のみ)
+	Dests          PDFObject // 名前付き宛先の辞書 (PDF 1.1 形式の /Dests)
+	DestNames      PDFObject // 名前付き宛先の名前ツリー (/Names の /Dests)
+	EmbeddedFiles  PDFObject // 添付ファイルの名前ツリー (/Names の /EmbeddedFiles)
+	PageLabels     PDFObject // ページラベルの数値ツリー (/PageLabels)
+	AcroForm       PDFObject // 対話フォーム (/AcroForm)
+	OutputIntents  PDFObject // 出力インテントの配列 (/OutputIntents)
+}
+
+type PageTree struct {
+	Count      int
+	Kids       []PDFRef
+	PageWidth  float64
+	PageHeight float64
+}
+
+type Page struct {
+	ContentsRef   PDFRef
+	ResourcesRef  PDFRef
+	PageWidth     float64
+	PageHeight    float64
+	StructParents int       // 親ツリーのキー (/StructParents、-1 の場合はなし)
+	Annots        PDFObject // 注釈の配列 (/Annots、参照の場合あり)
+	Ref           PDFRef    // ページオブジェクトの参照
+}
+
+type ExtractedImage struct {
+	Data             []byte
+	MaskData         []byte
+	Width            float64
+	Height           float64
+	Ext              string
+	BitsPerComponent int
+	ColorSpace       string
+	ImageMask        bool
+	Metadata         *ImageMetadata // 埋め込みメタデータ (ImageMetadata が有効な場合のみ)
+	ICCProfile       PDFRef         // ICCBased 色空間のプロファイルストリーム (0 の場合はなし)
+}
+
+type IPDFParser interface {
+	StreamPageContents(pageNum int, outCh chan<- ParsedData) error
+	GetCatalog() (*Catalog, error)
+	GetObject(ref PDFRef) (PDFObject, error)
+	GetPageByNumber(pageNum int) (*Page, error)
+
+	Close() error
+}
+
+type IPDFFile interface {
+	io.Reader
+	io.Closer
+	io.Seeker
+}
+
+type ReadSeekCloser interface {
+	io.ReadCloser
+	io.Seeker
+}
+type SeekerCloser struct {
+	io.ReadCloser
+	io.Seeker
+}
+
+type PDFFile struct {
+	reader   ReadSeekCloser
+	original io.Closer
+}
+
+func (f *PDFFile) Close() error {
+	if f.original != nil {
+		return f.original.Close()
+	}
+	return f.reader.Close()
+}
+
+func (f *PDFFile) Read(p []byte) (int, error) {
+	return f.reader.Read(p)
+}
+
+func (f *PDFFile) Seek(offset int64, whence int) (int64, error) {
+	return f.reader.Seek(offset, whence)
+}
+
+func NewPDFFile(rc io.ReadCloser) (IPDFFile, error) {
+	if seeker, ok := rc.(io.Seeker); ok {
+		return &PDFFile{
+			reader: SeekerCloser{ReadCloser: rc, Seeker: seeker},
+		}, nil
+	}
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		rc.Close()
+		return nil, fmt.Errorf("failed to read data for seeking: %w", err)
+	}
+	rc.Close()
+
+	reader := bytes.NewReader(data)
+	return &PDFFile{
+		reader:   SeekerCloser{ReadCloser: io.NopCloser(reader), Seeker: reader},
+		original: nil,
+	}, nil
+}
+
+type PDFParser struct {
+	file      IPDFFile
+	xrefTable map[PDFRef]XRefTableElement
+	root      PDFRef
+	pageQueue []Page
+	fonts     *boundedCache[Font]
+	// objects は 解析済みの間接オブジェクト (ストリームの間に同じページ・リソース・フォントを読み直さない)
+	// 返したオブジェクトは共有するため、呼び出し側で変更しない
+	objects map[PDFRef]PDFObject
+
+	documents   *DocumentCache
+	documentKey string
+	documentID  string
+	info        PDFRef
+
+	streamLengthPolicy  StreamLengthPolicy
+	offPagePolicy       OffPagePolicy
+	dedupRunningContent bool
+	paintBatches        bool
+	coalesceText        bool
+	groupLines          bool
+	textOptions         TextOptions
+	imageMetadata       bool
+	fsTypePolicy        FsTypePolicy
+	attachments         bool
+	shapingHints        bool
+	glyphMaps           *boundedCache[glyphMap]
+	structureTree       bool
+	maxWarnings         int
+	thumbnailSize       int
+	rasterizer          PageRasterizer
+	pageFingerprints    bool
+	objectDigests       map[PDFRef]uint64
+	maxImagePixels      int
+	textPostProcessors  []TextPostProcessor
+	operatorBudget      OperatorBudget
+	pageTimeout         time.Duration
+	logger              *slog.Logger
+	limits              StreamLimits
+	progressInterval    time.Duration
+	iccProfiles         bool
+	fontFix             FontFixPolicy
+	prioritizeImages    bool
+	fontsFirst          bool
+	chunkOrder          ChunkOrder
+	pageSummary         bool
+	deliveredFonts      *DeliveredFonts
+	dedupImages         bool
+	imagePreviewSize    int
+	maxImageDim         int
+	imageTranscoder     ImageTranscoder
+	transcodeQuality    int
+	cropImagesToClip    bool
+	include             ContentTypes
+	pages               PageRanges
+	signatureRoots      *x509.CertPool
+	viewBase            atomic.Int64
+}
+
+// ParserConfig は PDFParser の動作設定
+type ParserConfig struct {
+	// StreamLengthPolicy は /Length とストリームデータの食い違いの扱い
+	StreamLengthPolicy StreamLengthPolicy
+	// OffPagePolicy は ページの表示領域外に描画されるコマンドの扱い
+	OffPagePolicy OffPagePolicy
+	// DedupRunningContent は ページ上端・下端で同じ位置に繰り返し現れるテキスト・パス
+	// (ヘッダー・フッター)を2ページ目以降は共有コンテンツへの参照として送る
+	DedupRunningContent bool
+	// PaintBatches は ページごとのコマンドを描画段階(背景・テキスト・画像・前景)ごとにまとめて送る
+	PaintBatches bool
+	// CoalesceText は フォント・サイズ・色・ベースラインが同じ連続したテキストを1つにまとめる
+	CoalesceText bool
+	// GroupLines は 同じベースラインに並ぶ同じスタイルのテキストを行単位にまとめ、単語の区切りを付ける
+	// 隙間の大きさにかかわらずまとめるため、CoalesceText より粗い単位になる
+	GroupLines bool
+	// TextOptions は 送信前にテキストへ適用する正規化
+	TextOptions TextOptions
+	// ImageMetadata は 画像の XMP (/Metadata) や JPEG の EXIF から著作権・説明などを読み込む
+	ImageMetadata bool
+	// FsTypePolicy は 埋め込みフォントの埋め込み制限 (OS/2 fsType) の扱い
+	FsTypePolicy FsTypePolicy
+	// Attachments は 埋め込まれた添付ファイルをページの内容の後に送る
+	Attachments bool
+	// ShapingHints は テキストに元のグリフIDの並びと、各グリフのテキスト中の位置(クラスター)を付ける
+	// 複合文字を使う文字体系をクライアントのシェーパーで組み直さないためのヒント
+	ShapingHints bool
+	// StructureTree は タグ付き PDF の構造ツリーを読み、テキストに構造種別 (見出し・段落・表など) と読み順を付ける
+	StructureTree bool
+	// MaxWarnings は 解析中の警告を WarningChunk として送る件数の上限
+	// 0 の場合は警告をログに出すだけで送らない。上限を超えた分は最後に件数だけを送る
+	MaxWarnings int
+	// ThumbnailSize は ページの内容より先に送るサムネイルの長辺のピクセル数 (0 の場合は送らない)
+	ThumbnailSize int
+	// Rasterizer は サムネイルの描画方法 (nil の場合は SimpleRasterizer)
+	Rasterizer PageRasterizer
+	// PageFingerprint は ページが参照するオブジェクトの内容のハッシュをページに付ける
+	// 文書の版をまたいでページが変わっていないかをクライアントやキャッシュが判定できる
+	PageFingerprint bool
+	// FontCacheSize は 読み込んだフォントとグリフIDの対応を保持する件数の上限 (0 は上限なし)
+	// 送信中のストリームで使うフォントは上限を超えても保持し、ストリームを送り終えてから追い出す
+	FontCacheSize int
+	// MaxImagePixels は 1つの画像チャンクのピクセル数の上限 (0 の場合は分割しない)
+	// 超える画像は上限に収まる正方形のタイルに分割し、それぞれを画像チャンクとして送る
+	// メモリの少ないクライアントがタイルごとに展開できるようにするため
+	MaxImagePixels int
+	// TextPostProcessors は ページのテキストを送る前に順に適用する後処理
+	// 描画用のテキストは変えず、置き換えたテキストを ActualText として付ける
+	TextPostProcessors []TextPostProcessor
+	// OperatorBudget は 1ページのコンテンツストリームで実行する演算子の数・処理時間の上限
+	// 超えたページはそこまでの内容で打ち切り、警告を送る
+	OperatorBudget OperatorBudget
+	// PageTimeout は 1ページの解析にかける時間の上限 (0 は上限なし)
+	// OperatorBudget と違い、超えた場合はページを打ち切らずにストリーム全体を ErrPageTimeout で中止する
+	PageTimeout time.Duration
+	// Logger は 解析中の警告・エラーを出力するロガー (nil の場合は slog.Default())
+	// HTTP ハンドラーは要求ごとに要求 ID・ファイル名・ページの範囲を付けたロガーを渡す
+	Logger *slog.Logger
+	// Limits は 1つの要求で解析するページ数・送るバイナリのバイト数の上限 (超えた場合は *LimitError で中止する)
+	Limits StreamLimits
+	// ProgressInterval は 処理したページ数を ProgressChunk として送る間隔 (0 の場合は送らない)
+	// ページの区切りごとに確認するため、1ページの処理がこれより長い場合はページごとに送る
+	ProgressInterval time.Duration
+	// ICCProfiles は ICCBased 色空間と出力インテントの ICC プロファイルを内容のハッシュを ID として文書ごとに1回だけ送る
+	// 画像チャンクには使うプロファイルの ID を付け、カラーマネジメントを行うクライアントが色を再現できるようにする
+	ICCProfiles bool
+	// FontFix は 埋め込みフォントに OS/2 テーブルを補う修正の扱い (空の場合は FontFixAuto)
+	FontFix FontFixPolicy
+	// PrioritizeImages は 画像を解析した順ではなく、基準ページに近いページ・ページの上にある画像から送る
+	// SetViewBase で基準ページが変わると、まだ送っていない画像の順番を決め直す
+	PrioritizeImages bool
+	// FontsFirst は フォントをページの完了の直前ではなく、各ページのテキストの直後に送る
+	// 大きな画像を送り終える前にテキストを描画できる
+	FontsFirst bool
+	// ChunkOrder は ページ・テキスト・パス・画像・フォントを送る順番 (空の場合は ChunkOrderImagesLast)
+	ChunkOrder ChunkOrder
+	// PageSummary は ページの内容より先に、送るテキスト・画像・パスの件数とおおよその範囲を送る
+	// 仮想スクロールするクライアントが、内容の届いていないページの配置を先に決められる
+	PageSummary bool
+	// DeliveredFonts は 以前の要求で同じクライアントに送ったフォントの記録 (nil の場合は要求の中でのみ重複を除く)
+	// 記録にあるフォントは送らない。送ったフォントは、チャンクを書き込めた後に呼び出し側が DeliveredFonts.MarkSent で記録する
+	DeliveredFonts *DeliveredFonts
+	// DedupImages は 同じ画像 XObject を複数回配置する場合に、画像を1度だけ送り、2回目以降は配置のみを送る
+	DedupImages bool
+	// ImagePreviewSize は 画像を元の解像度で送る前に、長辺をこのピクセル数に縮小したプレビューを送る (0 の場合は送らない)
+	// 全ての画像のプレビューを送った後に、同じ ImageID で元の解像度の画像を送る。遅い回線でも先にページの見た目を表示できる
+	ImagePreviewSize int
+	// MaxImageDim は 画像の長辺のピクセル数の上限 (0 の場合は縮小しない)
+	// 超える画像は縦横比を保って縮小してから送る。小さな画面のクライアントに高解像度のスキャン画像を送らないため
+	MaxImageDim int
+	// ImageTranscoder は FlateDecode・非圧縮の画像を送る前に WebP・AVIF などに変換する (nil の場合は変換しない)
+	// 変換後の方が大きい画像は元のまま送る
+	ImageTranscoder ImageTranscoder
+	// TranscodeQuality は ImageTranscoder に渡す品質 (1〜100。0 の場合は 80)
+	TranscodeQuality int
+	// DocumentCache は xref テーブルとページツリーを要求をまたいで保持するキャッシュ (nil の場合は保持しない)
+	DocumentCache *DocumentCache
+	// DocumentName は DocumentCache のキーに使う文書の名前 (空の場合は保持しない)
+	// ファイルの ETag、または大きさと更新日時を合わせてキーにするため、それらを返さないファイルは保持しない
+	DocumentName string
+	// CropImagesToClip は 矩形のクリッピングパスの下に描かれる画像を、見える範囲に切り出してから送る
+	// 回転・傾斜した画像と、マスクの大きさが画像と異なる画像は切り出さない
+	CropImagesToClip bool
+	// Include は StreamPageContents で送るページの内容の種類 (空の場合は全て送る)
+	// 含めない種類はチャンクを作らず、画像は展開もしない
+	Include ContentTypes
+	// Pages は StreamPageContents・StreamSearchResults で送るページ (空の場合は start から end まで)
+	// 指定した場合は start・end を使わず、このページだけを base に近い順に送る
+	Pages PageRanges
+	// SignatureRoots は VerifySignature で署名者の証明書の連なりを確かめるルート証明書 (nil の場合はシステムのルート証明書)
+	SignatureRoots *x509.CertPool
+}
+
+func NewPDFParser(open func() (IPDFFile, error)) (*PDFParser, error) {
+	return NewPDFParserWithConfig(open, ParserConfig{})
+}
+
+func NewPDFParserWithConfig(open func() (IPDFFile, error), config ParserConfig) (*PDFParser, error) {
+	document, err := OpenDocument(open, config)
+	if err != nil {
+		return nil, err
+	}
+	return document.Parser()
+}
+
+// prepareDocument は xref テーブルとトレーラーの /Root を読み込む
+func prepareDocument(file IPDFFile) (*preparedDocument, error) {
+	xrefTable, rootMetadata, err := parseXrefTable(file)
+	if err != nil {
+		return nil, err
+	}
+	rootObject, err := parseMetadata(*rootMetadata)
+	if err != nil {
+		return nil, err
+	}
+	rootString, found := findTarget(rootObject, "Root")
+	if !found {
+		return nil, errors.New("root not found")
+	}
+	root, ok := rootString.(string)
+	if !ok {
+		return nil, errors.New("root is not string")
+	}
+	rootRefs := strings.Split(root, " ")
+	if len(rootRefs) != 3 {
+		return nil, errors.New("root format error")
+	}
+	rootObjNum, err := strconv.Atoi(rootRefs[0])
+	if err != nil {
+		return nil, err
+	}
+
+	rootRef := xrefTable[PDFRef(rootObjNum)].ObjNum
+	info, _ := findTargetRef(rootObject, "Info")
+	return &preparedDocument{xrefTable: xrefTable, root: rootRef, id: trailerID(rootObject), info: info}, nil
+}
+
+// newPDFParser は 読み込んだ文書の構造からパーサーを作成する
+// キャッシュから取り出した構造の場合は、読み込み済みのページツリーも使う
+func newPDFParser(file IPDFFile, doc *preparedDocument, key string, config ParserConfig) *PDFParser {
+	return &PDFParser{
+		file:                file,
+		xrefTable:           doc.xrefTable,
+		root:                doc.root,
+		pageQueue:           doc.pageQueue,
+		documentID:          doc.id,
+		info:                doc.info,
+		documents:           config.DocumentCache,
+		documentKey:         key,
+		fonts:               newBoundedCache[Font](config.FontCacheSize),
+		objects:             make(map[PDFRef]PDFObject),
+		streamLengthPolicy:  config.StreamLengthPolicy,
+		offPagePolicy:       config.OffPagePolicy,
+		dedupRunningContent: config.DedupRunningContent,
+		paintBatches:        config.PaintBatches,
+		coalesceText:        config.CoalesceText,
+		groupLines:          config.GroupLines,
+		textOptions:         config.TextOptions,
+		imageMetadata:       config.ImageMetadata,
+		fsTypePolicy:        config.FsTypePolicy,
+		attachments:         config.Attachments,
+		shapingHints:        config.ShapingHints,
+		glyphMaps:           newBoundedCache[glyphMap](config.FontCacheSize),
+		structureTree:       config.StructureTree,
+		maxWarnings:         config.MaxWarnings,
+		thumbnailSize:       config.ThumbnailSize,
+		rasterizer:          config.Rasterizer,
+		pageFingerprints:    config.PageFingerprint,
+		objectDigests:       make(map[PDFRef]uint64),
+		maxImagePixels:      config.MaxImagePixels,
+		textPostProcessors:  config.TextPostProcessors,
+		operatorBudget:      config.OperatorBudget,
+		pageTimeout:         config.PageTimeout,
+		logger:              cmp.Or(config.Logger, slog.Default()),
+		limits:              config.Limits,
+		progressInterval:    config.ProgressInterval,
+		iccProfiles:         config.ICCProfiles,
+		fontFix:             config.FontFix,
+		prioritizeImages:    config.PrioritizeImages,
+		fontsFirst:          config.FontsFirst || config.ChunkOrder == ChunkOrderFontsFirst || config.ChunkOrder == ChunkOrderInterleave,
+		chunkOrder:          config.ChunkOrder,
+		pageSummary:         config.PageSummary,
+		deliveredFonts:      config.DeliveredFonts,
+		dedupImages:         config.DedupImages,
+		imagePreviewSize:    config.ImagePreviewSize,
+		maxImageDim:         config.MaxImageDim,
+		imageTranscoder:     config.ImageTranscoder,
+		transcodeQuality:    config.TranscodeQuality,
+		cropImagesToClip:    config.CropImagesToClip,
+		include:             config.Include,
+		pages:               config.Pages,
+		signatureRoots:      config.SignatureRoots,
+	}
+}
+
+func (p *PDFParser) ParseObject(ref PDFRef) (PDFObject, error) {
+	if object, ok := p.objects[ref]; ok {
+		return object, nil
+	}
+	object, err := p.readObject(ref)
+	if err != nil {
+		return nil, err
+	}
+	if p.objects != nil {
+		p.objects[ref] = object
+	}
+	return object, nil
+}
+
+// readObject は ref のオブジェクトをファイルから読み込んで解析する (キャッシュを使わない)
+func (p *PDFParser) readObject(ref PDFRef) (PDFObject, error) {
+	object := p.xrefTable[ref]
+	objectString := loadObject(p.file, object.offsetByte)
+	if trimmed := strings.TrimSpace(objectString); !strings.HasPrefix(trimmed, "<<") {
+		// 辞書以外(配列・数値など)の間接オブジェクト
+		return parseObject(strings.NewReader(trimmed))
+	}
+	return parseMetadata(objectString)
+}
+
+func loadObject(file IPDFFile, offsetByte int64) string {
+	file.Seek(int64(offsetByte), io.SeekStart)
+	scanner := bufio.NewScanner(file)
+	buffer := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "endobj") || strings.HasPrefix(line, "stream") {
+			break
+		}
+
+		buffer += line + "\n"
+	}
+	parts := strings.Split(buffer, "obj")
+	if len(parts) < 2 {
+		// オフセットがオブジェクトを指していない (壊れた相互参照表)
+		return ""
+	}
+	return parts[1]
+}
+
+type ImageRefCommand struct {
+	X           float64 // X座標
+	Y           float64 // Y座標
+	Z           int64   // Z座標
+	DW          float64 // 表示横幅
+	DH          float64 // 表示縦幅
+	Matrix      Matrix  // 配置行列
+	ImageRef    PDFRef  // 画像ID
+	Page        int64
+	ClipPath    string
+	StrokeAlpha float64 // ストローク不透明度
+	FillAlpha   float64 // 塗り不透明度
+	BlendMode   string  // ブレンドモード
+	SoftMask    *SoftMask
+	FillColor   string // ステンシルマスクの塗り色
+	ClipPaths   []ClipPath
+	OffPage     bool   // ページの表示領域外
+	Alt         string // 代替テキスト
+	ActualText  string // 置き換えテキスト
+}
+
+// StreamPageContents は 指定ページからデータを解析し、チャネルへ送る
+func (p *PDFParser) StreamPageContents(ctx context.Context, start, end, base int64, insertData func(data ParsedData)) error {
+	c, err := p.GetCatalog()
+	if err != nil {
+		return err
+	}
+	err = p.loadPageObject(*c)
+	if err != nil {
+		return err
+	}
+	sequence, base, err := p.pageSequence(start, end, base)
+	if err != nil {
+		return err
+	}
+	if err := p.limits.checkPages(len(sequence)); err != nil {
+		return err
+	}
+
+	// このストリームで読み込んだフォントは、送り終えるまで追い出さない
+	p.fonts.beginPin()
+	defer p.fonts.endPin()
+
+	// クライアントが SetViewBase で知らせていなければ、要求された基準ページを使う
+	p.viewBase.CompareAndSwap(0, base)
+	pageHeights := make(map[int64]float64, len(sequence))
+
+	summary := newStreamSummary(insertData)
+	limits := newLimitTracker(p.limits, summary.insert)
+	insertData = limits.insert
+	// checkpoint は 切断・時間切れ・上限を超えた場合に解析を中止する理由を返す
+	checkpoint := func() error {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		return limits.err()
+	}
+	progress := newProgressReporter(p.progressInterval, len(sequence), insertData)
+
+	// FIXME:capacityが0であるため追加するたびにメモリ再割り当てが発生している
+	imgCommands := make([]ImageRefCommand, 0)
+	warnings := newWarningLimiter(p.maxWarnings, p.logger, insertData)
+	defer warnings.flush()
+	fontFileList := make(map[string]Font, 0)
+	// pageFonts は ページごとに使うフォントを、最初に使った順に記録する
+	pageFonts := make(map[int64][]string)
+	sentFonts := make(map[string]bool)
+	// sendFonts は keys のフォントのうち、まだ送っていないものを送る
+	sendFonts := func(keys []string) error {
+		if !p.include.Includes(ContentFonts) {
+			return nil
+		}
+		for _, key := range keys {
+			if sentFonts[key] {
+				continue
+			}
+			sentFonts[key] = true
+			font := fontFileList[key]
+			if p.deliveredFonts != nil && font.FontDataRef != 0 && p.deliveredFonts.delivered(deliveredFontKey(key, font)) {
+				// 以前の要求で送ったフォントはクライアントが持っている
+				continue
+			}
+			if err := p.sendFont(key, font, insertData, warnings); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	softMasks := make(map[PDFRef][]byte)
+	loadSoftMask := func(mask *SoftMask) ([]byte, string) {
+		if mask == nil {
+			return nil, ""
+		}
+		if data, ok := softMasks[mask.GroupRef]; ok {
+			return data, mask.Subtype
+		}
+		data, err := p.renderSoftMask(mask)
+		if err != nil {
+			warnings.warn(0, "Failed to render soft mask: %v", err)
+		}
+		softMasks[mask.GroupRef] = data
+		return data, mask.Subtype
+	}
+	labels, err := p.pageLabels(c)
+	if err != nil {
+		warnings.warn(0, "Failed to read page labels: %v", err)
+	}
+	var roles structRoles
+	if p.structureTree {
+		roles, err = p.structTree(c.StructTreeRoot)
+		if err != nil {
+			warnings.warn(0, "Failed to read structure tree: %v", err)
+		}
+	}
+	batches := newPaintBatcher(p.paintBatches, insertData)
+	var shared *sharedContentTracker
+	if p.dedupRunningContent {
+		shared = newSharedContentTracker()
+	}
+	var iccProfiles *iccProfileTracker
+	if p.iccProfiles {
+		iccProfiles = newICCProfileTracker(p, insertData)
+		// 出力インテントは文書全体の色の再現に使うため、ページより先に送る
+		intents, err := p.outputIntents(c)
+		if err != nil {
+			warnings.warn(0, "Failed to read output intents: %v", err)
+		}
+		for _, intent := range intents {
+			if _, err := iccProfiles.send(intent.Ref, intent.Subtype); err != nil {
+				warnings.warn(0, "Failed to read output intent profile: %v", err)
+			}
+		}
+	}
+	// pageDone は ページの残りの内容とフォントを送ってから、ページの完了を送る
+	pageDone := func(page int64) error {
+		batches.flush()
+		if err := sendFonts(pageFonts[page]); err != nil {
+			return err
+		}
+		summary.pageDone(page)
+		return nil
+	}
+	pages := newPageCompletion(pageDone)
+	// buildImage は 画像を展開し、配置の情報を合わせる
+	buildImage := func(cmd ImageRefCommand) (*ParsedImage, error) {
+		img, err := p.ExtractImageStream(cmd.ImageRef)
+		if err != nil {
+			p.logger.Error("Failed to extract image stream", "ref", cmd.ImageRef, "err", err)
+			return nil, err
+		}
+		maskType := ""
+		fillColor := ""
+		if img.ImageMask {
+			fillColor = cmd.FillColor
+		}
+		if len(img.MaskData) == 0 {
+			// 画像自体に /SMask がなければ ExtGState のソフトマスクを適用する
+			img.MaskData, maskType = loadSoftMask(cmd.SoftMask)
+		}
+
+		parsed := &ParsedImage{
+			X:           cmd.X,
+			Y:           cmd.Y,
+			Z:           cmd.Z,
+			Width:       img.Width,
+			Height:      img.Height,
+			DW:          cmd.DW,
+			DH:          cmd.DH,
+			Matrix:      cmd.Matrix.Values(),
+			Data:        img.Data,
+			MaskData:    img.MaskData,
+			Page:        cmd.Page,
+			Ext:         img.Ext,
+			ClipPath:    cmd.ClipPath,
+			StrokeAlpha: cmd.StrokeAlpha,
+			FillAlpha:   cmd.FillAlpha,
+			BlendMode:   cmd.BlendMode,
+			MaskType:    maskType,
+
+			BitsPerComponent: img.BitsPerComponent,
+			ColorSpace:       img.ColorSpace,
+			ImageMask:        img.ImageMask,
+			FillColor:        fillColor,
+			ClipPaths:        cmd.ClipPaths,
+			OffPage:          cmd.OffPage,
+			Alt:              cmd.Alt,
+			ActualText:       cmd.ActualText,
+			Metadata:         img.Metadata,
+		}
+		if iccProfiles != nil && img.ICCProfile != 0 {
+			parsed.ICCProfile, err = iccProfiles.send(img.ICCProfile, "")
+			if err != nil {
+				warnings.warn(cmd.Page, "Failed to read ICC profile: %v", err)
+			}
+		}
+		if p.cropImagesToClip {
+			cropped, err := cropImageToClip(parsed, pageHeights[cmd.Page])
+			if err != nil {
+				warnings.warn(cmd.Page, "Image %.0fx%.0f is not cropped: %v", parsed.Width, parsed.Height, err)
+			}
+			parsed = cropped
+		}
+		if p.maxImageDim > 0 {
+			scaled, err := scaleImage(parsed, p.maxImageDim)
+			if err != nil {
+				warnings.warn(cmd.Page, "Image %.0fx%.0f is not downscaled: %v", parsed.Width, parsed.Height, err)
+			}
+			parsed = scaled
+		}
+		return parsed, nil
+	}
+	// transcode は ImageTranscoder が設定されていれば画像を変換する
+	transcode := func(img *ParsedImage) *ParsedImage {
+		if p.imageTranscoder == nil {
+			return img
+		}
+		transcoded, err := transcodeImage(img, p.imageTranscoder, p.transcodeQuality)
+		if err != nil {
+			warnings.warn(img.Page, "Image %.0fx%.0f is not transcoded: %v", img.Width, img.Height, err)
+		}
+		return transcoded
+	}
+	// sendImage は 画像をタイルに分割して送る (変換はタイルごとに行う)
+	sendImage := func(parsed *ParsedImage) {
+		tiles, err := tileImage(parsed, p.maxImagePixels)
+		if err != nil {
+			warnings.warn(parsed.Page, "Image %.0fx%.0f is not tiled: %v", parsed.Width, parsed.Height, err)
+		}
+		for _, tile := range tiles {
+			batches.add(parsed.Page, PaintBatchImage, transcode(tile))
+		}
+	}
+
+	imageIDs := &imageIDAllocator{}
+	var images *imageDedupTracker
+	if p.dedupImages {
+		images = newImageDedupTracker(imageIDs)
+	}
+	type fullImage struct {
+		cmd ImageRefCommand
+		id  string
+	}
+	// streamImages は 画像コマンドを順番に送り、送り終えたページの完了を pages に知らせる
+	streamImages := func(cmds []ImageRefCommand) error {
+		// プレビューを送った画像は、全ての画像のプレビューの後に元の解像度で送り直す
+		var fullImages []fullImage
+		queue := newImageQueue(cmds, pageHeights, p.viewBase.Load(), p.prioritizeImages)
+		for queue.Len() > 0 {
+			if err := checkpoint(); err != nil {
+				return err
+			}
+			cmd := queue.next(p.viewBase.Load())
+			if images != nil {
+				if placement, ok := images.placed(cmd); ok {
+					batches.add(cmd.Page, PaintBatchImage, placement)
+					if err := pages.imageSent(cmd.Page); err != nil {
+						return err
+					}
+					continue
+				}
+			}
+			parsed, err := buildImage(cmd)
+			if err != nil {
+				return err
+			}
+			if images != nil {
+				// タイルと切り出した画像は配置ごとに内容が変わるため、重複を除かない
+				tiled := p.maxImagePixels > 0 && int(parsed.Width)*int(parsed.Height) > p.maxImagePixels
+				parsed.ImageID = images.register(cmd, tiled || parsed.Crop != nil)
+			}
+			if p.imagePreviewSize > 0 {
+				preview, err := scaleImage(parsed, p.imagePreviewSize)
+				if err != nil {
+					warnings.warn(cmd.Page, "Image %.0fx%.0f has no preview: %v", parsed.Width, parsed.Height, err)
+				}
+				if preview != parsed {
+					if preview.ImageID == "" {
+						preview.ImageID = imageIDs.allocate()
+					}
+					preview.Preview = true
+					batches.add(cmd.Page, PaintBatchImage, transcode(preview))
+					fullImages = append(fullImages, fullImage{cmd: cmd, id: preview.ImageID})
+					continue
+				}
+			}
+			sendImage(parsed)
+			if err := pages.imageSent(cmd.Page); err != nil {
+				return err
+			}
+		}
+		for _, full := range fullImages {
+			if err := checkpoint(); err != nil {
+				return err
+			}
+			// 元の解像度の画像はメモリに保持せず、もう一度展開する
+			parsed, err := buildImage(full.cmd)
+			if err != nil {
+				return err
+			}
+			parsed.ImageID = full.id
+			sendImage(parsed)
+			if err := pages.imageSent(full.cmd.Page); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, i := range sequence {
+		// クライアントが切断した場合は、残りのページを解析しない
+		if err := checkpoint(); err != nil {
+			return err
+		}
+		pageImages := len(imgCommands)
+		page, err := p.ExtractPage(int(i))
+		if err != nil {
+			return err
+		}
+		pageHeights[int64(i)] = page.PageHeight
+		pageCtx, cancelPage := ctx, context.CancelFunc(func() {})
+		if p.pageTimeout > 0 {
+			pageCtx, cancelPage = context.WithTimeoutCause(ctx, p.pageTimeout, fmt.Errorf("%w: page %d exceeded %s", ErrPageTimeout, i, p.pageTimeout))
+		}
+		tc, ic, pc, err := p.extractPageContents(pageCtx, page.ContentsRef, page.ResourcesRef, page.PageHeight)
+		cancelPage()
+		if errors.Is(err, ErrOperatorBudgetExceeded) {
+			warnings.warn(int64(i), "Page %d is truncated: %v", i, err)
+		} else if err != nil {
+			return err
+		}
+		// 言語・文字体系はページのテキストから判定するため、解析後にページを送る
+		pageTexts := make([]string, 0, len(tc))
+		for _, cmd := range tc {
+			pageTexts = append(pageTexts, cmd.Text...)
+		}
+		script := detectScript(pageTexts)
+		fingerprint := ""
+		if p.pageFingerprints {
+			fingerprint, err = p.pageFingerprint(page)
+			if err != nil {
+				warnings.warn(int64(i), "Failed to compute page fingerprint: %v", err)
+			}
+		}
+		insertData(&ParsedPage{
+			Width:  page.PageWidth,
+			Height: page.PageHeight,
+			Page:   int64(i),
+			Lang:   pageLanguage(c.Lang, script),
+			Script: script,
+			Label:  pageLabel(labels, int64(i)),
+
+			Fingerprint: fingerprint,
+		})
+		if p.thumbnailSize > 0 {
+			p.sendThumbnail(int64(i), ThumbnailPage{
+				Width:  page.PageWidth,
+				Height: page.PageHeight,
+				Texts:  tc,
+				Paths:  pc,
+				Images: ic,
+			}, insertData, warnings)
+		}
+		// 送らない種類の内容はここで除く (サムネイルにはページ全体を描く)
+		if !p.include.Includes(ContentText) {
+			tc = nil
+		}
+		if !p.include.Includes(ContentPaths) {
+			pc = nil
+		}
+		if !p.include.Includes(ContentImages) {
+			ic = nil
+		}
+		if p.coalesceText {
+			tc = coalesceTextCommands(tc)
+		}
+		if p.groupLines {
+			tc = groupTextLines(tc)
+		}
+		pageBox := newRect(0, 0, page.PageWidth, page.PageHeight)
+		if p.pageSummary {
+			insertData(p.summarizePage(int64(i), pageBox, tc, pc, ic))
+		}
+		structTexts, err := p.structTexts(c.StructTreeRoot, page.StructParents)
+		if err != nil {
+			warnings.warn(int64(i), "Failed to read structure tree: %v", err)
+		}
+		firstTextZ := int64(0)
+		for j, cmd := range tc {
+			if j == 0 || cmd.Z < firstTextZ {
+				firstTextZ = cmd.Z
+			}
+		}
+		parsedTexts := make([]*ParsedText, 0, len(tc))
+		for _, cmd := range tc {
+			drop, offPage := p.offPagePolicy.offPage(pageBox, textBounds(cmd))
+			if drop {
+				continue
+			}
+			var texts string
+			var glyphs, clusters []int
+			if p.shapingHints {
+				texts, glyphs, clusters = p.shapeText(cmd)
+			} else {
+				for _, b := range cmd.Text {
+					texts += b
+				}
+				texts = p.textOptions.Apply(texts)
+			}
+			text := &ParsedText{
+				X:           cmd.X,
+				Y:           cmd.Y,
+				Z:           cmd.Z,
+				Text:        texts,
+				Width:       cmd.Width,
+				FontID:      cmd.FontID,
+				FontSize:    cmd.FontSize,
+				Page:        int64(i),
+				Color:       cmd.Color,
+				StrokeAlpha: cmd.StrokeAlpha,
+				FillAlpha:   cmd.FillAlpha,
+				BlendMode:   cmd.BlendMode,
+				ClipPaths:   cmd.ClipPaths,
+				OffPage:     offPage,
+				Glyphs:      glyphs,
+				Clusters:    clusters,
+				Words:       cmd.Words,
+			}
+			if role, ok := roles.lookup(page.Ref, cmd.MCID); ok {
+				text.Struct = role
+			}
+			parsedTexts = append(parsedTexts, text)
+		}
+		// 後処理は前後のテキストを参照するため、ページのテキストをそろえてから適用する
+		p.postProcessText(parsedTexts)
+		for _, text := range parsedTexts {
+			if shared != nil && inRunningBand(text.Y, text.Y, page.PageHeight) {
+				id, seen := shared.share(textContentKey(text))
+				if seen {
+					// 前のページで送った同じヘッダー・フッターを参照する
+					batches.add(int64(i), PaintBatchText, &ParsedSharedContent{SharedID: id, Page: int64(i), Z: text.Z})
+					continue
+				}
+				text.SharedID = id
+			}
+			batches.add(int64(i), PaintBatchText, text)
+			if font, ok := p.fonts.get(text.FontID); ok {
+				if !slices.Contains(pageFonts[int64(i)], text.FontID) {
+					pageFonts[int64(i)] = append(pageFonts[int64(i)], text.FontID)
+				}
+				fontFileList[text.FontID] = font
+			}
+		}
+		for _, cmd := range pc {
+			offPage := false
+			bounds, hasBounds := pathBounds(cmd.Path)
+			if hasBounds {
+				var drop bool
+				drop, offPage = p.offPagePolicy.offPage(pageBox, bounds)
+				if drop {
+					continue
+				}
+			}
+			maskData, maskType := loadSoftMask(cmd.SoftMask)
+			path := &ParsedPath{
+				X:           cmd.X,
+				Y:           cmd.Y,
+				Z:           cmd.Z,
+				Width:       cmd.Width,
+				Height:      cmd.Height,
+				Page:        int64(i),
+				Path:        cmd.Path,
+				PaintMode:   cmd.PaintMode,
+				FillRule:    cmd.FillRule,
+				StrokeColor: cmd.StrokeColor,
+				FillColor:   cmd.FillColor,
+				StrokeAlpha: cmd.StrokeAlpha,
+				FillAlpha:   cmd.FillAlpha,
+				BlendMode:   cmd.BlendMode,
+				MaskData:    maskData,
+				MaskType:    maskType,
+				ClipPaths:   cmd.ClipPaths,
+				OffPage:     offPage,
+			}
+			batch := pathPaintBatch(cmd.Z, firstTextZ, len(tc) > 0)
+			if shared != nil && hasBounds && len(maskData) == 0 && inRunningBand(bounds.Y0, bounds.Y1, page.PageHeight) {
+				id, seen := shared.share(pathContentKey(path))
+				if seen {
+					batches.add(int64(i), batch, &ParsedSharedContent{SharedID: id, Page: int64(i), Z: cmd.Z})
+					continue
+				}
+				path.SharedID = id
+			}
+			batches.add(int64(i), batch, path)
+		}
+		for _, cmd := range ic {
+			drop, offPage := p.offPagePolicy.offPage(pageBox, imageBounds(cmd, page.PageHeight))
+			if drop {
+				continue
+			}
+			// 画像の参照は Form XObject のリソースも含めて実行時に解決済み
+			ir := cmd.ImageRef
+			if ir == 0 {
+				return errors.New(fmt.Sprintf("Image not found: %s", cmd.ImageID))
+			}
+
+			c := ImageRefCommand{
+				X:           cmd.X,
+				Y:           cmd.Y,
+				Z:           cmd.Z,
+				DW:          cmd.DW,
+				DH:          cmd.DH,
+				Matrix:      cmd.Matrix,
+				ImageRef:    ir,
+				Page:        int64(i),
+				ClipPath:    cmd.ClipPath,
+				StrokeAlpha: cmd.StrokeAlpha,
+				FillAlpha:   cmd.FillAlpha,
+				BlendMode:   cmd.BlendMode,
+				SoftMask:    cmd.SoftMask,
+				FillColor:   cmd.FillColor,
+				ClipPaths:   cmd.ClipPaths,
+				OffPage:     offPage,
+				Alt:         cmd.Alt,
+				ActualText:  cmd.ActualText,
+			}
+			// マークコンテンツに代替テキストがなければ対応する構造要素から取る
+			if text, ok := structTexts[cmd.MCID]; ok {
+				if c.Alt == "" {
+					c.Alt = text.Alt
+				}
+				if c.ActualText == "" {
+					c.ActualText = text.ActualText
+				}
+			}
+
+			imgCommands = append(imgCommands, c)
+			pages.imageQueued(c.Page)
+		}
+		// 画像はページの最後か全ページの解析後に送るため、ここではテキストとパスの段階を送る
+		batches.flush()
+		if p.fontsFirst {
+			// テキストを画像より先に描画できるよう、ページで使うフォントをすぐに送る
+			if err := sendFonts(pageFonts[int64(i)]); err != nil {
+				return err
+			}
+		}
+		// 注釈はページの内容より前面に表示されるため、内容の後に送る
+		if p.include.Includes(ContentAnnotations) {
+			annotations, err := p.extractAnnotations(c, page.Annots, int64(i), page.PageHeight)
+			if err != nil {
+				warnings.warn(int64(i), "Failed to extract annotations: %v", err)
+			}
+			for _, annotation := range annotations {
+				insertData(annotation)
+			}
+		}
+		// 画像のないページはここで完了する
+		if err := pages.pageParsed(int64(i)); err != nil {
+			return err
+		}
+		if p.chunkOrder == ChunkOrderInterleave {
+			// 次のページより先に、このページの画像を送ってページを完了する
+			cmds := imgCommands[pageImages:]
+			imgCommands = imgCommands[:pageImages]
+			if err := streamImages(cmds); err != nil {
+				return err
+			}
+		}
+		progress.page()
+	}
+
+	if p.chunkOrder != ChunkOrderInterleave {
+		// 画像は全ページの解析後に送るため、画像のあるページは画像を送り終えた時点で完了する
+		if err := streamImages(imgCommands); err != nil {
+			return err
+		}
+	}
+	batches.flush()
+	if err := checkpoint(); err != nil {
+		return err
+	}
+
+	if p.attachments {
+		attachments, err := p.ListAttachments()
+		if err != nil {
+			return err
+		}
+		for _, attachment := range attachments {
+			if err := checkpoint(); err != nil {
+				return err
+			}
+			data, err := p.attachmentData(attachment)
+			if err != nil {
+				warnings.warn(0, "Failed to extract attachment: %v", err)
+				continue
+			}
+			insertData(&ParsedAttachment{
+				Name:        attachment.Name,
+				Description: attachment.Description,
+				MimeType:    attachment.MimeType,
+				Page:        attachment.Page,
+				Data:        data,
+			})
+		}
+	}
+	warnings.flush()
+	if err := limits.err(); err != nil {
+		return err
+	}
+	summary.done()
+	return nil
+}
+
+func (p *PDFParser) GetMediaBox(page PDFObject) ([]int, error) {
+	return p.mediaBox(page, 0)
+}
+
+// maxPageTreeDepth は MediaBox を親のページツリーからたどる深さの上限 (Parent が循環した壊れたファイルのため)
+const maxPageTreeDepth = 64
+
+func (p *PDFParser) mediaBox(page PDFObject, depth int) ([]int, error) {
+	mediaBox, found := findTarget(page, "MediaBox")
+	if found {
+		mediaBoxArray, ok := mediaBox.([]PDFObject)
+		if !ok {
+			return nil, errors.New("MediaBox is not array")
+		}
+		intMediaBox := make([]int, 0)
+		for _, v := range mediaBoxArray {
+			intV, ok := v.(int)
+			if !ok {
+				return nil, errors.New("MediaBox is not int")
+			}
+			intMediaBox = append(intMediaBox, intV)
+		}
+		if len(intMediaBox) != 4 {
+			return nil, fmt.Errorf("MediaBox has %d values", len(intMediaBox))
+		}
+		return intMediaBox, nil
+	} else {
+		parentRef, found := findTargetRef(page, "Parent")
+		if !found {
+			return nil, errors.New("mediaBox not found")
+		}
+		parent, err := p.ParseObject(parentRef)
+		if err != nil {
+			return nil, err
+		}
+		if depth >= maxPageTreeDepth {
+			return nil, errors.New("mediaBox not found: page tree is too deep")
+		}
+		return p.mediaBox(parent, depth+1)
+	}
+}
+
+func generateSequence(start, end, base int64) ([]int64, error) {
+	if start > end {
+		return nil, errors.New("start must be less than or equal to end")
+	}
+	if base < start || base > end {
+		return nil, errors.New("base must be within the range [start, end]")
+	}
+
+	size := end - start + 1
+	slice := make([]int64, size)
+	for i := int64(0); i < size; i++ {
+		slice[i] = start + i
+	}
+	sortByDistance(slice, base)
+
+	return slice, nil
+}
+
+// sortByDistance は 昇順に並んだページを base に近い順に並べ替える (同じ距離の場合は前のページから)
+func sortByDistance(pages []int64, base int64) {
+	abs := func(x int64) int64 {
+		if x < 0 {
+			return -x
+		}
+		return x
+	}
+
+	sort.SliceStable(pages, func(i, j int) bool {
+		return abs(pages[i]-base) < abs(pages[j]-base)
+	})
+}
+
+// pageSequence は 送るページを送る順に並べ、範囲に合わせた基準ページと合わせて返す
+// Pages を指定した場合は start・end の代わりに使う
+func (p *PDFParser) pageSequence(start, end, base int64) ([]int64, int64, error) {
+	pageLen := int64(len(p.pageQueue))
+	if len(p.pages) > 0 {
+		base = min(max(base, 1), pageLen)
+		sequence, err := p.pages.sequence(base, pageLen)
+		return sequence, base, err
+	}
+	start, end, base = normalizePageNum(start, end, base, pageLen)
+	sequence, err := generateSequence(start, end, base)
+	return sequence, base, err
+}
+
+func normalizePageNum(start, end, base, pageLen int64) (int64, int64, int64) {
+	if pageLen < 1 {
+		pageLen = 1
+	}
+
+	if start < 1 {
+		start = 1
+	}
+
+	if start > pageLen {
+		start = pageLen
+	}
+
+	if base < start {
+		base = start
+	}
+
+	if base > pageLen {
+		base = pageLen
+	}
+
+	if end < base {
+		end = base
+	}
+
+	if end > pageLen {
+		end = pageLen
+	}
+
+	return start, end, base
+}
+func (p *PDFParser) GetCatalog() (*Catalog, error) {
+	root, err := p.ParseObject(p.root)
+	if err != nil {
+		return nil, err
+	}
+	pagesRef, found := findTargetRef(root, "Pages")
+	if !found {
+		return nil, errors.New("Pages not found")
+	}
+	catalog := &Catalog{PagesRef: pagesRef}
+	if dict, ok := root.(map[string]PDFObject); ok {
+		catalog.Lang, _ = dict["Lang"].(string)
+	}
+	catalog.StructTreeRoot, _ = findTargetRef(root, "StructTreeRoot")
+	if dict, ok := root.(map[string]PDFObject); ok {
+		catalog.Dests = dict["Dests"]
+		catalog.PageLabels = dict["PageLabels"]
+		catalog.AcroForm = dict["AcroForm"]
+		catalog.OutputIntents = dict["OutputIntents"]
+		if names, err := p.resolveObject(dict["Names"]); err == nil {
+			catalog.DestNames, _ = findTarget(names, "Dests")
+			catalog.EmbeddedFiles, _ = findTarget(names, "EmbeddedFiles")
+		}
+	}
+	return catalog, nil
+}
+
+func (p *PDFParser) loadPageObject(catalogRef Catalog) error {
+	if len(p.pageQueue) > 0 {
+		// 読み込み済み・DocumentCache から取り出したページツリーを使う
+		return nil
+	}
+	if err := p.loadPageTree(catalogRef); err != nil {
+		p.pageQueue = nil
+		return err
+	}
+	p.cacheDocument()
+	return nil
+}
+
+// cacheDocument は 読み込んだ文書の構造と識別子を DocumentCache に保持する
+func (p *PDFParser) cacheDocument() {
+	p.documents.put(p.documentKey, &preparedDocument{xrefTable: p.xrefTable, root: p.root, id: p.documentID, info: p.info, pageQueue: p.pageQueue})
+}
+
+func (p *PDFParser) loadPageTree(catalogRef Catalog) error {
+	pages, err := p.ParseObject(catalogRef.PagesRef)
+	if err != nil {
+		return err
+	}
+	kids, found := findTargetRefs(pages, "Kids")
+	if !found {
+		return errors.New("kids not found ")
+	}
+	// 壊れたファイルではページツリーが循環していることがあるため、たどったノードを記録する
+	visited := map[PDFRef]bool{catalogRef.PagesRef: true}
+	for _, kid := range kids {
+		err = p.loadPerPageObject(kid, visited)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+
+}
+
+func (p *PDFParser) loadPerPageObject(ptRef PDFRef, visited map[PDFRef]bool) error {
+	if visited[ptRef] {
+		return fmt.Errorf("page tree has a cycle at object %d", ptRef)
+	}
+	visited[ptRef] = true
+	pt, err := p.ParseObject(ptRef)
+	if err != nil {
+		return err
+	}
+	t, found := findTarget(pt, "Type")
+	if !found {
+		return errors.New("Type not found")
+	}
+	if t == "Pages" {
+		kids, found := findTargetRefs(pt, "Kids")
+		if !found {
+			return errors.New("Kids not found")
+		}
+
+		for _, kid := range kids {
+			err := p.loadPerPageObject(kid, visited)
+			if err != nil {
+				return err
+			}
+		}
+	} else if t == "Page" {
+		contentsRef, found := findTargetRef(pt, "Contents")
+		if !found {
+			return errors.New("Contents not found")
+		}
+
+		resourcesRef, found := findTargetRef(pt, "Resources")
+		if !found {
+			return errors.New("Resources not found")
+		}
+
+		intMediaBox, err := p.GetMediaBox(pt)
+		if err != nil {
+			return err
+		}
+
+		pageWidth := intMediaBox[2] - intMediaBox[0]
+		pageHeight := intMediaBox[3] - intMediaBox[1]
+		structParents := -1
+		var annots PDFObject
+		if dict, ok := pt.(map[string]PDFObject); ok {
+			if v, ok := dict["StructParents"].(int); ok {
+				structParents = v
+			}
+			annots = dict["Annots"]
+		}
+		p.pageQueue = append(p.pageQueue, Page{contentsRef, resourcesRef, float64(pageWidth), float64(pageHeight), structParents, annots, ptRef})
+	} else {
+		return errors.New(fmt.Sprintf("Type is not Pages or Page: %s", t))
+	}
+	return nil
+}
+
+// sendFont は 埋め込みフォントを検証・修正して送る
+// 送れないフォントは代替フォントを指示する
+func (p *PDFParser) sendFont(key string, font Font, insertData func(data ParsedData), warnings *warningLimiter) error {
+	if font.FontDataRef == 0 {
+		// 埋め込みフォントファイルがない
+		return nil
+	}
+	fontStream, err := p.ExtractFontStream(font.FontDataRef)
+	if err != nil {
+		return err
+	}
+	// 壊れたフォントはクライアントのフォントスタックを落とすことがあるため、代替フォントを指示する
+	mismatched, err := validateFont(fontStream)
+	if len(mismatched) > 0 {
+		warnings.warn(0, "Font %s has checksum mismatches in %v", key, mismatched)
+	}
+	sendable := err == nil
+	if err != nil {
+		warnings.warn(0, "Font %s is not sent: %v", key, err)
+	} else {
+		fontStream, sendable = applyFsTypePolicy(p.logger, p.fsTypePolicy, key, fontStream)
+	}
+	style, metrics := font.style, font.metrics
+	deliveredKey := ""
+	if p.deliveredFonts != nil {
+		deliveredKey = deliveredFontKey(key, font)
+	}
+	if !sendable {
+		insertData(&ParsedFont{
+			FontID:     key,
+			Substitute: substituteFontFamily(font.flags),
+			Style:      style,
+			Metrics:    metrics,
+
+			deliveredKey: deliveredKey,
+		})
+		return nil
+	}
+	fontStream = applyFontFix(p.logger, p.fontFix, key, fontStream)
+	applySfntStyle(&style, fontStream)
+	applySfntMetrics(&metrics, fontStream)
+	insertData(&ParsedFont{
+		FontID:  key,
+		Data:    []byte(fontStream),
+		Style:   style,
+		Metrics: metrics,
+
+		deliveredKey: deliveredKey,
+	})
+	return nil
+}
+
+// SetViewBase は クライアントが表示しているページを知らせる
+// PrioritizeImages が有効な場合、まだ送っていない画像をこのページに近い順に送り直す
+// StreamPageContents の実行中に別の goroutine から呼べる
+func (p *PDFParser) SetViewBase(page int64) {
+	p.viewBase.Store(page)
+}
+
+// Close は 解析済みのオブジェクトのキャッシュを捨て、ファイルを閉じる
+// 送信中に固定したフォントは StreamPageContents の終わりで固定を外しているため、ここでは扱わない
+func (p *PDFParser) Close() error {
+	// 解析済みのオブジェクトを捨てる
+	clear(p.objects)
+	// ファイルを閉じる
+	if err := p.file.Close(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (p *PDFParser) ExtractPage(pageNum int) (*Page, error) {
+	if len(p.pageQueue) == 0 {
+		return nil, errors.New("no page")
+	}
+	if pageNum < 1 || len(p.pageQueue) < pageNum {
+		return nil, errors.New("index out of range page")
+	}
+	page := p.pageQueue[pageNum-1]
+	return &page, nil
+}
+func (p *PDFParser) ExtractPageContents(contentsRef, resourcesRef PDFRef, pageHeight float64) ([]TextCommand, []ImageCommand, []PathCommand, error) {
+	return p.extractPageContents(context.Background(), contentsRef, resourcesRef, pageHeight)
+}
+
+// extractPageContents は ExtractPageContents と同じだが、ctx が取り消された場合は演算子の実行を中止してその理由を返す
+func (p *PDFParser) extractPageContents(ctx context.Context, contentsRef, resourcesRef PDFRef, pageHeight float64) ([]TextCommand, []ImageCommand, []PathCommand, error) {
+	budget := p.operatorBudget.start(ctx)
+	contents, err := p.ParseStreamObject(contentsRef)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	contentsStream, err := contents.Decoded()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	resources, err := p.ParseObject(resourcesRef)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	pageResources, err := p.loadResources(resources)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	to := NewTokenObject(string(contentsStream), pageResources, p.loadFormXObject)
+	to.logger = p.logger
+	to.budget = budget
+	tc, ic, pc := to.ExtractCommands(pageHeight)
+	// 上限を超えた場合はそこまでのコマンドとエラーを返す
+	return tc, ic, pc, budget.err()
+}
+
+func (p *PDFParser) ExtractFont(resourceRef PDFRef) error {
+	resources, err := p.ParseObject(resourceRef)
+	if err != nil {
+		return err
+	}
+	_, err = p.extractFonts(resources)
+	return err
+}
+
+// extractFonts は リソース辞書(インライン可)の /Font を読み込み、名前ごとのフォントを返す
+func (p *PDFParser) extractFonts(resources PDFObject) (map[string]Font, error) {
+	loaded := make(map[string]Font)
+	fonts, found := findTarget(resources, "Font")
+	if !found {
+		return loaded, nil
+	}
+	fonts, err := p.resolveObject(fonts)
+	if err != nil {
+		return nil, err
+	}
+	fontsMap, ok := fonts.(map[string]PDFObject)
+	if !ok {
+		return nil, errors.New("Font is not map")
+	}
+	for key, value := range fontsMap {
+		fontRef, ok := parseRef(value.(string))
+		if !ok {
+			return nil, errors.New("Font format error")
+		}
+		font, err := p.ParseObject(fontRef)
+		if err != nil {
+			return nil, err
+		}
+		subType, found := findTarget(font, "Subtype")
+		if !found {
+			return nil, errors.New("Subtype not found")
+		}
+
+		if subType == "TrueType" {
+			baseFont := ""
+			if name, found := findTarget(font, "BaseFont"); found {
+				baseFont, _ = name.(string)
+			}
+			toUnicodeRef, found := findTargetRef(font, "ToUnicode")
+			if !found {
+				return nil, errors.New("ToUnicode not found")
+			}
+			toUnicode, err := p.ParseStreamObject(toUnicodeRef)
+			if err != nil {
+				return nil, err
+			}
+			toUnicodeStream, err := toUnicode.Decoded()
+			if err != nil {
+				return nil, err
+			}
+			firstChar, found := findTarget(font, "FirstChar")
+			if !found {
+				return nil, errors.New("FirstChar not found")
+			}
+			firstCharInt, ok := firstChar.(int)
+			if !ok {
+				return nil, errors.New("FirstChar is not int")
+			}
+			cmaps, err := p.ExtractCMaps(string(toUnicodeStream), int8(firstCharInt))
+			if err != nil {
+				return nil, err
+			}
+			fontFileRef := PDFRef(0)
+			flags := 0
+			var descriptor PDFObject
+			FontDescriptorRef, found := findTargetRef(font, "FontDescriptor")
+			if found {
+				FontDescriptor, err := p.ParseObject(FontDescriptorRef)
+				if err != nil {
+					return nil, err
+				}
+				descriptor = FontDescriptor
+				if f, found := findTarget(FontDescriptor, "Flags"); found {
+					flags, _ = f.(int)
+				}
+				fontFileRef, found = findTargetRef(FontDescriptor, "FontFile2")
+				if !found {
+					return nil, errors.New("FontFile not found")
+				}
+			}
+			loaded[key] = Font{
+				FontID:      key,
+				FontDataRef: fontFileRef,
+				fontMap:     cmaps,
+				widths:      p.readFontWidths(font, firstCharInt),
+				flags:       flags,
+				style:       fontStyleFromDescriptor(descriptor, baseFont, flags),
+				metrics:     fontMetricsFromDescriptor(descriptor),
+			}
+			p.fonts.put(key, loaded[key])
+		} else if subType == "Type0" {
+			// descendantFontRefs, found := findTargetRefs(font, "DescendantFonts")
+			// if !found {
+			// 	return nil, errors.New("DescendantFonts not found")
+			// }
+
+		}
+	}
+	return loaded, nil
+}
+
+// readFontWidths は フォント辞書の /Widths を文字コードごとのグリフ幅として読み込む
+func (p *PDFParser) readFontWidths(font PDFObject, firstChar int) map[byte]float64 {
+	dict, ok := font.(map[string]PDFObject)
+	if !ok {
+		return nil
+	}
+	obj, err := p.resolveObject(dict["Widths"])
+	if err != nil {
+		return nil
+	}
+	widthsArray, ok := obj.([]PDFObject)
+	if !ok {
+		return nil
+	}
+	widths := make(map[byte]float64, len(widthsArray))
+	for i, w := range widthsArray {
+		code := firstChar + i
+		if code < 0 || code > 255 {
+			continue
+		}
+		if f, ok := toFloat(w); ok {
+			widths[byte(code)] = f
+		}
+	}
+	return widths
+}
+
+func (p *PDFParser) ExtractImageRefs(resourceRef PDFRef) (map[string]PDFRef, error) {
+	resources, err := p.ParseObject(resourceRef)
+	if err != nil {
+		return nil, err
+	}
+	return p.imageRefsFromResources(resources)
+}
+
+// imageRefsFromResources は リソース辞書(インライン可)の /XObject から名前と参照の対応を取り出す
+func (p *PDFParser) imageRefsFromResources(resources PDFObject) (map[string]PDFRef, error) {
+	images := make(map[string]PDFRef, 0)
+	XObjects, found := findTarget(resources, "XObject")
+	if !found {
+		return nil, nil
+	}
+	imagesMap, ok := XObjects.(map[string]PDFObject)
+
+	if !ok {
+		return nil, errors.New("XObject is not map")
+	}
+
+	for key, value := range imagesMap {
+		imageRef, ok := parseRef(value.(string))
+		if !ok {
+			return nil, errors.New("Image format error")
+		}
+		images[key] = imageRef
+	}
+	return images, nil
+}
+
+// resolveObject は 参照文字列であれば参照先オブジェクトを読み込んで返す
+func (p *PDFParser) resolveObject(obj PDFObject) (PDFObject, error) {
+	if refString, ok := obj.(string); ok {
+		if ref, ok := parseRef(refString); ok {
+			return p.ParseObject(ref)
+		}
+	}
+	return obj, nil
+}
+
+// ExtractExtGStates は リソースの /ExtGState から名前ごとのグラフィックス状態パラメータを読み込む
+func (p *PDFParser) ExtractExtGStates(resourceRef PDFRef) (map[string]ExtGState, error) {
+	resources, err := p.ParseObject(resourceRef)
+	if err != nil {
+		return nil, err
+	}
+	return p.extGStatesFromResources(resources)
+}
+
+// extGStatesFromResources は リソース辞書(インライン可)の /ExtGState を読み込む
+func (p *PDFParser) extGStatesFromResources(resources PDFObject) (map[string]ExtGState, error) {
+	extGStates := make(map[string]ExtGState)
+	gsObj, found := findTarget(resources, "ExtGState")
+	if !found {
+		return extGStates, nil
+	}
+	gsObj, err := p.resolveObject(gsObj)
+	if err != nil {
+		return nil, err
+	}
+	gsMap, ok := gsObj.(map[string]PDFObject)
+	if !ok {
+		return nil, errors.New("ExtGState is not map")
+	}
+	for key, value := range gsMap {
+		value, err := p.resolveObject(value)
+		if err != nil {
+			return nil, err
+		}
+		dict, ok := value.(map[string]PDFObject)
+		if !ok {
+			return nil, errors.New("ExtGState format error")
+		}
+		extGState := parseExtGState(dict)
+		if smask, found := dict["SMask"]; found {
+			softMask, err := p.parseSoftMask(smask)
+			if err != nil {
+				return nil, err
+			}
+			extGState.SoftMask = softMask
+		}
+		extGStates[key] = extGState
+	}
+	return extGStates, nil
+}
+
+func parseExtGState(dict map[string]PDFObject) ExtGState {
+	gs := ExtGState{}
+	if ca, ok := toFloat(dict["CA"]); ok {
+		gs.StrokeAlpha = &ca
+	}
+	if ca, ok := toFloat(dict["ca"]); ok {
+		gs.FillAlpha = &ca
+	}
+	switch bm := dict["BM"].(type) {
+	case string:
+		gs.BlendMode = bm
+	case []PDFObject:
+		// 配列の場合は先頭の対応可能なブレンドモードを使う
+		if len(bm) > 0 {
+			if name, ok := bm[0].(string); ok {
+				gs.BlendMode = name
+			}
+		}
+	}
+	return gs
+}
+
+func (p *PDFParser) ExtractImageStream(imageRef PDFRef) (*ExtractedImage, error) {
+	image, err := p.ParseStreamObject(imageRef)
+	if err != nil {
+		return nil, err
+	}
+	imageStream, err := image.Raw()
+	if err != nil {
+		return nil, err
+	}
+	format, err := p.readImageFormat(image.Dict)
+	if err != nil {
+		return nil, err
+	}
+	imageFilter := image.Filter()
+	if codec, ok := findImageCodec(imageFilter, format.ColorSpace); ok && !format.ImageMask {
+		// 登録されたコーデックで展開し、FlateDecode 画像として送る
+		imageStream, format, err = decodeWithCodec(codec, imageStream, format, image.Dict["DecodeParms"])
+		if err != nil {
+			return nil, err
+		}
+		imageFilter = format.Filter
+	}
+	if imageFilter == "" && !format.ImageMask {
+		return nil, errors.New("image Filter not found")
+	}
+	imageStream, err = normalizeImageStream(imageStream, format)
+	if err != nil {
+		if len(imageStream) == 0 {
+			return nil, err
+		}
+		p.logger.Warn("Image stream is truncated", "ref", imageRef, "err", err)
+	}
+
+	smask, found := image.Dict["SMask"]
+	smaskStream := make([]byte, 0)
+	if found {
+
+		smaskRef, ok := parseRef(smask.(string))
+		if !ok {
+			return nil, errors.New("SMask format error")
+		}
+
+		smaskObject, err := p.ParseStreamObject(smaskRef)
+		if err != nil {
+			return nil, err
+		}
+		smaskStream, err = smaskObject.Raw()
+		if err != nil {
+			return nil, err
+		}
+		smaskFormat, err := p.readImageFormat(smaskObject.Dict)
+		if err != nil {
+			return nil, err
+		}
+		smaskStream, err = normalizeImageStream(smaskStream, smaskFormat)
+		if err != nil {
+			if len(smaskStream) == 0 {
+				return nil, err
+			}
+			p.logger.Warn("Soft mask stream is truncated", "ref", smaskRef, "err", err)
+		}
+	}
+	var Ext string
+
+	if format.ImageMask && format.BitsPerComponent == 8 {
+		// 8bitの不透明度に展開済みのステンシルマスク
+		Ext = "mask"
+	} else if imageFilter == "DCTDecode" {
+		Ext = "jpg"
+	} else {
+		Ext = "png"
+	}
+	var metadata *ImageMetadata
+	if p.imageMetadata {
+		metadata = p.readImageMetadata(image.Dict, imageStream, Ext)
+	}
+	return &ExtractedImage{
+		Data:             (imageStream),
+		MaskData:         (smaskStream),
+		Width:            float64(format.Width),
+		Height:           float64(format.Height),
+		Ext:              Ext,
+		BitsPerComponent: format.BitsPerComponent,
+		ColorSpace:       format.ColorSpace,
+		ImageMask:        format.ImageMask,
+		Metadata:         metadata,
+		ICCProfile:       format.ICCProfile,
+	}, nil
+
+}
+func (p *PDFParser) ExtractCMaps(cmapsString string, firstCharNumber int8) (map[byte]string, error) {
+	re := regexp.MustCompile(`(?s)\d+\s+beginbfrange\s+(.*?)\s+endbfrange`)
+
+	matches := re.FindAllStringSubmatch(cmapsString, -1)
+
+	var substrings string
+
+	for _, match := range matches {
+		substrings = substrings + "\n" + match[1]
+	}
+	values := make(map[byte]string)
+	cmaps := strings.Split(substrings, "\n")
+	cnt := int8(0)
+	for _, cmap := range cmaps {
+		if cmap == "" {
+			continue
+		}
+		split := strings.Split(strings.Trim(strings.Trim(cmap, "<"), ">"), "><")
+
+		startIndex, err := strconv.ParseInt(split[0], 16, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		endIndex, err := strconv.ParseInt(split[1], 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		value, err := strconv.ParseInt(split[2], 16, 64)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for i := 0; i <= int(endIndex-startIndex); i++ {
+			values[uint8(firstCharNumber+cnt)] = string(rune(int(value) + int(i)))
+			cnt += 1
+		}
+	}
+	return values, nil
+
+}
+
+func (p *PDFParser) ExtractFontStream(fontRef PDFRef) ([]byte, error) {
+	font, err := p.ParseStreamObject(fontRef)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to parse font object: %w", err)
+	}
+	fontStream, err := font.Decoded()
+	if err != nil {
+		return nil, err
+	}
+	if font.Filter() == "" {
+		return fontStream, nil
+	}
+	fontLength1, found := font.Dict["Length1"]
+	if found {
+		// 間接参照の /Length1 もある
+		fontLength1, err = p.resolveObject(fontLength1)
+		fontLength1Int, ok := fontLength1.(int)
+		if err != nil || !ok {
+			p.logger.Warn("Font /Length1 is not an integer", "ref", fontRef, "err", err)
+		} else if fontLength1Int <= len(fontStream) {
+			fontStream = fontStream[:fontLength1Int]
+		}
+	}
+	return fontStream, nil
+}
+
+func (p *PDFParser) ExtractStreamByRef(ref PDFRef) ([]byte, error) {
+	so, err := p.ParseStreamObject(ref)
+	if err != nil {
+		return nil, err
+	}
+	return so.Raw()
+}
+
+// streamLength は ストリーム辞書の /Length を(間接参照も解決して)返す
+func (p *PDFParser) streamLength(object PDFObject) (int, bool) {
+	length, found := findTarget(object, "Length")
+	if !found {
+		return 0, false
+	}
+	length, err := p.resolveObject(length)
+	if err != nil {
+		return 0, false
+	}
+	lengthInt, ok := length.(int)
+	if !ok || lengthInt < 0 {
+		return 0, false
+	}
+	return lengthInt, true
+}
+
+// deCompressStream は FlateDecode のデータを展開する
+// 途中で壊れているデータは、展開できた部分とエラーの両方を返す
+func deCompressStream(buffer []byte) ([]byte, error) {
+	fr, err := zlib.NewReader(bytes.NewReader(buffer))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrParserDeCompressionError, err)
+	}
+	defer fr.Close()
+
+	var decompressedData bytes.Buffer
+	if _, err := io.Copy(&decompressedData, fr); err != nil {
+		return decompressedData.Bytes(), fmt.Errorf("%w: %w", ErrParserDeCompressionError, err)
+	}
+	return decompressedData.Bytes(), nil
+}
+
+func parseXrefTable(file IPDFFile) (map[PDFRef]XRefTableElement, *string, error) {
+	xrefTableOffsetByte := getXrefTableOffsetByte(file)
+	if xrefTableOffsetByte == nil {
+		return nil, nil, errors.New("xref table offset not found")
+	}
+	file.Seek(int64(*xrefTableOffsetByte), io.SeekStart)
+
+	scanner := bufio.NewScanner(file)
+	scanner.Scan()
+	line := scanner.Text()
+	if line != "xref" {
+		return nil, nil, errors.New("xref table not found")
+	}
+	// xrefセクションは "開始番号 個数" のヘッダを持つサブセクションを複数含むことがある
+	xrefTable := make(map[PDFRef]XRefTableElement)
+	trailerFound := false
+	rootObject := ""
+	for !trailerFound && scanner.Scan() {
+		line = strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "trailer") {
+			trailerFound = true
+			rootObject = strings.TrimPrefix(line, "trailer")
+			break
+		}
+		lns := strings.Fields(line)
+		if len(lns) != 2 {
+			return nil, nil, errors.New("xref table format error")
+		}
+		firstNum, err := strconv.Atoi(lns[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		lnNum, err := strconv.Atoi(lns[1])
+		if err != nil {
+			return nil, nil, err
+		}
+		for i := 0; i < lnNum; i++ {
+			if !scanner.Scan() {
+				return nil, nil, errors.New("xref table is truncated")
+			}
+			line = scanner.Text()
+			if strings.HasPrefix(line, "trailer") {
+				trailerFound = true
+				rootObject = strings.TrimPrefix(line, "trailer")
+				break
+			}
+			lns = strings.Fields(line)
+			if len(lns) != 3 {
+				return nil, nil, errors.New("xref table line format error")
+			}
+
+			genNum, err := strconv.Atoi(lns[1])
+			if err != nil {
+				return nil, nil, err
+			}
+			offsetByte, err := strconv.Atoi(lns[0])
+			if err != nil {
+				return nil, nil, err
+			}
+			objNum := PDFRef(firstNum + i)
+			xrefTable[objNum] = XRefTableElement{objNum, PDFRef(genNum), int64(offsetByte)}
+		}
+	}
+
+	for !strings.Contains(rootObject, ">>") && scanner.Scan() {
+		line = scanner.Text()
+		if strings.Contains(line, "trailer") {
+			continue
+		}
+		rootObject += line
+		if strings.Contains(line, ">>") {
+			break
+		}
+	}
+
+	return xrefTable, &rootObject, nil
+
+}
+
+func getXrefTableOffsetByte(file IPDFFile) *int {
+	file.Seek(-100, io.SeekEnd)
+	scanner := bufio.NewScanner(file)
+	nextIsXRef := false
+	b := int(0)
+	includeEOF := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if nextIsXRef {
+			intBytes, err := strconv.Atoi(strings.TrimSpace(line))
+			if err != nil || intBytes < 0 {
+				// 壊れたファイルでは startxref の後に数値がないことがある
+				return nil
+			}
+			b = intBytes
+			nextIsXRef = false
+		}
+		if line == "startxref" {
+			nextIsXRef = true
+
+		}
+		if line == "%%EOF" {
+			includeEOF = true
+		}
+	}
+	if includeEOF {
+		return &b
+	}
+	return nil
+}
+
+// FontCacheStats は 読み込んだフォントのキャッシュの利用状況を返す
+func (p *PDFParser) FontCacheStats() CacheStats {
+	return p.fonts.snapshot()
+}
+
+// GlyphMapCacheStats は グリフIDの対応のキャッシュの利用状況を返す
+func (p *PDFParser) GlyphMapCacheStats() CacheStats {
+	return p.glyphMaps.snapshot()
+}
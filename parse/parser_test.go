@@ -0,0 +1,52 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"strings"
+	"testing"
+)
+
+// TestParseXrefSubsections は 開始番号が0以外のサブセクションを複数持つ相互参照表から、各オブジェクトの位置を読み込むことを確かめる
+func TestParseXrefSubsections(t *testing.T) {
+	body := "%PDF-1.7\n% objects are not read by parseXrefTable\n"
+	xref := "xref\n" +
+		"0 1\n" +
+		"0000000000 65535 f \n" +
+		"3 2\n" +
+		"0000000017 00000 n \n" +
+		"0000000081 00000 n \n" +
+		"10 1\n" +
+		"0000000150 00002 n\r\n" +
+		"20 3\n" +
+		"0000000200 00000 n \n" +
+		"0000000250 00000 n \n" +
+		"0000000300 00001 n \n" +
+		"trailer\n" +
+		"<< /Size 23 /Root 3 0 R >>\n"
+	data := fmt.Sprintf("%s%sstartxref\n%d\n%%%%EOF\n", body, xref, len(body))
+
+	xrefTable, trailer, err := parseXrefTable(nopFile{bytes.NewReader([]byte(data))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[PDFRef]XRefTableElement{
+		0:  {ObjNum: 0, GenNum: 65535, offsetByte: 0},
+		3:  {ObjNum: 3, GenNum: 0, offsetByte: 17},
+		4:  {ObjNum: 4, GenNum: 0, offsetByte: 81},
+		10: {ObjNum: 10, GenNum: 2, offsetByte: 150},
+		20: {ObjNum: 20, GenNum: 0, offsetByte: 200},
+		21: {ObjNum: 21, GenNum: 0, offsetByte: 250},
+		22: {ObjNum: 22, GenNum: 1, offsetByte: 300},
+	}
+	if !maps.Equal(xrefTable, want) {
+		t.Errorf("xref table = %v, want %v", xrefTable, want)
+	}
+	if trailer == nil {
+		t.Fatal("trailer not found")
+	}
+	if !strings.Contains(*trailer, "/Root 3 0 R") {
+		t.Errorf("trailer = %q, want it to contain /Root 3 0 R", *trailer)
+	}
+}
@@ -0,0 +1,150 @@
+package parse
+
+import (
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TextRun は 後処理に渡すテキストの1区切り (1つのテキストチャンクになる)
+// Text はクライアントが描画に使うため変えず、検索・抽出用のテキストを ActualText として付ける
+type TextRun struct {
+	Text     string
+	X        float64
+	Y        float64 // ベースラインの位置 (ページ上端からの距離)
+	Width    float64
+	FontID   string
+	FontSize float64
+	Page     int64
+
+	actualText *string
+}
+
+// ExtractedText は 検索・抽出用のテキスト (置き換えがなければ Text) を返す
+func (r *TextRun) ExtractedText() string {
+	if r.actualText != nil {
+		return *r.actualText
+	}
+	return r.Text
+}
+
+// SetActualText は 検索・抽出用のテキストを置き換える (空文字の場合は抽出時に何も出力しない)
+func (r *TextRun) SetActualText(text string) {
+	r.actualText = &text
+}
+
+// TextPostProcessor は 送信前のページのテキストを加工する
+// runs はページ内の描画順に並んでいる
+type TextPostProcessor interface {
+	ProcessText(runs []*TextRun)
+}
+
+// TextPostProcessorFunc は 関数を TextPostProcessor として使うための型
+type TextPostProcessorFunc func(runs []*TextRun)
+
+func (f TextPostProcessorFunc) ProcessText(runs []*TextRun) {
+	f(runs)
+}
+
+// ligatures は 合字の表示形 (Alphabetic Presentation Forms) と展開後の文字列
+var ligatures = strings.NewReplacer(
+	"ﬀ", "ff",
+	"ﬁ", "fi",
+	"ﬂ", "fl",
+	"ﬃ", "ffi",
+	"ﬄ", "ffl",
+	"ﬅ", "st",
+	"ﬆ", "st",
+)
+
+// LigatureExpander は ToUnicode が合字の表示形に対応付けたテキストを元の文字の並びに展開する
+type LigatureExpander struct{}
+
+func (LigatureExpander) ProcessText(runs []*TextRun) {
+	for _, run := range runs {
+		text := run.ExtractedText()
+		if expanded := ligatures.Replace(text); expanded != text {
+			run.SetActualText(expanded)
+		}
+	}
+}
+
+// HyphenationJoiner は 行末のハイフンで分割された単語を前の行の末尾でつなげる
+// 次の行の先頭が小文字で始まる場合のみ、ハイフンを除いて続きの部分を前の行へ移す
+type HyphenationJoiner struct{}
+
+func (HyphenationJoiner) ProcessText(runs []*TextRun) {
+	for i := 0; i+1 < len(runs); i++ {
+		cur, next := runs[i], runs[i+1]
+		if !startsNextLine(cur, next) {
+			continue
+		}
+		head, ok := trimLineEndHyphen(cur.ExtractedText())
+		if !ok {
+			continue
+		}
+		tail := next.ExtractedText()
+		first, _ := utf8.DecodeRuneInString(tail)
+		if !unicode.IsLower(first) {
+			continue
+		}
+		end := strings.IndexFunc(tail, func(r rune) bool {
+			return !unicode.IsLetter(r)
+		})
+		if end < 0 {
+			end = len(tail)
+		}
+		cur.SetActualText(head + tail[:end])
+		next.SetActualText(strings.TrimLeftFunc(tail[end:], unicode.IsSpace))
+	}
+}
+
+// startsNextLine は next が cur の次の行の先頭にあるかを返す
+func startsNextLine(cur, next *TextRun) bool {
+	return next.Page == cur.Page &&
+		next.Y-cur.Y > math.Max(math.Abs(cur.FontSize), math.Abs(next.FontSize))*lineBaselineTolerance &&
+		next.X < cur.X+cur.Width
+}
+
+// trimLineEndHyphen は 文字の直後にある行末のハイフンを取り除く
+func trimLineEndHyphen(text string) (string, bool) {
+	trimmed := strings.TrimRightFunc(text, unicode.IsSpace)
+	last, size := utf8.DecodeLastRuneInString(trimmed)
+	switch last {
+	case '-', '\u00ad', '\u2010': // ハイフン・ソフトハイフン・Unicode のハイフン
+	default:
+		return "", false
+	}
+	head := trimmed[:len(trimmed)-size]
+	prev, _ := utf8.DecodeLastRuneInString(head)
+	if !unicode.IsLetter(prev) {
+		return "", false
+	}
+	return head, true
+}
+
+// postProcessText は 設定された後処理をページのテキストに適用し、置き換えたテキストを ActualText に設定する
+func (p *PDFParser) postProcessText(texts []*ParsedText) {
+	if len(p.textPostProcessors) == 0 || len(texts) == 0 {
+		return
+	}
+	runs := make([]*TextRun, len(texts))
+	for i, text := range texts {
+		runs[i] = &TextRun{
+			Text:     text.Text,
+			X:        text.X,
+			Y:        text.Y,
+			Width:    text.Width,
+			FontID:   text.FontID,
+			FontSize: text.FontSize,
+			Page:     text.Page,
+		}
+	}
+	for _, processor := range p.textPostProcessors {
+		processor.ProcessText(runs)
+	}
+	for i, run := range runs {
+		texts[i].ActualText = run.actualText
+	}
+}
@@ -0,0 +1,32 @@
+package parse
+
+import "time"
+
+// progressReporter は 処理したページ数を数え、一定の間隔ごとに ProgressChunk を送る
+type progressReporter struct {
+	interval   time.Duration
+	pages      int
+	totalPages int
+	last       time.Time
+	insertData func(data ParsedData)
+}
+
+// newProgressReporter は 進捗を送る progressReporter を返す (interval が 0 以下の場合は送らない)
+func newProgressReporter(interval time.Duration, totalPages int, insertData func(data ParsedData)) *progressReporter {
+	return &progressReporter{
+		interval:   interval,
+		totalPages: totalPages,
+		last:       time.Now(),
+		insertData: insertData,
+	}
+}
+
+// page は ページを1つ処理し終えたことを記録し、前回から interval 以上経っていれば進捗を送る
+func (r *progressReporter) page() {
+	r.pages++
+	if r.interval <= 0 || time.Since(r.last) < r.interval {
+		return
+	}
+	r.last = time.Now()
+	r.insertData(&ParsedProgress{Pages: r.pages, TotalPages: r.totalPages})
+}
@@ -0,0 +1,132 @@
+package parse
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+)
+
+// scaleJPEGQuality は 縮小した JPEG を再圧縮する品質
+const scaleJPEGQuality = 85
+
+// scaleImage は 長辺が maxDim ピクセルを超える画像を、縦横比を保って縮小した複製を返す
+// 配置行列は単位正方形に対するものなので変えない。縮小が不要な場合は img をそのまま返す
+// 縮小できない形式の場合は img とエラーを返す
+func scaleImage(img *ParsedImage, maxDim int) (*ParsedImage, error) {
+	width, height := int(img.Width), int(img.Height)
+	if maxDim <= 0 || (width <= maxDim && height <= maxDim) {
+		return img, nil
+	}
+	scaledWidth, scaledHeight := maxDim, maxDim
+	if width > height {
+		scaledHeight = max(height*maxDim/width, 1)
+	} else {
+		scaledWidth = max(width*maxDim/height, 1)
+	}
+
+	scaled := *img
+	switch img.Ext {
+	case "png", "mask":
+		samples, err := inflateSamples(img.Data)
+		if err != nil {
+			return img, err
+		}
+		components := len(samples) / (width * height)
+		if img.BitsPerComponent != 8 || components == 0 || len(samples) != width*height*components {
+			return img, fmt.Errorf("unsupported sample layout (%d bits, %d bytes)", img.BitsPerComponent, len(samples))
+		}
+		// パレットの番号は平均できないため、最も近いピクセルを使う
+		nearest := img.ColorSpace == "Indexed"
+		scaled.Data = deflateSamples(scaleSamples(samples, width, height, components, scaledWidth, scaledHeight, nearest))
+	case "jpg":
+		decoded, err := jpeg.Decode(bytes.NewReader(img.Data))
+		if err != nil {
+			return img, err
+		}
+		if _, ok := decoded.(*image.CMYK); ok {
+			// 再圧縮で Adobe の反転情報が失われるため縮小しない
+			return img, errors.New("CMYK JPEG")
+		}
+		if decoded.Bounds().Dx() != width || decoded.Bounds().Dy() != height {
+			return img, errors.New("unexpected JPEG size")
+		}
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, scaleRGBA(decoded, scaledWidth, scaledHeight), &jpeg.Options{Quality: scaleJPEGQuality}); err != nil {
+			return img, err
+		}
+		scaled.Data = buf.Bytes()
+	default:
+		return img, fmt.Errorf("unsupported format %q", img.Ext)
+	}
+	if len(img.MaskData) > 0 {
+		mask, err := inflateSamples(img.MaskData)
+		if err != nil {
+			return img, fmt.Errorf("mask: %w", err)
+		}
+		if len(mask) != width*height {
+			return img, errors.New("mask size differs from image")
+		}
+		scaled.MaskData = deflateSamples(scaleSamples(mask, width, height, 1, scaledWidth, scaledHeight, false))
+	}
+	scaled.Width = float64(scaledWidth)
+	scaled.Height = float64(scaledHeight)
+	return &scaled, nil
+}
+
+// scaleSamples は 1ピクセル components バイトのサンプル列を、元の範囲の平均 (nearest の場合は中央のピクセル) で縮小する
+func scaleSamples(samples []byte, width, height, components, scaledWidth, scaledHeight int, nearest bool) []byte {
+	out := make([]byte, 0, scaledWidth*scaledHeight*components)
+	sums := make([]int, components)
+	for y := 0; y < scaledHeight; y++ {
+		y0, y1 := y*height/scaledHeight, max((y+1)*height/scaledHeight, y*height/scaledHeight+1)
+		for x := 0; x < scaledWidth; x++ {
+			x0, x1 := x*width/scaledWidth, max((x+1)*width/scaledWidth, x*width/scaledWidth+1)
+			if nearest {
+				i := (((y0+y1)/2)*width + (x0+x1)/2) * components
+				out = append(out, samples[i:i+components]...)
+				continue
+			}
+			clear(sums)
+			for sy := y0; sy < y1; sy++ {
+				row := samples[(sy*width+x0)*components : (sy*width+x1)*components]
+				for i, v := range row {
+					sums[i%components] += int(v)
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			for _, sum := range sums {
+				out = append(out, byte((sum+n/2)/n))
+			}
+		}
+	}
+	return out
+}
+
+// scaleRGBA は 画像を元の範囲の平均で縮小する
+func scaleRGBA(src image.Image, scaledWidth, scaledHeight int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, scaledWidth, scaledHeight))
+	for y := 0; y < scaledHeight; y++ {
+		y0, y1 := y*height/scaledHeight, max((y+1)*height/scaledHeight, y*height/scaledHeight+1)
+		for x := 0; x < scaledWidth; x++ {
+			x0, x1 := x*width/scaledWidth, max((x+1)*width/scaledWidth, x*width/scaledWidth+1)
+			var r, g, b, a uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(bounds.Min.X+sx, bounds.Min.Y+sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+				}
+			}
+			n := uint64((y1 - y0) * (x1 - x0))
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = byte(r / n >> 8)
+			dst.Pix[i+1] = byte(g / n >> 8)
+			dst.Pix[i+2] = byte(b / n >> 8)
+			dst.Pix[i+3] = byte(a / n >> 8)
+		}
+	}
+	return dst
+}
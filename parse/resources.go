@@ -0,0 +1,170 @@
+package parse
+
+import (
+	"errors"
+)
+
+// maxFormNesting は Form XObject の入れ子を展開する最大の深さ
+// 自分自身を参照するフォームなどで無限に展開されるのを防ぐ
+const maxFormNesting = 16
+
+// maxFormExpansions は 1つのコンテンツストリームで Form XObject を展開する回数の上限
+// 同じフォームを何度も描画するフォームが入れ子になると、深さの上限内でも展開の回数が指数的に増えるため
+const maxFormExpansions = 4096
+
+// Resources は ページまたは Form XObject の /Resources から読み込んだ名前付きリソース
+type Resources struct {
+	Fonts      map[string]map[byte]string
+	FontWidths map[string]map[byte]float64
+	ExtGStates map[string]ExtGState
+	XObjects   map[string]PDFRef
+}
+
+// ResourceStack は 実行中のコンテンツストリームのリソースを積むスタック
+// 名前は上(最後に積まれたもの)から順に探すため、Form XObject のリソースがページのリソースを隠す
+type ResourceStack struct {
+	stack []*Resources
+}
+
+func NewResourceStack(page *Resources) *ResourceStack {
+	rs := &ResourceStack{}
+	rs.Push(page)
+	return rs
+}
+
+// Push は リソースを積む。nil の場合は空のリソースを積み、名前の解決は下のリソースに任せる
+func (rs *ResourceStack) Push(r *Resources) {
+	if r == nil {
+		r = &Resources{}
+	}
+	rs.stack = append(rs.stack, r)
+}
+
+// Pop は 最後に積んだリソースを取り除く
+func (rs *ResourceStack) Pop() {
+	if len(rs.stack) > 0 {
+		rs.stack = rs.stack[:len(rs.stack)-1]
+	}
+}
+
+// Depth は 積まれているリソースの数を返す
+func (rs *ResourceStack) Depth() int {
+	return len(rs.stack)
+}
+
+func (rs *ResourceStack) Font(name string) (map[byte]string, bool) {
+	for i := len(rs.stack) - 1; i >= 0; i-- {
+		if font, ok := rs.stack[i].Fonts[name]; ok {
+			return font, true
+		}
+	}
+	return nil, false
+}
+
+func (rs *ResourceStack) FontWidths(name string) (map[byte]float64, bool) {
+	for i := len(rs.stack) - 1; i >= 0; i-- {
+		if widths, ok := rs.stack[i].FontWidths[name]; ok {
+			return widths, true
+		}
+	}
+	return nil, false
+}
+
+func (rs *ResourceStack) ExtGState(name string) (ExtGState, bool) {
+	for i := len(rs.stack) - 1; i >= 0; i-- {
+		if gs, ok := rs.stack[i].ExtGStates[name]; ok {
+			return gs, true
+		}
+	}
+	return ExtGState{}, false
+}
+
+func (rs *ResourceStack) XObject(name string) (PDFRef, bool) {
+	for i := len(rs.stack) - 1; i >= 0; i-- {
+		if ref, ok := rs.stack[i].XObjects[name]; ok {
+			return ref, true
+		}
+	}
+	return 0, false
+}
+
+// FormXObject は Do で実行される Form XObject を表す
+type FormXObject struct {
+	Contents  string
+	Matrix    Matrix     // フォーム空間からユーザー空間への変換 (/Matrix)
+	Resources *Resources // nil の場合は呼び出し元のリソースを使う
+}
+
+// loadResources は リソース辞書(インライン可)からフォント・ExtGState・XObject を読み込む
+func (p *PDFParser) loadResources(resources PDFObject) (*Resources, error) {
+	resources, err := p.resolveObject(resources)
+	if err != nil {
+		return nil, err
+	}
+	fonts, err := p.extractFonts(resources)
+	if err != nil {
+		return nil, err
+	}
+	extGStates, err := p.extGStatesFromResources(resources)
+	if err != nil {
+		return nil, err
+	}
+	xobjects, err := p.imageRefsFromResources(resources)
+	if err != nil {
+		return nil, err
+	}
+	fontMaps := make(map[string]map[byte]string, len(fonts))
+	fontWidths := make(map[string]map[byte]float64, len(fonts))
+	for key, font := range fonts {
+		fontMaps[key] = font.fontMap
+		fontWidths[key] = font.widths
+	}
+	return &Resources{
+		Fonts:      fontMaps,
+		FontWidths: fontWidths,
+		ExtGStates: extGStates,
+		XObjects:   xobjects,
+	}, nil
+}
+
+// loadFormXObject は ref が Form XObject であれば内容・変換行列・リソースを読み込む
+// 画像など Form 以外の XObject の場合は nil を返す
+func (p *PDFParser) loadFormXObject(ref PDFRef) (*FormXObject, error) {
+	so, err := p.ParseStreamObject(ref)
+	if err != nil {
+		return nil, err
+	}
+	if subtype, _ := so.Dict["Subtype"].(string); subtype != "Form" {
+		return nil, nil
+	}
+	contents, err := so.Decoded()
+	if err != nil {
+		return nil, err
+	}
+	form := &FormXObject{
+		Contents: string(contents),
+		Matrix:   IdentityMatrix(),
+	}
+	if matrix, ok := so.Dict["Matrix"].([]PDFObject); ok && len(matrix) == 6 {
+		var v [6]float64
+		for i, obj := range matrix {
+			f, ok := toFloat(obj)
+			if !ok {
+				return nil, errors.New("Form Matrix is not number array")
+			}
+			v[i] = f
+		}
+		form.Matrix = Matrix{
+			{v[0], v[1], 0},
+			{v[2], v[3], 0},
+			{v[4], v[5], 1},
+		}
+	}
+	if resources, found := so.Dict["Resources"]; found {
+		form.Resources, err = p.loadResources(resources)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return form, nil
+}
@@ -0,0 +1,167 @@
+package parse
+
+import (
+	"container/heap"
+	"math"
+)
+
+const (
+	// imageAgingStep は 基準ページが離れていくたびに、後回しになった画像の優先度を上げる量 (ページ数)
+	// 近づいた画像は上げないため、スクロールで何度も後回しにされた画像ほど先に送られるようになる
+	imageAgingStep = 0.25
+	// imagePositionWeight は ページ内の位置 (上端 0 から下端 1) を優先度に加えるときの重み
+	imagePositionWeight = 0.4
+	// imagePrecedingPenalty は 基準より前のページの画像を、同じ距離の後ろのページの画像より後にする量
+	imagePrecedingPenalty = 0.5
+)
+
+// ChunkOrder は ページの内容のチャンクを送る順番を示す
+// どの順番でもページの中ではテキスト・パスを画像より先に送り、PageDoneChunk はページの最後に送る
+// ページで使うフォントは遅くとも PageDoneChunk の直前に送り、PageDoneChunk は画像を含むページの内容を送り終えたらすぐに送る
+type ChunkOrder string
+
+const (
+	// ChunkOrderImagesLast は 全ページのテキスト・パスを先に送り、画像を後に送る (空文字列も同じ)
+	ChunkOrderImagesLast ChunkOrder = "images-last"
+	// ChunkOrderFontsFirst は 各ページのテキストの直後にフォントを送り、画像は全ページの後に送る
+	ChunkOrderFontsFirst ChunkOrder = "fonts-first"
+	// ChunkOrderInterleave は 1ページずつテキスト・パス・フォント・画像を送り、ページを完了してから次のページに進む
+	// 先頭のページから順に表示を完成させたいクライアント向け
+	ChunkOrderInterleave ChunkOrder = "interleave-by-page"
+)
+
+// queuedImage は 送る順番を待っている画像コマンド
+type queuedImage struct {
+	cmd      ImageRefCommand
+	index    int // 解析した順番 (優先度が同じ場合に使う)
+	age      int // 待っている間に基準ページが離れていき、後回しになった回数
+	priority float64
+}
+
+// imageQueue は 画像コマンドを取り出す順番を決める
+// 優先度を使わない場合は解析した順 (ページ順) に取り出す
+// 優先度を使う場合は 基準ページ (クライアントが表示しているページ) からの距離とページ内の位置から順番を決め、
+// 基準ページが変わるたびに計算し直す
+type imageQueue struct {
+	items      []*queuedImage
+	prioritize bool
+	base       int64
+	heights    map[int64]float64
+}
+
+func newImageQueue(cmds []ImageRefCommand, heights map[int64]float64, base int64, prioritize bool) *imageQueue {
+	q := &imageQueue{
+		items:      make([]*queuedImage, 0, len(cmds)),
+		prioritize: prioritize,
+		base:       base,
+		heights:    heights,
+	}
+	for i, cmd := range cmds {
+		item := &queuedImage{cmd: cmd, index: i}
+		item.priority = q.priority(item)
+		q.items = append(q.items, item)
+	}
+	if prioritize {
+		heap.Init(q)
+	}
+	return q
+}
+
+// next は 次に送る画像コマンドを取り出す
+// base は 現在の基準ページで、前回から変わっていれば優先度を計算し直す
+// 画像は1つずつ取り出すため、送信が詰まっている間に基準ページが変わっても次の画像の選択に反映される
+func (q *imageQueue) next(base int64) ImageRefCommand {
+	if !q.prioritize {
+		item := q.items[0]
+		q.items = q.items[1:]
+		return item.cmd
+	}
+	if base != q.base {
+		q.base = base
+		for _, item := range q.items {
+			// すべての画像を同じだけ古くしても順番は変わらないため、後回しになった画像だけを古くする
+			previous := item.priority
+			item.priority = q.priority(item)
+			if item.priority > previous {
+				item.age++
+				item.priority = q.priority(item)
+			}
+		}
+		heap.Init(q)
+	}
+	return heap.Pop(q).(*queuedImage).cmd
+}
+
+// priority は 画像の優先度を返す (小さいほど先に送る)
+func (q *imageQueue) priority(item *queuedImage) float64 {
+	cmd := item.cmd
+	distance := float64(cmd.Page - q.base)
+	if distance < 0 {
+		distance = -distance + imagePrecedingPenalty
+	}
+	// ページの上にある画像ほど先に表示される。ページ外の画像は同じページの最後にする
+	position := 1.0
+	if height := q.heights[cmd.Page]; height > 0 && !cmd.OffPage {
+		top := (height - (cmd.Y + cmd.DH)) / height
+		position = math.Min(math.Max(top, 0), 1)
+	}
+	return distance + position*imagePositionWeight - float64(item.age)*imageAgingStep
+}
+
+func (q *imageQueue) Len() int { return len(q.items) }
+
+func (q *imageQueue) Less(i, j int) bool {
+	if q.items[i].priority != q.items[j].priority {
+		return q.items[i].priority < q.items[j].priority
+	}
+	return q.items[i].index < q.items[j].index
+}
+
+func (q *imageQueue) Swap(i, j int) { q.items[i], q.items[j] = q.items[j], q.items[i] }
+
+func (q *imageQueue) Push(x any) { q.items = append(q.items, x.(*queuedImage)) }
+
+func (q *imageQueue) Pop() any {
+	last := q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+	return last
+}
+
+// pageCompletion は 各ページの残りの画像を数え、解析を終えて画像もすべて送ったページの完了を送る
+// ページの完了は他のページを待たずに送る (画像のないページは解析を終えた時点で完了する)
+type pageCompletion struct {
+	remaining map[int64]int
+	parsed    map[int64]bool
+	done      func(page int64) error
+}
+
+func newPageCompletion(done func(page int64) error) *pageCompletion {
+	return &pageCompletion{
+		remaining: make(map[int64]int),
+		parsed:    make(map[int64]bool),
+		done:      done,
+	}
+}
+
+// imageQueued は page の画像を1つ送る予定にしたことを記録する
+func (c *pageCompletion) imageQueued(page int64) {
+	c.remaining[page]++
+}
+
+// pageParsed は page の画像以外の内容を送り終えたことを記録する
+func (c *pageCompletion) pageParsed(page int64) error {
+	c.parsed[page] = true
+	if c.remaining[page] > 0 {
+		return nil
+	}
+	return c.done(page)
+}
+
+// imageSent は page の画像を1つ送ったことを記録する
+func (c *pageCompletion) imageSent(page int64) error {
+	c.remaining[page]--
+	if c.remaining[page] > 0 || !c.parsed[page] {
+		return nil
+	}
+	return c.done(page)
+}
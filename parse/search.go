@@ -0,0 +1,133 @@
+package parse
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// StreamSearchResults は 指定ページのテキストから query を探し、見つかった箇所を送る
+// 大文字・小文字は区別しない。テキストは ExtractText と同じく行ごとにまとめ、離れたテキストの間の空白を補って探す
+func (p *PDFParser) StreamSearchResults(ctx context.Context, start, end, base int64, query string, insertData func(data ParsedData)) error {
+	needle := []rune(strings.ToLower(query))
+	if len(needle) == 0 {
+		return nil
+	}
+	c, err := p.GetCatalog()
+	if err != nil {
+		return err
+	}
+	if err := p.loadPageObject(*c); err != nil {
+		return err
+	}
+	sequence, _, err := p.pageSequence(start, end, base)
+	if err != nil {
+		return err
+	}
+	if err := p.limits.checkPages(len(sequence)); err != nil {
+		return err
+	}
+	summary := newStreamSummary(insertData)
+	progress := newProgressReporter(p.progressInterval, len(sequence), summary.insert)
+	for _, i := range sequence {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		page, err := p.ExtractPage(int(i))
+		if err != nil {
+			return err
+		}
+		tc, _, _, err := p.extractPageContents(ctx, page.ContentsRef, page.ResourcesRef, page.PageHeight)
+		if errors.Is(err, ErrOperatorBudgetExceeded) {
+			// 打ち切ったページはそこまでのテキストを検索する
+			p.logger.Warn("Page is truncated", "page", i, "err", err)
+		} else if err != nil {
+			return err
+		}
+		for _, line := range textLines(tc) {
+			for _, result := range searchLine(line, needle) {
+				result.Page = int64(i)
+				summary.insert(result)
+			}
+		}
+		summary.pageDone(int64(i))
+		progress.page()
+	}
+	summary.done()
+	return nil
+}
+
+// lineGlyph は 行のテキストの1文字とそのおおよその位置
+type lineGlyph struct {
+	r        rune
+	x, width float64
+	y, size  float64
+}
+
+// layoutLine は 行のテキストを1文字ずつに分け、テキストの幅を文字数で按分した位置を付ける
+func layoutLine(line []TextCommand) []lineGlyph {
+	var glyphs []lineGlyph
+	for j, cmd := range line {
+		runes := []rune(strings.Join(cmd.Text, ""))
+		if len(runes) == 0 {
+			continue
+		}
+		width := approxTextWidth(cmd)
+		if j > 0 {
+			prev := line[j-1]
+			gap := cmd.X - (prev.X + approxTextWidth(prev))
+			if gap > math.Abs(prev.FontSize)*coalesceSpaceGap && !endsWithSpace(prev.Text) && !startsWithSpace(cmd.Text) {
+				glyphs = append(glyphs, lineGlyph{r: ' ', x: cmd.X - gap, width: gap, y: cmd.Y, size: math.Abs(cmd.FontSize)})
+			}
+		}
+		perRune := width / float64(len(runes))
+		for k, r := range runes {
+			glyphs = append(glyphs, lineGlyph{
+				r:     r,
+				x:     cmd.X + perRune*float64(k),
+				width: perRune,
+				y:     cmd.Y,
+				size:  math.Abs(cmd.FontSize),
+			})
+		}
+	}
+	return glyphs
+}
+
+// searchLine は 行のテキストから needle (小文字) を重ならないように全て探す
+func searchLine(line []TextCommand, needle []rune) []*ParsedSearchResult {
+	glyphs := layoutLine(line)
+	var results []*ParsedSearchResult
+	for i := 0; i+len(needle) <= len(glyphs); i++ {
+		matched := true
+		for k, r := range needle {
+			if unicode.ToLower(glyphs[i+k].r) != r {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		match := glyphs[i : i+len(needle)]
+		text := make([]rune, len(match))
+		x0, x1, top, bottom := match[0].x, match[0].x, math.Inf(1), math.Inf(-1)
+		for k, g := range match {
+			text[k] = g.r
+			x1 = math.Max(x1, g.x+g.width)
+			top = math.Min(top, g.y-g.size)
+			bottom = math.Max(bottom, g.y)
+		}
+		results = append(results, &ParsedSearchResult{
+			X:      x0,
+			Y:      top,
+			Width:  x1 - x0,
+			Height: bottom - top,
+			Text:   string(text),
+		})
+		i += len(needle) - 1
+	}
+	return results
+}
@@ -0,0 +1,175 @@
+package parse
+
+import (
+	"encoding/binary"
+	"unicode/utf8"
+)
+
+// glyphMap は 単純 TrueType フォントの文字コードからグリフIDへの対応
+type glyphMap map[int]int
+
+// glyphID は 文字コードのグリフIDを返す
+// 対応が分からない場合は文字コードをそのままグリフIDとみなす
+func (m glyphMap) glyphID(code int) int {
+	if gid, ok := m[code]; ok {
+		return gid
+	}
+	return code
+}
+
+// fontGlyphMap は 埋め込みフォントの cmap から文字コードとグリフIDの対応を読み込んで保持する
+func (p *PDFParser) fontGlyphMap(fontID string) glyphMap {
+	if m, ok := p.glyphMaps.get(fontID); ok {
+		return m
+	}
+	var m glyphMap
+	if font, ok := p.fonts.get(fontID); ok && font.FontDataRef != 0 {
+		ref := font.FontDataRef
+		data, err := p.ExtractFontStream(ref)
+		if err != nil {
+			p.logger.Warn("Failed to read font for glyph ids", "font", fontID, "err", err)
+		} else {
+			m = parseSimpleCmap(data)
+		}
+	}
+	p.glyphMaps.put(fontID, m)
+	return m
+}
+
+// parseSimpleCmap は 単純フォントで使われる cmap サブテーブルから1バイトの文字コードの対応を読み込む
+// (3,0) シンボルのサブテーブルは 0xF000 + 文字コード に割り当てられている
+func parseSimpleCmap(data []byte) glyphMap {
+	ot, err := parseOffsetTable(data)
+	if err != nil || len(data) < 12+int(ot.NumTables)*16 {
+		return nil
+	}
+	directory, err := parseTableDirectory(data[12:], int(ot.NumTables))
+	if err != nil {
+		return nil
+	}
+	var cmap []byte
+	for _, rec := range directory {
+		if tagUint32ToString(rec.Tag) == "cmap" && uint64(rec.Offset)+uint64(rec.Length) <= uint64(len(data)) {
+			cmap = data[rec.Offset : rec.Offset+rec.Length]
+		}
+	}
+	if len(cmap) < 4 {
+		return nil
+	}
+	count := int(binary.BigEndian.Uint16(cmap[2:4]))
+	var symbol, mac []byte
+	for i := 0; i < count; i++ {
+		rec := 4 + i*8
+		if rec+8 > len(cmap) {
+			break
+		}
+		platform := binary.BigEndian.Uint16(cmap[rec:])
+		encoding := binary.BigEndian.Uint16(cmap[rec+2:])
+		offset := int(binary.BigEndian.Uint32(cmap[rec+4:]))
+		if offset >= len(cmap) {
+			continue
+		}
+		switch {
+		case platform == 3 && encoding == 0:
+			symbol = cmap[offset:]
+		case platform == 1 && encoding == 0:
+			mac = cmap[offset:]
+		}
+	}
+	m := make(glyphMap)
+	if symbol != nil {
+		for code := 0; code < 256; code++ {
+			if gid, ok := cmapLookup(symbol, 0xF000+code); ok {
+				m[code] = gid
+			} else if gid, ok := cmapLookup(symbol, code); ok {
+				m[code] = gid
+			}
+		}
+		return m
+	}
+	if mac != nil {
+		for code := 0; code < 256; code++ {
+			if gid, ok := cmapLookup(mac, code); ok {
+				m[code] = gid
+			}
+		}
+	}
+	return m
+}
+
+// cmapLookup は cmap サブテーブル (フォーマット 0 / 4 / 6) から文字コードのグリフIDを引く
+func cmapLookup(sub []byte, code int) (int, bool) {
+	if len(sub) < 6 {
+		return 0, false
+	}
+	switch binary.BigEndian.Uint16(sub) {
+	case 0:
+		if code < 256 && 6+code < len(sub) {
+			return int(sub[6+code]), sub[6+code] != 0
+		}
+	case 4:
+		if len(sub) < 14 {
+			return 0, false
+		}
+		segCount := int(binary.BigEndian.Uint16(sub[6:])) / 2
+		endCodes := 14
+		startCodes := endCodes + segCount*2 + 2
+		idDeltas := startCodes + segCount*2
+		idRangeOffsets := idDeltas + segCount*2
+		if idRangeOffsets+segCount*2 > len(sub) {
+			return 0, false
+		}
+		for i := 0; i < segCount; i++ {
+			end := int(binary.BigEndian.Uint16(sub[endCodes+i*2:]))
+			if code > end {
+				continue
+			}
+			start := int(binary.BigEndian.Uint16(sub[startCodes+i*2:]))
+			if code < start {
+				return 0, false
+			}
+			delta := int(binary.BigEndian.Uint16(sub[idDeltas+i*2:]))
+			rangeOffset := int(binary.BigEndian.Uint16(sub[idRangeOffsets+i*2:]))
+			if rangeOffset == 0 {
+				return (code + delta) & 0xFFFF, true
+			}
+			pos := idRangeOffsets + i*2 + rangeOffset + (code-start)*2
+			if pos+2 > len(sub) {
+				return 0, false
+			}
+			gid := int(binary.BigEndian.Uint16(sub[pos:]))
+			if gid == 0 {
+				return 0, false
+			}
+			return (gid + delta) & 0xFFFF, true
+		}
+	case 6:
+		if len(sub) < 10 {
+			return 0, false
+		}
+		first := int(binary.BigEndian.Uint16(sub[6:]))
+		n := int(binary.BigEndian.Uint16(sub[8:]))
+		if code >= first && code < first+n && 10+(code-first)*2+2 <= len(sub) {
+			gid := int(binary.BigEndian.Uint16(sub[10+(code-first)*2:]))
+			return gid, gid != 0
+		}
+	}
+	return 0, false
+}
+
+// shapeText は テキストを正規化しながら、各グリフのグリフIDとテキスト中の開始位置(クラスター)を求める
+// 正規化はグリフごとのテキストに適用するため、クラスターは送信するテキストの位置と一致する
+func (p *PDFParser) shapeText(cmd TextCommand) (string, []int, []int) {
+	glyphs := p.fontGlyphMap(cmd.FontID)
+	text := ""
+	var gids, clusters []int
+	for i, s := range cmd.Text {
+		s = p.textOptions.Apply(s)
+		if i < len(cmd.Codes) && cmd.Codes[i] >= 0 {
+			gids = append(gids, glyphs.glyphID(cmd.Codes[i]))
+			clusters = append(clusters, utf8.RuneCountInString(text))
+		}
+		text += s
+	}
+	return text, gids, clusters
+}
@@ -0,0 +1,233 @@
+package parse
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signature は 署名フィールド (/FT /Sig) の署名辞書の内容
+type Signature struct {
+	Field       string    // フィールド名 (/T)
+	SignerName  string    // 署名者 (/Name、ない場合は署名者の証明書の CN)
+	SigningTime time.Time // 署名日時 (/M、ない場合はゼロ値)
+	Reason      string    // 署名の理由 (/Reason)
+	Location    string    // 署名の場所 (/Location)
+	SubFilter   string    // 署名の形式 (adbe.pkcs7.detached など)
+	ByteRange   []int64   // 署名の対象となるバイト範囲 (開始位置と長さの組)
+	// CoversWholeFile は 署名値 (/Contents) を除くファイル全体が署名の対象であるか
+	// false の場合は署名後に追記(増分更新)されている
+	CoversWholeFile bool
+
+	contents []byte
+}
+
+// Signatures は 対話フォームの署名フィールドを列挙する
+// 署名されていない (/V がない) フィールドは含めない
+func (p *PDFParser) Signatures() ([]Signature, error) {
+	c, err := p.GetCatalog()
+	if err != nil {
+		return nil, err
+	}
+	form, err := p.resolveObject(c.AcroForm)
+	if err != nil {
+		return nil, err
+	}
+	fields, found := findTarget(form, "Fields")
+	if !found {
+		return nil, nil
+	}
+	size, err := p.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	var signatures []Signature
+	err = p.walkFields(fields, "", "", 0, func(name string, value PDFObject) error {
+		sig, ok, err := p.readSignature(value, size)
+		if err != nil || !ok {
+			return err
+		}
+		sig.Field = name
+		signatures = append(signatures, sig)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return signatures, nil
+}
+
+// walkFields は フィールドの階層をたどり、署名フィールドの完全な名前と /V を fn に渡す
+// /FT は親から継承される
+func (p *PDFParser) walkFields(fields PDFObject, parentName, parentType string, depth int, fn func(name string, value PDFObject) error) error {
+	if depth > maxTreeDepth {
+		return nil
+	}
+	fields, err := p.resolveObject(fields)
+	if err != nil {
+		return err
+	}
+	array, ok := fields.([]PDFObject)
+	if !ok {
+		return nil
+	}
+	for _, field := range array {
+		field, err := p.resolveObject(field)
+		if err != nil {
+			return err
+		}
+		dict, ok := field.(map[string]PDFObject)
+		if !ok {
+			continue
+		}
+		name := parentName
+		if t := textStringFrom(dict["T"]); t != "" {
+			if name != "" {
+				name += "."
+			}
+			name += t
+		}
+		fieldType := parentType
+		if ft, ok := dict["FT"].(string); ok {
+			fieldType = strings.TrimLeft(ft, "/")
+		}
+		if kids, found := dict["Kids"]; found {
+			if err := p.walkFields(kids, name, fieldType, depth+1, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if fieldType != "Sig" || dict["V"] == nil {
+			continue
+		}
+		if err := fn(name, dict["V"]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readSignature は 署名辞書を読み込む
+func (p *PDFParser) readSignature(value PDFObject, fileSize int64) (Signature, bool, error) {
+	obj, err := p.resolveObject(value)
+	if err != nil {
+		return Signature{}, false, err
+	}
+	dict, ok := obj.(map[string]PDFObject)
+	if !ok {
+		return Signature{}, false, nil
+	}
+	sig := Signature{
+		SignerName: textStringFrom(dict["Name"]),
+		Reason:     textStringFrom(dict["Reason"]),
+		Location:   textStringFrom(dict["Location"]),
+	}
+	if subFilter, ok := dict["SubFilter"].(string); ok {
+		sig.SubFilter = strings.TrimLeft(subFilter, "/")
+	}
+	if m := textStringFrom(dict["M"]); m != "" {
+		sig.SigningTime, _ = parsePDFDate(m)
+	}
+	if contents, ok := dict["Contents"].(string); ok {
+		sig.contents, _ = hex.DecodeString(contents)
+	}
+	byteRange, err := p.resolveObject(dict["ByteRange"])
+	if err != nil {
+		return Signature{}, false, err
+	}
+	if array, ok := byteRange.([]PDFObject); ok {
+		for _, v := range array {
+			n, ok := v.(int)
+			if !ok {
+				return Signature{}, false, fmt.Errorf("%w: ByteRange is not int", ErrSignatureInvalid)
+			}
+			sig.ByteRange = append(sig.ByteRange, int64(n))
+		}
+	}
+	br := sig.ByteRange
+	sig.CoversWholeFile = len(br) == 4 && br[0] == 0 && br[0]+br[1] <= br[2] && br[2]+br[3] == fileSize
+	if sig.SignerName == "" && len(sig.contents) > 0 {
+		if signed, err := parseCMSSignedData(sig.contents); err == nil {
+			if cert := signed.signerCertificate(); cert != nil {
+				sig.SignerName = cert.Subject.CommonName
+			}
+		}
+	}
+	return sig, true, nil
+}
+
+// VerifySignature は 署名値 (CMS) が ByteRange の内容に対して正しく、署名者の証明書が信頼できるかを検証する
+// 証明書は署名値に同梱された証明書を中間証明書として ParserConfig.SignatureRoots まで連なることを確かめる
+// 証明書の失効は検証しない。SHA-1 の署名は ErrSignatureAlgorithmUnsupported として扱う
+func (p *PDFParser) VerifySignature(sig Signature) error {
+	switch sig.SubFilter {
+	case "adbe.pkcs7.detached", "ETSI.CAdES.detached":
+	default:
+		return fmt.Errorf("%w: %s", ErrSignatureUnsupported, sig.SubFilter)
+	}
+	if len(sig.ByteRange)%2 != 0 || len(sig.contents) == 0 {
+		return fmt.Errorf("%w: missing ByteRange or Contents", ErrSignatureInvalid)
+	}
+	var content []byte
+	for i := 0; i < len(sig.ByteRange); i += 2 {
+		offset, length := sig.ByteRange[i], sig.ByteRange[i+1]
+		if offset < 0 || length < 0 {
+			return fmt.Errorf("%w: negative ByteRange", ErrSignatureInvalid)
+		}
+		if _, err := p.file.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		buf := make([]byte, length)
+		if _, err := io.ReadFull(p.file, buf); err != nil {
+			return fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+		}
+		content = append(content, buf...)
+	}
+	signed, err := parseCMSSignedData(sig.contents)
+	if err != nil {
+		return err
+	}
+	return signed.verify(content, p.signatureRoots)
+}
+
+// parsePDFDate は PDF の日付文字列 (D:YYYYMMDDHHmmSSOHH'mm') を読み込む
+// 省略された後ろの項目は既定値 (月日は1、時刻は0、UTC) とする
+func parsePDFDate(s string) (time.Time, bool) {
+	s = strings.TrimPrefix(s, "D:")
+	fields := []int{0, 1, 1, 0, 0, 0}
+	widths := []int{4, 2, 2, 2, 2, 2}
+	for i, w := range widths {
+		if len(s) < w {
+			if i == 0 {
+				return time.Time{}, false
+			}
+			break
+		}
+		n, err := strconv.Atoi(s[:w])
+		if err != nil {
+			return time.Time{}, false
+		}
+		fields[i] = n
+		s = s[w:]
+	}
+	loc := time.UTC
+	if len(s) > 0 && (s[0] == '+' || s[0] == '-') {
+		tz := strings.ReplaceAll(s[1:], "'", "")
+		hours, minutes := 0, 0
+		if len(tz) >= 2 {
+			hours, _ = strconv.Atoi(tz[:2])
+		}
+		if len(tz) >= 4 {
+			minutes, _ = strconv.Atoi(tz[2:4])
+		}
+		offset := hours*3600 + minutes*60
+		if s[0] == '-' {
+			offset = -offset
+		}
+		loc = time.FixedZone("", offset)
+	}
+	return time.Date(fields[0], time.Month(fields[1]), fields[2], fields[3], fields[4], fields[5], 0, loc), true
+}
@@ -0,0 +1,82 @@
+package parse
+
+import (
+	"errors"
+)
+
+// SoftMask は ExtGState の /SMask で指定されるソフトマスクを表す
+type SoftMask struct {
+	Subtype  string // Luminosity または Alpha
+	GroupRef PDFRef // マスクを描画する透明グループ (Form XObject)
+}
+
+// IsNone は /SMask /None (ソフトマスクの解除)を表すかを返す
+func (sm *SoftMask) IsNone() bool {
+	return sm.GroupRef == 0
+}
+
+// parseSoftMask は ExtGState の /SMask の値を解析する
+func (p *PDFParser) parseSoftMask(obj PDFObject) (*SoftMask, error) {
+	if name, ok := obj.(string); ok && name == "None" {
+		return &SoftMask{}, nil
+	}
+	obj, err := p.resolveObject(obj)
+	if err != nil {
+		return nil, err
+	}
+	dict, ok := obj.(map[string]PDFObject)
+	if !ok {
+		return nil, errors.New("SMask format error")
+	}
+	subtype, _ := dict["S"].(string)
+	groupString, ok := dict["G"].(string)
+	if !ok {
+		return nil, errors.New("SMask group not found")
+	}
+	groupRef, ok := parseRef(groupString)
+	if !ok {
+		return nil, errors.New("SMask group format error")
+	}
+	return &SoftMask{Subtype: subtype, GroupRef: groupRef}, nil
+}
+
+// renderSoftMask は マスクグループを実行してマスクのバイト列を得る
+// ラスタライザを持たないため、グループが描画する画像をマスクとして扱う
+func (p *PDFParser) renderSoftMask(mask *SoftMask) ([]byte, error) {
+	group, err := p.ParseStreamObject(mask.GroupRef)
+	if err != nil {
+		return nil, err
+	}
+	groupStream, err := group.Decoded()
+	if err != nil {
+		return nil, err
+	}
+	resources, found := group.Dict["Resources"]
+	if !found {
+		return nil, errors.New("SMask group resources not found")
+	}
+	groupResources, err := p.loadResources(resources)
+	if err != nil {
+		return nil, err
+	}
+	to := NewTokenObject(string(groupStream), groupResources, p.loadFormXObject)
+	to.logger = p.logger
+	_, ic, _ := to.ExtractCommands(0)
+	if len(ic) == 0 {
+		p.logger.Warn("Soft mask group has no image to use as mask", "ref", mask.GroupRef)
+		return nil, nil
+	}
+	imageRef := ic[0].ImageRef
+	if imageRef == 0 {
+		return nil, errors.New("SMask group image not found: " + ic[0].ImageID)
+	}
+	img, err := p.ExtractImageStream(imageRef)
+	if err != nil {
+		return nil, err
+	}
+	// Alpha マスクはグループの不透明度を使うため、画像自体のマスクがあればそちらを優先する
+	if mask.Subtype == "Alpha" && len(img.MaskData) > 0 {
+		return img.MaskData, nil
+	}
+	return img.Data, nil
+}
@@ -0,0 +1,326 @@
+package parse
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// StreamLengthPolicy は ストリームの /Length と実際のデータが食い違う場合の扱いを示す
+type StreamLengthPolicy int
+
+const (
+	// StreamLengthTolerant は 警告を出した上で /Length に合わせてパディング・切り詰めを行う
+	StreamLengthTolerant StreamLengthPolicy = iota
+	// StreamLengthStrict は 食い違いをエラーとして扱う
+	StreamLengthStrict
+	// StreamLengthAuto は endstream の位置から実際の長さを検出して読み直す
+	StreamLengthAuto
+)
+
+const (
+	endstreamKeyword       = "endstream"
+	endstreamScanChunkSize = 64 * 1024
+	// maxObjectHeaderLength は 読み込みの境界をまたぐオブジェクトの開始を見落とさないために重ねて探す長さ
+	maxObjectHeaderLength = 64
+)
+
+// objectHeaderPattern は 行頭の "N G obj" (次の間接オブジェクトの開始) に一致する
+var objectHeaderPattern = regexp.MustCompile(`[\r\n][ \t]*\d+[ \t\r\n]+\d+[ \t\r\n]+obj\b`)
+
+// readStreamData は offset から length バイトのストリームデータを読み込み、
+// 後続に endstream があるかを検証した上でポリシーに従って結果を返す
+func (p *PDFParser) readStreamData(ref PDFRef, offset int64, length int) ([]byte, error) {
+	buffer := make([]byte, length)
+	if _, err := p.file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	n, err := io.ReadFull(p.file, buffer)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("%w: %v", ErrParserReadStreamError, err)
+	}
+	shortRead := n < length
+	if !shortRead && p.hasEndstreamAt(offset+int64(length)) {
+		return buffer, nil
+	}
+
+	switch p.streamLengthPolicy {
+	case StreamLengthStrict:
+		return nil, fmt.Errorf("%w: object %d", ErrParserStreamLengthError, ref)
+	case StreamLengthAuto:
+		actual, found := p.findEndstream(offset)
+		if !found {
+			return nil, fmt.Errorf("%w: endstream not found in object %d", ErrParserStreamLengthError, ref)
+		}
+		p.logger.Warn("Stream length mismatch", "ref", ref, "length", length, "detected", actual)
+		return p.readStreamBytes(offset, actual)
+	default:
+		// 短い読み込みは0でパディング、endstreamが手前にあれば切り詰める
+		if actual, found := p.findEndstream(offset); found && actual < n {
+			p.logger.Warn("Stream length mismatch", "ref", ref, "length", length, "truncated", actual)
+			return buffer[:actual], nil
+		}
+		p.logger.Warn("Stream length mismatch", "ref", ref, "length", length, "read", n)
+		return buffer, nil
+	}
+}
+
+// hasEndstreamAt は offset の直後(改行を除く)に endstream キーワードがあるかを調べる
+func (p *PDFParser) hasEndstreamAt(offset int64) bool {
+	buf, err := p.readStreamBytes(offset, len(endstreamKeyword)+2)
+	if err != nil && len(buf) == 0 {
+		return false
+	}
+	return bytes.HasPrefix(bytes.TrimLeft(buf, "\r\n"), []byte(endstreamKeyword))
+}
+
+// findEndstream は offset から前方へ endstream を探し、データ部の長さ(直前の改行を除く)を返す
+// データ中に偶然現れる endstream を避けるため、改行の直後にあり endobj が続くものを優先する
+// 探すのは次のオブジェクトの開始までで、endobj が無いファイルでも残り全体を読み込まない
+func (p *PDFParser) findEndstream(offset int64) (int, bool) {
+	if _, err := p.file.Seek(offset, io.SeekStart); err != nil {
+		return 0, false
+	}
+	keyword := []byte(endstreamKeyword)
+	chunk := make([]byte, endstreamScanChunkSize)
+	var data []byte
+	lineStart, first := -1, -1
+	searchFrom := 0
+	eof := false
+	headerFrom := 0
+	for !eof {
+		n, err := p.file.Read(chunk)
+		data = append(data, chunk[:n]...)
+		if err != nil || n == 0 {
+			eof = true
+		}
+		// 次のオブジェクトの開始より後ろはこのストリームのデータではないため、そこで読み込みを止める
+		if loc := objectHeaderPattern.FindIndex(data[headerFrom:]); loc != nil {
+			data = data[:headerFrom+loc[0]]
+			searchFrom = min(searchFrom, len(data))
+			eof = true
+		} else if next := len(data) - maxObjectHeaderLength; next > headerFrom {
+			headerFrom = next
+		}
+		for {
+			idx := bytes.Index(data[searchFrom:], keyword)
+			if idx < 0 {
+				// 次のチャンクとの境界をまたぐ出現に備える
+				if next := len(data) - len(keyword) + 1; next > searchFrom {
+					searchFrom = next
+				}
+				break
+			}
+			pos := searchFrom + idx
+			rest := bytes.TrimLeft(data[pos+len(keyword):], " \t\r\n\f\x00")
+			if len(rest) < len("endobj") && !eof {
+				// 後続を判定するには読み込みが足りない
+				break
+			}
+			afterEOL := pos > 0 && (data[pos-1] == '\n' || data[pos-1] == '\r')
+			if afterEOL && bytes.HasPrefix(rest, []byte("endobj")) {
+				return trimStreamEOL(data, pos), true
+			}
+			if first < 0 {
+				first = pos
+			}
+			if afterEOL && lineStart < 0 {
+				lineStart = pos
+			}
+			searchFrom = pos + 1
+		}
+	}
+	if lineStart >= 0 {
+		return trimStreamEOL(data, lineStart), true
+	}
+	if first >= 0 {
+		return first, true
+	}
+	return 0, false
+}
+
+// trimStreamEOL は endstream の直前にある改行1つ分を除いたデータ長を返す
+func trimStreamEOL(data []byte, pos int) int {
+	if pos > 0 && data[pos-1] == '\n' {
+		pos--
+	}
+	if pos > 0 && data[pos-1] == '\r' {
+		pos--
+	}
+	return pos
+}
+
+// recoverStreamData は /Length が使えない場合に endstream の位置からデータを読み込む
+func (p *PDFParser) recoverStreamData(ref PDFRef, offset int64) ([]byte, error) {
+	if p.streamLengthPolicy == StreamLengthStrict {
+		return nil, fmt.Errorf("%w: invalid /Length in object %d", ErrParserStreamLengthError, ref)
+	}
+	actual, found := p.findEndstream(offset)
+	if !found {
+		return nil, fmt.Errorf("%w: endstream not found in object %d", ErrParserStreamLengthError, ref)
+	}
+	p.logger.Warn("Invalid /Length, recovered from endstream", "ref", ref, "recovered", actual)
+	return p.readStreamBytes(offset, actual)
+}
+
+// lengthWithinFile は offset から length バイトがファイル内に収まるかを返す
+func (p *PDFParser) lengthWithinFile(offset int64, length int) bool {
+	size, err := p.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return true
+	}
+	return offset+int64(length) <= size
+}
+
+// readStreamBytes は offset から最大 length バイトを読み込む
+func (p *PDFParser) readStreamBytes(offset int64, length int) ([]byte, error) {
+	if _, err := p.file.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, length)
+	n, err := io.ReadFull(p.file, buf)
+	if err == io.ErrUnexpectedEOF || err == io.EOF {
+		err = nil
+	}
+	return buf[:n], err
+}
+
+// StreamObject は ストリームを持つ間接オブジェクトを表す
+// 辞書はすぐに解析し、データは最初にアクセスされたときに読み込む
+type StreamObject struct {
+	Dict map[string]PDFObject
+
+	ref    PDFRef
+	offset int64 // ストリームデータの開始位置
+	parser *PDFParser
+	raw    []byte
+	loaded bool
+}
+
+// ParseStreamObject は ref のストリームオブジェクトの辞書を読み込む
+func (p *PDFParser) ParseStreamObject(ref PDFRef) (*StreamObject, error) {
+	element, ok := p.xrefTable[ref]
+	if !ok || ref == 0 {
+		return nil, fmt.Errorf("%w: object %d not found", ErrParserReadStreamError, ref)
+	}
+	objectString := loadObject(p.file, element.offsetByte)
+	object, err := parseMetadata(objectString)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrParserParseObjectError, err)
+	}
+	dict, ok := object.(map[string]PDFObject)
+	if !ok {
+		return nil, ErrParserParseObjectError
+	}
+	offset := int64(len(fmt.Sprintf("%v 0 obj", ref))) + element.offsetByte + int64(len(objectString)) + int64(len("stream\n"))
+	return &StreamObject{Dict: dict, ref: ref, offset: offset, parser: p}, nil
+}
+
+// Filter は ストリームの /Filter 名を返す(無ければ空文字)
+// 恒等変換の /Crypt フィルタは取り除いて扱う
+func (s *StreamObject) Filter() string {
+	filter, _ := streamFilter(s.Dict)
+	return filter
+}
+
+// streamFilter は /Filter から恒等変換の /Crypt フィルタを取り除き、残ったフィルタ名を返す
+// フィルタが複数残る場合は空文字を返す
+// 暗号化の処理には対応していないため、/Identity 以外の名前付き暗号フィルタはエラーにする
+// 配列に名前でない要素があるフィルタは、どう展開するか分からないためエラーにする
+func streamFilter(dict map[string]PDFObject) (string, error) {
+	switch filter := dict["Filter"].(type) {
+	case string:
+		if filter == "Crypt" {
+			return "", cryptFilterName(dict["DecodeParms"])
+		}
+		return filter, nil
+	case []PDFObject:
+		params, _ := dict["DecodeParms"].([]PDFObject)
+		var remaining []string
+		for i, f := range filter {
+			name, ok := f.(string)
+			if !ok {
+				return "", fmt.Errorf("%w: /Filter entry %v is not a name", ErrParserParseObjectError, f)
+			}
+			if name != "Crypt" {
+				remaining = append(remaining, name)
+				continue
+			}
+			var param PDFObject
+			if i < len(params) {
+				param = params[i]
+			}
+			if err := cryptFilterName(param); err != nil {
+				return "", err
+			}
+		}
+		if len(remaining) == 1 {
+			return remaining[0], nil
+		}
+	}
+	return "", nil
+}
+
+// cryptFilterName は /Crypt フィルタの /DecodeParms の /Name が恒等変換 (/Identity、省略時も同じ) かを確認する
+func cryptFilterName(params PDFObject) error {
+	dict, _ := params.(map[string]PDFObject)
+	name, _ := dict["Name"].(string)
+	if name == "" || name == "Identity" {
+		return nil
+	}
+	return fmt.Errorf("%w: crypt filter %s", ErrStreamEncrypted, name)
+}
+
+// Raw は フィルタを適用していないストリームデータを返す
+// 暗号化されたストリームは展開できないため、エラーを返す
+func (s *StreamObject) Raw() ([]byte, error) {
+	if s.loaded {
+		return s.raw, nil
+	}
+	if _, err := streamFilter(s.Dict); err != nil {
+		return nil, fmt.Errorf("object %d: %w", s.ref, err)
+	}
+	p := s.parser
+	length, ok := p.streamLength(s.Dict)
+	var err error
+	if !ok || !p.lengthWithinFile(s.offset, length) {
+		// /Length が無い・不正な場合は endstream から長さを復元する
+		s.raw, err = p.recoverStreamData(s.ref, s.offset)
+	} else {
+		s.raw, err = p.readStreamData(s.ref, s.offset, length)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.loaded = true
+	return s.raw, nil
+}
+
+// Decoded は FlateDecode を展開したストリームデータを返す
+// 対応していないフィルタの場合は Raw と同じデータを返す
+func (s *StreamObject) Decoded() ([]byte, error) {
+	raw, err := s.Raw()
+	if err != nil {
+		return nil, err
+	}
+	if s.Filter() == "FlateDecode" {
+		data, err := deCompressStream(raw)
+		if err != nil && len(data) > 0 && s.parser != nil {
+			// 末尾が壊れたストリームも、展開できた部分は使う
+			s.parser.logger.Warn("Stream is truncated", "ref", s.ref, "err", err)
+			return data, nil
+		}
+		return data, err
+	}
+	return raw, nil
+}
+
+// Reader は ストリームデータ(フィルタ適用済み)の Reader を返す
+func (s *StreamObject) Reader() (io.Reader, error) {
+	data, err := s.Decoded()
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
@@ -0,0 +1,211 @@
+package parse
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// newObjectsParser は objects を 1 番から順に並べたファイルと相互参照表を持つパーサーを返す
+func newObjectsParser(policy StreamLengthPolicy, objects ...string) *PDFParser {
+	var data bytes.Buffer
+	xrefTable := make(map[PDFRef]XRefTableElement)
+	for i, object := range objects {
+		ref := PDFRef(i + 1)
+		xrefTable[ref] = XRefTableElement{ObjNum: ref, offsetByte: int64(data.Len())}
+		data.WriteString(object)
+	}
+	return &PDFParser{
+		file:               nopFile{bytes.NewReader(data.Bytes())},
+		xrefTable:          xrefTable,
+		streamLengthPolicy: policy,
+		logger:             quietLogger(),
+	}
+}
+
+// TestStreamLengthRecovery は /Length が無い・不正な場合に endstream の位置からストリームデータを復元することを確かめる
+func TestStreamLengthRecovery(t *testing.T) {
+	// 64KiB ごとの読み込みの境界を endstream がまたぐデータ
+	straddling := strings.Repeat("x", endstreamScanChunkSize-4)
+
+	for _, test := range []struct {
+		name    string
+		policy  StreamLengthPolicy
+		objects []string
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "missing length",
+			objects: []string{"1 0 obj\n<< >>\nstream\nhello\nendstream\nendobj\n"},
+			want:    "hello",
+		},
+		{
+			name: "indirect length",
+			objects: []string{
+				"1 0 obj\n<< /Length 2 0 R >>\nstream\nhello\nendstream\nendobj\n",
+				"2 0 obj\n5\nendobj\n",
+			},
+			want: "hello",
+		},
+		{
+			name: "indirect length that is not a number",
+			objects: []string{
+				"1 0 obj\n<< /Length 2 0 R >>\nstream\nhello\nendstream\nendobj\n",
+				"2 0 obj\n(five)\nendobj\n",
+			},
+			want: "hello",
+		},
+		{
+			name:    "length past end of file",
+			objects: []string{"1 0 obj\n<< /Length 999999 >>\nstream\nhello\nendstream\nendobj\n"},
+			want:    "hello",
+		},
+		{
+			name:    "endstream inside the data",
+			objects: []string{"1 0 obj\n<< >>\nstream\na endstream b\nendstream\nc\nendstream\nendobj\n"},
+			want:    "a endstream b\nendstream\nc",
+		},
+		{
+			name:    "CRLF before endstream",
+			objects: []string{"1 0 obj\n<< >>\nstream\nhello\r\nendstream\r\nendobj\r\n"},
+			want:    "hello",
+		},
+		{
+			name:    "endstream across the scan chunk boundary",
+			objects: []string{"1 0 obj\n<< >>\nstream\n" + straddling + "\nendstream\nendobj\n"},
+			want:    straddling,
+		},
+		{
+			name:    "short length with auto policy",
+			policy:  StreamLengthAuto,
+			objects: []string{"1 0 obj\n<< /Length 3 >>\nstream\nhello\nendstream\nendobj\n"},
+			want:    "hello",
+		},
+		{
+			name:   "endstream only in the next object",
+			policy: StreamLengthTolerant,
+			objects: []string{
+				"1 0 obj\n<< >>\nstream\nhello\n",
+				"2 0 obj\n<< >>\nstream\nworld\nendstream\nendobj\n",
+			},
+			wantErr: ErrParserStreamLengthError,
+		},
+		{
+			name:    "missing length with strict policy",
+			policy:  StreamLengthStrict,
+			objects: []string{"1 0 obj\n<< >>\nstream\nhello\nendstream\nendobj\n"},
+			wantErr: ErrParserStreamLengthError,
+		},
+		{
+			name:    "length past end of file with strict policy",
+			policy:  StreamLengthStrict,
+			objects: []string{"1 0 obj\n<< /Length 999999 >>\nstream\nhello\nendstream\nendobj\n"},
+			wantErr: ErrParserStreamLengthError,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			p := newObjectsParser(test.policy, test.objects...)
+			stream, err := p.ParseStreamObject(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw, err := stream.Raw()
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("err = %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(raw) != test.want {
+				t.Errorf("data = %.40q (%d bytes), want %.40q (%d bytes)", raw, len(raw), test.want, len(test.want))
+			}
+		})
+	}
+}
+
+// TestStreamFilter は 恒等変換の /Crypt フィルタを取り除いて残りのフィルタで展開し、
+// /Identity 以外の暗号フィルタを ErrStreamEncrypted、名前でない要素を含む /Filter の配列をエラーにすることを確かめる
+func TestStreamFilter(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write([]byte("hello"))
+	zw.Close()
+	stream := func(dict, data string) string {
+		return fmt.Sprintf("1 0 obj\n<< /Length %d %s >>\nstream\n%s\nendstream\nendobj\n", len(data), dict, data)
+	}
+	for _, test := range []struct {
+		name    string
+		object  string
+		filter  string
+		want    string
+		wantErr error
+	}{
+		{
+			name:   "identity crypt filter",
+			object: stream("/Filter /Crypt", "hello"),
+			want:   "hello",
+		},
+		{
+			name:   "identity crypt filter with decode parms",
+			object: stream("/Filter /Crypt /DecodeParms << /Type /CryptFilterDecodeParms /Name /Identity >>", "hello"),
+			want:   "hello",
+		},
+		{
+			name:    "named crypt filter",
+			object:  stream("/Filter /Crypt /DecodeParms << /Name /StdCF >>", "hello"),
+			wantErr: ErrStreamEncrypted,
+		},
+		{
+			name:    "named crypt filter in an array",
+			object:  stream("/Filter [/Crypt /FlateDecode] /DecodeParms [<< /Name /StdCF >> null]", compressed.String()),
+			wantErr: ErrStreamEncrypted,
+		},
+		{
+			name:   "array with decode parms",
+			object: stream("/Filter [/Crypt /FlateDecode] /DecodeParms [<< /Name /Identity >> << /Predictor 1 >>]", compressed.String()),
+			filter: "FlateDecode",
+			want:   "hello",
+		},
+		{
+			name:   "array without decode parms",
+			object: stream("/Filter [/FlateDecode]", compressed.String()),
+			filter: "FlateDecode",
+			want:   "hello",
+		},
+		{
+			name:    "entry that is not a name",
+			object:  stream("/Filter [/FlateDecode 5]", compressed.String()),
+			wantErr: ErrParserParseObjectError,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := newObjectsParser(StreamLengthStrict, test.object).ParseStreamObject(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := s.Decoded()
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("err = %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.Filter() != test.filter {
+				t.Errorf("Filter() = %q, want %q", s.Filter(), test.filter)
+			}
+			if string(data) != test.want {
+				t.Errorf("data = %q, want %q", data, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package parse
+
+// maxStructDepth は 構造ツリーをたどる深さの上限
+const maxStructDepth = 64
+
+// StructRole は マークコンテンツが属する構造要素と論理構造上の読み順
+type StructRole struct {
+	Role         string // 標準の構造種別 (H1 / P / TD など、/RoleMap で変換済み)
+	StructID     int    // 構造要素の番号 (1 から、同じ要素のテキストをまとめるため)
+	ReadingOrder int    // 論理構造上の読み順 (1 から)
+}
+
+type structKey struct {
+	page PDFRef
+	mcid int
+}
+
+// structRoles は ページとマークコンテンツIDごとの構造要素の情報
+type structRoles map[structKey]StructRole
+
+// lookup は ページ page の MCID に対応する構造要素を返す
+func (r structRoles) lookup(page PDFRef, mcid int) (StructRole, bool) {
+	if r == nil || mcid < 0 {
+		return StructRole{}, false
+	}
+	role, ok := r[structKey{page, mcid}]
+	return role, ok
+}
+
+// structTree は /StructTreeRoot を深さ優先でたどり、マークコンテンツIDに構造種別と読み順を割り当てる
+// 構造ツリーがない場合は nil を返す
+func (p *PDFParser) structTree(structTreeRef PDFRef) (structRoles, error) {
+	if structTreeRef == 0 {
+		return nil, nil
+	}
+	root, err := p.ParseObject(structTreeRef)
+	if err != nil {
+		return nil, err
+	}
+	dict, ok := root.(map[string]PDFObject)
+	if !ok {
+		return nil, nil
+	}
+	roleMap := map[string]string{}
+	if obj, err := p.resolveObject(dict["RoleMap"]); err == nil {
+		if m, ok := obj.(map[string]PDFObject); ok {
+			for k, v := range m {
+				if s, ok := v.(string); ok {
+					roleMap[k] = s
+				}
+			}
+		}
+	}
+	w := &structWalker{
+		p:       p,
+		roleMap: roleMap,
+		roles:   make(structRoles),
+		visited: make(map[string]bool),
+	}
+	if err := w.walkKids(dict["K"], StructRole{}, 0, 0); err != nil {
+		return nil, err
+	}
+	return w.roles, nil
+}
+
+type structWalker struct {
+	p       *PDFParser
+	roleMap map[string]string
+	roles   structRoles
+	visited map[string]bool
+	nextID  int
+	order   int
+}
+
+// walkKids は /K (単一または配列)の子を順にたどる
+// parent は 直接のマークコンテンツIDに割り当てる要素、page はその要素の /Pg
+func (w *structWalker) walkKids(kids PDFObject, parent StructRole, page PDFRef, depth int) error {
+	if depth > maxStructDepth {
+		return nil
+	}
+	if array, ok := kids.([]PDFObject); ok {
+		for _, kid := range array {
+			if err := w.walkKid(kid, parent, page, depth); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return w.walkKid(kids, parent, page, depth)
+}
+
+func (w *structWalker) walkKid(kid PDFObject, parent StructRole, page PDFRef, depth int) error {
+	if mcid, ok := kid.(int); ok {
+		w.mark(page, mcid, parent)
+		return nil
+	}
+	if s, ok := kid.(string); ok {
+		if _, isRef := parseRef(s); isRef {
+			if w.visited[s] {
+				// 循環参照
+				return nil
+			}
+			w.visited[s] = true
+		}
+	}
+	obj, err := w.p.resolveObject(kid)
+	if err != nil {
+		return err
+	}
+	dict, ok := obj.(map[string]PDFObject)
+	if !ok {
+		return nil
+	}
+	if pg, ok := dict["Pg"].(string); ok {
+		if ref, ok := parseRef(pg); ok {
+			page = ref
+		}
+	}
+	switch dict["Type"] {
+	case "MCR":
+		// マークコンテンツ参照
+		if mcid, ok := dict["MCID"].(int); ok {
+			w.mark(page, mcid, parent)
+		}
+		return nil
+	case "OBJR":
+		// 注釈などのオブジェクト参照はテキストを持たない
+		return nil
+	}
+	role, _ := dict["S"].(string)
+	w.nextID++
+	elem := StructRole{Role: w.standardRole(role), StructID: w.nextID}
+	return w.walkKids(dict["K"], elem, page, depth+1)
+}
+
+// mark は マークコンテンツに要素と次の読み順を割り当てる
+func (w *structWalker) mark(page PDFRef, mcid int, elem StructRole) {
+	if page == 0 || elem.StructID == 0 {
+		return
+	}
+	key := structKey{page, mcid}
+	if _, ok := w.roles[key]; ok {
+		return
+	}
+	w.order++
+	elem.ReadingOrder = w.order
+	w.roles[key] = elem
+}
+
+// standardRole は /RoleMap をたどり独自の構造種別を標準の種別に変換する
+func (w *structWalker) standardRole(role string) string {
+	for i := 0; i < maxStructDepth; i++ {
+		mapped, ok := w.roleMap[role]
+		if !ok || mapped == role {
+			break
+		}
+		role = mapped
+	}
+	return role
+}
@@ -0,0 +1,134 @@
+package parse
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// TextNormalization は 抽出したテキストに適用するUnicode正規化形式
+type TextNormalization string
+
+const (
+	TextNormalizationNone TextNormalization = ""
+	TextNormalizationNFC  TextNormalization = "nfc"
+)
+
+// TextOptions は 送信前にテキストへ適用する処理
+// 検索・索引用途で一貫した照合ができるようにする
+type TextOptions struct {
+	Normalization TextNormalization
+	StripControl  bool // タブ・改行以外の制御文字を取り除く
+}
+
+// Apply は オプションに従ってテキストを正規化する
+func (o TextOptions) Apply(text string) string {
+	if o.StripControl {
+		text = strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) && r != '\t' && r != '\n' {
+				return -1
+			}
+			return r
+		}, text)
+	}
+	if o.Normalization == TextNormalizationNFC {
+		text = norm.NFC.String(text)
+	}
+	return text
+}
+
+// lineBaselineTolerance は 同じ行とみなすベースラインの差(フォントサイズに対する割合)
+const lineBaselineTolerance = 0.5
+
+// ExtractText は ページのテキストを読み順 (上から下、左から右) に並べたプレーンテキストとして返す
+// 離れたテキストの間には空白を、行の間には改行を補う。チャンクの送信は行わない
+func (p *PDFParser) ExtractText(pageNum int) (string, error) {
+	if len(p.pageQueue) == 0 {
+		c, err := p.GetCatalog()
+		if err != nil {
+			return "", err
+		}
+		if err := p.loadPageObject(*c); err != nil {
+			return "", err
+		}
+	}
+	page, err := p.ExtractPage(pageNum)
+	if err != nil {
+		return "", err
+	}
+	tc, _, _, err := p.ExtractPageContents(page.ContentsRef, page.ResourcesRef, page.PageHeight)
+	if errors.Is(err, ErrOperatorBudgetExceeded) {
+		// 打ち切ったページはそこまでのテキストとエラーを返す
+		return p.textOptions.Apply(plainText(tc)), err
+	}
+	if err != nil {
+		return "", err
+	}
+	return p.textOptions.Apply(plainText(tc)), nil
+}
+
+// plainText は テキストコマンドを行ごとにまとめ、空白と改行を補って連結する
+func plainText(commands []TextCommand) string {
+	lines := textLines(commands)
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		for j, cmd := range line {
+			text := strings.Join(cmd.Text, "")
+			if j > 0 {
+				prev := line[j-1]
+				if cmd.X-(prev.X+approxTextWidth(prev)) > math.Abs(prev.FontSize)*coalesceSpaceGap &&
+					!endsWithSpace(prev.Text) && !strings.HasPrefix(text, " ") {
+					b.WriteString(" ")
+				}
+			}
+			b.WriteString(text)
+		}
+	}
+	return b.String()
+}
+
+// textLines は テキストをベースラインの近いもの同士で行にまとめ、行を上から、行内を左から並べる
+func textLines(commands []TextCommand) [][]TextCommand {
+	texts := make([]TextCommand, 0, len(commands))
+	for _, cmd := range commands {
+		if strings.Join(cmd.Text, "") != "" {
+			texts = append(texts, cmd)
+		}
+	}
+	sort.SliceStable(texts, func(i, j int) bool { return texts[i].Y < texts[j].Y })
+	var lines [][]TextCommand
+	for _, cmd := range texts {
+		if n := len(lines); n > 0 {
+			last := lines[n-1][len(lines[n-1])-1]
+			if math.Abs(cmd.Y-last.Y) <= lineTolerance(last, cmd) {
+				lines[n-1] = append(lines[n-1], cmd)
+				continue
+			}
+		}
+		lines = append(lines, []TextCommand{cmd})
+	}
+	for _, line := range lines {
+		sort.SliceStable(line, func(i, j int) bool { return line[i].X < line[j].X })
+	}
+	return lines
+}
+
+// lineTolerance は 2つのテキストを同じ行とみなすベースラインの差の上限
+func lineTolerance(a, b TextCommand) float64 {
+	return math.Max(math.Abs(a.FontSize), math.Abs(b.FontSize)) * lineBaselineTolerance
+}
+
+// approxTextWidth は テキストの幅を返す。グリフ幅が分からない場合は1文字を半角として見積もる
+func approxTextWidth(cmd TextCommand) float64 {
+	if cmd.Width > 0 {
+		return cmd.Width
+	}
+	return float64(len([]rune(strings.Join(cmd.Text, "")))) * math.Abs(cmd.FontSize) * 0.5
+}
@@ -0,0 +1,138 @@
+package parse
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// textPDF は Helvetica (埋め込みなし) で content を描く1ページの PDF を組み立てる
+func textPDF(content string) []byte {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+	buf.WriteString("%PDF-1.7\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object("<< /Type /Pages /Kids [3 0 R] /Count 1 >>")
+	object("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources 5 0 R /Contents 4 0 R >>")
+	object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+	object("<< /Font << /F1 6 0 R >> >>")
+	widths := strings.TrimSpace(strings.Repeat("500 ", 0x7E-0x20+1))
+	object(fmt.Sprintf("<< /Type /Font /Subtype /TrueType /BaseFont /Helvetica /FirstChar 32 /LastChar 126 /Widths [%s] /ToUnicode 7 0 R >>", widths))
+	object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(asciiToUnicode), asciiToUnicode))
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
+
+// asciiToUnicode は 0x20〜0x7E の文字コードをそのまま Unicode に対応させる ToUnicode CMap
+const asciiToUnicode = `/CIDInit /ProcSet findresource begin
+12 dict begin
+begincmap
+/CMapName /ASCII def
+/CMapType 2 def
+1 begincodespacerange
+<00><FF>
+endcodespacerange
+1 beginbfrange
+<20><7E><0020>
+endbfrange
+endcmap
+end
+end`
+
+// newTextParser は content を描く1ページの PDF を開いたパーサーを返す
+func newTextParser(t *testing.T, content string, config ParserConfig) *PDFParser {
+	t.Helper()
+	data := textPDF(content)
+	config.Logger = quietLogger()
+	p, err := NewPDFParserWithConfig(func() (IPDFFile, error) { return nopFile{bytes.NewReader(data)}, nil }, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// TestExtractText は ページのテキストを上の行から、行内を左から並べ、離れたテキストの間に空白を補うことを確かめる
+// 12pt で 1 文字の幅は 6pt、空白を補う隙間は 1.8pt より広い場合
+func TestExtractText(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "lines from top to bottom",
+			content: "BT /F1 12 Tf 1 0 0 1 100 680 Tm (Second) Tj 1 0 0 1 100 700 Tm (First) Tj ET",
+			want:    "First\nSecond",
+		},
+		{
+			name:    "left to right within a line",
+			content: "BT /F1 12 Tf 1 0 0 1 200 700 Tm (world) Tj 1 0 0 1 100 700 Tm (Hello) Tj ET",
+			want:    "Hello world",
+		},
+		{
+			name:    "baseline jitter stays on one line",
+			content: "BT /F1 12 Tf 1 0 0 1 100 700 Tm (Hello) Tj 1 0 0 1 200 701.5 Tm (world) Tj ET",
+			want:    "Hello world",
+		},
+		{
+			name:    "no space across a small gap",
+			content: "BT /F1 12 Tf 1 0 0 1 100 700 Tm (Hel) Tj 1 0 0 1 119 700 Tm (lo) Tj ET",
+			want:    "Hello",
+		},
+		{
+			name:    "space across a gap wider than coalesceSpaceGap",
+			content: "BT /F1 12 Tf 1 0 0 1 100 700 Tm (Hel) Tj 1 0 0 1 121 700 Tm (lo) Tj ET",
+			want:    "Hel lo",
+		},
+		{
+			name:    "no extra space after a trailing space",
+			content: "BT /F1 12 Tf 1 0 0 1 100 700 Tm (Hello ) Tj 1 0 0 1 200 700 Tm (world) Tj ET",
+			want:    "Hello world",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			text, err := newTextParser(t, test.content, ParserConfig{}).ExtractText(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if text != test.want {
+				t.Errorf("text = %q, want %q", text, test.want)
+			}
+		})
+	}
+}
+
+// TestExtractTextBudgetExceeded は 演算子の数の上限を超えたページで、そこまでのテキストとエラーを返すことを確かめる
+func TestExtractTextBudgetExceeded(t *testing.T) {
+	// BT Tf Tm Tj Tm Tj ET のうち、4つ目の Tj までを実行する
+	content := "BT /F1 12 Tf 1 0 0 1 100 700 Tm (First) Tj 1 0 0 1 100 680 Tm (Second) Tj ET"
+	p := newTextParser(t, content, ParserConfig{OperatorBudget: OperatorBudget{MaxOperators: 4}})
+	text, err := p.ExtractText(1)
+	if !errors.Is(err, ErrOperatorBudgetExceeded) {
+		t.Fatalf("err = %v, want %v", err, ErrOperatorBudgetExceeded)
+	}
+	if text != "First" {
+		t.Errorf("text = %q, want %q", text, "First")
+	}
+}
+
+// TestExtractTextPageOutOfRange は 1 より小さいページと最後のページより後のページでエラーを返すことを確かめる
+func TestExtractTextPageOutOfRange(t *testing.T) {
+	p := newTextParser(t, "BT /F1 12 Tf 1 0 0 1 100 700 Tm (Hello) Tj ET", ParserConfig{})
+	for _, pageNum := range []int{0, -1, 2} {
+		if _, err := p.ExtractText(pageNum); err == nil {
+			t.Errorf("ExtractText(%d) returned no error", pageNum)
+		}
+	}
+}
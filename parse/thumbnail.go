@@ -0,0 +1,282 @@
+package parse
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ThumbnailPage は サムネイルの描画に使うページの大きさと解析済みのコマンド
+// 座標は上端を原点とするページ座標系
+type ThumbnailPage struct {
+	Width  float64
+	Height float64
+	Texts  []TextCommand
+	Paths  []PathCommand
+	Images []ImageCommand
+}
+
+// PageRasterizer は ページを width x height ピクセルの画像に描画する
+// 標準の描画は簡易的なため、より正確な描画が必要な場合は独自の実装を ParserConfig.Rasterizer に設定する
+type PageRasterizer interface {
+	Rasterize(page ThumbnailPage, width, height int) (image.Image, error)
+}
+
+// SimpleRasterizer は 外部ライブラリを使わない簡易的な描画
+// パスは塗りのみを描き、テキストは範囲を塗りつぶした帯、画像は灰色の矩形として描く
+type SimpleRasterizer struct{}
+
+var (
+	thumbnailImageColor = color.RGBA{0xc8, 0xc8, 0xc8, 0xff}
+	thumbnailTextAlpha  = 0.6
+	bezierSegments      = 8
+)
+
+func (SimpleRasterizer) Rasterize(page ThumbnailPage, width, height int) (image.Image, error) {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	if page.Width <= 0 || page.Height <= 0 {
+		return img, nil
+	}
+	scaleX, scaleY := float64(width)/page.Width, float64(height)/page.Height
+
+	// Z の順に重ねて描く
+	type drawable struct {
+		z    int64
+		draw func()
+	}
+	var items []drawable
+	for _, cmd := range page.Paths {
+		if cmd.FillRule == "" {
+			// ストロークのみの線は縮小すると見えないため描かない
+			continue
+		}
+		cmd := cmd
+		items = append(items, drawable{cmd.Z, func() {
+			fill := parseHexColor(cmd.FillColor, color.RGBA{0, 0, 0, 0xff})
+			polygons := flattenPath(cmd.Path, scaleX, scaleY)
+			fillPolygons(img, polygons, cmd.FillRule == "evenodd", fill, alphaOr(cmd.FillAlpha))
+		}})
+	}
+	for _, cmd := range page.Images {
+		cmd := cmd
+		items = append(items, drawable{cmd.Z, func() {
+			r := imageBounds(cmd, page.Height)
+			fillRect(img, r, scaleX, scaleY, thumbnailImageColor, 1)
+		}})
+	}
+	for _, cmd := range page.Texts {
+		cmd := cmd
+		items = append(items, drawable{cmd.Z, func() {
+			fill := parseHexColor(cmd.Color, color.RGBA{0, 0, 0, 0xff})
+			fillRect(img, textBounds(cmd), scaleX, scaleY, fill, thumbnailTextAlpha*alphaOr(cmd.FillAlpha))
+		}})
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].z < items[j].z })
+	for _, item := range items {
+		item.draw()
+	}
+	return img, nil
+}
+
+// alphaOr は 不透明度を返す (未設定の0は不透明として扱う)
+func alphaOr(alpha float64) float64 {
+	if alpha <= 0 || alpha > 1 {
+		return 1
+	}
+	return alpha
+}
+
+// parseHexColor は #rrggbb 形式の色を読む
+func parseHexColor(s string, fallback color.RGBA) color.RGBA {
+	if len(s) != 7 || s[0] != '#' {
+		return fallback
+	}
+	v, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return fallback
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}
+}
+
+// blendPixel は 画素に c を alpha の不透明度で重ねる
+func blendPixel(img *image.RGBA, x, y int, c color.RGBA, alpha float64) {
+	if !(image.Point{x, y}.In(img.Rect)) {
+		return
+	}
+	i := img.PixOffset(x, y)
+	for k, v := range []uint8{c.R, c.G, c.B} {
+		img.Pix[i+k] = uint8(float64(img.Pix[i+k])*(1-alpha) + float64(v)*alpha)
+	}
+}
+
+func fillRect(img *image.RGBA, r Rect, scaleX, scaleY float64, c color.RGBA, alpha float64) {
+	x0, x1 := int(math.Floor(r.X0*scaleX)), int(math.Ceil(r.X1*scaleX))
+	y0, y1 := int(math.Floor(r.Y0*scaleY)), int(math.Ceil(r.Y1*scaleY))
+	for y := max(y0, 0); y < min(y1, img.Rect.Dy()); y++ {
+		for x := max(x0, 0); x < min(x1, img.Rect.Dx()); x++ {
+			blendPixel(img, x, y, c, alpha)
+		}
+	}
+}
+
+// flattenPath は SVG 形式のパスを折れ線の多角形に変換し、ピクセル座標に拡大・縮小する
+func flattenPath(path string, scaleX, scaleY float64) [][][2]float64 {
+	fields := strings.Fields(strings.NewReplacer("M", " M ", "L", " L ", "C", " C ", "Z", " Z ").Replace(path))
+	var polygons [][][2]float64
+	var current [][2]float64
+	var cx, cy float64
+	closeCurrent := func() {
+		if len(current) > 2 {
+			polygons = append(polygons, current)
+		}
+		current = nil
+	}
+	numbers := func(i, n int) ([]float64, bool) {
+		if i+n >= len(fields) {
+			return nil, false
+		}
+		values := make([]float64, n)
+		for k := range values {
+			v, err := strconv.ParseFloat(fields[i+1+k], 64)
+			if err != nil {
+				return nil, false
+			}
+			values[k] = v
+		}
+		return values, true
+	}
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "M":
+			v, ok := numbers(i, 2)
+			if !ok {
+				return polygons
+			}
+			closeCurrent()
+			cx, cy = v[0]*scaleX, v[1]*scaleY
+			current = append(current, [2]float64{cx, cy})
+			i += 2
+		case "L":
+			v, ok := numbers(i, 2)
+			if !ok {
+				return polygons
+			}
+			cx, cy = v[0]*scaleX, v[1]*scaleY
+			current = append(current, [2]float64{cx, cy})
+			i += 2
+		case "C":
+			v, ok := numbers(i, 6)
+			if !ok {
+				return polygons
+			}
+			x0, y0 := cx, cy
+			for s := 1; s <= bezierSegments; s++ {
+				t := float64(s) / float64(bezierSegments)
+				u := 1 - t
+				cx = u*u*u*x0 + 3*u*u*t*v[0]*scaleX + 3*u*t*t*v[2]*scaleX + t*t*t*v[4]*scaleX
+				cy = u*u*u*y0 + 3*u*u*t*v[1]*scaleY + 3*u*t*t*v[3]*scaleY + t*t*t*v[5]*scaleY
+				current = append(current, [2]float64{cx, cy})
+			}
+			i += 6
+		case "Z":
+			if len(current) > 0 {
+				cx, cy = current[0][0], current[0][1]
+			}
+			closeCurrent()
+			current = append(current, [2]float64{cx, cy})
+		}
+	}
+	closeCurrent()
+	return polygons
+}
+
+// fillPolygons は 多角形を走査線ごとに塗りつぶす
+func fillPolygons(img *image.RGBA, polygons [][][2]float64, evenOdd bool, c color.RGBA, alpha float64) {
+	type crossing struct {
+		x   float64
+		dir int
+	}
+	for y := 0; y < img.Rect.Dy(); y++ {
+		sy := float64(y) + 0.5
+		var crossings []crossing
+		for _, polygon := range polygons {
+			for i := range polygon {
+				a, b := polygon[i], polygon[(i+1)%len(polygon)]
+				if (a[1] <= sy) == (b[1] <= sy) {
+					continue
+				}
+				x := a[0] + (sy-a[1])/(b[1]-a[1])*(b[0]-a[0])
+				dir := 1
+				if b[1] < a[1] {
+					dir = -1
+				}
+				crossings = append(crossings, crossing{x, dir})
+			}
+		}
+		sort.Slice(crossings, func(i, j int) bool { return crossings[i].x < crossings[j].x })
+		winding := 0
+		for i := 0; i+1 < len(crossings); i++ {
+			winding += crossings[i].dir
+			inside := winding != 0
+			if evenOdd {
+				inside = (i+1)%2 == 1
+			}
+			if !inside {
+				continue
+			}
+			for x := max(int(math.Round(crossings[i].x)), 0); x < min(int(math.Round(crossings[i+1].x)), img.Rect.Dx()); x++ {
+				blendPixel(img, x, y, c, alpha)
+			}
+		}
+	}
+}
+
+// thumbnailSize は ページの縦横比を保ったまま長辺を maxSize ピクセルにした大きさを返す
+func thumbnailSize(pageWidth, pageHeight float64, maxSize int) (int, int) {
+	if pageWidth <= 0 || pageHeight <= 0 {
+		return maxSize, maxSize
+	}
+	scale := float64(maxSize) / math.Max(pageWidth, pageHeight)
+	return max(1, int(math.Round(pageWidth*scale))), max(1, int(math.Round(pageHeight*scale)))
+}
+
+// renderThumbnail は ページのサムネイルを PNG として返す
+func renderThumbnail(rasterizer PageRasterizer, page ThumbnailPage, maxSize int) ([]byte, int, int, error) {
+	width, height := thumbnailSize(page.Width, page.Height, maxSize)
+	img, err := rasterizer.Rasterize(page, width, height)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, 0, 0, err
+	}
+	return buf.Bytes(), width, height, nil
+}
+
+// sendThumbnail は ページのサムネイルを描画して送る。描画に失敗した場合は警告のみ出す
+func (p *PDFParser) sendThumbnail(page int64, content ThumbnailPage, insertData func(data ParsedData), warnings *warningLimiter) {
+	rasterizer := p.rasterizer
+	if rasterizer == nil {
+		rasterizer = SimpleRasterizer{}
+	}
+	data, width, height, err := renderThumbnail(rasterizer, content, p.thumbnailSize)
+	if err != nil {
+		warnings.warn(page, "Failed to render thumbnail: %v", err)
+		return
+	}
+	insertData(&ParsedThumbnail{
+		Page:   page,
+		Width:  width,
+		Height: height,
+		Data:   data,
+	})
+}
@@ -0,0 +1,185 @@
+package parse
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"math"
+)
+
+// ImageTile は 分割した画像のタイルの位置
+// X, Y は元の画像の左上からのピクセル単位の位置
+type ImageTile struct {
+	X           int `json:"x"`
+	Y           int `json:"y"`
+	Column      int `json:"column"`
+	Row         int `json:"row"`
+	Columns     int `json:"columns"`
+	Rows        int `json:"rows"`
+	ImageWidth  int `json:"imageWidth"`
+	ImageHeight int `json:"imageHeight"`
+}
+
+// tileJPEGQuality は JPEG のタイルを再圧縮する品質
+const tileJPEGQuality = 90
+
+// tileImage は ピクセル数が maxPixels を超える画像をタイルに分割する
+// 各タイルの配置行列は元の画像の配置からタイルの範囲を切り出したもので、タイルを知らないクライアントでも正しい位置に描画される
+// 分割できない形式の場合は元の画像のみとエラーを返す
+func tileImage(img *ParsedImage, maxPixels int) ([]*ParsedImage, error) {
+	width, height := int(img.Width), int(img.Height)
+	if maxPixels <= 0 || width*height <= maxPixels {
+		return []*ParsedImage{img}, nil
+	}
+	side := max(int(math.Sqrt(float64(maxPixels))), 1)
+	columns := (width + side - 1) / side
+	rows := (height + side - 1) / side
+
+	crop, err := newImageCropper(img)
+	if err != nil {
+		return []*ParsedImage{img}, err
+	}
+
+	tiles := make([]*ParsedImage, 0, columns*rows)
+	for row := 0; row < rows; row++ {
+		for column := 0; column < columns; column++ {
+			r := image.Rect(column*side, row*side, min((column+1)*side, width), min((row+1)*side, height))
+			data, mask, err := crop(r)
+			if err != nil {
+				return []*ParsedImage{img}, err
+			}
+			tile := *img
+			tile.Data = data
+			tile.MaskData = mask
+			tile.Width = float64(r.Dx())
+			tile.Height = float64(r.Dy())
+			tile.Matrix = tileMatrix(img.Matrix, r, width, height)
+			tile.X, tile.Y = tile.Matrix[4], tile.Matrix[5]
+			tile.DW, tile.DH = matrixScale(tile.Matrix)
+			tile.Tile = &ImageTile{
+				X:           r.Min.X,
+				Y:           r.Min.Y,
+				Column:      column,
+				Row:         row,
+				Columns:     columns,
+				Rows:        rows,
+				ImageWidth:  width,
+				ImageHeight: height,
+			}
+			tiles = append(tiles, &tile)
+		}
+	}
+	return tiles, nil
+}
+
+// imageCropper は 画像と同じ大きさのマスクから、ピクセル単位の範囲を切り出す
+type imageCropper func(r image.Rectangle) (data, mask []byte, err error)
+
+// newImageCropper は 画像の形式に合わせて範囲を切り出す関数を作る
+// 8bit のサンプル列 (png / mask) は zlib 圧縮し直し、JPEG は再圧縮する
+func newImageCropper(img *ParsedImage) (imageCropper, error) {
+	width, height := int(img.Width), int(img.Height)
+	var mask []byte
+	if len(img.MaskData) > 0 {
+		samples, err := inflateSamples(img.MaskData)
+		if err != nil {
+			return nil, fmt.Errorf("mask: %w", err)
+		}
+		if len(samples) != width*height {
+			return nil, errors.New("mask size differs from image")
+		}
+		mask = samples
+	}
+	cropMask := func(r image.Rectangle) []byte {
+		if mask == nil {
+			return nil
+		}
+		return deflateSamples(cropSamples(mask, width, 1, r))
+	}
+
+	switch img.Ext {
+	case "png", "mask":
+		samples, err := inflateSamples(img.Data)
+		if err != nil {
+			return nil, err
+		}
+		components := len(samples) / (width * height)
+		if img.BitsPerComponent != 8 || components == 0 || len(samples) != width*height*components {
+			return nil, fmt.Errorf("unsupported sample layout (%d bits, %d bytes)", img.BitsPerComponent, len(samples))
+		}
+		return func(r image.Rectangle) ([]byte, []byte, error) {
+			return deflateSamples(cropSamples(samples, width, components, r)), cropMask(r), nil
+		}, nil
+	case "jpg":
+		decoded, err := jpeg.Decode(bytes.NewReader(img.Data))
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := decoded.(*image.CMYK); ok {
+			// 再圧縮で Adobe の反転情報が失われるため切り出さない
+			return nil, errors.New("CMYK JPEG")
+		}
+		sub, ok := decoded.(interface {
+			SubImage(r image.Rectangle) image.Image
+		})
+		if !ok || decoded.Bounds().Dx() != width || decoded.Bounds().Dy() != height {
+			return nil, errors.New("unexpected JPEG size")
+		}
+		origin := decoded.Bounds().Min
+		return func(r image.Rectangle) ([]byte, []byte, error) {
+			var buf bytes.Buffer
+			err := jpeg.Encode(&buf, sub.SubImage(r.Add(origin)), &jpeg.Options{Quality: tileJPEGQuality})
+			return buf.Bytes(), cropMask(r), err
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q", img.Ext)
+	}
+}
+
+// tileMatrix は 画像の配置行列から、タイルの範囲 r を単位正方形に対応させる配置行列を求める
+// 画像空間の単位正方形では、画像の1行目が上端 (v = 1) に来る
+func tileMatrix(m [6]float64, r image.Rectangle, width, height int) [6]float64 {
+	sx := float64(r.Dx()) / float64(width)
+	sy := float64(r.Dy()) / float64(height)
+	tx := float64(r.Min.X) / float64(width)
+	ty := 1 - float64(r.Max.Y)/float64(height)
+	return [6]float64{
+		sx * m[0],
+		sx * m[1],
+		sy * m[2],
+		sy * m[3],
+		tx*m[0] + ty*m[2] + m[4],
+		tx*m[1] + ty*m[3] + m[5],
+	}
+}
+
+// cropSamples は 1行 width ピクセル・1ピクセル components バイトのサンプル列から r の範囲を切り出す
+func cropSamples(samples []byte, width, components int, r image.Rectangle) []byte {
+	out := make([]byte, 0, r.Dx()*r.Dy()*components)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		start := (y*width + r.Min.X) * components
+		out = append(out, samples[start:start+r.Dx()*components]...)
+	}
+	return out
+}
+
+func inflateSamples(data []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+func deflateSamples(samples []byte) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(samples)
+	zw.Close()
+	return buf.Bytes()
+}
@@ -0,0 +1,1341 @@
+package parse
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type TokenObject struct {
+	resources *ResourceStack
+	loadForm  func(ref PDFRef) (*FormXObject, error)
+	contents  string
+	budget    *operatorCounter // 実行する演算子の上限 (nil の場合は上限なし)
+	logger    *slog.Logger
+	// 展開中の Form XObject (外側から順) と、これまでに展開した回数
+	forms      []PDFRef
+	expansions int
+}
+
+type ITokenObject interface {
+	GetFonts() map[byte]string
+}
+
+type GraphicsState struct {
+	CTM         Matrix     // 現在の変換マトリックス
+	StrokeAlpha float64    // ストローク不透明度（CA）
+	FillAlpha   float64    // 塗り不透明度（ca）
+	BlendMode   string     // ブレンドモード（BM）
+	SoftMask    *SoftMask  // ソフトマスク（SMask）
+	ClipPaths   []ClipPath // クリッピングパス（W / W*）
+}
+
+// Clip は クリッピングパスを追加する
+// q でシャローコピーされた状態とスライスを共有しないよう新しいスライスを作る
+func (gs *GraphicsState) Clip(path, fillRule string) {
+	clips := make([]ClipPath, 0, len(gs.ClipPaths)+1)
+	clips = append(clips, gs.ClipPaths...)
+	gs.ClipPaths = append(clips, ClipPath{Path: path, FillRule: fillRule})
+}
+
+// innermostClipPath は 最後に設定されたクリッピングパスを返す
+func (gs *GraphicsState) innermostClipPath() string {
+	if len(gs.ClipPaths) == 0 {
+		return ""
+	}
+	return gs.ClipPaths[len(gs.ClipPaths)-1].Path
+}
+
+// ExtGState は gs 演算子で適用されるグラフィックス状態パラメータ辞書を表す
+// 辞書に含まれないパラメータは nil / 空文字のまま
+type ExtGState struct {
+	StrokeAlpha *float64
+	FillAlpha   *float64
+	BlendMode   string
+	SoftMask    *SoftMask
+}
+
+// Apply は ExtGState に含まれるパラメータをグラフィックス状態に反映する
+func (gs *GraphicsState) Apply(e ExtGState) {
+	if e.StrokeAlpha != nil {
+		gs.StrokeAlpha = *e.StrokeAlpha
+	}
+	if e.FillAlpha != nil {
+		gs.FillAlpha = *e.FillAlpha
+	}
+	if e.BlendMode != "" {
+		gs.BlendMode = e.BlendMode
+	}
+	if e.SoftMask != nil {
+		if e.SoftMask.IsNone() {
+			gs.SoftMask = nil
+		} else {
+			gs.SoftMask = e.SoftMask
+		}
+	}
+}
+
+// 3x3マトリックスを表す構造体
+type Matrix [3][3]float64
+
+func NewGraphicsState() *GraphicsState {
+	return &GraphicsState{
+		CTM:         IdentityMatrix(),
+		StrokeAlpha: 1,
+		FillAlpha:   1,
+		BlendMode:   "Normal",
+	}
+}
+
+// ParseFloat は 文字列を数値に変換する (数値でない場合は 0)
+func ParseFloat(str string) float64 {
+	value, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+func (m Matrix) Multiply(n Matrix) Matrix {
+	var result Matrix
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			var sum float64
+			for k := 0; k < 3; k++ {
+				sum += m[i][k] * n[k][j]
+			}
+			result[i][j] = sum
+		}
+	}
+	return result
+}
+
+// Transform は 点 (x, y) を行列で変換する
+func (m Matrix) Transform(x, y float64) (float64, float64) {
+	return x*m[0][0] + y*m[1][0] + m[2][0], x*m[0][1] + y*m[1][1] + m[2][1]
+}
+
+// Values は 行列を PDF の [a b c d e f] 形式で返す
+func (m Matrix) Values() [6]float64 {
+	return [6]float64{m[0][0], m[0][1], m[1][0], m[1][1], m[2][0], m[2][1]}
+}
+
+// matrixScale は 配置行列 [a b c d e f] が単位正方形の横・縦の辺を変換した長さを返す
+// 回転・反転した画像でも表示される大きさは正の値になる
+func matrixScale(m [6]float64) (float64, float64) {
+	return math.Hypot(m[0], m[1]), math.Hypot(m[2], m[3])
+}
+
+func processTJ(arrayContent string, textState *TextState, graphicsState *GraphicsState, currentZ *int64, fonts map[byte]string, widths map[byte]float64, colorState ColorState, pageHeight float64) (*TextCommand, error) {
+
+	items, err := parsePDFArray(arrayContent)
+	if err != nil {
+		return nil, fmt.Errorf("配列のパースに失敗しました: %w", err)
+	}
+
+	// 最終的なテキストを保持するバッファ
+	var finalStrings []string
+	var codes []int
+	// テキスト空間での表示幅
+	width := 0.0
+
+	for _, item := range items {
+		switch v := item.(type) {
+		case TextToken:
+			finalStrings = append(finalStrings, v...)
+			for range v {
+				codes = append(codes, -1)
+			}
+		case string:
+			// ( ... )形式の文字列なのでparsePDFStringToBytesを適用
+			bytes := parsePDFStringToBytes(v, fonts)
+
+			finalStrings = append(finalStrings, bytes...)
+			codes = append(codes, pdfStringCodes(v)...)
+			width += pdfStringWidth(v, widths, textState)
+
+		case float64:
+			// カーニング処理
+			tx := -v / 1000 * textState.FontSize * (textState.HorizontalScaling / 100)
+			width += tx
+			m := Matrix{
+				{1, 0, 0},
+				{0, 1, 0},
+				{tx, 0, 1},
+			}
+			textState.Tm = textState.Tm.Multiply(m)
+		}
+	}
+	trm := textState.Tm.Multiply(graphicsState.CTM)
+	scaleY := math.Sqrt(trm[1][0]*trm[1][0] + trm[1][1]*trm[1][1])
+	effectiveFontSizeY := textState.FontSize * scaleY
+	return &TextCommand{
+		X:           trm[2][0],
+		Y:           pageHeight - trm[2][1],
+		Z:           *currentZ,
+		Text:        finalStrings,
+		Codes:       codes,
+		Width:       width * textScaleX(trm),
+		FontSize:    effectiveFontSizeY,
+		FontID:      textState.Font,
+		Color:       colorState.FillColor,
+		StrokeAlpha: graphicsState.StrokeAlpha,
+		FillAlpha:   graphicsState.FillAlpha,
+		BlendMode:   graphicsState.BlendMode,
+		ClipPaths:   graphicsState.ClipPaths,
+	}, nil
+}
+
+// テキスト状態を表す構造体
+type TextState struct {
+	Tm                Matrix   // テキストマトリックス
+	Tlm               Matrix   // テキストラインマトリックス
+	Font              string   // フォント名
+	FontSize          float64  // フォントサイズ
+	CharSpacing       float64  // 文字間隔（Tc）
+	WordSpacing       float64  // 単語間隔（Tw）
+	HorizontalScaling float64  // 水平スケーリング（Th）
+	Leading           float64  // リーディング（Tl）
+	Rise              float64  // 上昇量（Trise）
+	Text              []string // テキスト
+	Codes             []int    // Text の文字コード
+	MCID              int      // 最初に表示したテキストのマークコンテンツID (-1 の場合はなし)
+	Width             float64  // Text のテキスト空間での表示幅
+}
+
+type ColorState struct {
+	StrokeColor string
+	FillColor   string
+}
+
+func NewColorState() *ColorState {
+	return &ColorState{
+		StrokeColor: "",
+		FillColor:   "",
+	}
+}
+
+func IdentityMatrix() Matrix {
+	return Matrix{
+		{1, 0, 0},
+		{0, 1, 0},
+		{0, 0, 1},
+	}
+}
+
+// 新しいテキスト状態を作成する関数
+func NewTextState() *TextState {
+	return &TextState{
+		Tm:                IdentityMatrix(),
+		Tlm:               IdentityMatrix(),
+		FontSize:          12,  // デフォルトのフォントサイズ
+		HorizontalScaling: 100, // デフォルトの水平スケーリング（100%）
+		Leading:           0,   // デフォルトのリーディング
+		Rise:              0,   // デフォルトの上昇量
+		CharSpacing:       0,   // デフォルトの文字間隔
+		WordSpacing:       0,   // デフォルトの単語間隔
+		Text:              nil, // テキスト
+		MCID:              -1,
+	}
+}
+
+type PathState struct {
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+	Path   string
+}
+
+func NewPathState() *PathState {
+	return &PathState{
+		X:      0,
+		Y:      0,
+		Width:  0,
+		Height: 0,
+		Path:   "",
+	}
+}
+
+// sumASCII 関数
+func sumASCII(s string) []int {
+	sum := make([]int, 0)
+	byteS := []byte(s)
+	for _, b := range byteS {
+		sum = append(sum, int(b))
+	}
+	return sum
+}
+
+// トークンの種類を定義
+type TokenType int
+
+const (
+	TokenTypeOperator TokenType = iota
+	TokenTypeOperand
+	// tokenTypeFormEnd は 展開した Form XObject の終わりを示す内部用のトークン
+	tokenTypeFormEnd
+)
+
+// トークン構造体
+type Token struct {
+	Value string
+	Type  TokenType
+}
+
+type TextToken []string
+type ByteToken string
+
+func tokenize(content string) ([]Token, error) {
+	var tokens []Token
+	var currentToken []byte
+	inString := false
+	inArray := false
+	escapeNext := false
+
+	// ここでruneではなくバイトで処理する
+	contentBytes := []byte(content)
+	for i := 0; i < len(contentBytes); i++ {
+		c := contentBytes[i]
+
+		if inString {
+			currentToken = append(currentToken, c)
+			if escapeNext {
+				escapeNext = false
+			} else if c == '\\' {
+				escapeNext = true
+			} else if c == ')' {
+				// 文字列終了
+				inString = false
+				// currentTokenは ( ... ) 含めた生バイト列
+				tokens = append(tokens, Token{Value: string(currentToken), Type: TokenTypeOperand})
+				currentToken = currentToken[:0]
+			}
+			continue
+		}
+
+		if inArray {
+			currentToken = append(currentToken, c)
+			if c == ']' {
+				inArray = false
+				tokens = append(tokens, Token{Value: string(currentToken), Type: TokenTypeOperand})
+				currentToken = currentToken[:0]
+			}
+			continue
+		}
+
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			// トークン区切り
+			if len(currentToken) > 0 {
+				tokenValue := string(currentToken)
+				if isOperator(tokenValue) {
+					tokens = append(tokens, Token{Value: tokenValue, Type: TokenTypeOperator})
+				} else {
+					tokens = append(tokens, Token{Value: tokenValue, Type: TokenTypeOperand})
+				}
+				currentToken = currentToken[:0]
+			}
+		case '(':
+			// 文字列開始
+			inString = true
+			currentToken = append(currentToken, c)
+		case '[':
+			inArray = true
+			currentToken = append(currentToken, c)
+		default:
+			currentToken = append(currentToken, c)
+		}
+	}
+
+	if len(currentToken) > 0 {
+		tokenValue := string(currentToken)
+		if isOperator(tokenValue) {
+			tokens = append(tokens, Token{Value: tokenValue, Type: TokenTypeOperator})
+		} else {
+			tokens = append(tokens, Token{Value: tokenValue, Type: TokenTypeOperand})
+		}
+	}
+
+	return tokens, nil
+}
+
+var operators = map[string]bool{
+	"q": true, "Q": true, "cm": true, "BT": true, "ET": true,
+	"Tf": true, "Tr": true, "Ts": true, "Tw": true, "Tc": true,
+	"Tz": true, "TL": true, "Tm": true, "Td": true, "TD": true,
+	"T*": true, "'": true, "\"": true, "Tj": true, "TJ": true,
+	"Do": true, "w": true, "re": true, "m": true, "l": true,
+	"h": true, "f": true, "sc": true, "scn": true, "gs": true,
+	"cs": true, "W": true, "n": true, "f*": true, "c": true,
+	"SC": true, "M": true, "S": true, "CS": true, "ri": true,
+	"b": true, "B": true, "B*": true, "b*": true, "s": true,
+	"W*": true,
+	"rg": true, "RG": true,
+	"K": true, "k": true, "g": true, "G": true, "sh": true,
+	"BI": true, "ID": true, "EI": true,
+	"BMC": true, "BDC": true, "EMC": true, "MP": true,
+	"DP": true, "BX": true, "EX": true,
+}
+
+func isOperator(s string) bool {
+	return operators[s]
+}
+
+// ParsePDFArray 関数
+func parsePDFArray(arrayStr string) ([]interface{}, error) {
+	var items []interface{}
+	inString := false
+	escapeNext := false
+	currentToken := strings.Builder{}
+	arrayStr = strings.TrimSpace(arrayStr)
+
+	// 配列の始まりと終わりのブラケットを削除
+	if !strings.HasPrefix(arrayStr, "[") || !strings.HasSuffix(arrayStr, "]") {
+		return nil, fmt.Errorf("配列の形式が正しくありません: %s", arrayStr)
+	}
+	content := arrayStr[1 : len(arrayStr)-1]
+
+	contentRunes := []rune(content)
+	i := 0
+	for i < len(contentRunes) {
+		c := contentRunes[i]
+
+		if escapeNext {
+			currentToken.WriteRune(c)
+			escapeNext = false
+			i++
+			continue
+		}
+
+		if inString {
+			currentToken.WriteRune(c)
+			if c == '\\' {
+				escapeNext = true
+			} else if c == ')' {
+				inString = false
+				items = append(items, currentToken.String())
+				currentToken.Reset()
+			}
+			i++
+			continue
+		}
+
+		if c == '(' {
+			inString = true
+			currentToken.WriteRune(c)
+			i++
+			continue
+		}
+
+		if c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+			if currentToken.Len() > 0 {
+				tokenStr := currentToken.String()
+				if strings.HasPrefix(tokenStr, "<") || strings.HasSuffix(tokenStr, ">") {
+					texts, err := parseHexTextToken(tokenStr)
+					if err != nil {
+						return nil, err
+					}
+					items = append(items, texts)
+				} else if num, err := strconv.ParseFloat(tokenStr, 64); err == nil {
+					items = append(items, num)
+				} else {
+					return nil, fmt.Errorf("数値のパースに失敗しました: %s", tokenStr)
+				}
+				currentToken.Reset()
+			}
+			i++
+			continue
+		}
+
+		currentToken.WriteRune(c)
+		i++
+	}
+
+	// 最後のトークンを処理
+	if currentToken.Len() > 0 {
+		tokenStr := currentToken.String()
+		if strings.HasPrefix(tokenStr, "<") || strings.HasSuffix(tokenStr, ">") {
+			texts, err := parseHexTextToken(tokenStr)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, texts)
+		} else if num, err := strconv.ParseFloat(tokenStr, 64); err == nil {
+			items = append(items, num)
+		} else {
+			return nil, fmt.Errorf("数値のパースに失敗しました: %s", tokenStr)
+		}
+	}
+
+	return items, nil
+}
+
+// parseHexTextToken は TJ の配列の 16 進文字列 (<00410042>) を文字に変換する
+// 4 桁で割り切れる場合は 2 バイトの文字コード、それ以外は 1 バイトの文字コードとして読み、奇数桁の最後には 0 を補う
+func parseHexTextToken(tokenStr string) (TextToken, error) {
+	hexStr := strings.NewReplacer("<", "", ">", "").Replace(tokenStr)
+	if len(hexStr)%2 == 1 {
+		hexStr += "0"
+	}
+	size := 2
+	if len(hexStr)%4 == 0 {
+		size = 4
+	}
+	texts := TextToken{}
+	for i := 0; i+size <= len(hexStr); i += size {
+		t, err := strconv.ParseUint(hexStr[i:i+size], 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("16進数のパースに失敗しました: %s", hexStr[i:i+size])
+		}
+		texts = append(texts, string(rune(t)))
+	}
+	return texts, nil
+}
+
+func (to *TokenObject) processTokens(tokens []Token, pageHeight float64) ([]TextCommand, []ImageCommand, []PathCommand) {
+	currentZ := int64(0)
+	// グラフィックス状態スタック
+	graphicsStack := []*GraphicsState{NewGraphicsState()}
+	// テキスト状態
+	textState := NewTextState()
+	// パス状態
+	pathState := NewPathState()
+	// カラー状態
+	colorState := NewColorState()
+
+	// オペランドスタック
+	var operandStack []string
+	// テキスト要素のスライス
+	var textCommands []TextCommand
+	var imageCommands []ImageCommand
+	var pathCommands []PathCommand
+	// 入れ子のマークコンテンツ
+	var markedContents []MarkedContent
+
+	// W / W* で予約され、次のパス終了時に適用されるクリッピング規則
+	pendingClip := ""
+	applyPendingClip := func() {
+		if pendingClip != "" {
+			graphicsStack[len(graphicsStack)-1].Clip(pathState.Path, pendingClip)
+			pendingClip = ""
+		}
+	}
+
+	// paintPath は 現在のパスを描画コマンドとして追加し、パスを終了する
+	// closePath が true の場合はパスを閉じてから描画する (s / b / b*)
+	// fillRule は塗りの規則 (nonzero / evenodd)、ストロークのみの場合は空文字
+	// paintPath は 現在のパスを描くパスコマンドを追加する
+	// fillRule が空文字の場合は塗らず、stroke が false の場合は線を描かない。描かない方の色は空文字にする
+	paintPath := func(closePath bool, fillRule string, stroke bool) {
+		if closePath && !strings.HasSuffix(strings.TrimSpace(pathState.Path), "Z") {
+			pathState.Path += "Z"
+		}
+		gs := graphicsStack[len(graphicsStack)-1]
+		paintMode, fillColor, strokeColor := "fillStroke", colorState.FillColor, colorState.StrokeColor
+		if fillRule == "" {
+			paintMode, fillColor = "stroke", ""
+		} else if !stroke {
+			paintMode, strokeColor = "fill", ""
+		}
+		pathCommands = append(pathCommands, PathCommand{
+			X:           pathState.X,
+			Y:           pathState.Y,
+			Z:           currentZ,
+			Width:       pathState.Width,
+			Height:      pathState.Height,
+			FillColor:   fillColor,
+			StrokeColor: strokeColor,
+			Path:        pathState.Path,
+			PaintMode:   paintMode,
+			FillRule:    fillRule,
+			StrokeAlpha: gs.StrokeAlpha,
+			FillAlpha:   gs.FillAlpha,
+			BlendMode:   gs.BlendMode,
+			SoftMask:    gs.SoftMask,
+			ClipPaths:   gs.ClipPaths,
+		})
+		applyPendingClip()
+		pathState.Path = ""
+		currentZ++
+	}
+
+	// flushText は Tj で続けて表示したテキストを1つのテキストコマンドにする
+	// Tj はテキストマトリックスを進めないため、位置・フォントを変える演算子の前と ET で呼ぶ
+	flushText := func() {
+		if len(textState.Text) == 0 && len(textState.Codes) == 0 {
+			return
+		}
+		gs := graphicsStack[len(graphicsStack)-1]
+		trm := textState.Tm.Multiply(gs.CTM)
+		scaleY := math.Sqrt(trm[1][0]*trm[1][0] + trm[1][1]*trm[1][1])
+		textCommands = append(textCommands, TextCommand{
+			X:           trm[2][0],
+			Y:           pageHeight - trm[2][1],
+			Z:           currentZ,
+			Text:        textState.Text,
+			Codes:       textState.Codes,
+			MCID:        textState.MCID,
+			Width:       textState.Width * textScaleX(trm),
+			FontSize:    textState.FontSize * scaleY,
+			FontID:      textState.Font,
+			Color:       colorState.FillColor,
+			StrokeAlpha: gs.StrokeAlpha,
+			FillAlpha:   gs.FillAlpha,
+			BlendMode:   gs.BlendMode,
+			ClipPaths:   gs.ClipPaths,
+		})
+		textState.Text = nil
+		textState.Codes = nil
+		textState.MCID = -1
+		textState.Width = 0
+		currentZ++
+	}
+
+	// トークンを順番に処理
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		if token.Type == tokenTypeFormEnd {
+			// Form XObject のリソースを外す
+			to.resources.Pop()
+			to.forms = to.forms[:len(to.forms)-1]
+		} else if token.Type == TokenTypeOperand {
+			operandStack = append(operandStack, token.Value)
+		} else if token.Type == TokenTypeOperator {
+			if !to.budget.step() {
+				// 上限を超えたページはここまでのコマンドで打ち切る (表示済みで未確定のテキストも含める)
+				flushText()
+				break
+			}
+			switch token.Value {
+			case "q":
+				// グラフィックス状態を保存
+				currentState := graphicsStack[len(graphicsStack)-1]
+				newState := *currentState // シャローコピー
+				graphicsStack = append(graphicsStack, &newState)
+				operandStack = nil // オペランドスタックをクリア
+
+			case "Q":
+				// グラフィックス状態を復元
+				if len(graphicsStack) > 1 {
+					graphicsStack = graphicsStack[:len(graphicsStack)-1]
+				}
+				operandStack = nil
+			case "cm":
+				// CTMを更新
+				if len(operandStack) >= 6 {
+					a := to.parseFloat(operandStack[0])
+					b := to.parseFloat(operandStack[1])
+					c := to.parseFloat(operandStack[2])
+					d := to.parseFloat(operandStack[3])
+					e := to.parseFloat(operandStack[4])
+					f := to.parseFloat(operandStack[5])
+
+					m := Matrix{
+						{a, b, 0},
+						{c, d, 0},
+						{e, f, 1},
+					}
+
+					currentState := graphicsStack[len(graphicsStack)-1]
+					// 新しい変換は現在の CTM の前に掛ける (CTM' = m × CTM)
+					currentState.CTM = m.Multiply(currentState.CTM)
+					operandStack = operandStack[6:]
+				} else {
+					to.missingOperands("cm")
+				}
+			case "BT":
+				// テキストオブジェクトの開始
+				textState = NewTextState()
+				operandStack = nil
+			case "ET":
+				// テキストオブジェクトの終了
+				flushText()
+				operandStack = nil
+			case "Tf":
+				flushText()
+				// フォントとフォントサイズの設定
+				if len(operandStack) >= 2 {
+					fontName := operandStack[0]
+					fontSize := to.parseFloat(operandStack[1])
+					textState.Font = strings.TrimLeft(fontName, "/")
+					textState.FontSize = fontSize
+					operandStack = operandStack[2:]
+				} else {
+					to.missingOperands("Tf")
+				}
+			case "Tc":
+				// 文字間隔の設定
+				if len(operandStack) >= 1 {
+					charSpacing := to.parseFloat(operandStack[0])
+					textState.CharSpacing = charSpacing
+					operandStack = operandStack[1:]
+				} else {
+					to.missingOperands("Tc")
+				}
+			case "Tw":
+				// 単語間隔の設定
+				if len(operandStack) >= 1 {
+					wordSpacing := to.parseFloat(operandStack[0])
+					textState.WordSpacing = wordSpacing
+					operandStack = operandStack[1:]
+				} else {
+					to.missingOperands("Tw")
+				}
+			case "Tz":
+				// 水平スケーリングの設定
+				if len(operandStack) >= 1 {
+					horizontalScaling := to.parseFloat(operandStack[0])
+					textState.HorizontalScaling = horizontalScaling
+					operandStack = operandStack[1:]
+				} else {
+					to.missingOperands("Tz")
+				}
+			case "TL":
+				// リーディングの設定
+				if len(operandStack) >= 1 {
+					leading := to.parseFloat(operandStack[0])
+					textState.Leading = leading
+					operandStack = operandStack[1:]
+				} else {
+					to.missingOperands("TL")
+				}
+			case "Tm":
+				flushText()
+				// テキストマトリックスの設定
+				if len(operandStack) >= 6 {
+					a := to.parseFloat(operandStack[0])
+					b := to.parseFloat(operandStack[1])
+					c := to.parseFloat(operandStack[2])
+					d := to.parseFloat(operandStack[3])
+					e := to.parseFloat(operandStack[4])
+					f := to.parseFloat(operandStack[5])
+
+					textState.Tm = Matrix{
+						{a, b, 0},
+						{c, d, 0},
+						{e, f, 1},
+					}
+					textState.Tlm = textState.Tm
+					operandStack = operandStack[6:]
+				} else {
+					to.missingOperands("Tm")
+				}
+			case "Td":
+				flushText()
+				// テキスト位置の移動
+				if len(operandStack) >= 2 {
+					tx := to.parseFloat(operandStack[0])
+					ty := to.parseFloat(operandStack[1])
+					// 移動マトリックス
+					m := Matrix{
+						{1, 0, 0},
+						{0, 1, 0},
+						{tx, ty, 1},
+					}
+					textState.Tm = textState.Tlm.Multiply(m)
+					textState.Tlm = textState.Tm
+					operandStack = operandStack[2:]
+				} else {
+					to.missingOperands("Td")
+				}
+			case "TD":
+				flushText()
+				// テキスト位置の移動とリーディングの設定
+				if len(operandStack) >= 2 {
+					tx := to.parseFloat(operandStack[0])
+					ty := to.parseFloat(operandStack[1])
+					textState.Leading = -ty
+					// 移動マトリックス
+					m := Matrix{
+						{1, 0, 0},
+						{0, 1, 0},
+						{tx, ty, 1},
+					}
+					textState.Tm = textState.Tlm.Multiply(m)
+					textState.Tlm = textState.Tm
+					operandStack = operandStack[2:]
+				} else {
+					to.missingOperands("TD")
+				}
+			case "T*":
+				flushText()
+				// 改行（テキストラインを Leading 分だけ下げる）
+				m := Matrix{
+					{1, 0, 0},
+					{0, 1, 0},
+					{0, -textState.Leading, 1},
+				}
+				textState.Tm = textState.Tlm.Multiply(m)
+				textState.Tlm = textState.Tm
+				operandStack = nil
+			case "'":
+				flushText()
+				// 改行処理はそのまま
+				m := Matrix{
+					{1, 0, 0},
+					{0, 1, 0},
+					{0, -textState.Leading, 1},
+				}
+				textState.Tm = textState.Tlm.Multiply(m)
+				textState.Tlm = textState.Tm
+				// テキスト表示
+				if len(operandStack) >= 1 {
+					texts := operandStack[0] // これは"(...)"形式のPDF文字列
+					operandStack = operandStack[1:]
+					t := parsePDFStringToBytes(texts, to.font(textState.Font))
+					gs := graphicsStack[len(graphicsStack)-1]
+					trm := textState.Tm.Multiply(gs.CTM)
+					textCommands = append(textCommands, TextCommand{
+						X:           trm[2][0],
+						Y:           pageHeight - trm[2][1],
+						Z:           currentZ,
+						Text:        t,
+						Codes:       pdfStringCodes(texts),
+						MCID:        markedContentID(markedContents),
+						Width:       pdfStringWidth(texts, to.fontWidths(textState.Font), textState) * textScaleX(trm),
+						FontID:      textState.Font,
+						FontSize:    textState.FontSize,
+						Color:       colorState.FillColor,
+						StrokeAlpha: gs.StrokeAlpha,
+						FillAlpha:   gs.FillAlpha,
+						BlendMode:   gs.BlendMode,
+						ClipPaths:   gs.ClipPaths,
+					})
+					currentZ++
+				} else {
+					to.missingOperands("'")
+				}
+
+			case "\"":
+				flushText()
+				if len(operandStack) >= 3 {
+					aw := to.parseFloat(operandStack[0])
+					ac := to.parseFloat(operandStack[1])
+					texts := operandStack[2] // "(...)"形式
+					textState.WordSpacing = aw
+					textState.CharSpacing = ac
+					operandStack = operandStack[3:]
+					// 改行
+					m := Matrix{
+						{1, 0, 0},
+						{0, 1, 0},
+						{0, -textState.Leading, 1},
+					}
+					textState.Tm = textState.Tlm.Multiply(m)
+					textState.Tlm = textState.Tm
+					// テキスト表示
+					rawBytes := parsePDFStringToBytes(texts, to.font(textState.Font))
+					gs := graphicsStack[len(graphicsStack)-1]
+					trm := textState.Tm.Multiply(gs.CTM)
+					textCommands = append(textCommands, TextCommand{
+						X:           trm[2][0],
+						Y:           pageHeight - trm[2][1],
+						Z:           currentZ,
+						Text:        rawBytes,
+						Codes:       pdfStringCodes(texts),
+						MCID:        markedContentID(markedContents),
+						Width:       pdfStringWidth(texts, to.fontWidths(textState.Font), textState) * textScaleX(trm),
+						FontID:      textState.Font,
+						FontSize:    textState.FontSize,
+						Color:       colorState.FillColor,
+						StrokeAlpha: gs.StrokeAlpha,
+						FillAlpha:   gs.FillAlpha,
+						BlendMode:   gs.BlendMode,
+						ClipPaths:   gs.ClipPaths,
+					})
+				} else {
+					to.missingOperands("\"")
+				}
+
+			// Tj演算子処理
+			case "Tj":
+				if len(operandStack) >= 1 {
+					texts := operandStack[0] // textsは"( ... )"を含む生文字列
+					operandStack = operandStack[1:]
+					rawBytes := parsePDFStringToBytes(texts, to.font(textState.Font)) // `(` `)`を除去、\エスケープ処理した生バイト列
+					textState.Text = append(textState.Text, rawBytes...)
+					textState.Codes = append(textState.Codes, pdfStringCodes(texts)...)
+					if textState.MCID < 0 {
+						// BT の内側で EMC が先に来ることがあるため、表示した時点の MCID を記録する
+						textState.MCID = markedContentID(markedContents)
+					}
+					textState.Width += pdfStringWidth(texts, to.fontWidths(textState.Font), textState)
+
+				} else {
+					to.missingOperands("Tj")
+				}
+
+			// `TJ`も同様に parsePDFStringToBytes を適用して生バイト列を抽出し、それをComputeTextPositionへ渡す
+
+			case "TJ":
+				flushText()
+				// テキスト配列の表示
+				if len(operandStack) >= 1 {
+					arrayContent := operandStack[0]
+					operandStack = operandStack[1:]
+					textCommand, err := processTJ(arrayContent, textState, graphicsStack[len(graphicsStack)-1], &currentZ, to.font(textState.Font), to.fontWidths(textState.Font), *colorState, pageHeight)
+					if err != nil {
+						to.logger.Warn("TJ演算子のオペランドを読み込めません", "err", err)
+					} else {
+						textCommand.MCID = markedContentID(markedContents)
+						textCommands = append(textCommands, *textCommand)
+					}
+
+				} else {
+					to.missingOperands("TJ")
+				}
+			case "Do":
+				// XObjectの描画
+				if len(operandStack) >= 1 {
+					xObjectName := strings.TrimLeft(operandStack[0], "/")
+					operandStack = operandStack[1:]
+					xObjectRef, _ := to.resources.XObject(xObjectName)
+					if formTokens, ok := to.expandForm(xObjectRef); ok {
+						// Form XObject は内容をこの位置に展開して実行する
+						tokens = append(tokens[:i+1], append(formTokens, tokens[i+1:]...)...)
+						continue
+					}
+					gs := graphicsStack[len(graphicsStack)-1]
+					ctm := gs.CTM
+					x := ctm[2][0]
+					y := ctm[2][1]
+
+					width, height := matrixScale(ctm.Values())
+					alt, actualText, mcid := markedContentText(markedContents)
+					imageCommands = append(imageCommands, ImageCommand{
+						X:           x,
+						Y:           y,
+						Z:           currentZ,
+						DW:          width,
+						DH:          height,
+						Matrix:      ctm,
+						ImageID:     xObjectName,
+						ImageRef:    xObjectRef,
+						ClipPath:    gs.innermostClipPath(),
+						StrokeAlpha: gs.StrokeAlpha,
+						FillAlpha:   gs.FillAlpha,
+						BlendMode:   gs.BlendMode,
+						SoftMask:    gs.SoftMask,
+						FillColor:   colorState.FillColor,
+						ClipPaths:   gs.ClipPaths,
+						Alt:         alt,
+						ActualText:  actualText,
+						MCID:        mcid,
+					})
+					currentZ++
+				} else {
+					to.missingOperands("Do")
+				}
+			case "m":
+				// moveto: 新規パス開始点を設定
+				// オペランドは x y (移動先)
+				if len(operandStack) >= 2 {
+					x, y := graphicsStack[len(graphicsStack)-1].CTM.Transform(to.parseFloat(operandStack[0]), to.parseFloat(operandStack[1]))
+					pathState.Path += fmt.Sprintf("M %f %f ", x, pageHeight-y)
+					pathState.X = x
+					pathState.Y = y
+
+					operandStack = operandStack[2:]
+				} else {
+					to.missingOperands("m")
+				}
+
+			case "l":
+				// lineto: 現在のパスに直線を追加
+				// オペランド: x y
+				if len(operandStack) >= 2 {
+					x, y := graphicsStack[len(graphicsStack)-1].CTM.Transform(to.parseFloat(operandStack[0]), to.parseFloat(operandStack[1]))
+					pathState.Path += fmt.Sprintf("L %f %f ", x, pageHeight-y)
+					operandStack = operandStack[2:]
+				} else {
+					to.missingOperands("l")
+				}
+
+			case "h":
+				// closepath: 現在のパスを閉じる
+
+				pathState.Path += "Z"
+				operandStack = nil
+
+			case "sc":
+				// setnonstrokingcolor: 非ストローク描画色を設定
+				// オペランド: カラーコンポーネント (数値が複数個)
+				// DeviceGrayなら1つ、DeviceRGBなら3つ、DeviceCMYKなら4つ
+				components := make([]float64, 0, len(operandStack))
+				for _, op := range operandStack {
+					components = append(components, to.parseFloat(op))
+				}
+				colorState.FillColor = parseColor(components)
+
+				operandStack = nil
+			case "SC":
+				// setstrokingcolor: ストローク描画色を設定
+				// オペランド: カラーコンポーネント (数値が複数個)
+				// DeviceGrayなら1つ、DeviceRGBなら3つ、DeviceCMYKなら4つ
+				components := make([]float64, 0, len(operandStack))
+				for _, op := range operandStack {
+					components = append(components, to.parseFloat(op))
+				}
+				colorState.StrokeColor = parseColor(components)
+				operandStack = nil
+			case "cs":
+				// setcolorspace: 非ストローク用カラー空間の指定
+				// オペランド: カラー空間名(Nameオペランド)
+				if len(operandStack) >= 1 {
+					colorSpaceName := operandStack[0]
+					// カラー空間設定(実装例)
+					_ = colorSpaceName
+					operandStack = operandStack[1:]
+				} else {
+					to.missingOperands("cs")
+				}
+
+			case "re":
+				// rectangle: 長方形パスを追加
+				// オペランド: x y width height
+				if len(operandStack) >= 4 {
+					x := to.parseFloat(operandStack[0])
+					y := to.parseFloat(operandStack[1])
+					w := to.parseFloat(operandStack[2])
+					h := to.parseFloat(operandStack[3])
+					// 4隅を CTM で変換する (回転・傾斜していても正しい四角形になる)
+					ctm := graphicsStack[len(graphicsStack)-1].CTM
+					x0, y0 := ctm.Transform(x, y)
+					x1, y1 := ctm.Transform(x+w, y)
+					x2, y2 := ctm.Transform(x+w, y+h)
+					x3, y3 := ctm.Transform(x, y+h)
+					pathState.Path += fmt.Sprintf("M %f %f L %f %f L %f %f L %f %f Z ", x0, pageHeight-y0, x1, pageHeight-y1, x2, pageHeight-y2, x3, pageHeight-y3)
+
+					operandStack = operandStack[4:]
+				} else {
+					to.missingOperands("re")
+				}
+
+			case "W":
+				// clip: 現在のパスを非ゼロ規則でクリッピングパスにセット
+				// 次のパス描画演算子(n など)でパスが終了した時点で有効になる
+				pendingClip = "nonzero"
+				operandStack = nil
+
+			case "W*":
+				// clip (even-odd rule): 現在のパスを偶奇規則でクリッピングパスにセット
+				pendingClip = "evenodd"
+				operandStack = nil
+
+			case "n":
+				// end path without fill or stroke: パスを閉じず描画せず終了
+				// オペランドなし
+				applyPendingClip()
+				pathState.Path = ""
+				operandStack = nil
+
+			case "w":
+				// setlinewidth: 線幅を設定
+				// オペランド: lineWidth
+				if len(operandStack) >= 1 {
+					lineWidth := to.parseFloat(operandStack[0])
+					// 線幅設定(実装例)
+					_ = lineWidth
+					operandStack = operandStack[1:]
+				} else {
+					to.missingOperands("w")
+				}
+			case "f":
+				// fill: 現在のパスを非ゼロルールで塗りつぶし
+				// オペランドなし
+				paintPath(false, "nonzero", false)
+				operandStack = nil
+
+			case "S":
+				// stroke: 現在のパスをストローク
+				// オペランドなし
+				paintPath(false, "", true)
+				operandStack = nil
+
+			case "s":
+				// close and stroke: パスを閉じてストローク
+				paintPath(true, "", true)
+				operandStack = nil
+
+			case "f*":
+				// fill (even-odd rule): 現在のパスを偶数-非偶数ルールで塗りつぶし
+				// オペランドなし
+				paintPath(false, "evenodd", false)
+				operandStack = nil
+
+			case "B":
+				// fill and stroke: 現在のパスを非ゼロ規則で塗りつぶしてストローク
+				paintPath(false, "nonzero", true)
+				operandStack = nil
+
+			case "B*":
+				// fill and stroke (even-odd rule)
+				paintPath(false, "evenodd", true)
+				operandStack = nil
+
+			case "b":
+				// close, fill and stroke: パスを閉じて非ゼロ規則で塗りつぶし、ストローク
+				paintPath(true, "nonzero", true)
+				operandStack = nil
+
+			case "b*":
+				// close, fill and stroke (even-odd rule)
+				paintPath(true, "evenodd", true)
+				operandStack = nil
+
+			case "gs":
+				// set graphics state
+				// オペランド: ExtGStateリソース名(例: /GS1)
+				if len(operandStack) >= 1 {
+					gsName := strings.TrimLeft(operandStack[0], "/")
+					operandStack = operandStack[1:]
+					// gsNameに対応するExtGStateを取得し、透明度とブレンドモードを反映する
+					if extGState, ok := to.resources.ExtGState(gsName); ok {
+						graphicsStack[len(graphicsStack)-1].Apply(extGState)
+					} else {
+						to.logger.Warn("ExtGStateが見つかりません", "name", gsName)
+					}
+				} else {
+					to.missingOperands("gs")
+				}
+			case "c":
+				// curveto: ベジエ曲線を現在のパスに追加
+				// オペランド: x1 y1 x2 y2 x3 y3 (6つ)
+				if len(operandStack) >= 6 {
+					ctm := graphicsStack[len(graphicsStack)-1].CTM
+					x1, y1 := ctm.Transform(to.parseFloat(operandStack[0]), to.parseFloat(operandStack[1]))
+					x2, y2 := ctm.Transform(to.parseFloat(operandStack[2]), to.parseFloat(operandStack[3]))
+					x3, y3 := ctm.Transform(to.parseFloat(operandStack[4]), to.parseFloat(operandStack[5]))
+
+					pathState.Path += fmt.Sprintf("C %f %f %f %f %f %f ", x1, pageHeight-y1, x2, pageHeight-y2, x3, pageHeight-y3)
+
+					operandStack = operandStack[6:]
+				} else {
+					to.missingOperands("c")
+				}
+			case "CS":
+				// setcolorspace: ストローク用カラー空間の指定
+				// オペランド: カラー空間名(Nameオペランド)
+				if len(operandStack) >= 1 {
+					colorSpaceName := operandStack[0]
+					// カラー空間設定(実装例)
+					_ = colorSpaceName
+					operandStack = operandStack[1:]
+				} else {
+					to.missingOperands("CS")
+				}
+
+			case "ri":
+				// setrenderingintent: レンダリングインテントを設定
+				// オペランド: インテント名 (/Perceptual など)。色を変換しないため読み捨てる
+				if len(operandStack) >= 1 {
+					operandStack = operandStack[1:]
+				} else {
+					to.missingOperands("ri")
+				}
+
+			case "BDC", "BMC":
+				// マークコンテンツの開始
+				markedContents = append(markedContents, parseMarkedContent(operandStack))
+				operandStack = nil
+			case "EMC":
+				// マークコンテンツの終了
+				if len(markedContents) > 0 {
+					markedContents = markedContents[:len(markedContents)-1]
+				}
+				operandStack = nil
+			default:
+				// 未知の演算子
+				to.logger.Debug("未知の演算子", "operator", token.Value)
+				operandStack = nil
+			}
+		}
+	}
+	return textCommands, imageCommands, pathCommands
+}
+
+// pdfStringWidth は "(...)" 形式の文字列をテキスト空間で表示したときの幅を返す
+// グリフ幅が分からない文字は幅0として扱う
+func pdfStringWidth(pdfString string, widths map[byte]float64, textState *TextState) float64 {
+	if len(pdfString) < 2 {
+		return 0
+	}
+	inner := pdfString[1 : len(pdfString)-1]
+	width := 0.0
+	escape := false
+	for i := 0; i < len(inner); i++ {
+		c := inner[i]
+		if !escape && c == '\\' {
+			escape = true
+			continue
+		}
+		escape = false
+		width += widths[c]/1000*textState.FontSize + textState.CharSpacing
+	}
+	return width * textState.HorizontalScaling / 100
+}
+
+// textScaleX は テキストレンダリング行列の水平方向の拡大率を返す
+func textScaleX(trm Matrix) float64 {
+	return math.Sqrt(trm[0][0]*trm[0][0] + trm[0][1]*trm[0][1])
+}
+
+func parsePDFStringToBytes(pdfString string, fonts map[byte]string) []string {
+	codes := pdfStringCodes(pdfString)
+	if codes == nil {
+		return []string{}
+	}
+	var result []string
+	for _, c := range codes {
+		result = append(result, fonts[byte(c)])
+	}
+	return result
+}
+
+// pdfStringCodes は "(ABC\\)DEF)" 形式の PDF 文字列から文字コードを順に取り出す
+func pdfStringCodes(pdfString string) []int {
+	// 先頭と末尾の()を削除
+	if len(pdfString) < 2 {
+		return nil
+	}
+	inner := pdfString[1 : len(pdfString)-1]
+
+	codes := make([]int, 0, len(inner))
+	escape := false
+	for i := 0; i < len(inner); i++ {
+		c := inner[i]
+		if escape {
+			// エスケープ後はそのまま文字を追加
+			codes = append(codes, int(c))
+			escape = false
+		} else {
+			if c == '\\' {
+				escape = true
+			} else {
+				codes = append(codes, int(c))
+			}
+		}
+	}
+	return codes
+}
+
+// parseFloat は オペランドを数値に変換する (数値でない場合は 0)
+func (to *TokenObject) parseFloat(operand string) float64 {
+	value, err := strconv.ParseFloat(operand, 64)
+	if err != nil {
+		to.logger.Debug("数値に変換できません", "operand", operand)
+		return 0
+	}
+	return value
+}
+
+// missingOperands は 演算子に必要なオペランドが不足していることを記録する (その演算子は無視する)
+func (to *TokenObject) missingOperands(operator string) {
+	to.logger.Debug("演算子に必要なオペランドが不足しています", "operator", operator)
+}
+
+func (to *TokenObject) ExtractCommands(pageHeight float64) ([]TextCommand, []ImageCommand, []PathCommand) {
+	tokens, err := tokenize(to.contents)
+	if err != nil {
+		to.logger.Warn("トークンの分割に失敗しました", "err", err)
+		return nil, nil, nil
+	}
+
+	textCommands, imageCommands, pathCommands := to.processTokens(tokens, pageHeight)
+	return textCommands, imageCommands, pathCommands
+}
+
+// NewTokenObject は ページ(またはフォーム)のリソースでコンテンツを実行する TokenObject を作る
+// loadForm が nil の場合、Form XObject は展開せず画像と同様に扱う
+func NewTokenObject(contents string, resources *Resources, loadForm func(ref PDFRef) (*FormXObject, error)) *TokenObject {
+	return &TokenObject{
+		resources: NewResourceStack(resources),
+		loadForm:  loadForm,
+		contents:  contents,
+		logger:    slog.Default(),
+	}
+}
+
+// font は 現在のリソースからフォントの文字コード対応を探す
+func (to *TokenObject) font(name string) map[byte]string {
+	font, _ := to.resources.Font(name)
+	return font
+}
+
+// fontWidths は 現在のリソースからフォントのグリフ幅を探す
+func (to *TokenObject) fontWidths(name string) map[byte]float64 {
+	widths, _ := to.resources.FontWidths(name)
+	return widths
+}
+
+// expandForm は ref が Form XObject であれば、実行するトークン列
+// (q [Matrix] cm 内容 Q)を返し、フォームのリソースを積む
+func (to *TokenObject) expandForm(ref PDFRef) ([]Token, bool) {
+	if ref == 0 || to.loadForm == nil {
+		return nil, false
+	}
+	form, err := to.loadForm(ref)
+	if err != nil {
+		to.logger.Warn("Form XObjectの読み込みに失敗しました", "ref", ref, "err", err)
+		return nil, false
+	}
+	if form == nil {
+		return nil, false
+	}
+	if to.resources.Depth() > maxFormNesting {
+		to.logger.Warn("Form XObjectの入れ子が深すぎます", "ref", ref)
+		return nil, true
+	}
+	if slices.Contains(to.forms, ref) {
+		// 自身を (間接的に) 描画するフォームは展開しない
+		to.logger.Warn("Form XObjectが循環しています", "ref", ref)
+		return nil, true
+	}
+	if to.expansions >= maxFormExpansions {
+		to.logger.Warn("Form XObjectの展開回数が上限を超えました", "ref", ref)
+		return nil, true
+	}
+	contents, err := tokenize(form.Contents)
+	if err != nil {
+		to.logger.Warn("トークンの分割に失敗しました", "ref", ref, "err", err)
+		return nil, true
+	}
+	m := form.Matrix
+	tokens := make([]Token, 0, len(contents)+10)
+	tokens = append(tokens, Token{Value: "q", Type: TokenTypeOperator})
+	for _, v := range []float64{m[0][0], m[0][1], m[1][0], m[1][1], m[2][0], m[2][1]} {
+		tokens = append(tokens, Token{Value: strconv.FormatFloat(v, 'f', -1, 64), Type: TokenTypeOperand})
+	}
+	tokens = append(tokens, Token{Value: "cm", Type: TokenTypeOperator})
+	tokens = append(tokens, contents...)
+	tokens = append(tokens, Token{Type: tokenTypeFormEnd}, Token{Value: "Q", Type: TokenTypeOperator})
+	to.resources.Push(form.Resources)
+	to.forms = append(to.forms, ref)
+	to.expansions++
+	return tokens, true
+}
+
+func parseColor(rgb []float64) string {
+	switch len(rgb) {
+	case 0:
+		return ""
+	case 1:
+		// DeviceGray
+		rgb = []float64{rgb[0], rgb[0], rgb[0]}
+	case 4:
+		// DeviceCMYK
+		c, m, y, k := rgb[0], rgb[1], rgb[2], rgb[3]
+		rgb = []float64{(1 - c) * (1 - k), (1 - m) * (1 - k), (1 - y) * (1 - k)}
+	}
+	if len(rgb) < 3 {
+		return ""
+	}
+	r := int(rgb[0] * 255)
+	g := int(rgb[1] * 255)
+	b := int(rgb[2] * 255)
+	return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+}
@@ -0,0 +1,205 @@
+package parse
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+// TestImagePlacement は 回転・反転した cm で描画した画像の表示の大きさが正の値になり、配置行列がそのまま付くことを確かめる
+func TestImagePlacement(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		cm     string
+		dw, dh float64
+		matrix [6]float64
+	}{
+		{"scaled", "100 0 0 50 10 20", 100, 50, [6]float64{100, 0, 0, 50, 10, 20}},
+		{"rotated 90", "0 100 -50 0 60 20", 100, 50, [6]float64{0, 100, -50, 0, 60, 20}},
+		{"rotated 45", "70.71067811865476 70.71067811865476 -35.35533905932738 35.35533905932738 0 0", 100, 50, [6]float64{70.71067811865476, 70.71067811865476, -35.35533905932738, 35.35533905932738, 0, 0}},
+		{"flipped vertically", "100 0 0 -50 10 70", 100, 50, [6]float64{100, 0, 0, -50, 10, 70}},
+		{"flipped horizontally", "-100 0 0 50 110 20", 100, 50, [6]float64{-100, 0, 0, 50, 110, 20}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			to := NewTokenObject("q "+test.cm+" cm /Im1 Do Q", nil, nil)
+			to.logger = quietLogger()
+			_, images, _ := to.ExtractCommands(792)
+			if len(images) != 1 {
+				t.Fatalf("got %d images, want 1", len(images))
+			}
+			image := images[0]
+			if !near(image.DW, test.dw) || !near(image.DH, test.dh) {
+				t.Errorf("DW, DH = %g, %g, want %g, %g", image.DW, image.DH, test.dw, test.dh)
+			}
+			for i, v := range image.Matrix.Values() {
+				if !near(v, test.matrix[i]) {
+					t.Errorf("Matrix = %v, want %v", image.Matrix.Values(), test.matrix)
+					break
+				}
+			}
+		})
+	}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// TestFormResourcesShadowPage は Form XObject の実行中はフォームの /Resources の名前をページより優先し、
+// フォームを抜けるとページのリソースに戻ることを確かめる
+func TestFormResourcesShadowPage(t *testing.T) {
+	opaque, translucent := 1.0, 0.5
+	page := &Resources{
+		ExtGStates: map[string]ExtGState{"GS1": {FillAlpha: &opaque}},
+		XObjects:   map[string]PDFRef{"Fm1": 10},
+	}
+	form := &FormXObject{
+		Contents:  "/GS1 gs 0 0 10 10 re f",
+		Matrix:    IdentityMatrix(),
+		Resources: &Resources{ExtGStates: map[string]ExtGState{"GS1": {FillAlpha: &translucent}}},
+	}
+	to := NewTokenObject("/Fm1 Do /GS1 gs 0 0 10 10 re f", page, func(ref PDFRef) (*FormXObject, error) {
+		if ref != 10 {
+			t.Fatalf("loadForm(%d), want 10", ref)
+		}
+		return form, nil
+	})
+	to.logger = quietLogger()
+	_, _, paths := to.ExtractCommands(792)
+	if len(paths) != 2 {
+		t.Fatalf("got %d paths, want 2", len(paths))
+	}
+	if paths[0].FillAlpha != translucent || paths[1].FillAlpha != opaque {
+		t.Errorf("fill alphas = %g, %g, want %g inside the form and %g after it", paths[0].FillAlpha, paths[1].FillAlpha, translucent, opaque)
+	}
+}
+
+// TestFormCycle は 自身を描画するフォームと、互いを描画するフォームの展開が止まることを確かめる
+func TestFormCycle(t *testing.T) {
+	forms := map[PDFRef]*FormXObject{
+		// Fm1 は自身を描画する
+		10: {Contents: "0 0 10 10 re f /Fm1 Do", Matrix: IdentityMatrix()},
+		// Fm2 と Fm3 は互いを描画する
+		20: {Contents: "0 0 10 10 re f /Fm3 Do", Matrix: IdentityMatrix()},
+		30: {Contents: "0 0 10 10 re f /Fm2 Do", Matrix: IdentityMatrix()},
+	}
+	page := &Resources{XObjects: map[string]PDFRef{"Fm1": 10, "Fm2": 20, "Fm3": 30}}
+	for _, test := range []struct {
+		contents string
+		paths    int
+	}{
+		{"/Fm1 Do", 1},
+		{"/Fm2 Do", 2},
+	} {
+		to := NewTokenObject(test.contents, page, func(ref PDFRef) (*FormXObject, error) {
+			return forms[ref], nil
+		})
+		to.logger = quietLogger()
+		_, _, paths := to.ExtractCommands(792)
+		if len(paths) != test.paths {
+			t.Errorf("%s: got %d paths, want each form painted once (%d)", test.contents, len(paths), test.paths)
+		}
+	}
+}
+
+// TestPaintMode は パスの描画演算子ごとに描き方・塗りの規則を付け、描かない方の色を空文字にすることを確かめる
+func TestPaintMode(t *testing.T) {
+	for _, test := range []struct {
+		op          string
+		paintMode   string
+		fillRule    string
+		fillColor   string
+		strokeColor string
+	}{
+		{"S", "stroke", "", "", "#0000ff"},
+		{"f", "fill", "nonzero", "#ff0000", ""},
+		{"f*", "fill", "evenodd", "#ff0000", ""},
+		{"B", "fillStroke", "nonzero", "#ff0000", "#0000ff"},
+		{"b*", "fillStroke", "evenodd", "#ff0000", "#0000ff"},
+	} {
+		t.Run(test.op, func(t *testing.T) {
+			to := NewTokenObject("1 0 0 sc 0 0 1 SC 0 0 10 10 re "+test.op, nil, nil)
+			to.logger = quietLogger()
+			_, _, paths := to.ExtractCommands(792)
+			if len(paths) != 1 {
+				t.Fatalf("got %d paths, want 1", len(paths))
+			}
+			path := paths[0]
+			if path.PaintMode != test.paintMode || path.FillRule != test.fillRule {
+				t.Errorf("PaintMode, FillRule = %q, %q, want %q, %q", path.PaintMode, path.FillRule, test.paintMode, test.fillRule)
+			}
+			if path.FillColor != test.fillColor || path.StrokeColor != test.strokeColor {
+				t.Errorf("FillColor, StrokeColor = %q, %q, want %q, %q", path.FillColor, path.StrokeColor, test.fillColor, test.strokeColor)
+			}
+		})
+	}
+}
+
+// clipRules は クリッピングパスの内外判定規則を外側から並べる
+func clipRules(clips []ClipPath) []string {
+	rules := make([]string, len(clips))
+	for i, clip := range clips {
+		rules[i] = clip.FillRule
+	}
+	return rules
+}
+
+// TestClipPath は W・W* で設定したクリッピングパスが次のパス描画演算子 (n など) の後から有効になり、
+// q と Q の間で重ねたクリッピングパスが Q で q の時点に戻ることを確かめる
+func TestClipPath(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		contents string
+		want     [][]string // パスごとのクリッピングパスの規則
+	}{
+		{"W then n", "0 0 10 10 re W n 0 0 50 50 re f", [][]string{{"nonzero"}}},
+		{"W* then n", "0 0 10 10 re W* n 0 0 50 50 re f", [][]string{{"evenodd"}}},
+		{"clip after painting", "0 0 10 10 re W f 0 0 50 50 re f", [][]string{{}, {"nonzero"}}},
+		{
+			name:     "nested q W Q",
+			contents: "q 0 0 100 100 re W n q 10 10 20 20 re W* n 0 0 5 5 re f Q 0 0 6 6 re f Q 0 0 7 7 re f",
+			want:     [][]string{{"nonzero", "evenodd"}, {"nonzero"}, {}},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			to := NewTokenObject(test.contents, nil, nil)
+			to.logger = quietLogger()
+			_, _, paths := to.ExtractCommands(792)
+			if len(paths) != len(test.want) {
+				t.Fatalf("got %d paths, want %d (n must not paint)", len(paths), len(test.want))
+			}
+			for i, path := range paths {
+				if got := clipRules(path.ClipPaths); !slices.Equal(got, test.want[i]) {
+					t.Errorf("path %d clip rules = %q, want %q", i, got, test.want[i])
+				}
+			}
+		})
+	}
+
+	// クリッピングパスは W の前に作ったパスで、n で描いたパスを捨てても残る
+	to := NewTokenObject("0 0 10 10 re W n 0 0 50 50 re f 0 0 10 10 re f", nil, nil)
+	to.logger = quietLogger()
+	_, _, paths := to.ExtractCommands(792)
+	if len(paths) != 2 || len(paths[0].ClipPaths) != 1 || paths[0].ClipPaths[0].Path != paths[1].Path {
+		t.Errorf("paths = %+v, want the first clipped by the path of the second", paths)
+	}
+}
+
+// TestClipPathOnTextAndImages は 設定したクリッピングパスが後に描くテキストと画像にも付くことを確かめる
+func TestClipPathOnTextAndImages(t *testing.T) {
+	to := NewTokenObject("0 0 10 10 re W n q 10 10 20 20 re W* n q 100 0 0 100 0 0 cm /Im1 Do Q Q BT /F1 12 Tf (a) Tj ET", nil, nil)
+	to.logger = quietLogger()
+	texts, images, paths := to.ExtractCommands(792)
+	if len(texts) != 1 || len(images) != 1 || len(paths) != 0 {
+		t.Fatalf("got %d texts, %d images and %d paths, want 1, 1 and 0", len(texts), len(images), len(paths))
+	}
+	if got, want := clipRules(images[0].ClipPaths), []string{"nonzero", "evenodd"}; !slices.Equal(got, want) {
+		t.Errorf("image clip rules = %q, want %q", got, want)
+	}
+	if got, want := clipRules(texts[0].ClipPaths), []string{"nonzero"}; !slices.Equal(got, want) {
+		t.Errorf("text clip rules = %q, want %q", got, want)
+	}
+	if texts[0].ClipPaths[0].Path != images[0].ClipPaths[0].Path {
+		t.Errorf("text clip path %q differs from the image clip path %q", texts[0].ClipPaths[0].Path, images[0].ClipPaths[0].Path)
+	}
+}
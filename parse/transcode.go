@@ -0,0 +1,81 @@
+package parse
+
+import (
+	"errors"
+	"fmt"
+	"image"
+)
+
+// ImageTranscoder は FlateDecode・非圧縮の画像を WebP や AVIF などの形式に変換する
+// 標準ライブラリにはエンコーダーがないため、外部のライブラリを使う実装を ParserConfig.ImageTranscoder に設定する
+type ImageTranscoder interface {
+	// Transcode は 画像を quality (1〜100) で変換し、変換後のデータと形式 (画像チャンクの ext) を返す
+	Transcode(img image.Image, quality int) ([]byte, string, error)
+}
+
+// DefaultTranscodeQuality は ParserConfig.TranscodeQuality が 0 の場合の品質
+const DefaultTranscodeQuality = 80
+
+// transcodeImage は zlib 圧縮したサンプル列の画像を transcoder で変換した複製を返す
+// マスクは変換せずにそのまま送る。変換後の方が大きい場合や変換できない形式の場合は img を返す
+func transcodeImage(img *ParsedImage, transcoder ImageTranscoder, quality int) (*ParsedImage, error) {
+	if img.Ext != "png" {
+		// JPEG は既に圧縮されており、ステンシルマスクは FillColor で塗るため変換しない
+		return img, nil
+	}
+	if img.ColorSpace == "Indexed" {
+		// パレットの色が分からないため変換しない
+		return img, nil
+	}
+	if quality <= 0 {
+		quality = DefaultTranscodeQuality
+	}
+	samples, err := inflateSamples(img.Data)
+	if err != nil {
+		return img, err
+	}
+	decoded, err := samplesImage(samples, int(img.Width), int(img.Height), img.BitsPerComponent)
+	if err != nil {
+		return img, err
+	}
+	data, ext, err := transcoder.Transcode(decoded, quality)
+	if err != nil {
+		return img, err
+	}
+	if ext == "" {
+		return img, errors.New("transcoder returned no format")
+	}
+	if len(data) >= len(img.Data) {
+		return img, nil
+	}
+	transcoded := *img
+	transcoded.Data = data
+	transcoded.Ext = ext
+	return &transcoded, nil
+}
+
+// samplesImage は 8bit のサンプル列を成分数に応じた画像 (グレースケール / RGB / CMYK) にする
+func samplesImage(samples []byte, width, height, bitsPerComponent int) (image.Image, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid image size %dx%d", width, height)
+	}
+	components := len(samples) / (width * height)
+	if bitsPerComponent != 8 || len(samples) != width*height*components {
+		return nil, fmt.Errorf("unsupported sample layout (%d bits, %d bytes)", bitsPerComponent, len(samples))
+	}
+	rect := image.Rect(0, 0, width, height)
+	switch components {
+	case 1:
+		return &image.Gray{Pix: samples, Stride: width, Rect: rect}, nil
+	case 3:
+		rgba := image.NewRGBA(rect)
+		for i, j := 0, 0; i < len(samples); i, j = i+3, j+4 {
+			rgba.Pix[j], rgba.Pix[j+1], rgba.Pix[j+2], rgba.Pix[j+3] = samples[i], samples[i+1], samples[i+2], 0xff
+		}
+		return rgba, nil
+	case 4:
+		return &image.CMYK{Pix: samples, Stride: width * 4, Rect: rect}, nil
+	default:
+		return nil, fmt.Errorf("unsupported component count %d", components)
+	}
+}
@@ -0,0 +1,47 @@
+package parse
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// warningLimiter は 解析中の警告をログに出し、設定された件数まで WarningChunk として送る
+// 壊れた文書で警告がクライアントに大量に送られないよう、上限を超えた分は最後に件数だけを送る
+type warningLimiter struct {
+	max        int
+	sent       int
+	suppressed int
+	logger     *slog.Logger
+	insertData func(data ParsedData)
+}
+
+func newWarningLimiter(max int, logger *slog.Logger, insertData func(data ParsedData)) *warningLimiter {
+	return &warningLimiter{max: max, logger: logger, insertData: insertData}
+}
+
+// warn は 警告をログに出し、上限に達していなければ送る (page が 0 の場合は文書全体の警告)
+func (w *warningLimiter) warn(page int64, format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	w.logger.Warn(message, "page", page)
+	if w.max <= 0 {
+		return
+	}
+	if w.sent >= w.max {
+		w.suppressed++
+		return
+	}
+	w.sent++
+	w.insertData(&ParsedWarning{Message: message, Page: page})
+}
+
+// flush は 上限を超えて送らなかった警告の件数を送る
+func (w *warningLimiter) flush() {
+	if w.suppressed == 0 {
+		return
+	}
+	w.insertData(&ParsedWarning{
+		Message:    fmt.Sprintf("%d further warnings suppressed", w.suppressed),
+		Suppressed: w.suppressed,
+	})
+	w.suppressed = 0
+}
@@ -0,0 +1,170 @@
+// Package pdtpclient は PDTP のエンドポイントに接続し、ストリームのチャンクを型付きのイベントとして読み込む
+//
+//	stream, err := (&pdtpclient.Client{}).Open(ctx, "http://localhost:8080/pdtp?file=example.pdf", "start=1;end=3")
+//	if err != nil {
+//		return err
+//	}
+//	defer stream.Close()
+//	for event, err := range stream.Events() {
+//		if err != nil {
+//			return err
+//		}
+//		switch e := event.(type) {
+//		case *pdtpclient.TextEvent:
+//			fmt.Println(e.Page, e.Text)
+//		case *pdtpclient.ImageEvent:
+//			fmt.Println(e.Page, e.Ext, len(e.Data))
+//		}
+//	}
+package pdtpclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pdtp-workbench/pdtp-go"
+)
+
+// Client は PDTP のエンドポイントに要求を送り、ストリームを開く
+type Client struct {
+	// HTTPClient は 要求に使うクライアント (nil の場合は http.DefaultClient)
+	HTTPClient *http.Client
+	// Version は 要求するフレームの形式 (0 の場合は pdtp.ProtocolVersion1)
+	Version int
+	// Encoding は 要求するメタデータの形式 (空の場合は pdtp.ChunkEncodingJSON)
+	Encoding pdtp.ChunkEncoding
+	// Limits は 受け付けるチャンクの大きさの上限 (0 の項目は pdtp.DefaultMaxMetadataSize などの既定値)
+	Limits pdtp.ChunkLimits
+}
+
+// Open は url に要求を送り、レスポンスのストリームを返す
+// field は Pdtp ヘッダーの値 (start=1;end=3 など)。version と encoding は Client の設定から加える
+func (c *Client) Open(ctx context.Context, url string, field string) (*Stream, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	version := c.Version
+	if version == 0 {
+		version = pdtp.ProtocolVersion1
+	}
+	options := strings.Trim(field, ";")
+	if version != pdtp.ProtocolVersion1 {
+		options += ";version=" + strconv.Itoa(version)
+	}
+	if c.Encoding != "" && c.Encoding != pdtp.ChunkEncodingJSON {
+		options += ";encoding=" + string(c.Encoding)
+	}
+	if options = strings.TrimPrefix(options, ";"); options != "" {
+		req.Header.Set("Pdtp", options)
+	}
+	return c.do(req)
+}
+
+// OpenSpec は url に spec を本文とする POST を送り、レスポンスのストリームを返す
+// spec の Version・Encoding が空の場合は Client の設定を使う
+func (c *Client) OpenSpec(ctx context.Context, url string, spec pdtp.RequestSpec) (*Stream, error) {
+	if spec.Version == 0 {
+		spec.Version = c.Version
+	}
+	if spec.Encoding == "" && c.Encoding != pdtp.ChunkEncodingJSON {
+		spec.Encoding = string(c.Encoding)
+	}
+	body, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.do(req)
+}
+
+// do は 要求を送り、レスポンスのヘッダーに合わせてストリームを読み込む
+func (c *Client) do(req *http.Request) (*Stream, error) {
+	// Accept-Encoding を指定すると net/http は gzip を自動で展開しないため、decodeBody で展開する
+	req.Header.Set("Accept-Encoding", "zstd, gzip")
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := decodeBody(resp)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	// サーバーが対応していない形式は要求しても使われないため、レスポンスのヘッダーに従う
+	version := pdtp.ProtocolVersion1
+	if v, err := strconv.Atoi(resp.Header.Get("Pdtp-Version")); err == nil {
+		version = v
+	}
+	encoding := pdtp.ChunkEncodingJSON
+	if resp.Header.Get("Pdtp-Encoding") == string(pdtp.ChunkEncodingCBOR) {
+		encoding = pdtp.ChunkEncodingCBOR
+	}
+	stream := NewStream(body, version, encoding)
+	stream.SetLimits(c.Limits)
+	stream.closer = body
+	if resp.StatusCode != http.StatusOK {
+		defer stream.Close()
+		// 要求を処理できなかった場合の本文は ErrorChunk のみ (429 などはチャンクでない場合がある)
+		if event, err := stream.Next(); err == nil {
+			if e, ok := event.(*ErrorEvent); ok {
+				return nil, e
+			}
+		}
+		return nil, fmt.Errorf("pdtpclient: unexpected status: %s", resp.Status)
+	}
+	return stream, nil
+}
+
+// decodeBody は Content-Encoding に合わせてレスポンスを展開する
+// 返した ReadCloser を閉じると、展開に使ったデコーダーとレスポンスのボディを閉じる
+func decodeBody(resp *http.Response) (io.ReadCloser, error) {
+	switch encoding := resp.Header.Get("Content-Encoding"); encoding {
+	case "", "identity":
+		return resp.Body, nil
+	case "gzip":
+		reader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return &decodedBody{Reader: reader, decoder: reader, body: resp.Body}, nil
+	case "zstd":
+		decoder, err := zstd.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		// zstd のデコーダーは展開用の goroutine を持つため、閉じないと残り続ける
+		return &decodedBody{Reader: decoder, decoder: decoder.IOReadCloser(), body: resp.Body}, nil
+	default:
+		return nil, fmt.Errorf("pdtpclient: unsupported content encoding: %s", encoding)
+	}
+}
+
+// decodedBody は 展開したレスポンスのボディ
+type decodedBody struct {
+	io.Reader
+	decoder io.Closer
+	body    io.Closer
+}
+
+func (b *decodedBody) Close() error {
+	b.decoder.Close()
+	return b.body.Close()
+}
@@ -0,0 +1,56 @@
+package pdtpclient
+
+import (
+	"context"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/pdtp-workbench/pdtp-go"
+)
+
+// TestCloseReleasesDecoder は zstd で圧縮したストリームを途中で閉じても、デコーダーの goroutine が残らないことを確かめる
+func TestCloseReleasesDecoder(t *testing.T) {
+	// デコーダーは GOMAXPROCS が 1 の場合は goroutine を使わずに展開するため、複数にして確かめる
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	server := httptest.NewServer(pdtp.NewPDFProtocolHandler(pdtp.Config{
+		OpenPDF:           pdtp.OpenUnder("../example"),
+		CompressionMethod: pdtp.ZstdCompression{},
+	}))
+	defer server.Close()
+	client := &Client{}
+	url := server.URL + "?file=example.pdf"
+
+	// 接続を使い回すための goroutine を先に起動させておく
+	warmup, err := client.Open(context.Background(), url, "start=1;end=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range warmup.Events() {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	warmup.Close()
+	before := runtime.NumGoroutine()
+
+	for range 5 {
+		stream, err := client.Open(context.Background(), url, "start=1;end=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 最後まで読まずに閉じる
+		if _, err := stream.Next(); err != nil {
+			t.Fatal(err)
+		}
+		stream.Close()
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines after closing the streams, want at most %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,92 @@
+package pdtpclient
+
+import (
+	"fmt"
+
+	"github.com/pdtp-workbench/pdtp-go"
+)
+
+// Event は ストリームから読み込んだ1つのチャンク
+// 種別に応じて *PageEvent・*TextEvent・*ImageEvent・*FontEvent・*PathEvent・*PageDoneEvent・*DoneEvent・*MetadataEvent・*ErrorEvent になり、
+// それ以外の種別は *RawEvent になる
+type Event interface {
+	// Type は チャンクの種別 (pdtp.DataTypePage など)
+	Type() byte
+}
+
+// PageEvent は ページの大きさと言語を知らせる
+type PageEvent struct {
+	pdtp.NewPageChunkArgs
+}
+
+func (e *PageEvent) Type() byte { return pdtp.DataTypePage }
+
+// TextEvent は テキストの配置
+type TextEvent struct {
+	pdtp.TextChunkArgs
+}
+
+func (e *TextEvent) Type() byte { return pdtp.DataTypeText }
+
+// ImageEvent は 画像の配置と画像のデータ
+type ImageEvent struct {
+	pdtp.SendImageJson
+	Data     []byte
+	MaskData []byte
+}
+
+func (e *ImageEvent) Type() byte { return pdtp.DataTypeImage }
+
+// FontEvent は 埋め込みフォントのデータ (代替フォントを使う場合は Font が空)
+type FontEvent struct {
+	pdtp.SendFontJson
+	Font []byte
+}
+
+func (e *FontEvent) Type() byte { return pdtp.DataTypeFont }
+
+// PathEvent は パスの描画 (ソフトマスクがある場合は MaskData にマスクの画像)
+type PathEvent struct {
+	pdtp.PathChunkArgs
+}
+
+func (e *PathEvent) Type() byte { return pdtp.DataTypePath }
+
+// PageDoneEvent は ページの内容をすべて受け取ったことを知らせる
+type PageDoneEvent struct {
+	pdtp.PageDoneChunkArgs
+}
+
+func (e *PageDoneEvent) Type() byte { return pdtp.DataTypePageDone }
+
+// DoneEvent は ストリームの最後に、送られたチャンクの件数を知らせる
+type DoneEvent struct {
+	pdtp.DoneChunkArgs
+}
+
+func (e *DoneEvent) Type() byte { return pdtp.DataTypeDone }
+
+// MetadataEvent は ページ数・ページの大きさ・ラベル・文書情報 (Pdtp ヘッダーの mode=meta の応答)
+type MetadataEvent struct {
+	pdtp.MetadataChunkArgs
+}
+
+func (e *MetadataEvent) Type() byte { return pdtp.DataTypeMetadata }
+
+// ErrorEvent は サーバーが要求を処理できなかったことを知らせる。この後にイベントは続かない
+type ErrorEvent struct {
+	pdtp.ErrorChunkArgs
+}
+
+func (e *ErrorEvent) Type() byte { return pdtp.DataTypeError }
+
+func (e *ErrorEvent) Error() string {
+	return fmt.Sprintf("pdtp error %d: %s", e.Code, e.Message)
+}
+
+// RawEvent は 型を定めていない種別のチャンク (注釈・警告・サムネイルなど)
+type RawEvent struct {
+	Chunk *pdtp.Chunk
+}
+
+func (e *RawEvent) Type() byte { return e.Chunk.Type }
@@ -0,0 +1,103 @@
+package pdtpclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+
+	"github.com/pdtp-workbench/pdtp-go"
+)
+
+// Stream は PDTP のストリームからチャンクを順に読み込み、イベントに変換する
+type Stream struct {
+	chunks *pdtp.ChunkReader
+	closer io.Closer
+}
+
+// NewStream は r から展開済みのストリームを読み込む Stream を作る
+// ファイルに保存したストリームや、WebSocket などの別の経路で受け取ったストリームの読み込みに使う
+func NewStream(r io.Reader, version int, encoding pdtp.ChunkEncoding) *Stream {
+	return &Stream{chunks: pdtp.NewChunkReaderWithEncoding(r, version, encoding)}
+}
+
+// SetLimits は 受け付けるチャンクの大きさの上限を変える。上限を超えるチャンクは pdtp.ErrInvalidChunk になる
+func (s *Stream) SetLimits(limits pdtp.ChunkLimits) {
+	s.chunks.SetLimits(limits)
+}
+
+// Next は 次のイベントを読み込む。ストリームの終わりでは io.EOF を返す
+func (s *Stream) Next() (Event, error) {
+	chunk, err := s.chunks.Next()
+	if err != nil {
+		return nil, err
+	}
+	return decodeEvent(chunk)
+}
+
+// Events は ストリームの終わりまでイベントを順に返す
+// 読み込みに失敗した場合はエラーを返して終わる
+func (s *Stream) Events() iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		for {
+			event, err := s.Next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(event, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Close は レスポンスのボディを閉じる
+func (s *Stream) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+func decodeEvent(chunk *pdtp.Chunk) (Event, error) {
+	var event Event
+	switch chunk.Type {
+	case pdtp.DataTypePage:
+		event = &PageEvent{}
+	case pdtp.DataTypeText:
+		event = &TextEvent{}
+	case pdtp.DataTypeImage:
+		event = &ImageEvent{}
+	case pdtp.DataTypeFont:
+		event = &FontEvent{}
+	case pdtp.DataTypePath:
+		event = &PathEvent{}
+	case pdtp.DataTypePageDone:
+		event = &PageDoneEvent{}
+	case pdtp.DataTypeDone:
+		event = &DoneEvent{}
+	case pdtp.DataTypeMetadata:
+		event = &MetadataEvent{}
+	case pdtp.DataTypeError:
+		event = &ErrorEvent{}
+	default:
+		return &RawEvent{Chunk: chunk}, nil
+	}
+	if err := json.Unmarshal(chunk.JSON, event); err != nil {
+		return nil, fmt.Errorf("pdtpclient: %s: %w", chunk.TypeName(), err)
+	}
+	// JSON に続くバイナリを分ける (長さは読み込み時に検証済み)
+	switch e := event.(type) {
+	case *ImageEvent:
+		e.Data, e.MaskData = chunk.Payload[:e.Length], chunk.Payload[e.Length:]
+	case *FontEvent:
+		e.Font = chunk.Payload
+	case *PathEvent:
+		e.MaskData = chunk.Payload
+	}
+	return event, nil
+}
@@ -0,0 +1,43 @@
+package pdtp
+
+import (
+	"context"
+	"net/http"
+)
+
+// rateLimitBytes は RateLimiter.Wait を呼ぶ間隔 (送ったバイト数)
+const rateLimitBytes = 1 << 20
+
+// RateLimiter は 公開したエンドポイントで、クライアントごとの要求の数と送る量を制限する
+// golang.org/x/time/rate の Limiter をクライアントごとに持つ実装などを想定する
+type RateLimiter interface {
+	// Allow は 要求を受け付ける場合に true を返す (false の場合は 429 Too Many Requests を返す)
+	Allow(r *http.Request) bool
+	// Wait は 1MiB を送るたびに呼ばれ、続きを送ってよくなるまで待つ (送る量を制限しない場合は nil を返す)
+	// エラーを返した場合はストリームを中止する。ctx から RequestFromContext で要求を取り出せる
+	Wait(ctx context.Context) error
+}
+
+// streamThrottle は 送ったバイト数が 1MiB を超えるたびに RateLimiter.Wait を呼ぶ
+type streamThrottle struct {
+	limiter RateLimiter
+	next    int64
+}
+
+func newStreamThrottle(limiter RateLimiter) *streamThrottle {
+	return &streamThrottle{limiter: limiter, next: rateLimitBytes}
+}
+
+// wait は sent バイトを送った後に、制限に達していれば待つ
+func (t *streamThrottle) wait(ctx context.Context, sent int64) error {
+	if t.limiter == nil || sent < t.next {
+		return nil
+	}
+	for t.next <= sent {
+		t.next += rateLimitBytes
+		if err := t.limiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package pdtp
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// TestRateLimiterAllow は RateLimiter.Allow が false を返した要求に、PDF を開かずに 429 と Retry-After を返すことを確かめる
+func TestRateLimiterAllow(t *testing.T) {
+	var opens atomic.Int32
+	open := OpenUnder("cmd/pdtp/testdata/conform")
+	limiter := &countingLimiter{}
+	handler := NewPDFProtocolHandler(Config{
+		OpenPDF: func(ctx context.Context, r *http.Request, fileName string) (IPDFFile, error) {
+			opens.Add(1)
+			return open(ctx, r, fileName)
+		},
+		RateLimiter: limiter,
+		Logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	for _, test := range []struct {
+		allow  bool
+		status int
+		opens  int32
+	}{
+		{false, http.StatusTooManyRequests, 0},
+		{true, http.StatusOK, 1},
+	} {
+		limiter.deny.Store(!test.allow)
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, "/?file=multipage.pdf", nil))
+		if rec.Code != test.status {
+			t.Errorf("allow %t: status = %d, want %d", test.allow, rec.Code, test.status)
+		}
+		if got := rec.Header().Get("Retry-After"); (got != "") != !test.allow {
+			t.Errorf("allow %t: Retry-After = %q", test.allow, got)
+		}
+		if n := opens.Load(); n != test.opens {
+			t.Errorf("allow %t: opened the PDF %d times, want %d", test.allow, n, test.opens)
+		}
+	}
+}
+
+// TestRateLimiterWait は 1MiB を送るたびに RateLimiter.Wait を呼び、Wait がエラーを返した場合はストリームを中止することを確かめる
+func TestRateLimiterWait(t *testing.T) {
+	dir := t.TempDir()
+	// 圧縮しても小さくならない 1.5MiB ほどの画像を1つ含む PDF
+	writeImagePDF(t, filepath.Join(dir, "large.pdf"), 1, 720, 720)
+	for _, test := range []struct {
+		name    string
+		waitErr error
+	}{
+		{"wait", nil},
+		{"wait error", errors.New("quota exceeded")},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			limiter := &countingLimiter{waitErr: test.waitErr}
+			rec := httptest.NewRecorder()
+			NewPDFProtocolHandler(Config{
+				OpenPDF:     OpenUnder(dir),
+				RateLimiter: limiter,
+				Logger:      slog.New(slog.NewTextHandler(io.Discard, nil)),
+			})(rec, httptest.NewRequest(http.MethodGet, "/?file=large.pdf", nil))
+			chunks := readAllChunks(t, rec.Body.Bytes(), ProtocolVersion1, ChunkEncodingJSON)
+			last := chunks[len(chunks)-1].Type
+			if test.waitErr == nil {
+				if want := int32(rec.Body.Len() / rateLimitBytes); want == 0 || limiter.waits.Load() != want {
+					t.Errorf("Wait called %d times for %d bytes, want %d", limiter.waits.Load(), rec.Body.Len(), want)
+				}
+				if last != DataTypeDone {
+					t.Errorf("stream ends with %s, want done", chunks[len(chunks)-1].TypeName())
+				}
+				return
+			}
+			if limiter.waits.Load() != 1 || last != DataTypeError {
+				t.Errorf("Wait called %d times and the stream ends with %s, want one call and an error", limiter.waits.Load(), chunks[len(chunks)-1].TypeName())
+			}
+		})
+	}
+}
+
+// countingLimiter は Allow と Wait の呼び出しを数える RateLimiter
+type countingLimiter struct {
+	deny    atomic.Bool
+	waits   atomic.Int32
+	waitErr error
+}
+
+func (c *countingLimiter) Allow(r *http.Request) bool { return !c.deny.Load() }
+
+func (c *countingLimiter) Wait(ctx context.Context) error {
+	c.waits.Add(1)
+	return c.waitErr
+}
+
+// writeImagePDF は width × height のランダムな RGB の画像を images 個描画する1ページの PDF を path に書き込む
+func writeImagePDF(t *testing.T, path string, images, width, height int) {
+	t.Helper()
+	r := rand.New(rand.NewPCG(3, 4))
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string, stream []byte) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\n", len(offsets), body)
+		if stream != nil {
+			fmt.Fprintf(&buf, "stream\n%s\nendstream\n", stream)
+		}
+		buf.WriteString("endobj\n")
+	}
+	var content, xobjects strings.Builder
+	for i := range images {
+		fmt.Fprintf(&content, "q 100 0 0 100 %d 150 cm /Im%d Do Q\n", 50+i*110, i+1)
+		fmt.Fprintf(&xobjects, "/Im%d %d 0 R ", i+1, 6+i)
+	}
+	buf.WriteString("%PDF-1.7\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>", nil)
+	object("<< /Type /Pages /Kids [3 0 R] /Count 1 >>", nil)
+	object("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources 5 0 R /Contents 4 0 R >>", nil)
+	object(fmt.Sprintf("<< /Length %d >>", content.Len()), []byte(content.String()))
+	object("<< /XObject << "+xobjects.String()+">> >>", nil)
+	for range images {
+		samples := make([]byte, width*height*3)
+		for i := range samples {
+			samples[i] = byte(r.UintN(256))
+		}
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		zw.Write(samples)
+		zw.Close()
+		object(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode /Length %d >>", width, height, compressed.Len()), compressed.Bytes())
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,127 @@
+package pdtp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxRequestSpecBytes は POST の本文の大きさの上限
+const maxRequestSpecBytes = 64 << 10
+
+// RequestSpec は POST の本文 (JSON) で指定する要求
+// GET のクエリーと Pdtp ヘッダーと同じ項目を、区切り文字を気にせずに指定できる。省略した項目は既定値になる
+//
+//	{"file": "report.pdf", "start": 3, "end": 5, "encoding": "cbor", "compression": ["zstd", "gzip"]}
+type RequestSpec struct {
+	File        string   `json:"file"`                  // PDF のファイル名 (必須)
+	Format      string   `json:"format,omitempty"`      // 出力の形式 (ndjson)
+	Query       string   `json:"q,omitempty"`           // 検索する文字列 (指定した場合は検索結果を送る)
+	Start       int64    `json:"start,omitempty"`       // 読み込み範囲最小ページ
+	End         int64    `json:"end,omitempty"`         // 読み込み範囲最大ページ
+	Base        int64    `json:"base,omitempty"`        // 読みこみ基準ページ
+	Pages       string   `json:"pages,omitempty"`       // 送るページの番号と範囲 (1,5,9-12)。指定した場合は start・end を使わない
+	Normalize   string   `json:"normalize,omitempty"`   // テキストのUnicode正規化 (nfc)
+	Strip       string   `json:"strip,omitempty"`       // テキストから取り除く文字 (control)
+	Version     int      `json:"version,omitempty"`     // フレームの形式のバージョン
+	FontFix     string   `json:"fontfix,omitempty"`     // フォントの修正の扱い
+	Encoding    string   `json:"encoding,omitempty"`    // チャンクのメタデータの形式 (json / cbor)
+	MaxImageDim int      `json:"maxImageDim,omitempty"` // 画像の長辺のピクセル数の上限
+	Quality     int      `json:"quality,omitempty"`     // ImageTranscoder で変換する画像の品質 (1〜100)
+	Resume      string   `json:"resume,omitempty"`      // 中断したストリームを再開する位置
+	Session     string   `json:"session,omitempty"`     // 送ったフォントの記録に使うクライアントの識別子
+	Mode        string   `json:"mode,omitempty"`        // 送る内容 (content / meta)
+	Include     []string `json:"include,omitempty"`     // 送るページの内容の種類 (text / paths / images / fonts / annotations)
+	Compression []string `json:"compression,omitempty"` // 受け付ける圧縮方法を優先する順に (Config.CompressionMethods から選ぶ)
+}
+
+// field は 指定された項目を Pdtp ヘッダーと同じ規則で読み込む
+func (s *RequestSpec) field() (PDTPField, error) {
+	field := newPDTPField()
+	for _, kv := range []struct {
+		key   string
+		value string
+		set   bool
+	}{
+		{"start", strconv.FormatInt(s.Start, 10), s.Start != 0},
+		{"end", strconv.FormatInt(s.End, 10), s.End != 0},
+		{"base", strconv.FormatInt(s.Base, 10), s.Base != 0},
+		{"pages", s.Pages, s.Pages != ""},
+		{"normalize", s.Normalize, s.Normalize != ""},
+		{"strip", s.Strip, s.Strip != ""},
+		{"version", strconv.Itoa(s.Version), s.Version != 0},
+		{"fontfix", s.FontFix, s.FontFix != ""},
+		{"encoding", s.Encoding, s.Encoding != ""},
+		{"maxImageDim", strconv.Itoa(s.MaxImageDim), s.MaxImageDim != 0},
+		{"quality", strconv.Itoa(s.Quality), s.Quality != 0},
+		{"resume", s.Resume, s.Resume != ""},
+		{"session", s.Session, s.Session != ""},
+		{"mode", s.Mode, s.Mode != ""},
+		{"include", strings.Join(s.Include, ","), len(s.Include) > 0},
+	} {
+		if !kv.set {
+			continue
+		}
+		if err := field.set(kv.key, kv.value); err != nil {
+			return field, err
+		}
+	}
+	return field, nil
+}
+
+// pdtpRequest は GET のクエリーと Pdtp ヘッダー、または POST の本文から読み込んだ要求
+type pdtpRequest struct {
+	file        string
+	format      string
+	query       string
+	field       PDTPField
+	compression []string // クライアントが優先する圧縮方法 (POST のみ)
+	variant     string   // 送る内容を変える指定 (ETag に使う)
+}
+
+// readRequest は 要求を読み込む。POST の場合は本文の RequestSpec、それ以外はクエリーと Pdtp ヘッダーを使う
+// エラーはクライアントに送るメッセージにする
+func readRequest(w http.ResponseWriter, r *http.Request) (*pdtpRequest, error) {
+	if r.Method != http.MethodPost {
+		req := &pdtpRequest{
+			file:    r.URL.Query().Get("file"),
+			format:  r.URL.Query().Get("format"),
+			query:   r.URL.Query().Get("q"),
+			variant: r.Header.Get("Pdtp") + "\x00" + r.URL.Query().Get("format") + "\x00" + r.URL.Query().Get("q"),
+		}
+		field, err := parsePDTPField(r.Header.Get("Pdtp"))
+		if err != nil {
+			return req, err
+		}
+		req.field = field
+		return req, nil
+	}
+	req := &pdtpRequest{field: newPDTPField()}
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "application/json" {
+			return req, fmt.Errorf("invalid request body: unsupported content type %q", contentType)
+		}
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSpecBytes))
+	if err != nil {
+		return req, fmt.Errorf("invalid request body: %v", err)
+	}
+	var spec RequestSpec
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&spec); err != nil {
+		return req, fmt.Errorf("invalid request body: %v", err)
+	}
+	req.file, req.format, req.query = spec.File, spec.Format, spec.Query
+	req.compression = spec.Compression
+	req.variant = string(body)
+	if req.field, err = spec.field(); err != nil {
+		return req, fmt.Errorf("invalid request body: %w", err)
+	}
+	return req, nil
+}
@@ -0,0 +1,109 @@
+package pdtp
+
+import (
+	"image"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestPostRequest は POST の本文の形式・未知の項目・大きさを確かめ、compression を Accept-Encoding より優先することを確かめる
+func TestPostRequest(t *testing.T) {
+	handler := NewPDFProtocolHandler(Config{
+		OpenPDF:            OpenUnder("cmd/pdtp/testdata/conform"),
+		CompressionMethods: []CompressionMethod{ZstdCompression{}, GzipCompression{}},
+	})
+	for _, test := range []struct {
+		name           string
+		contentType    string
+		body           string
+		acceptEncoding string
+		status         int
+		encoding       string // 成功した場合の Content-Encoding
+	}{
+		{"json", "application/json", `{"file": "multipage.pdf", "start": 1, "end": 1}`, "", http.StatusOK, ""},
+		{"json with charset", "application/json; charset=utf-8", `{"file": "multipage.pdf"}`, "", http.StatusOK, ""},
+		{"no content type", "", `{"file": "multipage.pdf"}`, "", http.StatusOK, ""},
+		{"unsupported content type", "application/x-www-form-urlencoded", "file=multipage.pdf", "", http.StatusBadRequest, ""},
+		{"unknown field", "application/json", `{"file": "multipage.pdf", "stat": 1}`, "", http.StatusBadRequest, ""},
+		{"invalid option", "application/json", `{"file": "multipage.pdf", "quality": 101}`, "", http.StatusBadRequest, ""},
+		{"oversized body", "application/json", `{"file": "multipage.pdf", "q": "` + strings.Repeat("a", maxRequestSpecBytes) + `"}`, "", http.StatusBadRequest, ""},
+		{"Accept-Encoding", "application/json", `{"file": "multipage.pdf"}`, "gzip, zstd", http.StatusOK, "zstd"},
+		{"compression preference", "application/json", `{"file": "multipage.pdf", "compression": ["gzip", "zstd"]}`, "gzip, zstd", http.StatusOK, "gzip"},
+		{"unknown compression", "application/json", `{"file": "multipage.pdf", "compression": ["br", "zstd"]}`, "gzip", http.StatusOK, "zstd"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+			if test.contentType != "" {
+				req.Header.Set("Content-Type", test.contentType)
+			}
+			if test.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", test.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, test.status, rec.Body)
+			}
+			if got := rec.Header().Get("Content-Encoding"); test.status == http.StatusOK && got != test.encoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, test.encoding)
+			}
+		})
+	}
+}
+
+// TestRequestQuality は 要求の quality が Config.TranscodeQuality より低い場合だけ使われることを確かめる
+func TestRequestQuality(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		config  int
+		body    string
+		quality int // ImageTranscoder に渡される品質
+	}{
+		{"default", 0, `{"file": "image.pdf"}`, DefaultTranscodeQuality},
+		{"lower than the default", 0, `{"file": "image.pdf", "quality": 40}`, 40},
+		{"higher than the default", 0, `{"file": "image.pdf", "quality": 95}`, DefaultTranscodeQuality},
+		{"lower than the config", 60, `{"file": "image.pdf", "quality": 30}`, 30},
+		{"higher than the config", 60, `{"file": "image.pdf", "quality": 70}`, 60},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			transcoder := &recordingTranscoder{}
+			handler := NewPDFProtocolHandler(Config{
+				OpenPDF:          OpenUnder("cmd/pdtp/testdata/conform"),
+				ImageTranscoder:  transcoder,
+				TranscodeQuality: test.config,
+			})
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+			}
+			transcoder.mu.Lock()
+			defer transcoder.mu.Unlock()
+			if len(transcoder.qualities) == 0 {
+				t.Fatal("no image was transcoded")
+			}
+			if slices.ContainsFunc(transcoder.qualities, func(q int) bool { return q != test.quality }) {
+				t.Errorf("qualities = %v, want %d", transcoder.qualities, test.quality)
+			}
+		})
+	}
+}
+
+// recordingTranscoder は 渡された品質を記録し、変換せずに元の画像を送らせる ImageTranscoder
+type recordingTranscoder struct {
+	mu        sync.Mutex
+	qualities []int
+}
+
+func (r *recordingTranscoder) Transcode(img image.Image, quality int) ([]byte, string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.qualities = append(r.qualities, quality)
+	// 元のデータより大きいため変換しない
+	return make([]byte, 1<<20), "webp", nil
+}
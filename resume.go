@@ -0,0 +1,187 @@
+package pdtp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// StreamCursor は 中断したストリームを再開する位置
+// 画像の優先度やフォントの記録によってチャンクの順番と数は変わるため、送ったチャンクの数ではなく
+// PageDoneChunk まで送ったページで表す。再開したストリームは完了したページのチャンクを送らない
+type StreamCursor struct {
+	Key   string     // 文書と、送る内容を決めるオプションのハッシュ (一致しない要求では再開しない)
+	Pages PageRanges // PageDoneChunk まで送ったページ
+}
+
+// String は Key とページを . でつなげる (3fa2c1d07b9e4a51.1-3,5)
+func (c StreamCursor) String() string {
+	return c.Key + "." + c.Pages.String()
+}
+
+// ParseStreamCursor は PageDoneChunk・ProgressChunk の cursor の値を読み込む
+func ParseStreamCursor(s string) (StreamCursor, error) {
+	key, pages, ok := strings.Cut(s, ".")
+	if !ok {
+		return StreamCursor{}, errors.New("invalid cursor")
+	}
+	if _, err := hex.DecodeString(key); err != nil || len(key) != resumeKeyLength*2 {
+		return StreamCursor{}, fmt.Errorf("invalid cursor key %q", key)
+	}
+	cursor := StreamCursor{Key: key}
+	if pages != "" {
+		ranges, err := ParsePageRanges(pages)
+		if err != nil {
+			return StreamCursor{}, fmt.Errorf("invalid cursor pages: %w", err)
+		}
+		cursor.Pages = ranges
+	}
+	return cursor, nil
+}
+
+// resumeKeyLength は StreamCursor.Key にするハッシュのバイト数
+const resumeKeyLength = 8
+
+// resumeKey は 文書と、送るページ・チャンクの内容を変えるオプションのハッシュを返す
+// 基準ページは解析の順番を変え、共有する内容や画像の ID の割り当てが変わるため含める
+func resumeKey(documentID string, config ParserConfig, field PDTPField, query string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%d:%d:%d:%s:%s\x00", documentID, field.Start, field.End, field.Base, field.Mode, query)
+	fmt.Fprintf(h, "%s\x00%s\x00%+v\x00%d\x00%s\x00", config.Pages, config.Include, config.TextOptions, config.MaxImageDim, config.FontFix)
+	fmt.Fprintf(h, "%v:%v:%v:%v:%v:%v:%v:%v:%v:%v\x00",
+		config.DedupRunningContent, config.PaintBatches, config.CoalesceText, config.GroupLines, config.ImageMetadata,
+		config.Attachments, config.ShapingHints, config.StructureTree, config.PageFingerprint, config.ICCProfiles)
+	fmt.Fprintf(h, "%v:%v:%v:%d:%d:%d:%v:%d\x00",
+		config.OffPagePolicy, config.FsTypePolicy, config.PageSummary, config.ThumbnailSize, config.MaxImagePixels,
+		config.ImagePreviewSize, config.DedupImages, config.TranscodeQuality)
+	fmt.Fprintf(h, "%v:%v\x00", config.CropImagesToClip, config.ImageTranscoder != nil)
+	return hex.EncodeToString(h.Sum(nil)[:resumeKeyLength])
+}
+
+// checkResume は cursor からこの要求のストリームを再開できるかを確かめる
+func checkResume(cursor *StreamCursor, key string, config Config) error {
+	if cursor == nil {
+		return nil
+	}
+	invalid := func(reason string) error {
+		return &PDTPHeaderError{Field: "resume", Value: cursor.String(), Reason: reason}
+	}
+	if cursor.Key != key {
+		return invalid("the cursor is for a different document or options")
+	}
+	if config.PrioritizeImages && (config.DedupImages || config.ImagePreviewSize > 0) {
+		// 画像の ID は送った順に割り当てるため、表示位置で順番が変わると前の接続で届いた ID と一致しない
+		return invalid("streams with prioritized image IDs cannot be resumed")
+	}
+	return nil
+}
+
+// resumeTracker は 完了したページを記録し、再開する場合は前の接続で完了したページのチャンクを読み捨てる
+// PageDoneChunk と ProgressChunk には、クライアントが再開に使う位置を付ける
+type resumeTracker struct {
+	key       string
+	skip      map[int64]bool  // 前の接続で完了したページ
+	skipFonts map[string]bool // 前の接続で完了したページで使ったフォント
+	done      []int64         // 完了したページ (昇順)
+}
+
+func newResumeTracker(key string, resume *StreamCursor) *resumeTracker {
+	t := &resumeTracker{
+		key:       key,
+		skip:      make(map[int64]bool),
+		skipFonts: make(map[string]bool),
+	}
+	if resume != nil {
+		for _, r := range resume.Pages {
+			for page := r.Start; page <= r.End; page++ {
+				t.skip[page] = true
+				t.done = append(t.done, page)
+			}
+		}
+		slices.Sort(t.done)
+		t.done = slices.Compact(t.done)
+	}
+	return t
+}
+
+// deliver は data を送る場合に true を返す
+func (t *resumeTracker) deliver(data ParsedData) bool {
+	switch d := data.(type) {
+	case *ParsedProgress:
+		d.Cursor = t.cursor().String()
+		return true
+	case *ParsedFont:
+		// 完了したページのフォントはクライアントが持っている
+		return !t.skipFonts[d.FontID]
+	}
+	page := chunkPage(data)
+	if page == 0 {
+		// 文書全体のチャンクは再開するたびに送る
+		return true
+	}
+	if t.skip[page] {
+		if text, ok := data.(*ParsedText); ok {
+			t.skipFonts[text.FontID] = true
+		}
+		return false
+	}
+	if done, ok := data.(*ParsedPageDone); ok {
+		if i, found := slices.BinarySearch(t.done, page); !found {
+			t.done = slices.Insert(t.done, i, page)
+		}
+		done.Cursor = t.cursor().String()
+	}
+	return true
+}
+
+// cursor は 現在の再開位置を返す (連続したページは範囲にまとめる)
+func (t *resumeTracker) cursor() StreamCursor {
+	cursor := StreamCursor{Key: t.key}
+	for _, page := range t.done {
+		if n := len(cursor.Pages); n > 0 && cursor.Pages[n-1].End == page-1 {
+			cursor.Pages[n-1].End = page
+			continue
+		}
+		cursor.Pages = append(cursor.Pages, PageRange{Start: page, End: page})
+	}
+	return cursor
+}
+
+// chunkPage は ページの PageDoneChunk より前に送るチャンクのページを返す (文書全体のチャンクは 0)
+// 添付ファイルは全ページの後に送るため、注釈のページがあっても文書全体のチャンクとして扱う
+func chunkPage(data ParsedData) int64 {
+	switch d := data.(type) {
+	case *ParsedPage:
+		return d.Page
+	case *ParsedText:
+		return d.Page
+	case *ParsedPath:
+		return d.Page
+	case *ParsedSharedContent:
+		return d.Page
+	case *ParsedImage:
+		return d.Page
+	case *ParsedImagePlacement:
+		return d.Page
+	case *ParsedBatch:
+		return d.Page
+	case *ParsedAnnotation:
+		return d.Page
+	case *ParsedLink:
+		return d.Page
+	case *ParsedWarning:
+		return d.Page
+	case *ParsedSearchResult:
+		return d.Page
+	case *ParsedThumbnail:
+		return d.Page
+	case *ParsedPageSummary:
+		return d.Page
+	case *ParsedPageDone:
+		return d.Page
+	}
+	return 0
+}
@@ -0,0 +1,109 @@
+package pdtp
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestResume は PageDoneChunk の cursor で再開したストリームが、完了したページのチャンクとそのページのフォントを送らず、
+// 残りのページを中断しなかった場合と同じチャンクで送ることを確かめる
+func TestResume(t *testing.T) {
+	handler := NewPDFProtocolHandler(Config{
+		OpenPDF: OpenUnder("cmd/pdtp/testdata/conform"),
+		Logger:  slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	stream := func(header string) (int, []*Chunk) {
+		req := httptest.NewRequest(http.MethodGet, "/?file=multipage.pdf", nil)
+		req.Header.Set("Pdtp", header)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec.Code, readAllChunks(t, rec.Body.Bytes(), ProtocolVersion1, ChunkEncodingJSON)
+	}
+	// chunkInfo は チャンクの JSON からページ・フォント・cursor を取り出す
+	type chunkInfo struct {
+		Page   int64  `json:"page"`
+		FontID string `json:"fontID"`
+		Cursor string `json:"cursor"`
+	}
+	info := func(chunk *Chunk) chunkInfo {
+		var i chunkInfo
+		if err := json.Unmarshal(chunk.JSON, &i); err != nil {
+			t.Fatal(err)
+		}
+		return i
+	}
+
+	_, full := stream("start=1;end=3")
+	var cursors []string
+	for _, chunk := range full {
+		if chunk.Type == DataTypePageDone {
+			cursors = append(cursors, info(chunk).Cursor)
+		}
+	}
+	if len(cursors) != 3 {
+		t.Fatalf("%d PageDone chunks, want 3", len(cursors))
+	}
+
+	status, resumed := stream("start=1;end=3;resume=" + cursors[0])
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	// 1ページ目のテキストのフォントはクライアントが持っている
+	page1Fonts := make(map[string]bool)
+	var want []*Chunk
+	for _, chunk := range full {
+		switch i := info(chunk); {
+		case i.Page == 1 && chunk.Type == DataTypeText:
+			page1Fonts[i.FontID] = true
+		case i.Page >= 2:
+			want = append(want, chunk)
+		}
+	}
+	var got []*Chunk
+	for _, chunk := range resumed {
+		i := info(chunk)
+		if i.Page == 1 {
+			t.Errorf("resumed stream sent a %s chunk of completed page 1", chunk.TypeName())
+		}
+		if chunk.Type == DataTypeFont {
+			var font struct{ FontID string }
+			json.Unmarshal(chunk.JSON, &font)
+			if page1Fonts[font.FontID] {
+				t.Errorf("resumed stream sent font %s used on completed page 1", font.FontID)
+			}
+		}
+		if i.Page >= 2 {
+			got = append(got, chunk)
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("resumed stream sent %d chunks for pages 2-3, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if got[i].Type != want[i].Type || !bytes.Equal(got[i].JSON, want[i].JSON) || !bytes.Equal(got[i].Payload, want[i].Payload) {
+			t.Errorf("chunk %d (%s) differs from the uninterrupted stream", i, got[i].TypeName())
+		}
+	}
+
+	for _, header := range []string{
+		"start=2;end=3;resume=" + cursors[0],      // オプションが異なる
+		"start=1;end=3;resume=0123456789abcdef.1", // 別の文書
+		"start=1;end=3;resume=not-a-cursor",
+	} {
+		status, chunks := stream(header)
+		if status != http.StatusBadRequest || len(chunks) != 1 || chunks[0].Type != DataTypeError {
+			t.Errorf("%s: status = %d with %d chunks, want 400 with an error chunk", header, status, len(chunks))
+			continue
+		}
+		var args ErrorChunkArgs
+		json.Unmarshal(chunks[0].JSON, &args)
+		if args.Field != "resume" {
+			t.Errorf("%s: error field = %q, want resume", header, args.Field)
+		}
+	}
+}
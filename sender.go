@@ -3,17 +3,33 @@ package pdtp
 import (
 	"encoding/binary"
 	"encoding/json"
-	"log"
 	"net/http"
 )
 
 const (
-	DataTypePage  = byte(0x00)
-	DataTypeText  = byte(0x01)
-	DataTypeImage = byte(0x02)
-	DataTypeFont  = byte(0x03)
-	DataTypePath  = byte(0x04)
-	DataTypeError = byte(0xFF)
+	DataTypePage        = byte(0x00)
+	DataTypeText        = byte(0x01)
+	DataTypeImage       = byte(0x02)
+	DataTypeFont        = byte(0x03)
+	DataTypePath        = byte(0x04)
+	DataTypeShared      = byte(0x05)
+	DataTypeBatch       = byte(0x06)
+	DataTypeAnnotation  = byte(0x07)
+	DataTypeLink        = byte(0x08)
+	DataTypeAttachment  = byte(0x09)
+	DataTypeWarning     = byte(0x0A)
+	DataTypeSearch      = byte(0x0B)
+	DataTypeThumbnail   = byte(0x0C)
+	DataTypePageDone    = byte(0x0D)
+	DataTypeDone        = byte(0x0E)
+	DataTypeProgress    = byte(0x0F)
+	DataTypeICCProfile  = byte(0x10)
+	DataTypePageSummary = byte(0x11)
+	DataTypePlacement   = byte(0x12)
+	// DataTypeContinuation は Config.MaxFrameSize を超えて分割したバイナリの続き
+	DataTypeContinuation = byte(0x13)
+	DataTypeMetadata     = byte(0x14)
+	DataTypeError        = byte(0xFF)
 )
 
 type IChunk interface {
@@ -30,6 +46,11 @@ type NewPageChunkArgs struct {
 	Width  float64 `json:"width"`
 	Height float64 `json:"height"`
 	Page   int64   `json:"page"`
+	Lang   string  `json:"lang"`
+	Script string  `json:"script"`
+	Label  string  `json:"label"`
+
+	Fingerprint string `json:"fingerprint,omitempty"`
 }
 
 func NewPageChunk(args *NewPageChunkArgs) *PageChunk {
@@ -49,21 +70,20 @@ func (p *PageChunk) Send(w FlusherWriter, flusher http.Flusher) error {
 	lengthBuf := make([]byte, 4)
 	binary.BigEndian.PutUint32(lengthBuf, messageLength)
 	if _, err := w.Write([]byte{messageType}); err != nil {
-		log.Printf("Failed to write message length: %v", err)
 		return err
 	}
 
 	if _, err := w.Write(lengthBuf); err != nil {
-		log.Printf("Failed to write message length: %v", err)
 		return err
 	}
 
 	if _, err := w.Write(messageData); err != nil {
-		log.Printf("Failed to write message messageLength: %v", err)
 		return err
 	}
 
-	w.Flush()
+	if err := w.Flush(); err != nil {
+		return err
+	}
 	flusher.Flush()
 
 	return nil
@@ -71,13 +91,31 @@ func (p *PageChunk) Send(w FlusherWriter, flusher http.Flusher) error {
 
 type TextChunkArgs struct {
 	X        float64 `json:"x"`
-	Y        float64 `json:"y"`
+	Y        float64 `json:"y"` // ベースラインの位置 (ページ上端からの距離)、グリフの上端ではない
 	Z        int64   `json:"z"`
 	Text     string  `json:"text"`
+	Width    float64 `json:"width"`
 	FontID   string  `json:"fontID"`
 	FontSize float64 `json:"fontSize"`
 	Page     int64   `json:"page"`
 	Color    string  `json:"color"`
+
+	StrokeAlpha float64    `json:"strokeAlpha"`
+	FillAlpha   float64    `json:"fillAlpha"`
+	BlendMode   string     `json:"blendMode"`
+	ClipPaths   []ClipPath `json:"clipPaths"`
+	OffPage     bool       `json:"offPage"`
+	SharedID    string     `json:"sharedID,omitempty"`
+	Glyphs      []int      `json:"glyphs,omitempty"`
+	Clusters    []int      `json:"clusters,omitempty"`
+	// タグ付き PDF の構造ツリーから得た構造種別 (H1 / P / TD など) と読み順
+	Role         string `json:"role,omitempty"`
+	StructID     int    `json:"structId,omitempty"`
+	ReadingOrder int    `json:"readingOrder,omitempty"`
+	// 行単位にまとめたテキストの単語の区切り
+	Words []TextWord `json:"words,omitempty"`
+	// 後処理で置き換えた検索・抽出用のテキスト (描画には text を使う)
+	ActualText *string `json:"actualText,omitempty"`
 }
 
 type TextChunk struct {
@@ -103,21 +141,20 @@ func (p *TextChunk) Send(w FlusherWriter, flusher http.Flusher) error {
 	lengthBuf := make([]byte, 4)
 	binary.BigEndian.PutUint32(lengthBuf, messageLength)
 	if _, err := w.Write([]byte{messageType}); err != nil {
-		log.Printf("Failed to write message length: %v", err)
 		return err
 	}
 
 	if _, err := w.Write(lengthBuf); err != nil {
-		log.Printf("Failed to write message length: %v", err)
 		return err
 	}
 
 	if _, err := w.Write(messageData); err != nil {
-		log.Printf("Failed to write message messageLength: %v", err)
 		return err
 	}
 
-	w.Flush()
+	if err := w.Flush(); err != nil {
+		return err
+	}
 	flusher.Flush()
 
 	return nil
@@ -131,11 +168,32 @@ type ImageChunkArgs struct {
 	Height   float64
 	DW       float64
 	DH       float64
+	Matrix   [6]float64
 	Data     []byte
 	MaskData []byte
 	Page     int64
 	Ext      string
 	ClipPath string
+
+	StrokeAlpha float64
+	FillAlpha   float64
+	BlendMode   string
+	MaskType    string
+
+	BitsPerComponent int
+	ColorSpace       string
+	ImageMask        bool
+	FillColor        string
+	ClipPaths        []ClipPath
+	OffPage          bool
+	Alt              string
+	ActualText       string
+	Metadata         *ImageMetadata
+	Tile             *ImageTile
+	ICCProfile       string
+	ImageID          string
+	Preview          bool
+	Crop             *ImageCrop
 }
 
 type ImageChunk struct {
@@ -147,18 +205,39 @@ type ImageChunk struct {
 }
 
 type SendImageJson struct {
-	X          float64 `json:"x"`
-	Y          float64 `json:"y"`
-	Z          int64   `json:"z"`
-	Width      float64 `json:"width"`
-	Height     float64 `json:"height"`
-	DW         float64 `json:"dw"`
-	DH         float64 `json:"dh"`
-	Length     int64   `json:"length"`
-	MaskLength int64   `json:"maskLength"`
-	Page       int64   `json:"page"`
-	Ext        string  `json:"ext"`
-	ClipPath   string  `json:"clipPath"`
+	X          float64    `json:"x"`
+	Y          float64    `json:"y"`
+	Z          int64      `json:"z"`
+	Width      float64    `json:"width"`
+	Height     float64    `json:"height"`
+	DW         float64    `json:"dw"`
+	DH         float64    `json:"dh"`
+	Matrix     [6]float64 `json:"matrix"`
+	Length     int64      `json:"length"`
+	MaskLength int64      `json:"maskLength"`
+	Page       int64      `json:"page"`
+	Ext        string     `json:"ext"`
+	ClipPath   string     `json:"clipPath"`
+
+	StrokeAlpha float64 `json:"strokeAlpha"`
+	FillAlpha   float64 `json:"fillAlpha"`
+	BlendMode   string  `json:"blendMode"`
+	MaskType    string  `json:"maskType"`
+
+	BitsPerComponent int            `json:"bitsPerComponent"`
+	ColorSpace       string         `json:"colorSpace"`
+	ImageMask        bool           `json:"imageMask"`
+	FillColor        string         `json:"fillColor"`
+	ClipPaths        []ClipPath     `json:"clipPaths"`
+	OffPage          bool           `json:"offPage"`
+	Alt              string         `json:"alt,omitempty"`
+	ActualText       string         `json:"actualText,omitempty"`
+	Metadata         *ImageMetadata `json:"metadata,omitempty"`
+	Tile             *ImageTile     `json:"tile,omitempty"`
+	ICCProfile       string         `json:"iccProfile,omitempty"`
+	ImageID          string         `json:"imageID,omitempty"`
+	Preview          bool           `json:"preview,omitempty"`
+	Crop             *ImageCrop     `json:"crop,omitempty"`
 }
 
 func NewImageChunk(args *ImageChunkArgs) *ImageChunk {
@@ -171,11 +250,32 @@ func NewImageChunk(args *ImageChunkArgs) *ImageChunk {
 			Height:     args.Height,
 			DW:         args.DW,
 			DH:         args.DH,
+			Matrix:     args.Matrix,
 			Length:     int64(len(args.Data)),
 			MaskLength: int64(len(args.MaskData)),
 			Page:       args.Page,
 			Ext:        args.Ext,
 			ClipPath:   args.ClipPath,
+
+			StrokeAlpha: args.StrokeAlpha,
+			FillAlpha:   args.FillAlpha,
+			BlendMode:   args.BlendMode,
+			MaskType:    args.MaskType,
+
+			BitsPerComponent: args.BitsPerComponent,
+			ColorSpace:       args.ColorSpace,
+			ImageMask:        args.ImageMask,
+			FillColor:        args.FillColor,
+			ClipPaths:        args.ClipPaths,
+			OffPage:          args.OffPage,
+			Alt:              args.Alt,
+			ActualText:       args.ActualText,
+			Metadata:         args.Metadata,
+			Tile:             args.Tile,
+			ICCProfile:       args.ICCProfile,
+			ImageID:          args.ImageID,
+			Preview:          args.Preview,
+			Crop:             args.Crop,
 		},
 		Data:     &args.Data,
 		MaskData: &args.MaskData,
@@ -196,27 +296,29 @@ func (p *ImageChunk) Send(w FlusherWriter, flusher http.Flusher) error {
 	lengthBuf := make([]byte, 4)
 	binary.BigEndian.PutUint32(lengthBuf, messageLength)
 	if _, err := w.Write([]byte{messageType}); err != nil {
-		log.Printf("Failed to write message length: %v", err)
 		return err
 	}
 
 	if _, err := w.Write(lengthBuf); err != nil {
-		log.Printf("Failed to write message length: %v", err)
 		return err
 	}
 
 	if _, err := w.Write(messageData); err != nil {
-		log.Printf("Failed to write message messageLength: %v", err)
 		return err
 	}
-	w.Flush()
+	if err := w.Flush(); err != nil {
+		return err
+	}
 	flusher.Flush()
 	return nil
 }
 
 type FontChunkArgs struct {
-	FontID string
-	Font   []byte
+	FontID     string
+	Font       []byte
+	Substitute string
+	Style      FontStyle
+	Metrics    FontMetrics
 }
 
 type FontChunk struct {
@@ -227,15 +329,41 @@ type FontChunk struct {
 }
 
 type SendFontJson struct {
-	FontID string
-	Length int64
+	FontID     string
+	Length     int64
+	Substitute string `json:",omitempty"` // フォントを送らない場合の代替 (serif / sans-serif / monospace)
+
+	// 読み込みに失敗した場合の代替フォント選択用の書体の情報
+	Family      string
+	Weight      int
+	ItalicAngle float64
+	Italic      bool
+	Serif       bool
+	FixedPitch  bool
+
+	// テキストの縦方向の配置に使う寸法 (Ascent / Descent は UnitsPerEm を1emとする単位)
+	Ascent     float64
+	Descent    float64
+	UnitsPerEm int
 }
 
 func NewFontChunk(args *FontChunkArgs) *FontChunk {
 	return &FontChunk{
 		json: &SendFontJson{
-			FontID: args.FontID,
-			Length: int64(len(args.Font)),
+			FontID:     args.FontID,
+			Length:     int64(len(args.Font)),
+			Substitute: args.Substitute,
+
+			Family:      args.Style.Family,
+			Weight:      args.Style.Weight,
+			ItalicAngle: args.Style.ItalicAngle,
+			Italic:      args.Style.Italic,
+			Serif:       args.Style.Serif,
+			FixedPitch:  args.Style.FixedPitch,
+
+			Ascent:     args.Metrics.Ascent,
+			Descent:    args.Metrics.Descent,
+			UnitsPerEm: args.Metrics.UnitsPerEm,
 		},
 		Font: &args.Font,
 	}
@@ -254,34 +382,44 @@ func (p *FontChunk) Send(w FlusherWriter, flusher http.Flusher) error {
 	lengthBuf := make([]byte, 4)
 	binary.BigEndian.PutUint32(lengthBuf, messageLength)
 	if _, err := w.Write([]byte{messageType}); err != nil {
-		log.Printf("Failed to write message length: %v", err)
 		return err
 	}
 
 	if _, err := w.Write(lengthBuf); err != nil {
-		log.Printf("Failed to write message length: %v", err)
 		return err
 	}
 
 	if _, err := w.Write(messageData); err != nil {
-		log.Printf("Failed to write message messageLength: %v", err)
 		return err
 	}
-	w.Flush()
+	if err := w.Flush(); err != nil {
+		return err
+	}
 	flusher.Flush()
 	return nil
 }
 
 type PathChunkArgs struct {
-	X           float64 `json:"x"`
-	Y           float64 `json:"y"`
-	Z           int64   `json:"z"`
-	Width       float64 `json:"width"`
-	Height      float64 `json:"height"`
-	Page        int64   `json:"page"`
-	Path        string  `json:"path"`
-	FillColor   string  `json:"fillColor"`
-	StrokeColor string  `json:"strokeColor"`
+	X           float64    `json:"x"`
+	Y           float64    `json:"y"`
+	Z           int64      `json:"z"`
+	Width       float64    `json:"width"`
+	Height      float64    `json:"height"`
+	Page        int64      `json:"page"`
+	Path        string     `json:"path"`
+	PaintMode   string     `json:"paintMode"`
+	FillRule    string     `json:"fillRule"`
+	FillColor   string     `json:"fillColor"`
+	StrokeColor string     `json:"strokeColor"`
+	StrokeAlpha float64    `json:"strokeAlpha"`
+	FillAlpha   float64    `json:"fillAlpha"`
+	BlendMode   string     `json:"blendMode"`
+	MaskType    string     `json:"maskType"`
+	MaskLength  int64      `json:"maskLength"`
+	MaskData    []byte     `json:"-"`
+	ClipPaths   []ClipPath `json:"clipPaths"`
+	OffPage     bool       `json:"offPage"`
+	SharedID    string     `json:"sharedID,omitempty"`
 }
 
 type PathChunk struct {
@@ -291,6 +429,7 @@ type PathChunk struct {
 }
 
 func NewPathChunk(args *PathChunkArgs) *PathChunk {
+	args.MaskLength = int64(len(args.MaskData))
 	return &PathChunk{
 		json: args,
 	}
@@ -304,36 +443,952 @@ func (p *PathChunk) Send(w FlusherWriter, flusher http.Flusher) error {
 	messageType := DataTypePath
 	messageLength := uint32(len(jsonData))
 	messageData := jsonData
+	messageData = append(messageData, p.json.MaskData...)
+	lengthBuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(lengthBuf, messageLength)
+	if _, err := w.Write([]byte{messageType}); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(lengthBuf); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(messageData); err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	flusher.Flush()
+
+	return nil
+}
+
+type SharedChunkArgs struct {
+	SharedID string `json:"sharedID"`
+	Page     int64  `json:"page"`
+	Z        int64  `json:"z"`
+}
+
+// SharedChunk は 既に送った共有コンテンツ(ヘッダー・フッター)を別のページに描画させる
+type SharedChunk struct {
+	IChunk
+
+	json *SharedChunkArgs
+}
+
+func NewSharedChunk(args *SharedChunkArgs) *SharedChunk {
+	return &SharedChunk{
+		json: args,
+	}
+}
+
+func (p *SharedChunk) Send(w FlusherWriter, flusher http.Flusher) error {
+	jsonData, err := json.Marshal(&p.json)
+	if err != nil {
+		return err
+	}
+	messageType := DataTypeShared
+	messageLength := uint32(len(jsonData))
+	messageData := jsonData
+	lengthBuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(lengthBuf, messageLength)
+	if _, err := w.Write([]byte{messageType}); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(lengthBuf); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(messageData); err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	flusher.Flush()
+
+	return nil
+}
+
+type BatchChunkArgs struct {
+	Page  int64      `json:"page"`
+	Batch PaintBatch `json:"batch"`
+	Count int        `json:"count"`
+}
+
+// BatchChunk は 描画段階の境界を示し、続く count 個のチャンクがその段階に属する
+type BatchChunk struct {
+	IChunk
+
+	json *BatchChunkArgs
+}
+
+func NewBatchChunk(args *BatchChunkArgs) *BatchChunk {
+	return &BatchChunk{
+		json: args,
+	}
+}
+
+func (p *BatchChunk) Send(w FlusherWriter, flusher http.Flusher) error {
+	jsonData, err := json.Marshal(&p.json)
+	if err != nil {
+		return err
+	}
+	messageType := DataTypeBatch
+	messageLength := uint32(len(jsonData))
+	messageData := jsonData
+	lengthBuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(lengthBuf, messageLength)
+	if _, err := w.Write([]byte{messageType}); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(lengthBuf); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(messageData); err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	flusher.Flush()
+
+	return nil
+}
+
+type AnnotationChunkArgs struct {
+	Subtype  string  `json:"subtype"`
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	Width    float64 `json:"width"`
+	Height   float64 `json:"height"`
+	Contents string  `json:"contents"`
+	Color    string  `json:"color"`
+	Page     int64   `json:"page"`
+}
+
+// AnnotationChunk は ハイライト・メモ・スタンプなどの注釈を送る
+type AnnotationChunk struct {
+	IChunk
+
+	json *AnnotationChunkArgs
+}
+
+func NewAnnotationChunk(args *AnnotationChunkArgs) *AnnotationChunk {
+	return &AnnotationChunk{
+		json: args,
+	}
+}
+
+func (p *AnnotationChunk) Send(w FlusherWriter, flusher http.Flusher) error {
+	jsonData, err := json.Marshal(&p.json)
+	if err != nil {
+		return err
+	}
+	messageType := DataTypeAnnotation
+	messageLength := uint32(len(jsonData))
+	messageData := jsonData
+	lengthBuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(lengthBuf, messageLength)
+	if _, err := w.Write([]byte{messageType}); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(lengthBuf); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(messageData); err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	flusher.Flush()
+
+	return nil
+}
+
+type LinkChunkArgs struct {
+	X          float64 `json:"x"`
+	Y          float64 `json:"y"`
+	Width      float64 `json:"width"`
+	Height     float64 `json:"height"`
+	URI        string  `json:"uri,omitempty"`
+	TargetPage int64   `json:"targetPage,omitempty"`
+	Page       int64   `json:"page"`
+}
+
+// LinkChunk は クリック可能な領域と、その URI または移動先ページを送る
+type LinkChunk struct {
+	IChunk
+
+	json *LinkChunkArgs
+}
+
+func NewLinkChunk(args *LinkChunkArgs) *LinkChunk {
+	return &LinkChunk{
+		json: args,
+	}
+}
+
+func (p *LinkChunk) Send(w FlusherWriter, flusher http.Flusher) error {
+	jsonData, err := json.Marshal(&p.json)
+	if err != nil {
+		return err
+	}
+	messageType := DataTypeLink
+	messageLength := uint32(len(jsonData))
+	messageData := jsonData
+	lengthBuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(lengthBuf, messageLength)
+	if _, err := w.Write([]byte{messageType}); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(lengthBuf); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(messageData); err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	flusher.Flush()
+
+	return nil
+}
+
+type AttachmentChunkArgs struct {
+	Name        string
+	Description string
+	MimeType    string
+	Page        int64
+	Data        []byte
+}
+
+// AttachmentChunk は 添付ファイルの情報と内容を送る
+type AttachmentChunk struct {
+	IChunk
+
+	json *SendAttachmentJson
+	Data *[]byte
+}
+
+type SendAttachmentJson struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	MimeType    string `json:"mimeType"`
+	Page        int64  `json:"page"`
+	Length      int64  `json:"length"`
+}
+
+func NewAttachmentChunk(args *AttachmentChunkArgs) *AttachmentChunk {
+	return &AttachmentChunk{
+		json: &SendAttachmentJson{
+			Name:        args.Name,
+			Description: args.Description,
+			MimeType:    args.MimeType,
+			Page:        args.Page,
+			Length:      int64(len(args.Data)),
+		},
+		Data: &args.Data,
+	}
+}
+
+func (p *AttachmentChunk) Send(w FlusherWriter, flusher http.Flusher) error {
+	jsonData, err := json.Marshal(&p.json)
+	if err != nil {
+		return err
+	}
+	messageType := DataTypeAttachment
+	messageLength := uint32(len(jsonData))
+	messageData := jsonData
+	messageData = append(messageData, *p.Data...)
+
 	lengthBuf := make([]byte, 4)
 	binary.BigEndian.PutUint32(lengthBuf, messageLength)
 	if _, err := w.Write([]byte{messageType}); err != nil {
-		log.Printf("Failed to write message length: %v", err)
 		return err
 	}
 
 	if _, err := w.Write(lengthBuf); err != nil {
-		log.Printf("Failed to write message length: %v", err)
 		return err
 	}
 
 	if _, err := w.Write(messageData); err != nil {
-		log.Printf("Failed to write message messageLength: %v", err)
 		return err
 	}
 
-	w.Flush()
+	if err := w.Flush(); err != nil {
+		return err
+	}
 	flusher.Flush()
 
 	return nil
 }
 
+type ErrorChunkArgs struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Limit   string `json:"limit,omitempty"`
+	Max     int64  `json:"max,omitempty"`
+	Field   string `json:"field,omitempty"` // 読み込めなかった Pdtp ヘッダー・本文の項目 (400 の場合)
+}
+
+// ErrorChunk は 要求を処理できなかったことを知らせる。ErrorChunk の後にチャンクは送らない
 type ErrorChunk struct {
 	IChunk
 
-	Code    int
-	Message string
+	json *ErrorChunkArgs
+}
+
+func NewErrorChunk(args *ErrorChunkArgs) *ErrorChunk {
+	return &ErrorChunk{
+		json: args,
+	}
 }
 
-func (p *ErrorChunk) Send(w FlusherWriter, flusher http.Flusher, code int, message string) error {
+func (p *ErrorChunk) Send(w FlusherWriter, flusher http.Flusher) error {
+	jsonData, err := json.Marshal(&p.json)
+	if err != nil {
+		return err
+	}
+	messageType := DataTypeError
+	messageLength := uint32(len(jsonData))
+	messageData := jsonData
+	lengthBuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(lengthBuf, messageLength)
+	if _, err := w.Write([]byte{messageType}); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(lengthBuf); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(messageData); err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	flusher.Flush()
+
+	return nil
+}
+
+type WarningChunkArgs struct {
+	Message    string `json:"message"`
+	Page       int64  `json:"page,omitempty"`
+	Suppressed int    `json:"suppressed,omitempty"`
+}
+
+// WarningChunk は 解析を続けられた問題をクライアントに知らせる
+type WarningChunk struct {
+	IChunk
+
+	json *WarningChunkArgs
+}
+
+func NewWarningChunk(args *WarningChunkArgs) *WarningChunk {
+	return &WarningChunk{
+		json: args,
+	}
+}
+
+func (p *WarningChunk) Send(w FlusherWriter, flusher http.Flusher) error {
+	jsonData, err := json.Marshal(&p.json)
+	if err != nil {
+		return err
+	}
+	messageType := DataTypeWarning
+	messageLength := uint32(len(jsonData))
+	messageData := jsonData
+	lengthBuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(lengthBuf, messageLength)
+	if _, err := w.Write([]byte{messageType}); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(lengthBuf); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(messageData); err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	flusher.Flush()
+
+	return nil
+}
+
+type SearchResultChunkArgs struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	Text   string  `json:"text"`
+	Page   int64   `json:"page"`
+}
+
+// SearchResultChunk は 検索語が見つかったページと位置を送る
+type SearchResultChunk struct {
+	IChunk
+
+	json *SearchResultChunkArgs
+}
+
+func NewSearchResultChunk(args *SearchResultChunkArgs) *SearchResultChunk {
+	return &SearchResultChunk{
+		json: args,
+	}
+}
+
+func (p *SearchResultChunk) Send(w FlusherWriter, flusher http.Flusher) error {
+	jsonData, err := json.Marshal(&p.json)
+	if err != nil {
+		return err
+	}
+	messageType := DataTypeSearch
+	messageLength := uint32(len(jsonData))
+	messageData := jsonData
+	lengthBuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(lengthBuf, messageLength)
+	if _, err := w.Write([]byte{messageType}); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(lengthBuf); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(messageData); err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	flusher.Flush()
+
+	return nil
+}
+
+type ThumbnailChunkArgs struct {
+	Page   int64
+	Width  int
+	Height int
+	Data   []byte
+}
+
+// ThumbnailChunk は ページの詳細な内容より先に、ページ全体の低解像度の PNG 画像を送る
+type ThumbnailChunk struct {
+	IChunk
+
+	json *SendThumbnailJson
+	Data *[]byte
+}
+
+type SendThumbnailJson struct {
+	Page   int64 `json:"page"`
+	Width  int   `json:"width"`
+	Height int   `json:"height"`
+	Length int64 `json:"length"`
+}
+
+func NewThumbnailChunk(args *ThumbnailChunkArgs) *ThumbnailChunk {
+	return &ThumbnailChunk{
+		json: &SendThumbnailJson{
+			Page:   args.Page,
+			Width:  args.Width,
+			Height: args.Height,
+			Length: int64(len(args.Data)),
+		},
+		Data: &args.Data,
+	}
+}
+
+func (p *ThumbnailChunk) Send(w FlusherWriter, flusher http.Flusher) error {
+	jsonData, err := json.Marshal(&p.json)
+	if err != nil {
+		return err
+	}
+	messageType := DataTypeThumbnail
+	messageLength := uint32(len(jsonData))
+	messageData := jsonData
+	messageData = append(messageData, *p.Data...)
+
+	lengthBuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(lengthBuf, messageLength)
+	if _, err := w.Write([]byte{messageType}); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(lengthBuf); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(messageData); err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	flusher.Flush()
+
+	return nil
+}
+
+type PageDoneChunkArgs struct {
+	Page   int64  `json:"page"`
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// PageDoneChunk は ページの内容をすべて送り終えたことを知らせる
+type PageDoneChunk struct {
+	IChunk
+
+	json *PageDoneChunkArgs
+}
+
+func NewPageDoneChunk(args *PageDoneChunkArgs) *PageDoneChunk {
+	return &PageDoneChunk{
+		json: args,
+	}
+}
+
+func (p *PageDoneChunk) Send(w FlusherWriter, flusher http.Flusher) error {
+	jsonData, err := json.Marshal(&p.json)
+	if err != nil {
+		return err
+	}
+	messageType := DataTypePageDone
+	messageLength := uint32(len(jsonData))
+	messageData := jsonData
+	lengthBuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(lengthBuf, messageLength)
+	if _, err := w.Write([]byte{messageType}); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(lengthBuf); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(messageData); err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	flusher.Flush()
+
+	return nil
+}
+
+type DoneChunkArgs struct {
+	Pages         int `json:"pages"`
+	Texts         int `json:"texts"`
+	Images        int `json:"images"`
+	Paths         int `json:"paths"`
+	Fonts         int `json:"fonts"`
+	Shared        int `json:"shared"`
+	Annotations   int `json:"annotations"`
+	Links         int `json:"links"`
+	Attachments   int `json:"attachments"`
+	Warnings      int `json:"warnings"`
+	SearchResults int `json:"searchResults"`
+	Thumbnails    int `json:"thumbnails"`
+	ICCProfiles   int `json:"iccProfiles"`
+	PageSummaries int `json:"pageSummaries"`
+	Placements    int `json:"placements"`
+	Metadata      int `json:"metadata"`
+}
+
+// DoneChunk は ストリームの最後に送り、送ったチャンクの件数を知らせる
+// DoneChunk を受け取る前に接続が切れた場合、クライアントはストリームが途中で終わったと判断できる
+type DoneChunk struct {
+	IChunk
+
+	json *DoneChunkArgs
+}
+
+func NewDoneChunk(args *DoneChunkArgs) *DoneChunk {
+	return &DoneChunk{
+		json: args,
+	}
+}
+
+func (p *DoneChunk) Send(w FlusherWriter, flusher http.Flusher) error {
+	jsonData, err := json.Marshal(&p.json)
+	if err != nil {
+		return err
+	}
+	messageType := DataTypeDone
+	messageLength := uint32(len(jsonData))
+	messageData := jsonData
+	lengthBuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(lengthBuf, messageLength)
+	if _, err := w.Write([]byte{messageType}); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(lengthBuf); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(messageData); err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	flusher.Flush()
+
+	return nil
+}
+
+type ProgressChunkArgs struct {
+	Pages      int    `json:"pages"`
+	TotalPages int    `json:"totalPages"`
+	Bytes      int64  `json:"bytes"`
+	Cursor     string `json:"cursor,omitempty"`
+}
+
+// ProgressChunk は 処理したページ数と送ったバイト数を知らせ、クライアントが読み込みの進み具合を表示できるようにする
+type ProgressChunk struct {
+	IChunk
+
+	json *ProgressChunkArgs
+}
+
+func NewProgressChunk(args *ProgressChunkArgs) *ProgressChunk {
+	return &ProgressChunk{
+		json: args,
+	}
+}
+
+func (p *ProgressChunk) Send(w FlusherWriter, flusher http.Flusher) error {
+	jsonData, err := json.Marshal(&p.json)
+	if err != nil {
+		return err
+	}
+	messageType := DataTypeProgress
+	messageLength := uint32(len(jsonData))
+	messageData := jsonData
+	lengthBuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(lengthBuf, messageLength)
+	if _, err := w.Write([]byte{messageType}); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(lengthBuf); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(messageData); err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	flusher.Flush()
+
+	return nil
+}
+
+type ICCProfileChunkArgs struct {
+	ID           string
+	Components   int
+	OutputIntent string
+	Data         []byte
+}
+
+// ICCProfileChunk は ICC プロファイルを送る
+// 画像チャンクは iccProfile に ID を指定して、先に送ったプロファイルを参照する
+type ICCProfileChunk struct {
+	IChunk
+
+	json *SendICCProfileJson
+	Data *[]byte
+}
+
+type SendICCProfileJson struct {
+	ID           string `json:"id"`
+	Components   int    `json:"components"`
+	OutputIntent string `json:"outputIntent,omitempty"`
+	Length       int64  `json:"length"`
+}
+
+func NewICCProfileChunk(args *ICCProfileChunkArgs) *ICCProfileChunk {
+	return &ICCProfileChunk{
+		json: &SendICCProfileJson{
+			ID:           args.ID,
+			Components:   args.Components,
+			OutputIntent: args.OutputIntent,
+			Length:       int64(len(args.Data)),
+		},
+		Data: &args.Data,
+	}
+}
+
+func (p *ICCProfileChunk) Send(w FlusherWriter, flusher http.Flusher) error {
+	jsonData, err := json.Marshal(&p.json)
+	if err != nil {
+		return err
+	}
+	messageType := DataTypeICCProfile
+	messageLength := uint32(len(jsonData))
+	messageData := jsonData
+	messageData = append(messageData, *p.Data...)
+
+	lengthBuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(lengthBuf, messageLength)
+	if _, err := w.Write([]byte{messageType}); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(lengthBuf); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(messageData); err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	flusher.Flush()
+
+	return nil
+}
+
+type PageSummaryChunkArgs struct {
+	Page        int64
+	Texts       int
+	Images      int
+	Paths       int
+	TextBounds  *Rect
+	ImageBounds *Rect
+	PathBounds  *Rect
+}
+
+// PageSummaryChunk は ページの内容より先に、送るテキスト・画像・パスの件数とおおよその範囲を送る
+type PageSummaryChunk struct {
+	IChunk
+
+	json *SendPageSummaryJson
+}
+
+// SummaryBounds は ページの概要の範囲 (上端を原点とするページ座標系)
+type SummaryBounds struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+type SendPageSummaryJson struct {
+	Page        int64          `json:"page"`
+	Texts       int            `json:"texts"`
+	Images      int            `json:"images"`
+	Paths       int            `json:"paths"`
+	TextBounds  *SummaryBounds `json:"textBounds,omitempty"`
+	ImageBounds *SummaryBounds `json:"imageBounds,omitempty"`
+	PathBounds  *SummaryBounds `json:"pathBounds,omitempty"`
+}
+
+func NewPageSummaryChunk(args *PageSummaryChunkArgs) *PageSummaryChunk {
+	return &PageSummaryChunk{
+		json: &SendPageSummaryJson{
+			Page:        args.Page,
+			Texts:       args.Texts,
+			Images:      args.Images,
+			Paths:       args.Paths,
+			TextBounds:  newSummaryBounds(args.TextBounds),
+			ImageBounds: newSummaryBounds(args.ImageBounds),
+			PathBounds:  newSummaryBounds(args.PathBounds),
+		},
+	}
+}
+
+func newSummaryBounds(r *Rect) *SummaryBounds {
+	if r == nil {
+		return nil
+	}
+	return &SummaryBounds{X: r.X0, Y: r.Y0, Width: r.X1 - r.X0, Height: r.Y1 - r.Y0}
+}
+
+func (p *PageSummaryChunk) Send(w FlusherWriter, flusher http.Flusher) error {
+	jsonData, err := json.Marshal(&p.json)
+	if err != nil {
+		return err
+	}
+	messageType := DataTypePageSummary
+	messageLength := uint32(len(jsonData))
+	messageData := jsonData
+	lengthBuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(lengthBuf, messageLength)
+	if _, err := w.Write([]byte{messageType}); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(lengthBuf); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(messageData); err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	flusher.Flush()
+
+	return nil
+}
+
+type PlacementChunkArgs struct {
+	ImageID     string     `json:"imageID"`
+	X           float64    `json:"x"`
+	Y           float64    `json:"y"`
+	Z           int64      `json:"z"`
+	DW          float64    `json:"dw"`
+	DH          float64    `json:"dh"`
+	Matrix      [6]float64 `json:"matrix"`
+	Page        int64      `json:"page"`
+	ClipPath    string     `json:"clipPath"`
+	StrokeAlpha float64    `json:"strokeAlpha"`
+	FillAlpha   float64    `json:"fillAlpha"`
+	BlendMode   string     `json:"blendMode"`
+	FillColor   string     `json:"fillColor"`
+	ClipPaths   []ClipPath `json:"clipPaths"`
+	OffPage     bool       `json:"offPage"`
+	Alt         string     `json:"alt,omitempty"`
+	ActualText  string     `json:"actualText,omitempty"`
+}
+
+// PlacementChunk は 既に送った画像 (imageID) を別の位置・ページに配置させる
+type PlacementChunk struct {
+	IChunk
+
+	json *PlacementChunkArgs
+}
+
+func NewPlacementChunk(args *PlacementChunkArgs) *PlacementChunk {
+	return &PlacementChunk{
+		json: args,
+	}
+}
+
+func (p *PlacementChunk) Send(w FlusherWriter, flusher http.Flusher) error {
+	jsonData, err := json.Marshal(&p.json)
+	if err != nil {
+		return err
+	}
+	messageType := DataTypePlacement
+	messageLength := uint32(len(jsonData))
+	messageData := jsonData
+	lengthBuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(lengthBuf, messageLength)
+	if _, err := w.Write([]byte{messageType}); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(lengthBuf); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(messageData); err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	flusher.Flush()
+
+	return nil
+}
+
+type MetadataChunkArgs struct {
+	Pages     int                `json:"pages"`
+	PageSizes []MetadataPageSize `json:"pageSizes"`
+	Labels    []string           `json:"labels,omitempty"`
+	Lang      string             `json:"lang,omitempty"`
+	Info      map[string]string  `json:"info,omitempty"`
+}
+
+// MetadataPageSize は MetadataChunk の1ページの大きさ
+type MetadataPageSize struct {
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// MetadataChunk は ページの内容の代わりに、ページ数・ページの大きさ・ラベル・文書情報を送る (Pdtp ヘッダーの mode=meta)
+// labels は /PageLabels がある場合だけ、pageSizes と同じ順番で送る
+type MetadataChunk struct {
+	IChunk
+
+	json *MetadataChunkArgs
+}
+
+func NewMetadataChunk(args *MetadataChunkArgs) *MetadataChunk {
+	return &MetadataChunk{
+		json: args,
+	}
+}
+
+func (p *MetadataChunk) Send(w FlusherWriter, flusher http.Flusher) error {
+	jsonData, err := json.Marshal(&p.json)
+	if err != nil {
+		return err
+	}
+	messageType := DataTypeMetadata
+	messageLength := uint32(len(jsonData))
+	messageData := jsonData
+	lengthBuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(lengthBuf, messageLength)
+	if _, err := w.Write([]byte{messageType}); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(lengthBuf); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(messageData); err != nil {
+		return err
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	flusher.Flush()
+
 	return nil
 }
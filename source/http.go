@@ -0,0 +1,65 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/pdtp-workbench/pdtp-go"
+)
+
+// OpenURL は rawURL の PDF を範囲を指定した GET で読み込む RangeFile として開く
+// client が nil の場合は http.DefaultClient を使う
+func OpenURL(ctx context.Context, client *http.Client, rawURL string) (*RangeFile, error) {
+	return (&HTTPOrigin{HTTPClient: client}).open(ctx, rawURL)
+}
+
+// HTTPOrigin は 別のサーバーが公開している PDF を RangeFile として開く
+// ファイル全体をメモリに読み込まないため、オリジンが Range に対応していない場合は ErrRangeNotSupported を返す
+type HTTPOrigin struct {
+	// BaseURL は ファイル名を付け加える URL (例: https://cdn.example.com/pdf/)
+	BaseURL string
+	// Header は 各要求に付けるヘッダー (オリジンの認証など)
+	Header http.Header
+	// HTTPClient は 要求に使うクライアント (nil の場合は http.DefaultClient)
+	HTTPClient *http.Client
+	// BlockSize は 1回の GET で読み込むバイト数 (0 の場合は DefaultBlockSize)
+	BlockSize int64
+	// CacheBlocks は 1つのファイルで保持するブロックの数 (0 の場合は DefaultCacheBlocks)
+	CacheBlocks int
+}
+
+// OpenPDF は Config.OpenPDF として、ファイル名を BaseURL の下のパスとして開く
+// ファイル名の . と .. は取り除き、BaseURL の外を参照させない
+func (o *HTTPOrigin) OpenPDF(ctx context.Context, _ *http.Request, fileName string) (pdtp.IPDFFile, error) {
+	base, err := url.Parse(o.BaseURL)
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("source: invalid base URL %q", o.BaseURL)
+	}
+	name := strings.TrimPrefix(path.Clean("/"+fileName), "/")
+	if name == "" {
+		return nil, fmt.Errorf("%w: %q", pdtp.ErrInvalidFileName, fileName)
+	}
+	return o.open(ctx, base.JoinPath(name).String())
+}
+
+func (o *HTTPOrigin) open(ctx context.Context, rawURL string) (*RangeFile, error) {
+	client := o.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	fetch := func(ctx context.Context, start, end int64, etag string) (*http.Response, error) {
+		req, err := newRangeRequest(ctx, rawURL, start, end, etag)
+		if err != nil {
+			return nil, err
+		}
+		for name, values := range o.Header {
+			req.Header[name] = values
+		}
+		return client.Do(req)
+	}
+	return openRangeFile(ctx, fetch, o.BlockSize, o.CacheBlocks)
+}
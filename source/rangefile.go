@@ -0,0 +1,299 @@
+// Package source は Config.OpenPDF に使える、ローカルのファイル以外から PDF を読み込む IPDFFile を提供する
+//
+// 大きな PDF を丸ごとダウンロードせず、範囲を指定した GET で解析に必要な部分だけを読み込む
+//
+//	bucket, err := source.NewS3(source.S3Config{Region: "ap-northeast-1", Bucket: "documents"})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	handler := pdtp.NewPDFProtocolHandler(pdtp.Config{OpenPDF: bucket.OpenPDF})
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultBlockSize は 1回の GET で読み込む範囲の既定のバイト数
+	DefaultBlockSize = 256 << 10
+	// DefaultCacheBlocks は 読み込んだ範囲を保持する数の既定値
+	DefaultCacheBlocks = 64
+)
+
+var (
+	// ErrRangeNotSupported は サーバーが Range を無視してオブジェクトの全体を返した
+	ErrRangeNotSupported = errors.New("source: server ignored the Range header")
+	// ErrObjectChanged は 読み込みの途中でオブジェクトが置き換わった (If-Match が失敗した、または ETag・大きさが変わった)
+	ErrObjectChanged = errors.New("source: object changed while reading")
+)
+
+// newRangeRequest は start から end までを要求する GET を作成する
+func newRangeRequest(ctx context.Context, rawURL string, start, end int64, etag string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	}
+	return req, nil
+}
+
+// fetchFunc は オブジェクトの start から end まで (end を含む) を要求し、応答を返す
+// etag が空でない場合は If-Match を付け、読み込みの途中でオブジェクトが置き換わっていないことを確かめる
+type fetchFunc func(ctx context.Context, start, end int64, etag string) (*http.Response, error)
+
+// RangeFile は 範囲を指定した GET で必要な部分だけを読み込む IPDFFile
+// 読み込んだ範囲はブロック単位で保持し、xref や同じオブジェクトを読み直すたびに要求しない
+// 開いたときのコンテキストをその後の読み込みにも使うため、要求が取り消されると読み込みも中止する
+type RangeFile struct {
+	ctx       context.Context
+	fetch     fetchFunc
+	size      int64
+	etag      string
+	offset    int64
+	blockSize int64
+	blocks    *blockCache
+}
+
+// openRangeFile は 先頭のブロックを読み込み、Content-Range からオブジェクトの大きさを求める
+// ファイル全体をメモリに読み込まないため、範囲の指定を無視して全体を返すサーバーは ErrRangeNotSupported とする
+func openRangeFile(ctx context.Context, fetch fetchFunc, blockSize int64, cacheBlocks int) (*RangeFile, error) {
+	if blockSize <= 0 {
+		blockSize = DefaultBlockSize
+	}
+	if cacheBlocks <= 0 {
+		cacheBlocks = DefaultCacheBlocks
+	}
+	f := &RangeFile{ctx: ctx, fetch: fetch, blockSize: blockSize, blocks: newBlockCache(cacheBlocks)}
+	resp, err := fetch(ctx, 0, blockSize-1, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// 空のオブジェクト
+		return f, nil
+	}
+	data, size, err := readRange(resp, 0, blockSize-1)
+	if err != nil {
+		return nil, err
+	}
+	f.size = size
+	if etag := resp.Header.Get("ETag"); !strings.HasPrefix(etag, "W/") {
+		// 弱い ETag は If-Match で比較できない
+		f.etag = etag
+	}
+	f.blocks.put(0, data)
+	return f, nil
+}
+
+// ETag は 開いたときのオブジェクトの ETag を返す (弱い ETag・ETag がない場合は空)
+// DocumentCache は ETag を文書の版として使う
+func (f *RangeFile) ETag() string {
+	return f.etag
+}
+
+// Size は オブジェクトのバイト数を返す
+func (f *RangeFile) Size() int64 {
+	return f.size
+}
+
+func (f *RangeFile) Read(p []byte) (int, error) {
+	if f.offset >= f.size {
+		return 0, io.EOF
+	}
+	n, err := f.ReadAt(p, f.offset)
+	f.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// ReadAt は off から p の長さだけ読み込む。必要なブロックがなければ要求する
+func (f *RangeFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("source: negative offset")
+	}
+	n := 0
+	for n < len(p) && off < f.size {
+		index := off / f.blockSize
+		block, err := f.block(index)
+		if err != nil {
+			return n, err
+		}
+		start := off - index*f.blockSize
+		if start >= int64(len(block)) {
+			return n, io.ErrUnexpectedEOF
+		}
+		copied := copy(p[n:], block[start:])
+		n += copied
+		off += int64(copied)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *RangeFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	default:
+		return 0, errors.New("source: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("source: negative position")
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+// Close は 保持しているブロックを捨てる
+func (f *RangeFile) Close() error {
+	f.blocks = newBlockCache(1)
+	return nil
+}
+
+// block は index 番目のブロックを返す (保持していなければ要求する)
+func (f *RangeFile) block(index int64) ([]byte, error) {
+	if data, ok := f.blocks.get(index); ok {
+		return data, nil
+	}
+	start := index * f.blockSize
+	end := min(start+f.blockSize, f.size) - 1
+	resp, err := f.fetch(f.ctx, start, end, f.etag)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, size, err := readRange(resp, start, end)
+	if err != nil {
+		return nil, err
+	}
+	// If-Match を無視するサーバーでも、置き換わったオブジェクトのブロックを混ぜない
+	if etag := resp.Header.Get("ETag"); size != f.size || (f.etag != "" && etag != "" && etag != f.etag) {
+		return nil, fmt.Errorf("%w (ETag %s, %d bytes; opened as %s, %d bytes)", ErrObjectChanged, etag, size, f.etag, f.size)
+	}
+	f.blocks.put(index, data)
+	return data, nil
+}
+
+// readRange は 206 の応答が start から end まで (オブジェクトの終わりを超える場合は終わりまで) を返したことを確かめて読み込む
+// 読み込んだ内容とオブジェクトの大きさを返す
+func readRange(resp *http.Response, start, end int64) ([]byte, int64, error) {
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		return nil, 0, ErrRangeNotSupported
+	default:
+		return nil, 0, statusError(resp)
+	}
+	first, last, size, err := parseContentRange(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return nil, 0, err
+	}
+	end = min(end, size-1)
+	if first != start || last != end {
+		return nil, 0, fmt.Errorf("source: Content-Range %q does not match the requested bytes %d-%d", resp.Header.Get("Content-Range"), start, end)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, end-start+1))
+	if err != nil {
+		return nil, 0, err
+	}
+	if int64(len(data)) != end-start+1 {
+		return nil, 0, fmt.Errorf("source: short range response (%d of %d bytes)", len(data), end-start+1)
+	}
+	return data, size, nil
+}
+
+// parseContentRange は "bytes 0-99/1234" の形式の Content-Range から範囲の最初と最後のバイト、全体の大きさを取り出す
+func parseContentRange(contentRange string) (first, last, size int64, err error) {
+	invalid := fmt.Errorf("source: unexpected Content-Range %q", contentRange)
+	spec, ok := strings.CutPrefix(contentRange, "bytes ")
+	if !ok {
+		return 0, 0, 0, invalid
+	}
+	byteRange, total, ok := strings.Cut(spec, "/")
+	if !ok {
+		return 0, 0, 0, invalid
+	}
+	firstStr, lastStr, ok := strings.Cut(byteRange, "-")
+	if !ok {
+		return 0, 0, 0, invalid
+	}
+	first, err1 := strconv.ParseInt(firstStr, 10, 64)
+	last, err2 := strconv.ParseInt(lastStr, 10, 64)
+	size, err3 := strconv.ParseInt(total, 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil || first < 0 || last < first || size <= last {
+		return 0, 0, 0, invalid
+	}
+	return first, last, size, nil
+}
+
+// statusError は 失敗した応答をエラーにする
+// 404 と 403 はハンドラーが ErrorChunk のコードに変換できるよう fs.ErrNotExist・fs.ErrPermission を含める
+func statusError(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return fmt.Errorf("source: %s: %w", resp.Status, fs.ErrNotExist)
+	case http.StatusForbidden, http.StatusUnauthorized:
+		return fmt.Errorf("source: %s: %w", resp.Status, fs.ErrPermission)
+	case http.StatusPreconditionFailed:
+		return fmt.Errorf("%w (%s)", ErrObjectChanged, resp.Status)
+	}
+	return fmt.Errorf("source: unexpected response %s", resp.Status)
+}
+
+// blockCache は 最近使ったブロックを上限の数まで保持する
+type blockCache struct {
+	max    int
+	blocks map[int64][]byte
+	order  []int64 // 古い順
+}
+
+func newBlockCache(max int) *blockCache {
+	return &blockCache{max: max, blocks: make(map[int64][]byte)}
+}
+
+func (c *blockCache) get(index int64) ([]byte, bool) {
+	data, ok := c.blocks[index]
+	if ok {
+		c.touch(index)
+	}
+	return data, ok
+}
+
+func (c *blockCache) put(index int64, data []byte) {
+	if _, ok := c.blocks[index]; !ok && len(c.blocks) >= c.max {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		delete(c.blocks, oldest)
+	}
+	c.blocks[index] = data
+	c.touch(index)
+}
+
+// touch は index を最も新しく使ったブロックにする
+func (c *blockCache) touch(index int64) {
+	for i, v := range c.order {
+		if v == index {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+	c.order = append(c.order, index)
+}
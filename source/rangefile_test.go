@@ -0,0 +1,217 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testObject は テストで読み込むオブジェクト (ブロックの境界をまたいで読めるよう、1000 バイトのブロック 10 個より少し大きい)
+var testObject = func() []byte {
+	data := make([]byte, 10_500)
+	r := rand.New(rand.NewPCG(1, 2))
+	for i := range data {
+		data[i] = byte(r.UintN(256))
+	}
+	return data
+}()
+
+// openTestFile は 1000 バイトのブロックを2つまで保持する RangeFile として handler の URL を開く
+func openTestFile(t *testing.T, handler http.HandlerFunc) (*RangeFile, error) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return (&HTTPOrigin{HTTPClient: server.Client(), BlockSize: 1000, CacheBlocks: 2}).open(context.Background(), server.URL+"/object.pdf")
+}
+
+// serveObject は Range と If-Match に対応したサーバーとして data を返す
+func serveObject(w http.ResponseWriter, r *http.Request, data []byte, etag string) {
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+// TestRangeFileBlocks は ブロックの境界をまたぐ Seek・Read と、保持したブロックを要求し直さないことを確かめる
+func TestRangeFileBlocks(t *testing.T) {
+	var requests atomic.Int32
+	f, err := openTestFile(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		serveObject(w, r, testObject, `"v1"`)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Size() != int64(len(testObject)) || f.ETag() != `"v1"` {
+		t.Fatalf("Size, ETag = %d, %s", f.Size(), f.ETag())
+	}
+	read := func(offset int64, whence, n int) []byte {
+		t.Helper()
+		if _, err := f.Seek(offset, whence); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, n)
+		got, err := io.ReadFull(f, buf)
+		if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatal(err)
+		}
+		return buf[:got]
+	}
+	for _, step := range []struct {
+		name     string
+		offset   int64
+		whence   int
+		n        int
+		want     []byte
+		requests int32 // ここまでの要求の数
+	}{
+		{"first block", 10, io.SeekStart, 100, testObject[10:110], 1},
+		{"across blocks 0 and 1", 950, io.SeekStart, 100, testObject[950:1050], 2},
+		{"cached blocks", 900, io.SeekStart, 200, testObject[900:1100], 2},
+		{"relative seek into block 2", 1000, io.SeekCurrent, 10, testObject[2100:2110], 3},
+		{"evicted block 0", 0, io.SeekStart, 10, testObject[:10], 4},
+		{"short last block", -10, io.SeekEnd, 100, testObject[len(testObject)-10:], 5},
+	} {
+		if got := read(step.offset, step.whence, step.n); !bytes.Equal(got, step.want) {
+			t.Errorf("%s: read %d bytes that differ from the object", step.name, len(got))
+		}
+		if n := requests.Load(); n != step.requests {
+			t.Errorf("%s: %d requests, want %d", step.name, n, step.requests)
+		}
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	all, err := io.ReadAll(f)
+	if err != nil || !bytes.Equal(all, testObject) {
+		t.Errorf("ReadAll = %d bytes, %v; want the %d bytes of the object", len(all), err, len(testObject))
+	}
+	if _, err := f.Seek(-1, io.SeekStart); err == nil {
+		t.Error("Seek to a negative position succeeded")
+	}
+}
+
+// TestRangeFileErrors は 範囲を無視した応答・短い応答・置き換わったオブジェクトを、混ぜずにエラーにすることを確かめる
+func TestRangeFileErrors(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		handler func(w http.ResponseWriter, r *http.Request, n int32)
+		openErr bool  // 開くときに失敗する
+		want    error // errors.Is で比べるエラー (nil の場合はエラーであることだけを確かめる)
+	}{
+		{
+			name: "server ignores Range",
+			handler: func(w http.ResponseWriter, r *http.Request, n int32) {
+				w.Write(testObject)
+			},
+			openErr: true,
+			want:    ErrRangeNotSupported,
+		},
+		{
+			name: "server ignores Range after the first block",
+			handler: func(w http.ResponseWriter, r *http.Request, n int32) {
+				if n > 1 {
+					r.Header.Del("Range")
+				}
+				serveObject(w, r, testObject, `"v1"`)
+			},
+			want: ErrRangeNotSupported,
+		},
+		{
+			name: "short 206",
+			handler: func(w http.ResponseWriter, r *http.Request, n int32) {
+				var start, end int
+				fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+				end = min(end, len(testObject)-1)
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(testObject)))
+				w.WriteHeader(http.StatusPartialContent)
+				// 2つ目以降のブロックは途中までしか返さない
+				if n > 1 {
+					end -= 100
+				}
+				w.Write(testObject[start : end+1])
+			},
+		},
+		{
+			name: "206 for another range",
+			handler: func(w http.ResponseWriter, r *http.Request, n int32) {
+				r.Header.Set("Range", "bytes=0-999")
+				serveObject(w, r, testObject, `"v1"`)
+			},
+		},
+		{
+			name: "ETag changed, If-Match honoured",
+			handler: func(w http.ResponseWriter, r *http.Request, n int32) {
+				if n > 1 {
+					serveObject(w, r, testObject, `"v2"`)
+					return
+				}
+				serveObject(w, r, testObject, `"v1"`)
+			},
+			want: ErrObjectChanged,
+		},
+		{
+			name: "ETag changed, If-Match ignored",
+			handler: func(w http.ResponseWriter, r *http.Request, n int32) {
+				etag := `"v1"`
+				if n > 1 {
+					etag = `"v2"`
+				}
+				r.Header.Del("If-Match")
+				serveObject(w, r, testObject, etag)
+			},
+			want: ErrObjectChanged,
+		},
+		{
+			name: "size changed without an ETag",
+			handler: func(w http.ResponseWriter, r *http.Request, n int32) {
+				data := testObject
+				if n > 1 {
+					data = append(bytes.Clone(testObject), "appended"...)
+				}
+				http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+			},
+			want: ErrObjectChanged,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var requests atomic.Int32
+			f, err := openTestFile(t, func(w http.ResponseWriter, r *http.Request) {
+				test.handler(w, r, requests.Add(1))
+			})
+			if !test.openErr {
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = io.ReadAll(f)
+			}
+			if err == nil {
+				t.Fatal("read the object without an error")
+			}
+			if test.want != nil && !errors.Is(err, test.want) {
+				t.Errorf("error = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+// TestParseContentRange は Content-Range の範囲と大きさを読み、壊れた値を拒否することを確かめる
+func TestParseContentRange(t *testing.T) {
+	first, last, size, err := parseContentRange("bytes 100-199/1234")
+	if err != nil || first != 100 || last != 199 || size != 1234 {
+		t.Errorf("parseContentRange() = %d, %d, %d, %v", first, last, size, err)
+	}
+	for _, value := range []string{"", "bytes */1234", "bytes 0-99/*", "bytes 100-50/1234", "bytes 0-1234/1234", "items 0-9/10"} {
+		if _, _, _, err := parseContentRange(value); err == nil || !strings.Contains(err.Error(), "Content-Range") {
+			t.Errorf("parseContentRange(%q) = %v, want an error", value, err)
+		}
+	}
+}
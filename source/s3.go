@@ -0,0 +1,101 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pdtp-workbench/pdtp-go"
+)
+
+// S3Config は S3 互換のストレージの接続先と認証情報
+type S3Config struct {
+	// Endpoint は ストレージの URL (空の場合は https://s3.<Region>.amazonaws.com)
+	Endpoint string
+	// Region は 署名に使うリージョン (空の場合は us-east-1)
+	Region string
+	// Bucket は バケット名
+	Bucket string
+	// Prefix は ファイル名の前に付けるキーの接頭辞 (クライアントが指定できるオブジェクトをこの下に限る)
+	Prefix string
+	// PathStyle は バケットをホスト名ではなくパスに含める (MinIO など)
+	PathStyle bool
+	// AccessKeyID・SecretAccessKey・SessionToken は 署名に使う認証情報 (空の場合は署名しない)
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// HTTPClient は 要求に使うクライアント (nil の場合は http.DefaultClient)
+	HTTPClient *http.Client
+	// BlockSize は 1回の GET で読み込むバイト数 (0 の場合は DefaultBlockSize)
+	BlockSize int64
+	// CacheBlocks は 1つのファイルで保持するブロックの数 (0 の場合は DefaultCacheBlocks)
+	CacheBlocks int
+}
+
+// S3 は S3 互換のストレージのオブジェクトを RangeFile として開く
+type S3 struct {
+	config   S3Config
+	endpoint *url.URL
+}
+
+// NewS3 は config のバケットからオブジェクトを開く S3 を作成する
+func NewS3(config S3Config) (*S3, error) {
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("source: bucket is required")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = "https://s3." + config.Region + ".amazonaws.com"
+	}
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("source: invalid endpoint %q", config.Endpoint)
+	}
+	return &S3{config: config, endpoint: endpoint}, nil
+}
+
+// Open は key のオブジェクトを開く。先頭のブロックを読み込んで大きさを確かめる
+func (s *S3) Open(ctx context.Context, key string) (*RangeFile, error) {
+	objectURL := s.objectURL(key)
+	client := s.config.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	fetch := func(ctx context.Context, start, end int64, etag string) (*http.Response, error) {
+		req, err := newRangeRequest(ctx, objectURL.String(), start, end, etag)
+		if err != nil {
+			return nil, err
+		}
+		if s.config.AccessKeyID != "" {
+			signV4(req, s.config.Region, "s3", s.config.AccessKeyID, s.config.SecretAccessKey, s.config.SessionToken, time.Now())
+		}
+		return client.Do(req)
+	}
+	return openRangeFile(ctx, fetch, s.config.BlockSize, s.config.CacheBlocks)
+}
+
+// OpenPDF は Config.OpenPDF として、ファイル名を Prefix の下のキーとして開く
+// ファイル名の . と .. は取り除き、Prefix の外のオブジェクトを参照させない
+func (s *S3) OpenPDF(ctx context.Context, _ *http.Request, fileName string) (pdtp.IPDFFile, error) {
+	key := strings.TrimPrefix(path.Clean("/"+fileName), "/")
+	if key == "" {
+		return nil, fmt.Errorf("%w: %q", pdtp.ErrInvalidFileName, fileName)
+	}
+	return s.Open(ctx, s.config.Prefix+key)
+}
+
+// objectURL は key のオブジェクトの URL を返す
+func (s *S3) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	if s.config.PathStyle {
+		return u.JoinPath(s.config.Bucket, key)
+	}
+	u.Host = s.config.Bucket + "." + u.Host
+	return u.JoinPath(key)
+}
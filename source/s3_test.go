@@ -0,0 +1,111 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// authorizationPattern は Authorization ヘッダーから署名の範囲・署名するヘッダー・署名を取り出す
+var authorizationPattern = regexp.MustCompile(`^AWS4-HMAC-SHA256 Credential=AKID/(\S+), SignedHeaders=(\S+), Signature=([0-9a-f]{64})$`)
+
+// TestS3 は 空白を含むキーのオブジェクトを、署名した範囲の GET で読み込めることを確かめる
+// サーバーは受け取った要求のパス・クエリー・ヘッダーから署名を計算し直して比べる
+func TestS3(t *testing.T) {
+	pdf, err := os.ReadFile("../example/example.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu     sync.Mutex
+		ranges []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/documents/reports/annual report.pdf" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := checkSignature(r, "secret"); err != "" {
+			t.Errorf("%s %s: %s", r.Method, r.URL, err)
+			http.Error(w, err, http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(pdf))
+	}))
+	defer server.Close()
+
+	bucket, err := NewS3(S3Config{
+		Endpoint:        server.URL,
+		Bucket:          "documents",
+		Prefix:          "reports/",
+		PathStyle:       true,
+		AccessKeyID:     "AKID",
+		SecretAccessKey: "secret",
+		BlockSize:       4096,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := bucket.OpenPDF(context.Background(), nil, "annual report.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	got, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, pdf) {
+		t.Fatalf("read %d bytes that differ from the %d bytes of the object", len(got), len(pdf))
+	}
+	if want := (len(pdf) + 4095) / 4096; len(ranges) != want {
+		t.Errorf("%d GETs, want one per 4096-byte block (%d)", len(ranges), want)
+	}
+	for i, r := range ranges {
+		if !strings.HasPrefix(r, "bytes=") {
+			t.Errorf("GET %d has Range %q", i, r)
+		}
+	}
+
+	if _, err := bucket.OpenPDF(context.Background(), nil, "../secret.pdf"); err == nil {
+		t.Error("opened a key outside the prefix")
+	}
+}
+
+// checkSignature は 要求の Authorization ヘッダーの署名が、受け取った要求から計算した署名と一致するかを確かめる
+// 一致しない場合は理由を返す
+func checkSignature(r *http.Request, secretAccessKey string) string {
+	match := authorizationPattern.FindStringSubmatch(r.Header.Get("Authorization"))
+	if match == nil {
+		return "unexpected Authorization " + r.Header.Get("Authorization")
+	}
+	scope, signedHeaders, signature := match[1], match[2], match[3]
+	for _, name := range []string{"host", "range", "x-amz-content-sha256", "x-amz-date"} {
+		if !strings.Contains(";"+signedHeaders+";", ";"+name+";") {
+			return "SignedHeaders " + signedHeaders + " does not include " + name
+		}
+	}
+	// 受け取ったパスとクエリーを送られたままの形で使う
+	received, err := http.NewRequest(r.Method, "http://"+r.Host+r.URL.RequestURI(), nil)
+	if err != nil {
+		return err.Error()
+	}
+	received.Header = r.Header.Clone()
+	received.Header.Del("Authorization")
+	if _, want := signatureV4(received, scope, secretAccessKey); want != signature {
+		return "signature " + signature + " does not match " + want
+	}
+	return ""
+}
@@ -0,0 +1,130 @@
+package source
+
+import (
+	"cmp"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// emptyPayloadHash は 本文のない要求の SHA-256
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// signV4 は 本文のない要求に AWS Signature Version 4 の Authorization ヘッダーを付ける
+// 署名するヘッダーは host・range・if-match・x-amz-* のみ
+func signV4(req *http.Request, region, service, accessKeyID, secretAccessKey, sessionToken string, now time.Time) {
+	now = now.UTC()
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+	if sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", sessionToken)
+	}
+	scope := now.Format("20060102") + "/" + region + "/" + service + "/aws4_request"
+	signedHeaders, signature := signatureV4(req, scope, secretAccessKey)
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKeyID+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// signatureV4 は X-Amz-Date を付けた要求の署名するヘッダーの名前と署名を求める
+// scope は 日付/リージョン/サービス/aws4_request。送るパスとクエリーは署名したものに置き換える
+func signatureV4(req *http.Request, scope, secretAccessKey string) (signedHeaders, signature string) {
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "range" || name == "if-match" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders = strings.Join(names, ";")
+
+	// 送るパス・クエリーと署名するものを RFC 3986 の規則でそろえる
+	canonicalPath := escapePath(req.URL.Path)
+	req.URL.RawPath = canonicalPath
+	canonicalQuery := escapeQuery(req.URL.Query())
+	req.URL.RawQuery = canonicalQuery
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalPath,
+		canonicalQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+	amzDate := req.Header.Get("X-Amz-Date")
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256(canonicalRequest)
+
+	key := []byte("AWS4" + secretAccessKey)
+	for _, part := range strings.Split(scope, "/") {
+		key = hmacSHA256(key, part)
+	}
+	return signedHeaders, hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// escapePath は パスの / と非予約文字 (英数字と -._~) 以外をパーセントエンコードする
+func escapePath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	return escapeRFC3986(p, "/")
+}
+
+// escapeQuery は クエリーを名前・値の順に並べ、非予約文字以外をパーセントエンコードする
+// url.Values.Encode と違い、空白は + ではなく %20 にする
+func escapeQuery(values url.Values) string {
+	var pairs [][2]string
+	for name, vs := range values {
+		for _, v := range vs {
+			pairs = append(pairs, [2]string{escapeRFC3986(name, ""), escapeRFC3986(v, "")})
+		}
+	}
+	// 名前が他の名前の先頭と一致する場合も名前の順になるよう、= でつなぐ前に並べる
+	slices.SortFunc(pairs, func(a, b [2]string) int {
+		return cmp.Or(strings.Compare(a[0], b[0]), strings.Compare(a[1], b[1]))
+	})
+	var b strings.Builder
+	for i, pair := range pairs {
+		if i > 0 {
+			b.WriteByte('&')
+		}
+		b.WriteString(pair[0] + "=" + pair[1])
+	}
+	return b.String()
+}
+
+// escapeRFC3986 は 非予約文字 (英数字と -._~) と keep に含まれる文字以外をパーセントエンコードする
+func escapeRFC3986(s, keep string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '-' || c == '.' || c == '_' || c == '~' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || strings.IndexByte(keep, c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{c})))
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexSHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,12 +2,14 @@ package pdtp
 
 import (
 	"net/http"
+	"sync"
 
 	"github.com/klauspost/compress/zstd"
 )
 
 type ZstdFlusherWriter struct {
-	zw *zstd.Encoder
+	zw   *zstd.Encoder
+	pool *sync.Pool
 }
 
 func (z *ZstdFlusherWriter) Write(p []byte) (int, error) {
@@ -18,20 +20,64 @@ func (z *ZstdFlusherWriter) Flush() error {
 	return z.zw.Flush()
 }
 
+// Close は ストリームの終端を書き込み、エンコーダーをプールに戻す
 func (z *ZstdFlusherWriter) Close() error {
-	return z.zw.Close()
+	if z.zw == nil {
+		return nil
+	}
+	err := z.zw.Close()
+	if z.pool != nil {
+		// 次の要求で Reset してから使う
+		z.zw.Reset(nil)
+		z.pool.Put(z.zw)
+	}
+	z.zw = nil
+	return err
 }
+
 func (z ZstdCompression) Writer(w http.ResponseWriter) (FlusherWriter, error) {
-	w.Header().Set("Content-Encoding", "zstd")
-	zw, err := zstd.NewWriter(w)
-	if err != nil {
-		return nil, err
+	pool := zstdEncoderPool(z.concurrency())
+	zw, ok := pool.Get().(*zstd.Encoder)
+	if !ok {
+		var err error
+		zw, err = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(z.concurrency()))
+		if err != nil {
+			return nil, err
+		}
 	}
-	return &ZstdFlusherWriter{zw: zw}, nil
+	w.Header().Set("Content-Encoding", "zstd")
+	zw.Reset(w)
+	return &ZstdFlusherWriter{zw: zw, pool: pool}, nil
 }
 
-type ZstdCompression struct{}
+// ZstdCompression は zstd で圧縮する。エンコーダーは内部のバッファが大きいため、要求をまたいでプールして使い回す
+type ZstdCompression struct {
+	// Concurrency は 1つのエンコーダーが並行して圧縮するブロックの数 (0 の場合は 1)
+	// 多くの要求を並行して処理するサーバーでは 1 の方がメモリを使わない
+	Concurrency int
+}
 
 func (z ZstdCompression) Name() string {
 	return "zstd"
 }
+
+func (z ZstdCompression) concurrency() int {
+	return max(z.Concurrency, 1)
+}
+
+var (
+	zstdPoolsMu sync.Mutex
+	zstdPools   = make(map[int]*sync.Pool)
+)
+
+// zstdEncoderPool は 並行数ごとのエンコーダーのプールを返す (オプションの異なるエンコーダーを混ぜない)
+func zstdEncoderPool(concurrency int) *sync.Pool {
+	zstdPoolsMu.Lock()
+	defer zstdPoolsMu.Unlock()
+	pool, ok := zstdPools[concurrency]
+	if !ok {
+		pool = &sync.Pool{}
+		zstdPools[concurrency] = pool
+	}
+	return pool
+}